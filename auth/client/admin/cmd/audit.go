@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"time"
+
+	"github.com/open-cloud-initiative/glue/auth/internal/controllers/admin"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	AuditCmd.AddCommand(ListAuditCmd)
+	AuditCmd.AddCommand(ExportAuditCmd)
+
+	for _, c := range []*cobra.Command{ListAuditCmd, ExportAuditCmd} {
+		c.Flags().StringVar(&auditCmdConfig.Action, "action", "", "Only events of the action, e.g. user.banned")
+		c.Flags().StringVar(&auditCmdConfig.ActorID, "actor", "", "Only events made by the user ID")
+		c.Flags().StringVar(&auditCmdConfig.UserID, "user", "", "Only events that concern the user ID")
+		c.Flags().StringVar(&auditCmdConfig.Since, "since", "", "Only events at or after the time, RFC 3339 or a duration ago, e.g. 24h")
+		c.Flags().StringVar(&auditCmdConfig.Until, "until", "", "Only events before the time, RFC 3339 or a duration ago, e.g. 1h")
+		c.Flags().Int64Var(&auditCmdConfig.After, "after", 0, "Only events after the event ID")
+	}
+
+	ListAuditCmd.Flags().IntVarP(&auditCmdConfig.Limit, "limit", "l", 0, "Number of events, defaults to 100")
+	ExportAuditCmd.Flags().IntVarP(&auditCmdConfig.Limit, "limit", "l", 0, "Maximum number of events, exports all events if 0")
+	ExportAuditCmd.Flags().StringVarP(&auditCmdConfig.Output, "output", "o", "", "File to write the events to, defaults to stdout")
+}
+
+type AuditCmdConfig struct {
+	Action  string
+	ActorID string
+	UserID  string
+	Since   string
+	Until   string
+	After   int64
+	Limit   int
+	Output  string
+}
+
+var auditCmdConfig = &AuditCmdConfig{}
+
+var AuditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Query the audit log of the authentication service",
+	Long:  `This command allows administrators to query and export the audit log of security-relevant events.`,
+}
+
+var ListAuditCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List audit events",
+	Long:  `List a page of audit events. Pass the returned next value as --after to get the next page.`,
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		req, err := auditRequest()
+		if err != nil {
+			return err
+		}
+
+		conn, err := dial()
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		res, err := admin.NewClient(conn).ListAuditEvents(withToken(cmd.Context()), req)
+		if err != nil {
+			return err
+		}
+
+		return printJSON(res)
+	},
+}
+
+var ExportAuditCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export audit events as JSON Lines",
+	Long:  `Export all audit events matching the filters as JSON Lines, one event per line in the order they were written.`,
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		req, err := auditRequest()
+		if err != nil {
+			return err
+		}
+
+		out := io.Writer(os.Stdout)
+		if auditCmdConfig.Output != "" {
+			f, err := os.Create(auditCmdConfig.Output)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			out = f
+		}
+
+		conn, err := dial()
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		events, err := admin.NewClient(conn).ExportAuditEvents(withToken(cmd.Context()), req)
+		if err != nil {
+			return err
+		}
+
+		enc := json.NewEncoder(out)
+
+		for {
+			event, err := events.Recv()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+
+			if err != nil {
+				return err
+			}
+
+			if err := enc.Encode(event); err != nil {
+				return err
+			}
+		}
+	},
+}
+
+func auditRequest() (*admin.ListAuditEventsRequest, error) {
+	req := &admin.ListAuditEventsRequest{
+		Action:  auditCmdConfig.Action,
+		ActorID: auditCmdConfig.ActorID,
+		UserID:  auditCmdConfig.UserID,
+		After:   auditCmdConfig.After,
+		Limit:   auditCmdConfig.Limit,
+	}
+
+	var err error
+	if req.Since, err = parseTime(auditCmdConfig.Since); err != nil {
+		return nil, err
+	}
+
+	if req.Until, err = parseTime(auditCmdConfig.Until); err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+// parseTime parses an RFC 3339 time or a duration before now.
+func parseTime(s string) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	if d, err := time.ParseDuration(s); err == nil {
+		t := time.Now().Add(-d)
+		return &t, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return nil, errors.New("time must be RFC 3339, e.g. 2024-01-02T15:04:05Z, or a duration, e.g. 24h")
+	}
+
+	return &t, nil
+}
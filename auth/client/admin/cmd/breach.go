@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"github.com/open-cloud-initiative/glue/auth/internal/controllers/admin"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	BreachCmd.AddCommand(RefreshBreachCmd)
+}
+
+var BreachCmd = &cobra.Command{
+	Use:   "breach",
+	Short: "Manage the breached password corpus",
+	Long:  `This command allows administrators to manage the corpus of breached passwords that new passwords are checked against.`,
+}
+
+var RefreshBreachCmd = &cobra.Command{
+	Use:   "refresh",
+	Short: "Reload the breached password corpus",
+	Long:  `Reload the breached password corpus from disk, e.g. after the files were updated. Only the server that serves the request reloads.`,
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		conn, err := dial()
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		corpus, err := admin.NewClient(conn).RefreshBreachCorpus(withToken(cmd.Context()), &admin.RefreshBreachCorpusRequest{})
+		if err != nil {
+			return err
+		}
+
+		return printJSON(corpus)
+	},
+}
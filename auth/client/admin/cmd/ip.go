@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"github.com/open-cloud-initiative/glue/auth/internal/controllers/admin"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	IPCmd.AddCommand(UnlockIPCmd)
+}
+
+var IPCmd = &cobra.Command{
+	Use:   "ip",
+	Short: "Manage client IPs in the authentication service",
+	Long:  `This command allows administrators to manage client IPs that are locked out after failed logins.`,
+}
+
+var UnlockIPCmd = &cobra.Command{
+	Use:   "unlock <ip>",
+	Short: "Reset the failed login attempts of a client IP",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		conn, err := dial()
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		res, err := admin.NewClient(conn).UnlockIP(withToken(cmd.Context()), &admin.UnlockIPRequest{IP: args[0]})
+		if err != nil {
+			return err
+		}
+
+		return printJSON(res)
+	},
+}
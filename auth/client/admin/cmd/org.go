@@ -0,0 +1,259 @@
+package cmd
+
+import (
+	"github.com/open-cloud-initiative/glue/auth/internal/controllers/admin"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	OrgCmd.AddCommand(ListOrgsCmd)
+	OrgCmd.AddCommand(GetOrgCmd)
+	OrgCmd.AddCommand(CreateOrgCmd)
+	OrgCmd.AddCommand(UpdateOrgCmd)
+	OrgCmd.AddCommand(DeleteOrgCmd)
+	OrgCmd.AddCommand(ListMembersCmd)
+	OrgCmd.AddCommand(AddMemberCmd)
+	OrgCmd.AddCommand(UpdateMemberCmd)
+	OrgCmd.AddCommand(RemoveMemberCmd)
+	OrgCmd.AddCommand(InviteMemberCmd)
+
+	for _, c := range []*cobra.Command{CreateOrgCmd, UpdateOrgCmd} {
+		c.Flags().StringVarP(&orgCmdConfig.Name, "name", "n", "", "Display name of the organization")
+		c.Flags().StringSliceVarP(&orgCmdConfig.Providers, "provider", "p", nil, "Allowed login method, e.g. a provider ID or password (repeatable, all if omitted)")
+		c.Flags().BoolVar(&orgCmdConfig.RequireMFA, "require-mfa", false, "Require a verified second factor")
+		c.Flags().StringSliceVar(&orgCmdConfig.Domains, "domain", nil, "Captured email domain (repeatable, update keeps the domains if omitted)")
+	}
+}
+
+type OrgCmdConfig struct {
+	Name       string
+	Providers  []string
+	RequireMFA bool
+	Domains    []string
+}
+
+var orgCmdConfig = &OrgCmdConfig{}
+
+var OrgCmd = &cobra.Command{
+	Use:   "org",
+	Short: "Manage organizations and their members",
+	Long:  `This command allows administrators to manage organizations, their login policies, captured domains and members.`,
+}
+
+var ListOrgsCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List all organizations",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		conn, err := dial()
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		res, err := admin.NewClient(conn).ListOrganizations(withToken(cmd.Context()), &admin.ListOrganizationsRequest{})
+		if err != nil {
+			return err
+		}
+
+		return printJSON(res)
+	},
+}
+
+var GetOrgCmd = &cobra.Command{
+	Use:   "get SLUG",
+	Short: "Show an organization",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		conn, err := dial()
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		res, err := admin.NewClient(conn).GetOrganization(withToken(cmd.Context()), &admin.GetOrganizationRequest{Slug: args[0]})
+		if err != nil {
+			return err
+		}
+
+		return printJSON(res)
+	},
+}
+
+var CreateOrgCmd = &cobra.Command{
+	Use:   "create SLUG",
+	Short: "Create an organization",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		conn, err := dial()
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		res, err := admin.NewClient(conn).CreateOrganization(withToken(cmd.Context()), &admin.CreateOrganizationRequest{
+			Slug:             args[0],
+			Name:             orgCmdConfig.Name,
+			AllowedProviders: orgCmdConfig.Providers,
+			RequireMFA:       orgCmdConfig.RequireMFA,
+			Domains:          orgCmdConfig.Domains,
+		})
+		if err != nil {
+			return err
+		}
+
+		return printJSON(res)
+	},
+}
+
+var UpdateOrgCmd = &cobra.Command{
+	Use:   "update SLUG",
+	Short: "Replace the name and login policy of an organization",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		conn, err := dial()
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		req := &admin.UpdateOrganizationRequest{
+			Slug:             args[0],
+			Name:             orgCmdConfig.Name,
+			AllowedProviders: orgCmdConfig.Providers,
+			RequireMFA:       orgCmdConfig.RequireMFA,
+		}
+
+		if cmd.Flags().Changed("domain") {
+			req.Domains = append([]string{}, orgCmdConfig.Domains...)
+		}
+
+		res, err := admin.NewClient(conn).UpdateOrganization(withToken(cmd.Context()), req)
+		if err != nil {
+			return err
+		}
+
+		return printJSON(res)
+	},
+}
+
+var DeleteOrgCmd = &cobra.Command{
+	Use:   "delete SLUG",
+	Short: "Delete an organization and its memberships",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		conn, err := dial()
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		res, err := admin.NewClient(conn).DeleteOrganization(withToken(cmd.Context()), &admin.DeleteOrganizationRequest{Slug: args[0]})
+		if err != nil {
+			return err
+		}
+
+		return printJSON(res)
+	},
+}
+
+var ListMembersCmd = &cobra.Command{
+	Use:   "members SLUG",
+	Short: "List the members of an organization",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		conn, err := dial()
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		res, err := admin.NewClient(conn).ListMembers(withToken(cmd.Context()), &admin.ListMembersRequest{Slug: args[0]})
+		if err != nil {
+			return err
+		}
+
+		return printJSON(res)
+	},
+}
+
+var AddMemberCmd = &cobra.Command{
+	Use:   "add SLUG USER_ID ROLE",
+	Short: "Add a user to an organization with a role, e.g. org-admin",
+	Args:  cobra.ExactArgs(3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		conn, err := dial()
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		res, err := admin.NewClient(conn).AddMember(withToken(cmd.Context()), &admin.AddMemberRequest{Slug: args[0], UserID: args[1], Role: args[2]})
+		if err != nil {
+			return err
+		}
+
+		return printJSON(res)
+	},
+}
+
+var UpdateMemberCmd = &cobra.Command{
+	Use:   "set-role SLUG USER_ID ROLE",
+	Short: "Change the role of a member",
+	Args:  cobra.ExactArgs(3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		conn, err := dial()
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		res, err := admin.NewClient(conn).UpdateMember(withToken(cmd.Context()), &admin.UpdateMemberRequest{Slug: args[0], UserID: args[1], Role: args[2]})
+		if err != nil {
+			return err
+		}
+
+		return printJSON(res)
+	},
+}
+
+var RemoveMemberCmd = &cobra.Command{
+	Use:   "remove SLUG USER_ID",
+	Short: "Remove a member from an organization",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		conn, err := dial()
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		res, err := admin.NewClient(conn).RemoveMember(withToken(cmd.Context()), &admin.RemoveMemberRequest{Slug: args[0], UserID: args[1]})
+		if err != nil {
+			return err
+		}
+
+		return printJSON(res)
+	},
+}
+
+var InviteMemberCmd = &cobra.Command{
+	Use:   "invite SLUG EMAIL ROLE",
+	Short: "Send an invitation to join an organization with a role by email",
+	Args:  cobra.ExactArgs(3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		conn, err := dial()
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		res, err := admin.NewClient(conn).InviteMember(withToken(cmd.Context()), &admin.InviteMemberRequest{Slug: args[0], Email: args[1], Role: args[2]})
+		if err != nil {
+			return err
+		}
+
+		return printJSON(res)
+	},
+}
@@ -0,0 +1,225 @@
+package cmd
+
+import (
+	"github.com/open-cloud-initiative/glue/auth/internal/controllers/admin"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	RoleCmd.AddCommand(ListPermissionsCmd)
+	RoleCmd.AddCommand(ListRolesCmd)
+	RoleCmd.AddCommand(CreateRoleCmd)
+	RoleCmd.AddCommand(UpdateRoleCmd)
+	RoleCmd.AddCommand(DeleteRoleCmd)
+	RoleCmd.AddCommand(UserRolesCmd)
+	RoleCmd.AddCommand(AssignRoleCmd)
+	RoleCmd.AddCommand(UnassignRoleCmd)
+	RoleCmd.AddCommand(CheckPermissionCmd)
+
+	for _, c := range []*cobra.Command{CreateRoleCmd, UpdateRoleCmd} {
+		c.Flags().StringVarP(&roleCmdConfig.Description, "description", "d", "", "Description of the role")
+		c.Flags().StringSliceVarP(&roleCmdConfig.Permissions, "permission", "p", nil, "Permission granted by the role, e.g. users:read (repeatable)")
+	}
+}
+
+type RoleCmdConfig struct {
+	Description string
+	Permissions []string
+}
+
+var roleCmdConfig = &RoleCmdConfig{}
+
+var RoleCmd = &cobra.Command{
+	Use:   "role",
+	Short: "Manage roles and their assignment to users",
+	Long:  `This command allows administrators to manage roles, the permissions they grant and the roles of users.`,
+}
+
+var ListPermissionsCmd = &cobra.Command{
+	Use:   "permissions",
+	Short: "List all permissions",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		conn, err := dial()
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		res, err := admin.NewClient(conn).ListPermissions(withToken(cmd.Context()), &admin.ListPermissionsRequest{})
+		if err != nil {
+			return err
+		}
+
+		return printJSON(res)
+	},
+}
+
+var ListRolesCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List all roles",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		conn, err := dial()
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		res, err := admin.NewClient(conn).ListRoles(withToken(cmd.Context()), &admin.ListRolesRequest{})
+		if err != nil {
+			return err
+		}
+
+		return printJSON(res)
+	},
+}
+
+var CreateRoleCmd = &cobra.Command{
+	Use:   "create NAME",
+	Short: "Create a role",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		conn, err := dial()
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		res, err := admin.NewClient(conn).CreateRole(withToken(cmd.Context()), &admin.CreateRoleRequest{
+			Name:        args[0],
+			Description: roleCmdConfig.Description,
+			Permissions: roleCmdConfig.Permissions,
+		})
+		if err != nil {
+			return err
+		}
+
+		return printJSON(res)
+	},
+}
+
+var UpdateRoleCmd = &cobra.Command{
+	Use:   "update NAME",
+	Short: "Replace the description and permissions of a role",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		conn, err := dial()
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		res, err := admin.NewClient(conn).UpdateRole(withToken(cmd.Context()), &admin.UpdateRoleRequest{
+			Name:        args[0],
+			Description: roleCmdConfig.Description,
+			Permissions: roleCmdConfig.Permissions,
+		})
+		if err != nil {
+			return err
+		}
+
+		return printJSON(res)
+	},
+}
+
+var DeleteRoleCmd = &cobra.Command{
+	Use:   "delete NAME",
+	Short: "Delete a role and remove it from all users",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		conn, err := dial()
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		res, err := admin.NewClient(conn).DeleteRole(withToken(cmd.Context()), &admin.DeleteRoleRequest{Name: args[0]})
+		if err != nil {
+			return err
+		}
+
+		return printJSON(res)
+	},
+}
+
+var UserRolesCmd = &cobra.Command{
+	Use:   "user USER_ID",
+	Short: "List the roles and permissions of a user",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		conn, err := dial()
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		res, err := admin.NewClient(conn).ListUserRoles(withToken(cmd.Context()), &admin.ListUserRolesRequest{UserID: args[0]})
+		if err != nil {
+			return err
+		}
+
+		return printJSON(res)
+	},
+}
+
+var AssignRoleCmd = &cobra.Command{
+	Use:   "assign USER_ID ROLE",
+	Short: "Assign a role to a user",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		conn, err := dial()
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		res, err := admin.NewClient(conn).AssignRole(withToken(cmd.Context()), &admin.AssignRoleRequest{UserID: args[0], Role: args[1]})
+		if err != nil {
+			return err
+		}
+
+		return printJSON(res)
+	},
+}
+
+var UnassignRoleCmd = &cobra.Command{
+	Use:   "unassign USER_ID ROLE",
+	Short: "Remove a role from a user",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		conn, err := dial()
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		res, err := admin.NewClient(conn).UnassignRole(withToken(cmd.Context()), &admin.UnassignRoleRequest{UserID: args[0], Role: args[1]})
+		if err != nil {
+			return err
+		}
+
+		return printJSON(res)
+	},
+}
+
+var CheckPermissionCmd = &cobra.Command{
+	Use:   "check USER_ID PERMISSION",
+	Short: "Check if a user has a permission",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		conn, err := dial()
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		res, err := admin.NewClient(conn).CheckPermission(withToken(cmd.Context()), &admin.CheckPermissionRequest{UserID: args[0], Permission: args[1]})
+		if err != nil {
+			return err
+		}
+
+		return printJSON(res)
+	},
+}
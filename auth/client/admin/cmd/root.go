@@ -1,14 +1,35 @@
 package cmd
 
-import "github.com/spf13/cobra"
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"os"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+)
 
 func init() {
 	RootCmd.AddCommand(UserCmd)
-	RootCmd.PersistentFlags().StringVarP(&adminCmdConfig.Server, "server", "s", "http://localhost:8080", "Address of the authentication server")
+	RootCmd.AddCommand(BreachCmd)
+	RootCmd.AddCommand(IPCmd)
+	RootCmd.AddCommand(AuditCmd)
+	RootCmd.AddCommand(WebhookCmd)
+	RootCmd.AddCommand(RoleCmd)
+	RootCmd.AddCommand(OrgCmd)
+	RootCmd.PersistentFlags().StringVarP(&adminCmdConfig.Server, "server", "s", "localhost:4041", "Address of the admin service of the authentication server")
+	RootCmd.PersistentFlags().StringVarP(&adminCmdConfig.Token, "token", "t", os.Getenv("GLUE_ADMIN_TOKEN"), "Internal token, or session token of a user with the required permissions, defaults to $GLUE_ADMIN_TOKEN")
+	RootCmd.PersistentFlags().BoolVar(&adminCmdConfig.Plaintext, "plaintext", false, "Connect without TLS")
 }
 
 type AdminCmdConfig struct {
-	Server string
+	Server    string
+	Token     string
+	Plaintext bool
 }
 
 var adminCmdConfig = &AdminCmdConfig{}
@@ -21,3 +42,25 @@ var RootCmd = &cobra.Command{
 		// Placeholder for admin client functionality
 	},
 }
+
+// dial connects to the admin service.
+func dial() (*grpc.ClientConn, error) {
+	creds := credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12})
+	if adminCmdConfig.Plaintext {
+		creds = insecure.NewCredentials()
+	}
+
+	return grpc.NewClient(adminCmdConfig.Server, grpc.WithTransportCredentials(creds))
+}
+
+// withToken adds the admin token to the outgoing metadata.
+func withToken(ctx context.Context) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+adminCmdConfig.Token)
+}
+
+func printJSON(v any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(v)
+}
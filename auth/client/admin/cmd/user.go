@@ -1,13 +1,19 @@
 package cmd
 
 import (
+	"github.com/open-cloud-initiative/glue/auth/internal/controllers/admin"
+
 	"github.com/spf13/cobra"
-	"google.golang.org/grpc"
 )
 
 func init() {
 	UserCmd.AddCommand(GetUserCmd)
-	UserCmd.Flags().StringVarP(&getUserCmdConfig.Username, "username", "u", "", "Username of the user to manage")
+	UserCmd.AddCommand(BanUserCmd)
+	UserCmd.AddCommand(UnbanUserCmd)
+	UserCmd.AddCommand(UnlockUserCmd)
+	BanUserCmd.Flags().StringVarP(&banUserCmdConfig.Duration, "duration", "d", "", "Duration of the ban, e.g. 72h, bans indefinitely if empty")
+	BanUserCmd.Flags().StringVarP(&banUserCmdConfig.Reason, "reason", "r", "", "Reason of the ban, it is recorded with the ban")
+	_ = BanUserCmd.MarkFlagRequired("reason")
 }
 
 var UserCmd = &cobra.Command{
@@ -16,23 +22,96 @@ var UserCmd = &cobra.Command{
 	Long:  `This command allows administrators to manage users in the authentication service.`,
 }
 
-type GetUserCmdConfig struct {
-	Username string
+var GetUserCmd = &cobra.Command{
+	Use:   "get <user-id>",
+	Short: "Get user details",
+	Long:  `Retrieve details of a specific user by ID.`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		conn, err := dial()
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		user, err := admin.NewClient(conn).GetUser(withToken(cmd.Context()), &admin.GetUserRequest{UserID: args[0]})
+		if err != nil {
+			return err
+		}
+
+		return printJSON(user)
+	},
 }
 
-var getUserCmdConfig = &GetUserCmdConfig{}
+type BanUserCmdConfig struct {
+	Duration string
+	Reason   string
+}
 
-var GetUserCmd = &cobra.Command{
-	Use:   "get",
-	Short: "Get user details",
-	Long:  `Retrieve details of a specific user by username.`,
+var banUserCmdConfig = &BanUserCmdConfig{}
+
+var BanUserCmd = &cobra.Command{
+	Use:   "ban <user-id>",
+	Short: "Ban a user",
+	Long:  `Ban a user for a duration or indefinitely. The sessions and provider tokens of the user are revoked.`,
 	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		opts := []grpc.DialOption{}
+	RunE: func(cmd *cobra.Command, args []string) error {
+		conn, err := dial()
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
 
-		conn, err := grpc.NewClient(adminCmdConfig.Server, opts...)
+		user, err := admin.NewClient(conn).BanUser(withToken(cmd.Context()), &admin.BanUserRequest{
+			UserID:   args[0],
+			Duration: banUserCmdConfig.Duration,
+			Reason:   banUserCmdConfig.Reason,
+		})
 		if err != nil {
+			return err
+		}
+
+		return printJSON(user)
+	},
+}
+
+var UnbanUserCmd = &cobra.Command{
+	Use:   "unban <user-id>",
+	Short: "Lift the ban of a user",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		conn, err := dial()
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		user, err := admin.NewClient(conn).UnbanUser(withToken(cmd.Context()), &admin.UnbanUserRequest{UserID: args[0]})
+		if err != nil {
+			return err
+		}
+
+		return printJSON(user)
+	},
+}
+
+var UnlockUserCmd = &cobra.Command{
+	Use:   "unlock <user-id>",
+	Short: "Reset the failed login attempts of a user",
+	Long:  `Reset the failed login attempts of a user, so that a user locked out after failed logins can log in again.`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		conn, err := dial()
+		if err != nil {
+			return err
 		}
 		defer conn.Close()
+
+		user, err := admin.NewClient(conn).UnlockUser(withToken(cmd.Context()), &admin.UnlockUserRequest{UserID: args[0]})
+		if err != nil {
+			return err
+		}
+
+		return printJSON(user)
 	},
 }
@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"strconv"
+
+	"github.com/open-cloud-initiative/glue/auth/internal/controllers/admin"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	WebhookCmd.AddCommand(ListDeliveriesCmd)
+	WebhookCmd.AddCommand(ReplayEventCmd)
+	WebhookCmd.AddCommand(RetryDeadCmd)
+
+	ListDeliveriesCmd.Flags().Int64Var(&webhookCmdConfig.EventID, "event", 0, "Only deliveries of the event ID")
+	ListDeliveriesCmd.Flags().StringVar(&webhookCmdConfig.Status, "status", "", "Only deliveries in the state: pending, delivered or dead")
+	ListDeliveriesCmd.Flags().Int64Var(&webhookCmdConfig.After, "after", 0, "Only deliveries after the delivery ID")
+	ListDeliveriesCmd.Flags().IntVarP(&webhookCmdConfig.Limit, "limit", "l", 0, "Number of deliveries, defaults to 100")
+
+	for _, c := range []*cobra.Command{ListDeliveriesCmd, ReplayEventCmd, RetryDeadCmd} {
+		c.Flags().StringVar(&webhookCmdConfig.Endpoint, "endpoint", "", "Only the endpoint with the ID")
+	}
+}
+
+type WebhookCmdConfig struct {
+	EventID  int64
+	Endpoint string
+	Status   string
+	After    int64
+	Limit    int
+}
+
+var webhookCmdConfig = &WebhookCmdConfig{}
+
+var WebhookCmd = &cobra.Command{
+	Use:   "webhook",
+	Short: "Inspect and replay webhook deliveries",
+	Long:  `This command allows administrators to inspect the deliveries of user lifecycle events to webhooks and to deliver events again.`,
+}
+
+var ListDeliveriesCmd = &cobra.Command{
+	Use:   "deliveries",
+	Short: "List webhook deliveries",
+	Long:  `List a page of webhook deliveries. Pass the returned next value as --after to get the next page.`,
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		conn, err := dial()
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		res, err := admin.NewClient(conn).ListWebhookDeliveries(withToken(cmd.Context()), &admin.ListWebhookDeliveriesRequest{
+			EventID:  webhookCmdConfig.EventID,
+			Endpoint: webhookCmdConfig.Endpoint,
+			Status:   webhookCmdConfig.Status,
+			After:    webhookCmdConfig.After,
+			Limit:    webhookCmdConfig.Limit,
+		})
+		if err != nil {
+			return err
+		}
+
+		return printJSON(res)
+	},
+}
+
+var ReplayEventCmd = &cobra.Command{
+	Use:   "replay EVENT_ID",
+	Short: "Deliver an event again",
+	Long:  `Deliver an event again, to the endpoint or to all endpoints subscribed to it.`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			return err
+		}
+
+		conn, err := dial()
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		res, err := admin.NewClient(conn).ReplayWebhookEvent(withToken(cmd.Context()), &admin.ReplayWebhookEventRequest{
+			EventID:  id,
+			Endpoint: webhookCmdConfig.Endpoint,
+		})
+		if err != nil {
+			return err
+		}
+
+		return printJSON(res)
+	},
+}
+
+var RetryDeadCmd = &cobra.Command{
+	Use:   "retry-dead",
+	Short: "Retry the dead deliveries",
+	Long:  `Queue the deliveries that failed too often again, e.g. after an endpoint recovered from an outage.`,
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		conn, err := dial()
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		res, err := admin.NewClient(conn).RetryDeadWebhookDeliveries(withToken(cmd.Context()), &admin.RetryDeadWebhookDeliveriesRequest{
+			Endpoint: webhookCmdConfig.Endpoint,
+		})
+		if err != nil {
+			return err
+		}
+
+		return printJSON(res)
+	},
+}
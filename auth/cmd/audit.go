@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+
+	"github.com/open-cloud-initiative/glue/auth/internal/audit"
+
+	"github.com/spf13/cobra"
+)
+
+var auditPublicKeys []string
+
+func init() {
+	AuditVerify.Flags().StringSliceVar(&auditPublicKeys, "public-key", nil, "base64 encoded Ed25519 public key of checkpoints, e.g. of a rotated signing key (repeatable)")
+
+	Audit.AddCommand(AuditVerify)
+}
+
+var Audit = &cobra.Command{
+	Use:   "audit",
+	Short: "Manage the audit log",
+}
+
+var AuditVerify = &cobra.Command{
+	Use:   "verify",
+	Short: "Verify the hash chain and checkpoints of the audit log",
+	Long: `Walks the chain of audit events and reports the first event or checkpoint
+that does not match. Signatures of checkpoints are checked with the configured
+signing key and the --public-key keys. Without keys, only the hashes are checked.`,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		ctx := cmd.Context()
+
+		if err := cfg.LoadConfigFile(); err != nil {
+			return err
+		}
+
+		keys := []ed25519.PublicKey{}
+
+		for _, k := range auditPublicKeys {
+			key, err := audit.ParsePublicKey(k)
+			if err != nil {
+				return err
+			}
+
+			keys = append(keys, key)
+		}
+
+		if !cfg.File.Audit.SigningKey.IsZero() {
+			key, err := auditSigningKey(ctx)
+			if err != nil {
+				return err
+			}
+
+			keys = append(keys, key.Public().(ed25519.PublicKey))
+		}
+
+		conn, err := openDB(ctx)
+		if err != nil {
+			return err
+		}
+
+		store, err := newStore(conn)
+		if err != nil {
+			return err
+		}
+
+		report, err := audit.NewLog(store).Verify(ctx, keys...)
+
+		fmt.Fprintf(cfg.Stdout, "events: %d (%d before the chain)\n", report.Events, report.Unchained)
+		fmt.Fprintf(cfg.Stdout, "checkpoints: %d\n", report.Checkpoints)
+
+		var broken *audit.BrokenLinkError
+		if errors.As(err, &broken) {
+			fmt.Fprintf(cfg.Stdout, "first broken link: event %d", broken.EventID)
+			if broken.CheckpointID != 0 {
+				fmt.Fprintf(cfg.Stdout, ", checkpoint %d", broken.CheckpointID)
+			}
+			fmt.Fprintf(cfg.Stdout, ": %s\n", broken.Reason)
+
+			return err
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if len(keys) == 0 {
+			fmt.Fprintln(cfg.Stdout, "signatures: not checked, no signing key or public key given")
+		}
+
+		fmt.Fprintf(cfg.Stdout, "last event: %d %s\n", report.LastEventID, report.LastHash)
+
+		return nil
+	},
+}
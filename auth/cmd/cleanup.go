@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/open-cloud-initiative/glue/auth/internal/janitor"
+
+	"github.com/spf13/cobra"
+)
+
+var Cleanup = &cobra.Command{
+	Use:   "cleanup",
+	Short: "Remove expired sessions and tokens and purge soft-deleted rows",
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		ctx := cmd.Context()
+
+		if err := cfg.LoadConfigFile(); err != nil {
+			return err
+		}
+
+		conn, err := openDB(ctx)
+		if err != nil {
+			return err
+		}
+
+		purged, err := janitor.New(conn, janitorOpts()...).Run(ctx)
+		for _, table := range slices.Sorted(maps.Keys(purged)) {
+			fmt.Fprintf(cfg.Stdout, "%s: %d rows removed\n", table, purged[table])
+		}
+
+		return err
+	},
+}
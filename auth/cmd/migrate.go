@@ -1,33 +1,167 @@
 package cmd
 
 import (
-	"github.com/open-cloud-initiative/glue/auth/internal/adapters/db"
+	"github.com/open-cloud-initiative/glue/auth/internal/models"
 
-	"github.com/katallaxie/pkg/dbx"
 	"github.com/spf13/cobra"
-	"gorm.io/driver/postgres"
-	"gorm.io/gorm"
-	"gorm.io/gorm/schema"
 )
 
+// appendOnlyAuditEvents rejects updates and deletes of audit events and
+// checkpoints, so that the audit log is append-only even for clients of the
+// database. The only update is the one that chains an event.
+const appendOnlyAuditEvents = `
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'audit events are append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION audit_events_chain_only() RETURNS trigger AS $$
+BEGIN
+	IF OLD.seq IS NOT NULL OR NEW.seq IS NULL OR
+		(to_jsonb(NEW) - 'seq' - 'prev_hash' - 'hash') IS DISTINCT FROM (to_jsonb(OLD) - 'seq' - 'prev_hash' - 'hash') THEN
+		RAISE EXCEPTION 'audit events are append-only';
+	END IF;
+
+	RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_events_append_only ON audit_events;
+
+CREATE TRIGGER audit_events_append_only
+	BEFORE DELETE OR TRUNCATE ON audit_events
+	FOR EACH STATEMENT EXECUTE FUNCTION audit_events_append_only();
+
+DROP TRIGGER IF EXISTS audit_events_chain_only ON audit_events;
+
+CREATE TRIGGER audit_events_chain_only
+	BEFORE UPDATE ON audit_events
+	FOR EACH ROW EXECUTE FUNCTION audit_events_chain_only();
+
+DROP TRIGGER IF EXISTS audit_checkpoints_append_only ON audit_checkpoints;
+
+CREATE TRIGGER audit_checkpoints_append_only
+	BEFORE UPDATE OR DELETE OR TRUNCATE ON audit_checkpoints
+	FOR EACH STATEMENT EXECUTE FUNCTION audit_events_append_only();
+`
+
+// migrateUserRoles moves the roles of the former users.role column to role
+// assignments. Unknown roles are created without permissions.
+const migrateUserRoles = `
+DO $$
+BEGIN
+	IF EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'users' AND column_name = 'role') THEN
+		INSERT INTO roles (name, description, built_in, created_at, updated_at)
+			SELECT DISTINCT role, '', false, now(), now() FROM users WHERE role <> ''
+			ON CONFLICT (name) DO NOTHING;
+
+		INSERT INTO user_roles (user_id, role_id, source, created_at)
+			SELECT users.id, roles.id, '', now() FROM users JOIN roles ON roles.name = users.role
+			ON CONFLICT DO NOTHING;
+
+		ALTER TABLE users DROP COLUMN role;
+	END IF;
+END;
+$$;
+`
+
+// sequenceAuditEvents gives the events written before events were chained
+// by Log.Chain their ID as position in the chain. Those events were chained in
+// the order of their IDs while they were written, or precede the chain.
+const sequenceAuditEvents = `
+UPDATE audit_events SET seq = id
+WHERE seq IS NULL AND id <= COALESCE((SELECT max(id) FROM audit_events WHERE seq IS NULL AND hash <> ''), 0);
+`
+
+// dropAccountsProviderIndex drops the former unique index of accounts, that did
+// not include the tenant and is replaced by idx_accounts_tenant_provider_account.
+const dropAccountsProviderIndex = `DROP INDEX IF EXISTS idx_accounts_provider_account;`
+
+// qualifyTenantProviders prefixes the login methods of sessions and of the
+// policies of organizations that refer to a provider of a tenant with the
+// tenant, like models.ProviderKey. Sessions are matched by the accounts of
+// their users, organizations by the accounts of their tenant.
+const qualifyTenantProviders = `
+UPDATE sessions SET provider = a.tenant || '/' || a.provider
+FROM (
+	SELECT user_id, provider, min(tenant) AS tenant FROM accounts
+	GROUP BY user_id, provider HAVING count(DISTINCT tenant) = 1 AND min(tenant) <> ''
+) a
+WHERE a.user_id = sessions.user_id AND a.provider = sessions.provider;
+
+UPDATE organizations SET allowed_providers = (
+	SELECT jsonb_agg(CASE
+		WHEN EXISTS (SELECT 1 FROM accounts WHERE accounts.tenant = organizations.slug AND accounts.provider = p.value)
+		THEN to_jsonb(organizations.slug || '/' || p.value)
+		ELSE to_jsonb(p.value)
+	END ORDER BY p.n)
+	FROM jsonb_array_elements_text(organizations.allowed_providers) WITH ORDINALITY AS p(value, n)
+)
+WHERE jsonb_typeof(allowed_providers) = 'array' AND jsonb_array_length(allowed_providers) > 0;
+`
+
+func init() {
+	Migrate.AddCommand(Reencrypt)
+}
+
 var Migrate = &cobra.Command{
 	Use:   "migrate",
 	Short: "Migrate the database",
 	RunE: func(cmd *cobra.Command, _ []string) error {
-		conn, err := gorm.Open(postgres.Open(cfg.Flags.DatabaseURI), &gorm.Config{
-			NamingStrategy: schema.NamingStrategy{},
-		})
+		conn, err := openDB(cmd.Context())
 		if err != nil {
 			return err
 		}
 
-		store, err := dbx.NewDatabase(conn, db.NewReadTx(), db.NewWriteTx())
+		store, err := newStore(conn)
 		if err != nil {
 			return err
 		}
 
-		return store.Migrate(
+		err = store.Migrate(
 			cmd.Context(),
+			&models.User{},
+			&models.Account{},
+			&models.CsrfToken{},
+			&models.Organization{},
+			&models.OrganizationDomain{},
+			&models.Session{},
+			&models.VerificationToken{},
+			&models.MFAFactor{},
+			&models.PasswordCredential{},
+			&models.LoginAttempt{},
+			&models.RateLimit{},
+			&models.AuditEvent{},
+			&models.AuditCheckpoint{},
+			&models.OutboxEvent{},
+			&models.WebhookDelivery{},
+			&models.Permission{},
+			&models.Role{},
+			&models.RolePermission{},
+			&models.UserRole{},
+			&models.Membership{},
 		)
+		if err != nil {
+			return err
+		}
+
+		if err := conn.WithContext(cmd.Context()).Exec(migrateUserRoles).Error; err != nil {
+			return err
+		}
+
+		if err := conn.WithContext(cmd.Context()).Exec(dropAccountsProviderIndex).Error; err != nil {
+			return err
+		}
+
+		if err := conn.WithContext(cmd.Context()).Exec(qualifyTenantProviders).Error; err != nil {
+			return err
+		}
+
+		if err := conn.WithContext(cmd.Context()).Exec(appendOnlyAuditEvents).Error; err != nil {
+			return err
+		}
+
+		return conn.WithContext(cmd.Context()).Exec(sequenceAuditEvents).Error
 	},
 }
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/open-cloud-initiative/glue/auth/internal/envelope"
+	"github.com/open-cloud-initiative/glue/auth/internal/models"
+	"github.com/open-cloud-initiative/glue/auth/internal/ports"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+)
+
+const defaultBatchSize = 500
+
+var reencryptBatchSize int
+
+func init() {
+	Reencrypt.Flags().IntVar(&reencryptBatchSize, "batch-size", defaultBatchSize, "number of accounts rewritten per transaction")
+}
+
+var Reencrypt = &cobra.Command{
+	Use:   "reencrypt",
+	Short: "Re-encrypt provider tokens under the newest key, bound to their rows",
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		ctx := cmd.Context()
+
+		if err := cfg.LoadConfigFile(); err != nil {
+			return err
+		}
+
+		keyring, err := loadKeyring(ctx)
+		if err != nil {
+			return err
+		}
+
+		if keyring == nil {
+			return envelope.ErrNoKeyring
+		}
+
+		store, err := openStore(ctx)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+
+		total := 0
+		after := uuid.Nil
+
+		for {
+			n := 0
+
+			err := store.ReadWriteTx(ctx, func(ctx context.Context, tx ports.WriteTx) error {
+				accounts := []models.Account{}
+				if err := tx.ListAccountsAfter(ctx, after, reencryptBatchSize, &accounts); err != nil {
+					return err
+				}
+
+				for i := range accounts {
+					if err := tx.UpdateAccountTokens(ctx, &accounts[i]); err != nil {
+						return err
+					}
+
+					after = accounts[i].ID
+				}
+
+				n = len(accounts)
+
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+			total += n
+			if n < reencryptBatchSize {
+				break
+			}
+		}
+
+		fmt.Fprintf(cfg.Stdout, "re-encrypted %d accounts with key %s\n", total, keyring.Primary())
+
+		return nil
+	},
+}
@@ -2,17 +2,52 @@ package cmd
 
 import (
 	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"log"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"time"
 
+	"github.com/open-cloud-initiative/glue/auth/internal/adapters/auth"
+	"github.com/open-cloud-initiative/glue/auth/internal/adapters/auth/factory"
 	"github.com/open-cloud-initiative/glue/auth/internal/adapters/db"
+	"github.com/open-cloud-initiative/glue/auth/internal/adapters/mail"
+	"github.com/open-cloud-initiative/glue/auth/internal/adapters/sms"
+	"github.com/open-cloud-initiative/glue/auth/internal/audit"
 	"github.com/open-cloud-initiative/glue/auth/internal/config"
+	"github.com/open-cloud-initiative/glue/auth/internal/controllers"
+	"github.com/open-cloud-initiative/glue/auth/internal/controllers/admin"
 	"github.com/open-cloud-initiative/glue/auth/internal/controllers/saml"
+	"github.com/open-cloud-initiative/glue/auth/internal/envelope"
+	"github.com/open-cloud-initiative/glue/auth/internal/hooks"
+	"github.com/open-cloud-initiative/glue/auth/internal/janitor"
+	"github.com/open-cloud-initiative/glue/auth/internal/lockout"
+	"github.com/open-cloud-initiative/glue/auth/internal/models"
+	"github.com/open-cloud-initiative/glue/auth/internal/organization"
+	"github.com/open-cloud-initiative/glue/auth/internal/password"
+	"github.com/open-cloud-initiative/glue/auth/internal/ports"
+	"github.com/open-cloud-initiative/glue/auth/internal/ratelimit"
+	"github.com/open-cloud-initiative/glue/auth/internal/rbac"
+	"github.com/open-cloud-initiative/glue/auth/internal/reloader"
+	"github.com/open-cloud-initiative/glue/auth/internal/secrets"
+	"github.com/open-cloud-initiative/glue/auth/internal/tenancy"
+	"github.com/open-cloud-initiative/glue/auth/internal/vault"
+	"github.com/open-cloud-initiative/glue/auth/internal/verification"
+	"github.com/open-cloud-initiative/glue/auth/internal/webhook"
 
 	"github.com/gofiber/fiber/v3"
+	expvarmw "github.com/gofiber/fiber/v3/middleware/expvar"
 	logger "github.com/gofiber/fiber/v3/middleware/logger"
 	"github.com/gofiber/fiber/v3/middleware/requestid"
 	"github.com/katallaxie/pkg/dbx"
+	"github.com/katallaxie/pkg/utilx"
 	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/schema"
@@ -22,6 +57,8 @@ var cfg = config.New()
 
 const versionFmt = "%s (%s %s)"
 
+const defaultSMTPPort = 587
+
 var (
 	version = "dev"
 	commit  = "none"
@@ -37,6 +74,10 @@ func Init() error {
 	}
 
 	RootCmd.AddCommand(Migrate)
+	RootCmd.AddCommand(Cleanup)
+	RootCmd.AddCommand(Audit)
+
+	RootCmd.PersistentFlags().StringVarP(&cfg.Flags.ConfigFile, "config", "c", cfg.Flags.ConfigFile, "path to the configuration file")
 
 	RootCmd.SilenceErrors = true
 	RootCmd.SilenceUsage = true
@@ -59,24 +100,319 @@ var RootCmd = &cobra.Command{
 }
 
 func runRoot(ctx context.Context, _ ...string) error {
-	conn, err := gorm.Open(postgres.Open(cfg.Flags.DatabaseURI), &gorm.Config{
-		NamingStrategy: schema.NamingStrategy{},
-	})
+	err := cfg.LoadConfigFile()
+	if err != nil {
+		return err
+	}
+
+	if err := checkFile(cfg.File); err != nil {
+		return fmt.Errorf("%s: %w", cfg.Flags.ConfigFile, err)
+	}
+
+	providers, err := factory.FromFile(ctx, cfg.File)
+	if err != nil {
+		return err
+	}
+
+	registry := auth.NewRegistry(providers...)
+
+	if cfg.Flags.ConfigFile != "" {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		r := reloader.New(cfg.Flags.ConfigFile, cfg.File, registry, fromFile)
+		r.OnError(logErrors("config"))
+		r.OnReload(func(f *config.File) {
+			log.Printf("config: reloaded %s", cfg.Flags.ConfigFile)
+
+			if pending := reloader.Pending(cfg.File, f); len(pending) > 0 {
+				log.Printf("config: changes to %s take effect after a restart", strings.Join(pending, ", "))
+			}
+		})
+
+		go func() {
+			if err := r.Watch(ctx); err != nil {
+				log.Printf("config: watcher stopped: %v", err)
+			}
+		}()
+	}
+
+	keyring, err := loadKeyring(ctx)
+	if err != nil {
+		return err
+	}
+
+	if keyring == nil {
+		log.Printf("encryption: no keys configured, provider tokens are stored in plaintext")
+	}
+
+	conn, err := openDB(ctx)
 	if err != nil {
 		return err
 	}
 
-	store, err := dbx.NewDatabase(conn, db.NewReadTx(), db.NewWriteTx())
+	store, err := newStore(conn)
 	if err != nil {
 		return err
 	}
 
+	if cfg.File.Janitor.Enabled {
+		go janitor.New(conn, janitorOpts()...).Start(ctx)
+	}
+
+	authz := rbac.New(store)
+	if err := authz.Bootstrap(ctx); err != nil {
+		return fmt.Errorf("rbac: %w", err)
+	}
+
+	if err := checkRoleMappings(ctx, authz); err != nil {
+		return fmt.Errorf("rbac: %w", err)
+	}
+
+	auditLog, err := newAuditLog(ctx, store)
+	if err != nil {
+		return err
+	}
+
+	go auditLog.Start(ctx)
+
+	dispatcher, err := newDispatcher(ctx, store)
+	if err != nil {
+		return err
+	}
+
+	if dispatcher != nil {
+		go dispatcher.Start(ctx)
+	}
+
 	mc := saml.NewMetadataController(store)
+	authOpts := []controllers.AuthOpt{controllers.WithErrorHandler(logErrors("auth"))}
+	if cfg.Flags.Environment == "development" {
+		authOpts = append(authOpts, controllers.WithInsecureCookies())
+	}
+
+	adapterOpts := []db.AuthOpt{db.WithSessionTTL(controllers.DefaultSessionTTL)}
+	if cfg.File.AccountLinking != "" {
+		adapterOpts = append(adapterOpts, db.WithLinkPolicy(cfg.File.AccountLinking))
+	}
+
+	mailer, err := newMailer(ctx)
+	if err != nil {
+		return err
+	}
+
+	verifierOpts, err := smsOpts(ctx)
+	if err != nil {
+		return err
+	}
+
+	codeTTL := verification.DefaultCodeTTL
+	if cfg.File.SMS != nil && cfg.File.SMS.CodeTTL > 0 {
+		codeTTL = cfg.File.SMS.CodeTTL.Duration()
+	}
+
+	verifierOpts = append(verifierOpts,
+		verification.WithErrorHandler(logErrors("verification")),
+		verification.WithNumberLimit(defaultRateLimiter(store, "number", verification.DefaultNumberLimit, time.Hour)),
+		verification.WithAttemptLimit(defaultRateLimiter(store, "attempt", verification.DefaultAttemptLimit, codeTTL)))
+
+	ipLimit := defaultRateLimiter(store, "ip", controllers.DefaultIPLimit, time.Hour)
+	authOpts = append(authOpts, controllers.WithIPLimit(ipLimit))
+
+	pipeline, err := newHooks(ctx)
+	if err != nil {
+		return err
+	}
+
+	if pipeline != nil {
+		adapterOpts = append(adapterOpts, db.WithHooks(pipeline))
+		verifierOpts = append(verifierOpts, verification.WithHooks(pipeline))
+	}
+
+	passwords, corpus, err := newPasswords(store, pipeline)
+	if err != nil {
+		return err
+	}
+
+	if passwords != nil {
+		verifierOpts = append(verifierOpts, resetOpts(passwords)...)
+		authOpts = append(authOpts, controllers.WithPasswords(passwords))
+	}
+
+	baseURL := utilx.Or(cfg.File.BaseURL, "http://localhost"+cfg.Flags.Addr)
+	verifier := verification.New(store, mailer, baseURL, verifierOpts...)
+	authOpts = append(authOpts, controllers.WithVerifier(verifier))
+
+	adapter := db.NewAuth(store, adapterOpts...)
+	guard := lockout.New(store, lockoutOpts()...)
+	authOpts = append(authOpts, controllers.WithGuard(guard))
+
+	orgs := organization.New(store, mailer, baseURL, orgOpts()...)
+	authOpts = append(authOpts, controllers.WithDiscovery(orgs), controllers.WithTenancy(tenancy.New(tenancyOpts()...)))
+
+	ac := controllers.NewAuthController(registry, adapter, authOpts...)
+	acc := controllers.NewAccountController(adapter)
+	ec := controllers.NewEmailController(adapter, verifier, controllers.WithEmailGuard(guard))
+	pc := controllers.NewPhoneController(adapter, verifier, controllers.WithPhoneGuard(guard), controllers.WithPhoneIPLimit(ipLimit))
+
+	proxies, err := cfg.File.RateLimit.Proxies()
+	if err != nil {
+		return err
+	}
+
+	loginLimit := rateLimit(store, "login")
+	signupLimit := rateLimit(store, "signup")
 
 	app := fiber.New()
 	app.Use(requestid.New())
+	if len(proxies) > 0 {
+		app.Use(controllers.RealIP(proxies, utilx.Or(cfg.File.RateLimit.ProxyHeader, fiber.HeaderXForwardedFor)))
+	}
+	app.Use(controllers.RequestMetadata())
 	app.Use(logger.New())
 
+	app.Get("/saml/metadata", mc.GetMetadata)
+
+	app.Get("/auth/providers", ac.ListProviders)
+	app.Get("/auth/:provider/login", loginLimit, ac.Login)
+	app.Get("/auth/:provider/callback", loginLimit, ac.Callback)
+	app.Post("/auth/:provider/callback", loginLimit, ac.Callback)
+	app.Post("/auth/discover", loginLimit, ac.Discover)
+
+	tenant := app.Group("/t/:tenant/auth")
+	tenant.Get("/providers", ac.ListProviders)
+	tenant.Get("/:provider/login", loginLimit, ac.Login)
+	tenant.Get("/:provider/callback", loginLimit, ac.Callback)
+	tenant.Post("/:provider/callback", loginLimit, ac.Callback)
+	app.Post("/auth/logout", ac.Logout)
+	app.Post("/auth/anonymous", signupLimit, ac.Anonymous)
+	app.Get("/auth/email/confirm", loginLimit, ec.Confirm)
+	app.Post("/auth/sms/login", loginLimit, ac.SMSLogin)
+	app.Post("/auth/sms/login/verify", loginLimit, ac.SMSLoginVerify)
+
+	mfa := app.Group("/auth/mfa", loginLimit, controllers.PendingMFA(adapter))
+	mfa.Post("/sms/challenge", ac.MFAChallenge)
+	mfa.Post("/sms/verify", ac.MFAVerify)
+
+	me := app.Group("/me", controllers.Authenticated(adapter))
+	me.Get("/accounts", acc.ListAccounts)
+	me.Get("/accounts/:provider/link", ac.Link)
+	me.Delete("/accounts/:id", acc.UnlinkAccount)
+	me.Post("/email/verification", ec.SendVerification)
+	me.Put("/email", ec.ChangeEmail)
+	me.Post("/phone", pc.SendVerification)
+	me.Post("/phone/verify", pc.Verify)
+	me.Post("/mfa/sms", pc.EnrollSMS)
+	me.Delete("/mfa/:id", pc.RemoveFactor)
+
+	rc := controllers.NewRoleController(authz)
+	me.Get("/roles", rc.MyRoles)
+
+	oc := controllers.NewOrganizationController(orgs)
+	me.Get("/session", oc.MySession)
+	me.Get("/organizations", oc.MyOrganizations)
+	me.Put("/organization", oc.SwitchOrganization)
+	app.Post("/auth/invitations/accept", loginLimit, controllers.Authenticated(adapter), oc.AcceptInvitation)
+
+	org := app.Group("/orgs/:slug", rateLimit(store, "admin"), controllers.Authenticated(adapter))
+	org.Put("/", controllers.RequireOrgPermission(orgs, rbac.OrgWrite), oc.UpdatePolicy)
+	org.Get("/members", controllers.RequireOrgPermission(orgs, rbac.MembersRead), oc.ListMembers)
+	org.Put("/members/:id", controllers.RequireOrgPermission(orgs, rbac.MembersWrite), oc.UpdateMember)
+	org.Delete("/members/:id", controllers.RequireOrgPermission(orgs, rbac.MembersWrite), oc.RemoveMember)
+	org.Post("/invitations", controllers.RequireOrgPermission(orgs, rbac.MembersWrite), oc.Invite)
+
+	admins := app.Group("/admin", rateLimit(store, "admin"), controllers.Authenticated(adapter))
+	admins.Get("/permissions", controllers.RequirePermission(authz, rbac.RolesRead), rc.ListPermissions)
+	admins.Get("/roles", controllers.RequirePermission(authz, rbac.RolesRead), rc.ListRoles)
+	admins.Post("/roles", controllers.RequirePermission(authz, rbac.RolesWrite), rc.CreateRole)
+	admins.Put("/roles/:name", controllers.RequirePermission(authz, rbac.RolesWrite), rc.UpdateRole)
+	admins.Delete("/roles/:name", controllers.RequirePermission(authz, rbac.RolesWrite), rc.DeleteRole)
+	admins.Get("/users/:id/roles", controllers.RequirePermission(authz, rbac.RolesRead), rc.ListUserRoles)
+	admins.Put("/users/:id/roles/:role", controllers.RequirePermission(authz, rbac.RolesWrite), rc.AssignRole)
+	admins.Delete("/users/:id/roles/:role", controllers.RequirePermission(authz, rbac.RolesWrite), rc.UnassignRole)
+	admins.Get("/organizations", controllers.RequirePermission(authz, rbac.OrgsRead), oc.ListOrganizations)
+	admins.Post("/organizations", controllers.RequirePermission(authz, rbac.OrgsWrite), oc.CreateOrganization)
+	admins.Get("/organizations/:slug", controllers.RequirePermission(authz, rbac.OrgsRead), oc.GetOrganization)
+	admins.Put("/organizations/:slug", controllers.RequirePermission(authz, rbac.OrgsWrite), oc.UpdateOrganization)
+	admins.Delete("/organizations/:slug", controllers.RequirePermission(authz, rbac.OrgsWrite), oc.DeleteOrganization)
+	admins.Get("/organizations/:slug/members", controllers.RequirePermission(authz, rbac.OrgsRead), oc.ListMembers)
+	admins.Post("/organizations/:slug/members", controllers.RequirePermission(authz, rbac.OrgsWrite), oc.AddMember)
+	admins.Put("/organizations/:slug/members/:id", controllers.RequirePermission(authz, rbac.OrgsWrite), oc.UpdateMember)
+	admins.Delete("/organizations/:slug/members/:id", controllers.RequirePermission(authz, rbac.OrgsWrite), oc.RemoveMember)
+	admins.Post("/organizations/:slug/invitations", controllers.RequirePermission(authz, rbac.OrgsWrite), oc.Invite)
+
+	if passwords != nil {
+		pwc := controllers.NewPasswordController(adapter, passwords, verifier, controllers.WithPasswordGuard(guard), controllers.WithPasswordIPLimit(ipLimit))
+
+		app.Post("/auth/password/login", loginLimit, ac.PasswordLogin)
+		app.Post("/auth/password/signup", signupLimit, ac.PasswordSignup)
+		app.Post("/auth/password/forgot", loginLimit, pwc.Forgot)
+		app.Post("/auth/password/reset", loginLimit, pwc.Reset)
+		me.Put("/password", pwc.Change)
+	}
+
+	if cfg.Flags.InternalToken != "" {
+		token, err := secrets.Resolve(ctx, secrets.Ref(cfg.Flags.InternalToken))
+		if err != nil {
+			return fmt.Errorf("internal token: %w", err)
+		}
+
+		tc := controllers.NewTokenController(vault.New(store, registry))
+
+		internal := app.Group("/internal", rateLimit(store, "token"), controllers.Internal(token))
+		internal.Get("/users/:id/tokens/:provider", tc.GetToken)
+
+		lis, err := net.Listen("tcp", cfg.Flags.AdminAddr)
+		if err != nil {
+			return err
+		}
+
+		report := func(method string, err error) {
+			log.Printf("admin: %s: %v", method, err)
+		}
+
+		unary := []grpc.UnaryServerInterceptor{admin.ErrorInterceptor(report)}
+		stream := []grpc.StreamServerInterceptor{admin.ErrorStreamInterceptor(report)}
+		if limiter := rateLimiter(store, "admin"); limiter != nil {
+			unary = append(unary, admin.RateLimitInterceptor(limiter))
+			stream = append(stream, admin.RateLimitStreamInterceptor(limiter))
+		}
+
+		unary = append(unary, admin.AuthInterceptor(token, adapter, authz), admin.AuditInterceptor())
+		stream = append(stream, admin.AuthStreamInterceptor(token, adapter, authz))
+
+		srv := grpc.NewServer(grpc.ForceServerCodec(admin.Codec), grpc.ChainUnaryInterceptor(unary...), grpc.ChainStreamInterceptor(stream...))
+		adminOpts := []admin.Opt{admin.WithGuard(guard), admin.WithAuditLog(auditLog), admin.WithRBAC(authz), admin.WithOrganizations(orgs)}
+		if corpus != nil {
+			adminOpts = append(adminOpts, admin.WithCorpus(corpus))
+		}
+
+		if dispatcher != nil {
+			adminOpts = append(adminOpts, admin.WithDispatcher(dispatcher))
+		}
+
+		admin.RegisterAdminServer(srv, admin.NewServer(adapter, adminOpts...))
+
+		go func() {
+			if err := srv.Serve(lis); err != nil {
+				log.Printf("admin: server stopped: %v", err)
+			}
+		}()
+		defer srv.GracefulStop()
+	}
+
+	if cfg.Flags.DebugAddr != "" {
+		debug := fiber.New()
+		debug.Use(expvarmw.New())
+
+		go func() {
+			if err := debug.Listen(cfg.Flags.DebugAddr); err != nil {
+				log.Printf("debug: server stopped: %v", err)
+			}
+		}()
+		defer func() { _ = debug.Shutdown() }()
+	}
+
 	err = app.Listen(cfg.Flags.Addr)
 	if err != nil {
 		return err
@@ -84,3 +420,514 @@ func runRoot(ctx context.Context, _ ...string) error {
 
 	return nil
 }
+
+// openDB opens the database connection. The database URI is a postgres:// URI,
+// a connection string or a secret reference to one of them.
+func openDB(ctx context.Context) (*gorm.DB, error) {
+	dsn := secrets.New(cfg.Flags.DatabaseURI)
+
+	if !strings.HasPrefix(cfg.Flags.DatabaseURI, "postgres://") && !strings.HasPrefix(cfg.Flags.DatabaseURI, "postgresql://") {
+		var err error
+
+		dsn, err = secrets.Resolve(ctx, secrets.Ref(cfg.Flags.DatabaseURI))
+		if err != nil {
+			return nil, fmt.Errorf("database URI: %w", err)
+		}
+	}
+
+	return gorm.Open(postgres.Open(dsn.Value()), &gorm.Config{
+		NamingStrategy: schema.NamingStrategy{},
+	})
+}
+
+// openStore opens the database.
+func openStore(ctx context.Context) (dbx.Database[ports.ReadTx, ports.WriteTx], error) {
+	conn, err := openDB(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return newStore(conn)
+}
+
+func newStore(conn *gorm.DB) (dbx.Database[ports.ReadTx, ports.WriteTx], error) {
+	return dbx.NewDatabase(conn, db.NewReadTx(), db.NewWriteTx())
+}
+
+// newMailer returns the mailer of the configuration file. Emails are
+// logged if no SMTP server is configured.
+func newMailer(ctx context.Context) (ports.Mailer, error) {
+	c := cfg.File.Mail.SMTP
+	if c == nil {
+		log.Printf("mail: no smtp server configured, emails are logged")
+		return mail.NewLog(os.Stderr), nil
+	}
+
+	opts := []mail.Opt{}
+	if c.Username != "" {
+		password, err := secrets.Resolve(ctx, c.Password)
+		if err != nil {
+			return nil, fmt.Errorf("mail.smtp.password: %w", err)
+		}
+
+		opts = append(opts, mail.WithAuth(c.Username, password))
+	}
+
+	return mail.NewSMTP(c.Host, utilx.IfElse(c.Port > 0, c.Port, defaultSMTPPort), cfg.File.Mail.From, opts...), nil
+}
+
+// smsOpts returns the verification options of the SMS configuration.
+func smsOpts(ctx context.Context) ([]verification.Opt, error) {
+	c := cfg.File.SMS
+	if c == nil {
+		return nil, nil
+	}
+
+	opts := []verification.Opt{}
+	if c.CodeTTL > 0 {
+		opts = append(opts, verification.WithCodeTTL(c.CodeTTL.Duration()))
+	}
+
+	switch {
+	case c.Twilio != nil:
+		token, err := secrets.Resolve(ctx, c.Twilio.AuthToken)
+		if err != nil {
+			return nil, fmt.Errorf("sms.twilio.authToken: %w", err)
+		}
+
+		twilioOpts := []sms.Opt{}
+		if c.Twilio.BaseURL != "" {
+			twilioOpts = append(twilioOpts, sms.WithBaseURL(c.Twilio.BaseURL))
+		}
+
+		opts = append(opts, verification.WithSMS(sms.NewTwilio(c.Twilio.AccountSID, token, c.Twilio.From, twilioOpts...)))
+	case c.File != "":
+		opts = append(opts, verification.WithSMS(sms.NewFile(c.File)))
+	default:
+		opts = append(opts, verification.WithSMS(sms.NewLog(os.Stderr)))
+	}
+
+	return opts, nil
+}
+
+// newPasswords returns the password service and the breach corpus of the
+// configuration file. Both are nil if passwords or the corpus are disabled.
+func newPasswords(store dbx.Database[ports.ReadTx, ports.WriteTx], pipeline ports.Hooks) (*password.Service, *password.Corpus, error) {
+	c := cfg.File.Password
+	if c == nil {
+		return nil, nil, nil
+	}
+
+	policy := password.Policy{
+		MinLength:     utilx.IfElse(c.MinLength > 0, c.MinLength, password.DefaultPolicy.MinLength),
+		MaxLength:     utilx.IfElse(c.MaxLength > 0, c.MaxLength, password.DefaultPolicy.MaxLength),
+		RequireUpper:  c.RequireUpper,
+		RequireLower:  c.RequireLower,
+		RequireDigit:  c.RequireDigit,
+		RequireSymbol: c.RequireSymbol,
+	}
+
+	params := password.DefaultParams
+	if c.Argon2.Memory > 0 {
+		params.Memory = c.Argon2.Memory
+	}
+
+	if c.Argon2.Iterations > 0 {
+		params.Iterations = c.Argon2.Iterations
+	}
+
+	if c.Argon2.Parallelism > 0 {
+		params.Parallelism = c.Argon2.Parallelism
+	}
+
+	var corpus *password.Corpus
+	if c.BreachCorpus != "" {
+		corpus = password.NewCorpus(c.BreachCorpus, password.WithMinCount(c.BreachMinCount))
+
+		n, err := corpus.Load()
+		if err != nil {
+			return nil, nil, fmt.Errorf("password.breachCorpus: %w", err)
+		}
+
+		log.Printf("password: loaded %d breached password hashes", n)
+
+		policy.Breached = corpus
+	}
+
+	opts := []password.Opt{password.WithPolicy(policy), password.WithParams(params)}
+	if pipeline != nil {
+		opts = append(opts, password.WithHooks(pipeline))
+	}
+
+	return password.New(store, opts...), corpus, nil
+}
+
+// orgOpts returns the organization options of the configuration file.
+func orgOpts() []organization.Opt {
+	c := cfg.File.Organizations
+	opts := []organization.Opt{}
+
+	if c.InviteURL != "" {
+		opts = append(opts, organization.WithInviteURL(c.InviteURL))
+	}
+
+	if c.InviteTTL > 0 {
+		opts = append(opts, organization.WithInviteTTL(c.InviteTTL.Duration()))
+	}
+
+	return opts
+}
+
+// tenancyOpts returns the options of the tenant resolver.
+func tenancyOpts() []tenancy.Opt {
+	c := cfg.File.Tenancy
+	opts := []tenancy.Opt{tenancy.WithHosts(c.Hosts)}
+
+	if c.Domain != "" {
+		opts = append(opts, tenancy.WithDomain(c.Domain))
+	}
+
+	return opts
+}
+
+// resetOpts returns the verification options that reset passwords.
+func resetOpts(passwords *password.Service) []verification.Opt {
+	c := cfg.File.Password
+	opts := []verification.Opt{verification.WithPasswords(passwords)}
+
+	if c.ResetURL != "" {
+		opts = append(opts, verification.WithResetURL(c.ResetURL))
+	}
+
+	if c.ResetTTL > 0 {
+		opts = append(opts, verification.WithResetTTL(c.ResetTTL.Duration()))
+	}
+
+	return opts
+}
+
+// lockoutOpts returns the lockout options of the configuration file.
+func lockoutOpts() []lockout.Opt {
+	c := cfg.File.Lockout
+	opts := []lockout.Opt{
+		lockout.WithErrorHandler(logErrors("lockout")),
+		lockout.WithThresholds(lockout.Thresholds{
+			Subject:   c.SubjectThreshold,
+			IP:        c.IPThreshold,
+			SubjectIP: c.SubjectIPThreshold,
+		}),
+	}
+
+	if c.BaseDelay > 0 || c.MaxDelay > 0 {
+		opts = append(opts, lockout.WithBackoff(
+			utilx.IfElse(c.BaseDelay > 0, c.BaseDelay.Duration(), lockout.DefaultBaseDelay),
+			utilx.IfElse(c.MaxDelay > 0, c.MaxDelay.Duration(), lockout.DefaultMaxDelay),
+		))
+	}
+
+	if c.Window > 0 {
+		opts = append(opts, lockout.WithWindow(c.Window.Duration()))
+	}
+
+	return opts
+}
+
+// checkFile checks the settings of the configuration file that name the
+// events, algorithms and expressions of other packages. The config package
+// only checks the syntax of the file.
+func checkFile(f *config.File) error {
+	errs := []error{}
+
+	for name, g := range f.RateLimit.Groups {
+		switch ratelimit.Algorithm(g.Algorithm) {
+		case "", ratelimit.AlgorithmSlidingWindow, ratelimit.AlgorithmTokenBucket:
+		default:
+			errs = append(errs, fmt.Errorf("rateLimit.groups.%s.algorithm: must be sliding-window or token-bucket, got %q", name, g.Algorithm))
+		}
+	}
+
+	if f.Webhooks != nil {
+		for i, e := range f.Webhooks.Endpoints {
+			for _, event := range e.Events {
+				if !slices.Contains(audit.Actions, event) {
+					errs = append(errs, fmt.Errorf("webhooks.endpoints[%d] (id %q): unknown event %q", i, e.ID, event))
+				}
+			}
+		}
+	}
+
+	for i, h := range f.Hooks {
+		for _, event := range h.Events {
+			if !slices.Contains(hooks.Events, ports.HookEvent(event)) {
+				errs = append(errs, fmt.Errorf("hooks[%d] (id %q): unknown event %q", i, h.ID, event))
+			}
+		}
+
+		if h.Failure != "" && h.Failure != hooks.FailClosed && h.Failure != hooks.FailOpen {
+			errs = append(errs, fmt.Errorf("hooks[%d] (id %q): failure must be %q or %q", i, h.ID, hooks.FailClosed, hooks.FailOpen))
+		}
+
+		if h.CEL != "" {
+			if _, err := hooks.NewCEL(h.CEL, h.Message); err != nil {
+				errs = append(errs, fmt.Errorf("hooks[%d] (id %q): %w", i, h.ID, err))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// fromFile checks a reloaded configuration file and builds its providers.
+func fromFile(ctx context.Context, f *config.File) ([]auth.Provider, error) {
+	if err := checkFile(f); err != nil {
+		return nil, err
+	}
+
+	return factory.FromFile(ctx, f)
+}
+
+// rateLimiter returns the limiter of a route group, or nil if the group has no limit.
+func rateLimiter(store dbx.Database[ports.ReadTx, ports.WriteTx], group string) ratelimit.Limiter {
+	g, ok := cfg.File.RateLimit.Groups[group]
+	if !ok {
+		return nil
+	}
+
+	alg := ratelimit.Algorithm(utilx.Or(g.Algorithm, string(ratelimit.AlgorithmSlidingWindow)))
+
+	if cfg.File.RateLimit.Storage == config.RateLimitStoragePostgres {
+		return ratelimit.NewDatabase(store, group, alg, g.Limit, g.Window.Duration())
+	}
+
+	return ratelimit.NewMemory(alg, g.Limit, g.Window.Duration())
+}
+
+// defaultRateLimiter returns the limiter of a group, or a sliding window limiter
+// with the default limit if the group has none. Both count in the configured storage.
+func defaultRateLimiter(store dbx.Database[ports.ReadTx, ports.WriteTx], group string, limit int, window time.Duration) ratelimit.Limiter {
+	if limiter := rateLimiter(store, group); limiter != nil {
+		return limiter
+	}
+
+	if cfg.File.RateLimit.Storage == config.RateLimitStoragePostgres {
+		return ratelimit.NewDatabase(store, group, ratelimit.AlgorithmSlidingWindow, limit, window)
+	}
+
+	return ratelimit.NewSlidingWindow(limit, window)
+}
+
+// rateLimit returns the rate limit middleware of a route group.
+// It passes all requests if the group has no limit.
+func rateLimit(store dbx.Database[ports.ReadTx, ports.WriteTx], group string) fiber.Handler {
+	limiter := rateLimiter(store, group)
+	if limiter == nil {
+		return func(ctx fiber.Ctx) error {
+			return ctx.Next()
+		}
+	}
+
+	return controllers.RateLimit(group, limiter)
+}
+
+// janitorOpts returns the janitor options of the configuration file.
+func janitorOpts() []janitor.Opt {
+	opts := []janitor.Opt{janitor.WithErrorHandler(logErrors("janitor"))}
+	c := cfg.File.Janitor
+
+	if c.Interval > 0 {
+		opts = append(opts, janitor.WithInterval(c.Interval.Duration()))
+	}
+
+	if c.Retention > 0 {
+		opts = append(opts, janitor.WithRetention(c.Retention.Duration()))
+	}
+
+	if c.AnonymousRetention > 0 {
+		opts = append(opts, janitor.WithAnonymousRetention(c.AnonymousRetention.Duration()))
+	}
+
+	if c.BatchSize > 0 {
+		opts = append(opts, janitor.WithBatchSize(c.BatchSize))
+	}
+
+	return opts
+}
+
+// newAuditLog returns the audit log, that signs checkpoints if a signing key is configured.
+func newAuditLog(ctx context.Context, store dbx.Database[ports.ReadTx, ports.WriteTx]) (*audit.Log, error) {
+	c := cfg.File.Audit
+	opts := []audit.LogOpt{audit.WithErrorHandler(logErrors("audit"))}
+
+	if c.CheckpointInterval > 0 {
+		opts = append(opts, audit.WithCheckpointInterval(c.CheckpointInterval.Duration()))
+	}
+
+	if !c.SigningKey.IsZero() {
+		key, err := auditSigningKey(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		opts = append(opts, audit.WithSigningKey(key))
+	}
+
+	return audit.NewLog(store, opts...), nil
+}
+
+// newDispatcher returns the dispatcher of the webhooks, or nil if webhooks are not configured.
+func newDispatcher(ctx context.Context, store dbx.Database[ports.ReadTx, ports.WriteTx]) (*webhook.Dispatcher, error) {
+	c := cfg.File.Webhooks
+	if c == nil {
+		return nil, nil
+	}
+
+	endpoints := make([]webhook.Endpoint, 0, len(c.Endpoints))
+
+	for i, e := range c.Endpoints {
+		secret, err := secrets.Resolve(ctx, e.Secret)
+		if err != nil {
+			return nil, fmt.Errorf("webhooks.endpoints[%d] (id %q): %w", i, e.ID, err)
+		}
+
+		endpoints = append(endpoints, webhook.Endpoint{ID: e.ID, URL: e.URL, Secret: []byte(secret.Value()), Events: e.Events})
+	}
+
+	opts := []webhook.Opt{webhook.WithErrorHandler(logErrors("webhook"))}
+
+	if c.Interval > 0 {
+		opts = append(opts, webhook.WithInterval(c.Interval.Duration()))
+	}
+
+	if c.Timeout > 0 {
+		opts = append(opts, webhook.WithTimeout(c.Timeout.Duration()))
+	}
+
+	if c.MaxAttempts > 0 {
+		opts = append(opts, webhook.WithMaxAttempts(c.MaxAttempts))
+	}
+
+	if c.Backoff > 0 || c.MaxBackoff > 0 {
+		opts = append(opts, webhook.WithBackoff(
+			utilx.IfElse(c.Backoff > 0, c.Backoff.Duration(), webhook.DefaultBackoff),
+			utilx.IfElse(c.MaxBackoff > 0, c.MaxBackoff.Duration(), webhook.DefaultMaxBackoff),
+		))
+	}
+
+	return webhook.NewDispatcher(store, endpoints, opts...), nil
+}
+
+// newHooks returns the pipeline of the configured hooks, or nil if there are none.
+func newHooks(ctx context.Context) (ports.Hooks, error) {
+	if len(cfg.File.Hooks) == 0 {
+		return nil, nil
+	}
+
+	configs := make([]hooks.Config, 0, len(cfg.File.Hooks))
+
+	for i, h := range cfg.File.Hooks {
+		c := hooks.Config{ID: h.ID, Failure: h.Failure}
+
+		for _, event := range h.Events {
+			c.Events = append(c.Events, ports.HookEvent(event))
+		}
+
+		if h.HTTP != nil {
+			secret, err := secrets.Resolve(ctx, h.HTTP.Secret)
+			if err != nil {
+				return nil, fmt.Errorf("hooks[%d] (id %q): %w", i, h.ID, err)
+			}
+
+			opts := []hooks.HTTPOpt{}
+			if h.HTTP.Timeout > 0 {
+				opts = append(opts, hooks.WithTimeout(h.HTTP.Timeout.Duration()))
+			}
+
+			c.Hook = hooks.NewHTTP(h.HTTP.URL, []byte(secret.Value()), opts...)
+		} else {
+			hook, err := hooks.NewCEL(h.CEL, h.Message)
+			if err != nil {
+				return nil, fmt.Errorf("hooks[%d] (id %q): %w", i, h.ID, err)
+			}
+
+			c.Hook = hook
+		}
+
+		configs = append(configs, c)
+	}
+
+	return hooks.NewPipeline(configs, hooks.WithErrorHandler(logErrors("hooks"))), nil
+}
+
+// auditSigningKey resolves the signing key of audit checkpoints.
+func auditSigningKey(ctx context.Context) (ed25519.PrivateKey, error) {
+	s, err := secrets.Resolve(ctx, cfg.File.Audit.SigningKey)
+	if err != nil {
+		return nil, fmt.Errorf("audit.signingKey: %w", err)
+	}
+
+	key, err := audit.ParseKey(strings.TrimSpace(s.Value()))
+	if err != nil {
+		return nil, fmt.Errorf("audit.signingKey: %w", err)
+	}
+
+	return key, nil
+}
+
+// loadKeyring builds the keyring from the configuration and sets it on the
+// serializer that encrypts provider tokens. It returns nil if no keys are configured.
+func loadKeyring(ctx context.Context) (*envelope.Keyring, error) {
+	if len(cfg.File.Encryption.Keys) == 0 {
+		return nil, nil
+	}
+
+	keys := make([]envelope.Key, 0, len(cfg.File.Encryption.Keys))
+
+	for i, k := range cfg.File.Encryption.Keys {
+		s, err := secrets.Resolve(ctx, k.Secret)
+		if err != nil {
+			return nil, fmt.Errorf("encryption.keys[%d] (id %q): %w", i, k.ID, err)
+		}
+
+		secret, err := base64.StdEncoding.DecodeString(s.Value())
+		if err != nil {
+			return nil, fmt.Errorf("encryption.keys[%d] (id %q): secret is not base64 encoded", i, k.ID)
+		}
+
+		keys = append(keys, envelope.Key{ID: k.ID, Secret: secret})
+	}
+
+	keyring, err := envelope.NewKeyring(keys...)
+	if err != nil {
+		return nil, err
+	}
+
+	envelope.DefaultSerializer.SetKeyring(keyring)
+
+	return keyring, nil
+}
+
+// checkRoleMappings warns about role mappings of providers that refer to
+// unknown roles. They are skipped at the login.
+func checkRoleMappings(ctx context.Context, authz *rbac.Service) error {
+	roles, err := authz.Roles(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range cfg.File.Providers {
+		for _, r := range p.Roles {
+			if r.Role != "" && !slices.ContainsFunc(roles, func(role models.Role) bool { return role.Name == r.Role }) {
+				log.Printf("rbac: provider %s maps to unknown role %q", p.ID, r.Role)
+			}
+		}
+	}
+
+	return nil
+}
+
+// logErrors returns a function that logs the errors of the component.
+func logErrors(component string) func(error) {
+	return func(err error) {
+		log.Printf("%s: %v", component, err)
+	}
+}
@@ -0,0 +1,197 @@
+package factory
+
+import (
+	"context"
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/open-cloud-initiative/glue/auth/internal/adapters/auth"
+	"github.com/open-cloud-initiative/glue/auth/internal/adapters/auth/github"
+	"github.com/open-cloud-initiative/glue/auth/internal/adapters/auth/oidc"
+	"github.com/open-cloud-initiative/glue/auth/internal/adapters/auth/saml"
+	"github.com/open-cloud-initiative/glue/auth/internal/config"
+	"github.com/open-cloud-initiative/glue/auth/internal/secrets"
+
+	gooidc "github.com/coreos/go-oidc/v3/oidc"
+	crewjam "github.com/crewjam/saml"
+	"github.com/crewjam/saml/samlsp"
+	"github.com/katallaxie/pkg/utilx"
+)
+
+// ErrInvalidKey is returned when the SAML service provider key can not be used for signing.
+var ErrInvalidKey = errors.New("saml.key is not a signing key")
+
+// FromFile builds all providers of the configuration file.
+// Errors are wrapped in a config.ProviderError pointing to the offending entry.
+func FromFile(ctx context.Context, f *config.File) ([]auth.Provider, error) {
+	providers := make([]auth.Provider, 0, len(f.Providers))
+	errs := []error{}
+
+	for i, p := range f.Providers {
+		provider, err := New(ctx, p)
+		if err != nil {
+			errs = append(errs, config.NewProviderError(i, p.ID, err))
+			continue
+		}
+
+		providers = append(providers, provider)
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	return providers, nil
+}
+
+// New builds a single provider from its configuration.
+func New(ctx context.Context, p config.Provider) (auth.Provider, error) {
+	switch p.Type {
+	case config.ProviderKindGitHub:
+		return newGitHub(ctx, p)
+	case config.ProviderKindSAML:
+		return newSAML(ctx, p)
+	case config.ProviderKindOIDC:
+		return newOIDC(ctx, p)
+	default:
+		return nil, fmt.Errorf("%w %q", config.ErrUnknownKind, p.Type)
+	}
+}
+
+func newGitHub(ctx context.Context, p config.Provider) (auth.Provider, error) {
+	secret, err := secrets.Resolve(ctx, p.ClientSecret)
+	if err != nil {
+		return nil, fmt.Errorf("clientSecret: %w", err)
+	}
+
+	opts := []github.Opt{
+		github.WithID(p.ID),
+		github.WithTenant(p.Tenant),
+		github.WithScopes(p.Scopes...),
+		github.WithAllowedOrgs(p.AllowedOrgs...),
+	}
+
+	if utilx.NotEmpty(p.Name) {
+		opts = append(opts, github.WithName(p.Name))
+	}
+
+	if utilx.NotEmpty(p.EnterpriseURL) {
+		opts = append(opts, github.WithEnterpriseURL(p.EnterpriseURL))
+	}
+
+	if len(p.Roles) > 0 {
+		mappings := make([]github.RoleMapping, 0, len(p.Roles))
+		for _, r := range p.Roles {
+			mappings = append(mappings, github.RoleMapping{Org: r.Org, Team: r.Team, Role: r.Role, Metadata: r.Metadata})
+		}
+
+		opts = append(opts, github.WithRoleMappings(mappings...))
+	}
+
+	return github.New(p.ClientID, secret.Value(), p.CallbackURL, opts...), nil
+}
+
+func newOIDC(ctx context.Context, p config.Provider) (auth.Provider, error) {
+	secret, err := secrets.Resolve(ctx, p.ClientSecret)
+	if err != nil {
+		return nil, fmt.Errorf("clientSecret: %w", err)
+	}
+
+	issuer, err := gooidc.NewProvider(gooidc.ClientContext(ctx, auth.DefaultClient), p.Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("discovering issuer: %w", err)
+	}
+
+	opts := []oidc.Opt{oidc.WithID(p.ID), oidc.WithTenant(p.Tenant), oidc.WithScopes(p.Scopes...)}
+
+	if utilx.NotEmpty(p.Name) {
+		opts = append(opts, oidc.WithName(p.Name))
+	}
+
+	return oidc.New(issuer, p.ClientID, secret.Value(), p.CallbackURL, opts...), nil
+}
+
+func newSAML(ctx context.Context, p config.Provider) (auth.Provider, error) {
+	acsURL, err := url.Parse(p.CallbackURL)
+	if err != nil {
+		return nil, err
+	}
+
+	idp, err := metadata(ctx, p.SAML)
+	if err != nil {
+		return nil, fmt.Errorf("loading IdP metadata: %w", err)
+	}
+
+	opts := []saml.Opt{saml.WithID(p.ID), saml.WithTenant(p.Tenant)}
+
+	if utilx.NotEmpty(p.Name) {
+		opts = append(opts, saml.WithName(p.Name))
+	}
+
+	if !p.SAML.Key.IsZero() {
+		key, cert, err := keyPair(ctx, p.SAML.CertificateFile, p.SAML.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		opts = append(opts, saml.WithKeyPair(key, cert))
+	}
+
+	return saml.New(p.SAML.EntityID, *acsURL, idp, opts...), nil
+}
+
+func metadata(ctx context.Context, cfg *config.SAMLProvider) (*crewjam.EntityDescriptor, error) {
+	switch {
+	case utilx.NotEmpty(cfg.MetadataURL):
+		u, err := url.Parse(cfg.MetadataURL)
+		if err != nil {
+			return nil, err
+		}
+
+		return samlsp.FetchMetadata(ctx, auth.DefaultClient, *u)
+	case utilx.NotEmpty(cfg.MetadataFile):
+		data, err := os.ReadFile(filepath.Clean(cfg.MetadataFile))
+		if err != nil {
+			return nil, err
+		}
+
+		return samlsp.ParseMetadata(data)
+	default:
+		return samlsp.ParseMetadata([]byte(cfg.Metadata))
+	}
+}
+
+func keyPair(ctx context.Context, certFile string, keyRef secrets.Ref) (crypto.Signer, *x509.Certificate, error) {
+	certPEM, err := os.ReadFile(filepath.Clean(certFile))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keyPEM, err := secrets.Resolve(ctx, keyRef)
+	if err != nil {
+		return nil, nil, fmt.Errorf("saml.key: %w", err)
+	}
+
+	pair, err := tls.X509KeyPair(certPEM, []byte(keyPEM.Value()))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	key, ok := pair.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, nil, ErrInvalidKey
+	}
+
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return key, cert, nil
+}
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/open-cloud-initiative/glue/auth/internal/adapters/auth"
 	"github.com/open-cloud-initiative/glue/auth/internal/models"
@@ -26,23 +27,32 @@ var (
 	ErrNotAllowedOrg          = errors.New("goth: user not in allowed org")
 	ErrNoName                 = errors.New("goth: user has no display name set")
 	ErrAuthFailedParse        = errors.New("goth: failed to parse auth params, missing code or state")
+	ErrNoRefreshToken         = auth.ErrNoRefreshToken
 )
 
 const NoopEmail = ""
 
-var _ auth.Provider = (*githubProvider)(nil)
+var (
+	_ auth.Provider       = (*githubProvider)(nil)
+	_ auth.TokenRefresher = (*githubProvider)(nil)
+)
+
+// ScopeReadOrg is required to list private organization and team memberships.
+const ScopeReadOrg = "read:org"
 
 // DefaultScopes holds the default scopes used for GitHub.
 var DefaultScopes = []string{"user:email", "read:user"}
 
 type githubProvider struct {
 	id            string
+	tenant        string
 	name          string
 	clientKey     string
 	secret        string
 	callbackURL   string
 	enterpriseURL string
 	allowedOrgs   []string
+	roleMappings  []RoleMapping
 	providerType  auth.ProviderType
 	client        *http.Client
 	config        *oauth2.Config
@@ -52,10 +62,31 @@ type githubProvider struct {
 // Opt is a function that configures the GitHub provider.
 type Opt func(*githubProvider)
 
-// WithScopes sets the scopes for the GitHub provider.
+// WithID sets the ID of the GitHub provider.
+func WithID(id string) Opt {
+	return func(p *githubProvider) {
+		p.id = id
+	}
+}
+
+// WithTenant registers the GitHub provider for the organization with the slug.
+func WithTenant(tenant string) Opt {
+	return func(p *githubProvider) {
+		p.tenant = tenant
+	}
+}
+
+// WithName sets the display name of the GitHub provider.
+func WithName(name string) Opt {
+	return func(p *githubProvider) {
+		p.name = name
+	}
+}
+
+// WithScopes sets additional scopes for the GitHub provider.
 func WithScopes(scopes ...string) Opt {
 	return func(p *githubProvider) {
-		p.config.Scopes = scopes
+		p.scopes = scopes
 	}
 }
 
@@ -66,6 +97,14 @@ func WithAllowedOrgs(orgs ...string) Opt {
 	}
 }
 
+// WithRoleMappings maps GitHub organizations and teams to roles and AppMetadata.
+// The memberships are fetched and the mappings applied on every login.
+func WithRoleMappings(mappings ...RoleMapping) Opt {
+	return func(p *githubProvider) {
+		p.roleMappings = mappings
+	}
+}
+
 // WithEnterpriseURL sets the enterprise URL for the GitHub provider.
 func WithEnterpriseURL(url string) Opt {
 	return func(p *githubProvider) {
@@ -85,13 +124,17 @@ func New(clientKey, secret, callbackURL string, opts ...Opt) auth.Provider {
 		providerType:  auth.ProviderTypeOAuth2,
 		client:        auth.DefaultClient,
 		allowedOrgs:   []string{},
-		scopes:        DefaultScopes,
+		scopes:        []string{},
 	}
 
 	for _, opt := range opts {
 		opt(p)
 	}
 
+	if len(p.allowedOrgs) > 0 || len(p.roleMappings) > 0 {
+		p.scopes = append(append([]string{}, p.scopes...), ScopeReadOrg)
+	}
+
 	p.config = newConfig(p, p.scopes...)
 
 	return p
@@ -107,6 +150,11 @@ func (g *githubProvider) ID() string {
 	return g.id
 }
 
+// Tenant returns the slug of the organization of the provider.
+func (g *githubProvider) Tenant() string {
+	return g.tenant
+}
+
 // Name returns the provider's name.
 func (g *githubProvider) Name() string {
 	return g.name
@@ -155,24 +203,18 @@ func (g *githubProvider) BeginAuth(_ context.Context, _ ports.Auth, state string
 //
 //nolint:gocyclo
 func (g *githubProvider) CompleteAuth(ctx context.Context, adapter ports.Auth, params auth.AuthParams) (models.User, error) {
-	u := struct {
-		ID       int    `json:"id"`
-		Email    string `json:"email"`
-		Bio      string `json:"bio"`
-		Name     string `json:"name"`
-		Login    string `json:"login"`
-		Picture  string `json:"avatar_url"`
-		Location string `json:"location"`
-	}{}
-
-	verifier := oauth2.GenerateVerifier()
-
 	code := params.Get("code")
 	if code == "" {
-		return models.User{}, auth.ErrUnimplemented
+		return models.User{}, auth.Rejected(ErrAuthFailedParse)
+	}
+
+	token, err := g.config.Exchange(ctx, code, oauth2.VerifierOption(params.CodeVerifier()))
+
+	var rerr *oauth2.RetrieveError
+	if errors.As(err, &rerr) && rerr.Response != nil && rerr.Response.StatusCode < http.StatusInternalServerError {
+		return models.User{}, auth.Rejected(err)
 	}
 
-	token, err := g.config.Exchange(ctx, code, oauth2.SetAuthURLParam("code_verifier", verifier))
 	if err != nil {
 		return models.User{}, err
 	}
@@ -191,6 +233,8 @@ func (g *githubProvider) CompleteAuth(ctx context.Context, adapter ports.Auth, p
 		return models.User{}, err
 	}
 
+	state, _ := token.Extra("state").(string)
+
 	user := models.User{
 		Name:  gu.GetName(),
 		Email: gu.GetEmail(),
@@ -198,29 +242,29 @@ func (g *githubProvider) CompleteAuth(ctx context.Context, adapter ports.Auth, p
 		Accounts: []models.Account{
 			{
 				Type:              models.AccountTypeOAuth2,
+				Tenant:            g.Tenant(),
 				Provider:          g.ID(),
-				ProviderAccountID: cast.Ptr(strconv.Itoa(u.ID)),
+				ProviderAccountID: cast.Ptr(strconv.FormatInt(gu.GetID(), 10)),
 				AccessToken:       cast.Ptr(token.AccessToken),
 				RefreshToken:      cast.Ptr(token.RefreshToken),
-				ExpiresAt:         cast.Ptr(token.Expiry),
-				SessionState:      token.Extra("state").(string),
+				ExpiresAt:         utilx.IfElse(token.Expiry.IsZero(), nil, cast.Ptr(token.Expiry)),
+				TokenType:         cast.Ptr(token.Type()),
+				SessionState:      state,
 			},
 		},
 	}
 
-	if utilx.Empty(user.Email) && slices.Any(checkScope, g.config.Scopes...) {
-		opt := &github.ListOptions{}
+	if slices.Any(checkScope, g.config.Scopes...) {
+		emails := []*github.UserEmail{}
+		opt := &github.ListOptions{PerPage: perPage}
 
 		for {
-			emails, resp, err := gc.Users.ListEmails(ctx, opt)
+			page, resp, err := gc.Users.ListEmails(ctx, opt)
 			if err != nil {
 				return models.User{}, err
 			}
 
-			user.Email, err = checkEmail(emails...)
-			if err != nil {
-				return models.User{}, err
-			}
+			emails = append(emails, page...)
 
 			if resp.NextPage == 0 {
 				break
@@ -228,27 +272,50 @@ func (g *githubProvider) CompleteAuth(ctx context.Context, adapter ports.Auth, p
 
 			opt.Page = resp.NextPage
 		}
+
+		if utilx.Empty(user.Email) {
+			user.Email, err = checkEmail(emails...)
+			if err != nil {
+				return models.User{}, err
+			}
+		}
+
+		if isVerified(user.Email, emails...) {
+			user.EmailVerifiedAt = time.Now()
+		}
 	}
 
 	if utilx.Empty(user.Email) {
 		return user, ErrNoVerifiedPrimaryEmail
 	}
 
-	if len(g.allowedOrgs) > 0 && !slices.Any(checkOrg(ctx, gc, gu.GetLogin()), g.allowedOrgs...) {
-		return models.User{}, ErrNotAllowedOrg
-	}
+	if len(g.allowedOrgs) > 0 || len(g.roleMappings) > 0 {
+		m, err := fetchMembership(ctx, gc)
+		if err != nil {
+			return models.User{}, err
+		}
 
-	user, err = adapter.CreateUser(ctx, user)
-	if err != nil {
-		return models.User{}, err
+		if len(g.allowedOrgs) > 0 && !slices.Any(m.isMember, g.allowedOrgs...) {
+			return models.User{}, ErrNotAllowedOrg
+		}
+
+		m.apply(&user, g.roleMappings...)
 	}
 
-	user, err = adapter.GetUser(ctx, user.ID)
-	if err != nil {
-		return models.User{}, err
+	return adapter.UpsertUser(ctx, user)
+}
+
+// RefreshToken exchanges the refresh token for a new token. GitHub only issues
+// refresh tokens for apps with expiring user tokens enabled.
+func (g *githubProvider) RefreshToken(ctx context.Context, token *oauth2.Token) (*oauth2.Token, error) {
+	if utilx.Empty(token.RefreshToken) {
+		return nil, ErrNoRefreshToken
 	}
 
-	return user, nil
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, g.client)
+
+	// Only pass the refresh token, so the token source never returns the old access token.
+	return g.config.TokenSource(ctx, &oauth2.Token{RefreshToken: token.RefreshToken}).Token()
 }
 
 func newConfig(p *githubProvider, scopes ...string) *oauth2.Config {
@@ -257,7 +324,7 @@ func newConfig(p *githubProvider, scopes ...string) *oauth2.Config {
 		ClientSecret: p.secret,
 		RedirectURL:  p.callbackURL,
 		Endpoint:     endpoints.GitHub,
-		Scopes:       append(DefaultScopes, scopes...),
+		Scopes:       append(append([]string{}, DefaultScopes...), scopes...),
 	}
 
 	if utilx.NotEmpty(p.enterpriseURL) {
@@ -271,17 +338,6 @@ func checkScope(scope string) bool {
 	return strings.TrimSpace(scope) == "user" || strings.TrimSpace(scope) == "user:email"
 }
 
-func checkOrg(ctx context.Context, c *github.Client, user string) func(string) bool {
-	return func(org string) bool {
-		m, _, err := c.Organizations.IsMember(ctx, org, user)
-		if err != nil {
-			return false
-		}
-
-		return m
-	}
-}
-
 func checkEmail(emails ...*github.UserEmail) (string, error) {
 	for _, e := range emails {
 		if e.GetPrimary() && e.GetVerified() {
@@ -292,6 +348,16 @@ func checkEmail(emails ...*github.UserEmail) (string, error) {
 	return NoopEmail, ErrNoVerifiedPrimaryEmail
 }
 
+func isVerified(email string, emails ...*github.UserEmail) bool {
+	for _, e := range emails {
+		if strings.EqualFold(e.GetEmail(), email) && e.GetVerified() {
+			return true
+		}
+	}
+
+	return false
+}
+
 func githubEnterpriseConfig(url string) oauth2.Endpoint {
 	return oauth2.Endpoint{
 		AuthURL:       fmt.Sprintf("%s/login/oauth/authorize", strings.TrimSuffix(url, "/")),
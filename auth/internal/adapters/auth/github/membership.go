@@ -0,0 +1,151 @@
+package github
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/open-cloud-initiative/glue/auth/internal/models"
+
+	"github.com/google/go-github/v56/github"
+)
+
+const (
+	// MetadataOrgs is the AppMetadata key holding the user's organizations.
+	MetadataOrgs = "github_orgs"
+	// MetadataTeams is the AppMetadata key holding the user's teams as org/team.
+	MetadataTeams = "github_teams"
+
+	perPage = 100
+)
+
+// RoleMapping maps the membership in a GitHub organization or team
+// to a role and AppMetadata entries.
+type RoleMapping struct {
+	// Org is the login of the organization.
+	Org string
+	// Team is the slug of a team in the organization.
+	// If empty, the mapping matches any member of the organization.
+	Team string
+	// Role is the name of the role assigned to matching users.
+	Role string
+	// Metadata are AppMetadata entries set for matching users.
+	Metadata map[string]string
+}
+
+// membership is the set of organizations and teams of a user.
+type membership struct {
+	orgs  map[string]bool
+	teams map[string]bool
+}
+
+// fetchMembership lists the organizations and teams of the authenticated
+// user with one paginated pass each.
+func fetchMembership(ctx context.Context, gc *github.Client) (*membership, error) {
+	m := &membership{orgs: map[string]bool{}, teams: map[string]bool{}}
+
+	opt := &github.ListOptions{PerPage: perPage}
+	for {
+		orgs, resp, err := gc.Organizations.List(ctx, "", opt)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, o := range orgs {
+			m.orgs[strings.ToLower(o.GetLogin())] = true
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+
+		opt.Page = resp.NextPage
+	}
+
+	opt = &github.ListOptions{PerPage: perPage}
+	for {
+		teams, resp, err := gc.Teams.ListUserTeams(ctx, opt)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, t := range teams {
+			org := strings.ToLower(t.GetOrganization().GetLogin())
+			m.orgs[org] = true
+			m.teams[org+"/"+strings.ToLower(t.GetSlug())] = true
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+
+		opt.Page = resp.NextPage
+	}
+
+	return m, nil
+}
+
+// isMember returns true if the user is a member of the organization.
+func (m *membership) isMember(org string) bool {
+	return m.orgs[strings.ToLower(org)]
+}
+
+// matches returns true if the user matches the mapping.
+func (m *membership) matches(mapping RoleMapping) bool {
+	if mapping.Team == "" {
+		return m.isMember(mapping.Org)
+	}
+
+	return m.teams[strings.ToLower(mapping.Org)+"/"+strings.ToLower(mapping.Team)]
+}
+
+// apply sets the roles and AppMetadata of the user from the mappings.
+// The roles of all matching mappings are assigned, each once, and the
+// metadata of all matching mappings is merged in order. The keys of all
+// mappings are managed, so that the keys of mappings that no longer match
+// are removed.
+func (m *membership) apply(user *models.User, mappings ...RoleMapping) {
+	if user.AppMetadata == nil {
+		user.AppMetadata = map[string]string{}
+	}
+
+	user.AppMetadata[MetadataOrgs] = join(m.orgs)
+	user.AppMetadata[MetadataTeams] = join(m.teams)
+	user.ManagedMetadata = []string{MetadataOrgs, MetadataTeams}
+
+	roles := []string{}
+
+	for _, mapping := range mappings {
+		for k := range mapping.Metadata {
+			user.ManagedMetadata = append(user.ManagedMetadata, k)
+		}
+
+		if !m.matches(mapping) {
+			continue
+		}
+
+		if mapping.Role != "" && !slices.Contains(roles, mapping.Role) {
+			roles = append(roles, mapping.Role)
+		}
+
+		for k, v := range mapping.Metadata {
+			user.AppMetadata[k] = v
+		}
+	}
+
+	if len(mappings) > 0 {
+		user.Roles = roles
+	}
+}
+
+func join(set map[string]bool) string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return strings.Join(keys, ",")
+}
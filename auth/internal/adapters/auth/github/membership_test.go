@@ -0,0 +1,91 @@
+package github
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/open-cloud-initiative/glue/auth/internal/models"
+)
+
+func TestApply(t *testing.T) {
+	m := &membership{
+		orgs:  map[string]bool{"acme": true, "oss": true},
+		teams: map[string]bool{"acme/platform": true, "acme/sre": true},
+	}
+
+	tests := []struct {
+		name     string
+		mappings []RoleMapping
+		roles    []string
+		metadata map[string]string
+	}{
+		{
+			name:  "without mappings",
+			roles: nil,
+		},
+		{
+			name:     "no matching mapping",
+			mappings: []RoleMapping{{Org: "other", Role: "viewer"}},
+			roles:    []string{},
+		},
+		{
+			name: "overlapping org and team mappings",
+			mappings: []RoleMapping{
+				{Org: "acme", Role: "viewer"},
+				{Org: "ACME", Team: "Platform", Role: "editor"},
+				{Org: "acme", Team: "sre", Role: "admin"},
+				{Org: "acme", Team: "sales", Role: "billing"},
+			},
+			roles: []string{"viewer", "editor", "admin"},
+		},
+		{
+			name: "same role of several mappings",
+			mappings: []RoleMapping{
+				{Org: "acme", Team: "platform", Role: "editor"},
+				{Org: "acme", Role: "viewer"},
+				{Org: "acme", Team: "sre", Role: "editor"},
+				{Org: "oss", Role: "viewer"},
+			},
+			roles: []string{"editor", "viewer"},
+		},
+		{
+			name: "metadata of all matching mappings",
+			mappings: []RoleMapping{
+				{Org: "acme", Metadata: map[string]string{"tier": "member", "company": "acme"}},
+				{Org: "acme", Team: "sre", Role: "admin", Metadata: map[string]string{"tier": "oncall"}},
+				{Org: "other", Metadata: map[string]string{"partner": "true"}},
+			},
+			roles:    []string{"admin"},
+			metadata: map[string]string{"tier": "oncall", "company": "acme"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := &models.User{AppMetadata: map[string]string{"partner": "true"}}
+			m.apply(user, tt.mappings...)
+
+			if !slices.Equal(user.Roles, tt.roles) || (user.Roles == nil) != (tt.roles == nil) {
+				t.Errorf("apply() roles = %#v, want %#v", user.Roles, tt.roles)
+			}
+
+			if user.AppMetadata[MetadataOrgs] != "acme,oss" || user.AppMetadata[MetadataTeams] != "acme/platform,acme/sre" {
+				t.Errorf("apply() metadata = %v, want the orgs and teams", user.AppMetadata)
+			}
+
+			for k, v := range tt.metadata {
+				if user.AppMetadata[k] != v {
+					t.Errorf("apply() metadata %s = %q, want %q", k, user.AppMetadata[k], v)
+				}
+			}
+
+			for _, mapping := range tt.mappings {
+				for k := range mapping.Metadata {
+					if !slices.Contains(user.ManagedMetadata, k) {
+						t.Errorf("apply() managed metadata = %v, want %s", user.ManagedMetadata, k)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,298 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/open-cloud-initiative/glue/auth/internal/adapters/auth"
+	"github.com/open-cloud-initiative/glue/auth/internal/models"
+	"github.com/open-cloud-initiative/glue/auth/internal/ports"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/katallaxie/pkg/cast"
+	"github.com/katallaxie/pkg/utilx"
+	"golang.org/x/oauth2"
+)
+
+const (
+	clientID = "glue"
+	code     = "code"
+)
+
+// issuer is a fake OpenID provider. Its token endpoint answers with status,
+// or with an ID token of the claims signed by signer. Its keys publish key.
+type issuer struct {
+	srv    *httptest.Server
+	key    *rsa.PrivateKey
+	signer *rsa.PrivateKey
+	status int
+	claims map[string]any
+}
+
+func newIssuer(t *testing.T) *issuer {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	i := &issuer{key: key, signer: key, status: http.StatusOK}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, map[string]any{
+			"issuer":                                i.srv.URL,
+			"authorization_endpoint":                i.srv.URL + "/authorize",
+			"token_endpoint":                        i.srv.URL + "/token",
+			"jwks_uri":                              i.srv.URL + "/keys",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("GET /keys", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "test",
+			"alg": "RS256",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(i.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(i.key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		if i.status != http.StatusOK {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(i.status)
+			_, _ = w.Write([]byte(`{"error":"invalid_grant"}`))
+
+			return
+		}
+
+		if r.FormValue("code") != code || r.FormValue("code_verifier") == "" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":"invalid_grant"}`))
+
+			return
+		}
+
+		res := map[string]any{"access_token": "access", "token_type": "Bearer", "refresh_token": "refresh", "expires_in": 3600}
+		if i.claims != nil {
+			res["id_token"] = i.sign(t, i.claims)
+		}
+
+		writeJSON(w, res)
+	})
+
+	i.srv = httptest.NewServer(mux)
+	t.Cleanup(i.srv.Close)
+
+	return i
+}
+
+// sign returns a JWT of the claims signed with RS256.
+func (i *issuer) sign(t *testing.T, claims map[string]any) string {
+	t.Helper()
+
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "kid": "test", "typ": "JWT"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	sum := sha256.Sum256([]byte(signed))
+
+	sig, err := rsa.SignPKCS1v15(rand.Reader, i.signer, crypto.SHA256, sum[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+// provider discovers the issuer and returns an OIDC provider of it.
+func (i *issuer) provider(t *testing.T, opts ...Opt) auth.Provider {
+	t.Helper()
+
+	discovered, err := oidc.NewProvider(oidc.ClientContext(t.Context(), i.srv.Client()), i.srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return New(discovered, clientID, "secret", "https://auth.example.com/callback", opts...)
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// adapter returns the profile it is asked to upsert.
+type adapter struct {
+	ports.Auth
+}
+
+func (a *adapter) UpsertUser(_ context.Context, profile models.User) (models.User, error) {
+	return profile, nil
+}
+
+type params struct {
+	values   url.Values
+	verifier string
+}
+
+func (p *params) Get(name string) string { return p.values.Get(name) }
+func (p *params) CodeVerifier() string   { return p.verifier }
+
+func TestBeginAuth(t *testing.T) {
+	p := newIssuer(t).provider(t)
+
+	intent, err := p.BeginAuth(t.Context(), &adapter{}, "state", &params{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := intent.GetAuthURL()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q := u.Query()
+	if q.Get("state") != "state" || q.Get("client_id") != clientID || q.Get("code_challenge_method") != "S256" {
+		t.Errorf("auth URL = %s, want the state, client and PKCE challenge", raw)
+	}
+
+	if q.Get("code_challenge") != oauth2.S256ChallengeFromVerifier(intent.CodeVerifier()) {
+		t.Errorf("code_challenge = %q, want the challenge of the verifier", q.Get("code_challenge"))
+	}
+
+	if q.Get("nonce") != nonce(intent.CodeVerifier()) || q.Get("nonce") == q.Get("code_challenge") {
+		t.Errorf("nonce = %q, want the nonce of the verifier", q.Get("nonce"))
+	}
+}
+
+func TestCompleteAuth(t *testing.T) {
+	const verifier = "verifier-of-the-flow-with-enough-entropy-for-pkce"
+
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		opts     []Opt
+		code     string
+		noCode   bool
+		status   int
+		claims   func(c map[string]any)
+		noToken  bool
+		signer   *rsa.PrivateKey
+		err      error
+		rejected bool
+	}{
+		{name: "valid"},
+		{name: "tenant provider", opts: []Opt{WithID("okta"), WithTenant("acme")}},
+		{name: "missing code", noCode: true, err: ErrAuthFailedParse, rejected: true},
+		{name: "code rejected", code: "wrong", rejected: true},
+		{name: "issuer unavailable", status: http.StatusBadGateway},
+		{name: "no ID token", noToken: true, err: ErrNoIDToken},
+		{name: "other nonce", claims: func(c map[string]any) { c["nonce"] = nonce("other") }, err: ErrInvalidNonce, rejected: true},
+		{name: "no nonce", claims: func(c map[string]any) { delete(c, "nonce") }, err: ErrInvalidNonce, rejected: true},
+		{name: "other audience", claims: func(c map[string]any) { c["aud"] = "other" }, rejected: true},
+		{name: "other issuer", claims: func(c map[string]any) { c["iss"] = "https://evil.example.com" }, rejected: true},
+		{name: "expired", claims: func(c map[string]any) { c["exp"] = time.Now().Add(-time.Minute).Unix() }, rejected: true},
+		{name: "signed by another key", signer: other, rejected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i := newIssuer(t)
+			i.status = utilx.Or(tt.status, http.StatusOK)
+
+			if tt.signer != nil {
+				i.signer = tt.signer
+			}
+
+			if !tt.noToken {
+				i.claims = map[string]any{
+					"iss":            i.srv.URL,
+					"sub":            "00u1",
+					"aud":            clientID,
+					"exp":            time.Now().Add(time.Hour).Unix(),
+					"iat":            time.Now().Unix(),
+					"nonce":          nonce(verifier),
+					"email":          "ada@example.com",
+					"email_verified": true,
+					"name":           "Ada Lovelace",
+				}
+
+				if tt.claims != nil {
+					tt.claims(i.claims)
+				}
+			}
+
+			p := i.provider(t, tt.opts...)
+
+			values := url.Values{"code": {utilx.Or(tt.code, code)}}
+			if tt.noCode {
+				values = url.Values{}
+			}
+
+			user, err := p.CompleteAuth(t.Context(), &adapter{}, &params{values: values, verifier: verifier})
+
+			wantErr := tt.err != nil || tt.rejected || tt.status != 0
+			if (err != nil) != wantErr || (tt.err != nil && !errors.Is(err, tt.err)) {
+				t.Fatalf("CompleteAuth() error = %v, want %v", err, tt.err)
+			}
+
+			if errors.Is(err, auth.ErrInvalidCredentials) != tt.rejected {
+				t.Errorf("CompleteAuth() error = %v, want rejected %v", err, tt.rejected)
+			}
+
+			if wantErr {
+				return
+			}
+
+			if user.Email != "ada@example.com" || user.Name != "Ada Lovelace" {
+				t.Errorf("CompleteAuth() = %+v, want the profile of the claims", user)
+			}
+
+			// The issuer may claim any address verified, only a tenant vouches for emails.
+			if !user.EmailVerifiedAt.IsZero() {
+				t.Errorf("CompleteAuth() verified the email at %v, want unverified", user.EmailVerifiedAt)
+			}
+
+			a := user.Accounts[0]
+			if a.Provider != p.ID() || a.Tenant != p.Tenant() || cast.Value(a.ProviderAccountID) != "00u1" {
+				t.Errorf("account = %s/%s/%s, want %s/%s/00u1", a.Tenant, a.Provider, cast.Value(a.ProviderAccountID), p.Tenant(), p.ID())
+			}
+
+			if cast.Value(a.AccessToken) != "access" || cast.Value(a.RefreshToken) != "refresh" || a.ExpiresAt == nil || cast.Value(a.IDToken) == "" {
+				t.Errorf("account tokens = %+v, want the tokens of the response", a)
+			}
+		})
+	}
+}
@@ -5,10 +5,13 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/open-cloud-initiative/glue/auth/internal/models"
 	"github.com/open-cloud-initiative/glue/auth/internal/ports"
+
+	"golang.org/x/oauth2"
 )
 
 const (
@@ -35,8 +38,11 @@ var ErrNoAuthURL = errors.New("an AuthURL has not been set")
 
 // Provider needs to be implemented for each 3rd party authentication provider.
 type Provider interface {
-	// ID returns the provider's ID.
+	// ID returns the provider's ID, that is unique within its tenant.
 	ID() string
+	// Tenant returns the slug of the organization the provider is registered for,
+	// or an empty string for a global provider.
+	Tenant() string
 	// Debug sets the provider's debug mode.
 	Debug(bool)
 	// Name returns the provider's name.
@@ -49,6 +55,36 @@ type Provider interface {
 	CompleteAuth(ctx context.Context, adapter ports.Auth, params AuthParams) (models.User, error)
 }
 
+// ErrNoRefreshToken is returned by a TokenRefresher when the token has no refresh token.
+var ErrNoRefreshToken = errors.New("account has no refresh token")
+
+// TokenRefresher is implemented by providers that can refresh
+// the access tokens of their accounts.
+type TokenRefresher interface {
+	// RefreshToken exchanges the refresh token for a new token. It returns
+	// ErrNoRefreshToken if there is none, and an *oauth2.RetrieveError if the
+	// provider rejects it.
+	RefreshToken(ctx context.Context, token *oauth2.Token) (*oauth2.Token, error)
+}
+
+// ErrInvalidCredentials is wrapped by the errors of CompleteAuth for callbacks
+// the provider rejects, e.g. with a wrong code or an invalid assertion, so that
+// they can be told apart from failures of the provider or the store.
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// Rejected wraps err with ErrInvalidCredentials.
+func Rejected(err error) error {
+	return fmt.Errorf("%w: %w", ErrInvalidCredentials, err)
+}
+
+// Claimant is implemented by providers whose callbacks name the account
+// they authenticate, so that failed logins can be counted per account.
+type Claimant interface {
+	// Claim returns the provider account ID the callback claims before it is
+	// verified, or an empty string if it claims none.
+	Claim(params AuthParams) string
+}
+
 // AuthParams is the type of authentication parameters.
 type AuthParams interface {
 	//  Get returns the value of a parameter by name.
@@ -83,30 +119,111 @@ const (
 	ProviderTypeUnknown ProviderType = "unknow"
 )
 
+// ErrProviderNotFound is returned when a provider is not registered.
+var ErrProviderNotFound = errors.New("provider not found")
+
 // Providers is list of known/available providers.
 type Providers map[string]Provider
 
-var providers = Providers{}
+// Registry is a set of providers that is safe for concurrent use.
+// Providers are registered per tenant, the same ID may be used by several tenants.
+type Registry struct {
+	mu        sync.RWMutex
+	providers Providers
+}
+
+// NewRegistry returns a new registry with the given providers.
+func NewRegistry(provider ...Provider) *Registry {
+	r := &Registry{providers: Providers{}}
+	r.Add(provider...)
+
+	return r
+}
+
+// Add adds providers to the registry, replacing providers with the same tenant and ID.
+func (r *Registry) Add(provider ...Provider) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 
-// RegisterProvider adds a provider to the list of available providers for use with Goth.
-func RegisterProvider(provider ...Provider) {
 	for _, p := range provider {
-		providers[p.ID()] = p
+		r.providers[key(p)] = p
 	}
 }
 
-// GetProviders returns a list of all the providers currently in use.
-func GetProviders() Providers {
-	return providers
+// Remove removes the providers with the given IDs of the tenant from the registry.
+func (r *Registry) Remove(tenant string, id ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, i := range id {
+		delete(r.providers, models.ProviderKey(tenant, i))
+	}
 }
 
-// GetProvider returns a previously created provider. If Goth has not
-// been told to use the named provider it will return an error.
-func GetProvider(name string) (Provider, error) {
-	provider := providers[name]
-	if provider == nil {
-		return nil, fmt.Errorf("no provider for %s exists", name)
+// Replace atomically replaces all providers of the registry.
+func (r *Registry) Replace(provider ...Provider) {
+	providers := make(Providers, len(provider))
+	for _, p := range provider {
+		providers[key(p)] = p
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.providers = providers
+}
+
+// Get returns the provider with the given ID of the tenant.
+// The empty tenant selects a global provider.
+func (r *Registry) Get(tenant, id string) (Provider, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	k := models.ProviderKey(tenant, id)
+
+	provider, ok := r.providers[k]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrProviderNotFound, k)
 	}
 
 	return provider, nil
 }
+
+// List returns a copy of the providers of the tenant by ID.
+// The empty tenant lists the global providers.
+func (r *Registry) List(tenant string) Providers {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	providers := Providers{}
+	for _, p := range r.providers {
+		if p.Tenant() == tenant {
+			providers[p.ID()] = p
+		}
+	}
+
+	return providers
+}
+
+func key(p Provider) string {
+	return models.ProviderKey(p.Tenant(), p.ID())
+}
+
+// DefaultRegistry is the registry used by the package-level helpers.
+var DefaultRegistry = NewRegistry()
+
+// RegisterProvider adds a provider to the default registry.
+func RegisterProvider(provider ...Provider) {
+	DefaultRegistry.Add(provider...)
+}
+
+// GetProviders returns a copy of the global providers in the default registry.
+func GetProviders() Providers {
+	return DefaultRegistry.List("")
+}
+
+// GetProvider returns a previously registered global provider from the default registry.
+// If the provider has not been registered it will return an error.
+func GetProvider(name string) (Provider, error) {
+	return DefaultRegistry.Get("", name)
+}
@@ -0,0 +1,264 @@
+package saml
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"net/url"
+
+	"github.com/open-cloud-initiative/glue/auth/internal/adapters/auth"
+	"github.com/open-cloud-initiative/glue/auth/internal/models"
+	"github.com/open-cloud-initiative/glue/auth/internal/ports"
+
+	"github.com/crewjam/saml"
+	"github.com/katallaxie/pkg/cast"
+	"github.com/katallaxie/pkg/utilx"
+)
+
+var (
+	ErrMissingResponse = errors.New("saml: missing SAMLResponse")
+	ErrNoEmail         = errors.New("saml: assertion has no email")
+	ErrNoNameID        = errors.New("saml: assertion has no subject NameID")
+)
+
+var (
+	_ auth.Provider = (*samlProvider)(nil)
+	_ auth.Claimant = (*samlProvider)(nil)
+)
+
+// EmailAttributes are the assertion attributes that are checked for the email.
+var EmailAttributes = []string{
+	"email",
+	"mail",
+	"emailAddress",
+	"http://schemas.xmlsoap.org/ws/2005/05/identity/claims/emailaddress",
+	"urn:oid:0.9.2342.19200300.100.1.3",
+}
+
+// NameAttributes are the assertion attributes that are checked for the display name.
+var NameAttributes = []string{
+	"displayName",
+	"name",
+	"cn",
+	"http://schemas.xmlsoap.org/ws/2005/05/identity/claims/name",
+	"urn:oid:2.16.840.1.113730.3.1.241",
+}
+
+type samlProvider struct {
+	id           string
+	tenant       string
+	name         string
+	providerType auth.ProviderType
+	sp           *saml.ServiceProvider
+}
+
+// Opt is a function that configures the SAML provider.
+type Opt func(*samlProvider)
+
+// WithID sets the ID of the SAML provider.
+func WithID(id string) Opt {
+	return func(p *samlProvider) {
+		p.id = id
+	}
+}
+
+// WithTenant registers the SAML provider for the organization with the slug.
+func WithTenant(tenant string) Opt {
+	return func(p *samlProvider) {
+		p.tenant = tenant
+	}
+}
+
+// WithName sets the display name of the SAML provider.
+func WithName(name string) Opt {
+	return func(p *samlProvider) {
+		p.name = name
+	}
+}
+
+// WithKeyPair sets the service provider key and certificate.
+func WithKeyPair(key crypto.Signer, cert *x509.Certificate) Opt {
+	return func(p *samlProvider) {
+		p.sp.Key = key
+		p.sp.Certificate = cert
+	}
+}
+
+// New creates a new SAML provider.
+func New(entityID string, acsURL url.URL, idp *saml.EntityDescriptor, opts ...Opt) auth.Provider {
+	p := &samlProvider{
+		id:           "saml",
+		name:         "SAML",
+		providerType: auth.ProviderTypeSAML,
+		sp: &saml.ServiceProvider{
+			EntityID:          entityID,
+			AcsURL:            acsURL,
+			IDPMetadata:       idp,
+			AuthnNameIDFormat: saml.EmailAddressNameIDFormat,
+			HTTPClient:        auth.DefaultClient,
+		},
+	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	return p
+}
+
+// Debug sets the provider's debug mode.
+func (s *samlProvider) Debug(debug bool) {
+	// No debug implementation for SAML provider.
+}
+
+// ID returns the provider's ID.
+func (s *samlProvider) ID() string {
+	return s.id
+}
+
+// Tenant returns the slug of the organization of the provider.
+func (s *samlProvider) Tenant() string {
+	return s.tenant
+}
+
+// Name returns the provider's name.
+func (s *samlProvider) Name() string {
+	return s.name
+}
+
+// Type returns the provider's type.
+func (s *samlProvider) Type() auth.ProviderType {
+	return s.providerType
+}
+
+type authIntent struct {
+	authURL   string
+	requestID string
+}
+
+// GetAuthURL returns the URL for the authentication end-point.
+func (a *authIntent) GetAuthURL() (string, error) {
+	if a.authURL == "" {
+		return "", auth.ErrNoAuthURL
+	}
+
+	return a.authURL, nil
+}
+
+// CodeVerifier returns the ID of the AuthnRequest, which has to be
+// presented again to validate the response.
+func (a *authIntent) CodeVerifier() string {
+	return a.requestID
+}
+
+// BeginAuth starts the authentication process.
+func (s *samlProvider) BeginAuth(_ context.Context, _ ports.Auth, state string, _ auth.AuthParams) (auth.AuthIntent, error) {
+	req, err := s.sp.MakeAuthenticationRequest(
+		s.sp.GetSSOBindingLocation(saml.HTTPRedirectBinding),
+		saml.HTTPRedirectBinding,
+		saml.HTTPPostBinding,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	uri, err := req.Redirect(state, s.sp)
+	if err != nil {
+		return nil, err
+	}
+
+	return &authIntent{
+		authURL:   uri.String(),
+		requestID: req.ID,
+	}, nil
+}
+
+// CompleteAuth completes the authentication process.
+func (s *samlProvider) CompleteAuth(ctx context.Context, adapter ports.Auth, params auth.AuthParams) (models.User, error) {
+	raw := params.Get("SAMLResponse")
+	if raw == "" {
+		return models.User{}, auth.Rejected(ErrMissingResponse)
+	}
+
+	data, err := base64.StdEncoding.DecodeString(raw)
+	if err != nil {
+		return models.User{}, auth.Rejected(err)
+	}
+
+	assertion, err := s.sp.ParseXMLResponse(data, []string{params.CodeVerifier()}, s.sp.AcsURL)
+	if err != nil {
+		return models.User{}, auth.Rejected(err)
+	}
+
+	// The account is identified by the subject, never by an attribute the IdP may change.
+	if assertion.Subject == nil || assertion.Subject.NameID == nil || utilx.Empty(assertion.Subject.NameID.Value) {
+		return models.User{}, auth.Rejected(ErrNoNameID)
+	}
+
+	nameID := assertion.Subject.NameID.Value
+
+	email := utilx.Or(attribute(assertion, EmailAttributes...), nameID)
+	if utilx.Empty(email) {
+		return models.User{}, ErrNoEmail
+	}
+
+	// The email is asserted by the IdP, which may assert any address, so
+	// it is not verified. The adapter trusts the emails of a tenant provider
+	// at the domains the tenant captures.
+	user := models.User{
+		Name:  attribute(assertion, NameAttributes...),
+		Email: email,
+		Accounts: []models.Account{
+			{
+				Type:              models.AccountTypeSAML,
+				Tenant:            s.Tenant(),
+				Provider:          s.ID(),
+				ProviderAccountID: cast.Ptr(nameID),
+			},
+		},
+	}
+
+	return adapter.UpsertUser(ctx, user)
+}
+
+// Claim returns the NameID of the unverified response, unless the assertion is encrypted.
+func (s *samlProvider) Claim(params auth.AuthParams) string {
+	data, err := base64.StdEncoding.DecodeString(params.Get("SAMLResponse"))
+	if err != nil {
+		return ""
+	}
+
+	res := saml.Response{}
+	if err := xml.Unmarshal(data, &res); err != nil {
+		return ""
+	}
+
+	if res.Assertion == nil || res.Assertion.Subject == nil || res.Assertion.Subject.NameID == nil {
+		return ""
+	}
+
+	return res.Assertion.Subject.NameID.Value
+}
+
+func attribute(assertion *saml.Assertion, names ...string) string {
+	for _, stmt := range assertion.AttributeStatements {
+		for _, attr := range stmt.Attributes {
+			for _, name := range names {
+				if attr.Name != name && attr.FriendlyName != name {
+					continue
+				}
+
+				for _, v := range attr.Values {
+					if utilx.NotEmpty(v.Value) {
+						return v.Value
+					}
+				}
+			}
+		}
+	}
+
+	return ""
+}
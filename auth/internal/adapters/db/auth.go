@@ -0,0 +1,888 @@
+package db
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/open-cloud-initiative/glue/auth/internal/audit"
+	"github.com/open-cloud-initiative/glue/auth/internal/hooks"
+	"github.com/open-cloud-initiative/glue/auth/internal/models"
+	"github.com/open-cloud-initiative/glue/auth/internal/organization"
+	"github.com/open-cloud-initiative/glue/auth/internal/ports"
+	"github.com/open-cloud-initiative/glue/auth/internal/rbac"
+
+	"github.com/google/uuid"
+	"github.com/katallaxie/pkg/cast"
+	"github.com/katallaxie/pkg/dbx"
+	"github.com/katallaxie/pkg/utilx"
+	"gorm.io/gorm"
+)
+
+const (
+	tokenLength = 32
+
+	// DefaultSessionTTL is the default lifetime of a refreshed session.
+	DefaultSessionTTL = 7 * 24 * time.Hour
+)
+
+var _ ports.Auth = (*authImpl)(nil)
+
+type authImpl struct {
+	store      dbx.Database[ports.ReadTx, ports.WriteTx]
+	linkPolicy models.LinkPolicy
+	hooks      ports.Hooks
+	sessionTTL time.Duration
+}
+
+// AuthOpt is a function that configures the ports.Auth adapter.
+type AuthOpt func(*authImpl)
+
+// WithLinkPolicy sets the policy for linking new provider accounts
+// to existing users with the same email.
+func WithLinkPolicy(policy models.LinkPolicy) AuthOpt {
+	return func(a *authImpl) {
+		a.linkPolicy = policy
+	}
+}
+
+// WithHooks runs the hooks before users and sessions are created.
+func WithHooks(hooks ports.Hooks) AuthOpt {
+	return func(a *authImpl) {
+		a.hooks = hooks
+	}
+}
+
+// WithSessionTTL sets the lifetime of a session after it is refreshed.
+func WithSessionTTL(ttl time.Duration) AuthOpt {
+	return func(a *authImpl) {
+		a.sessionTTL = ttl
+	}
+}
+
+// NewAuth returns a ports.Auth adapter backed by the store.
+func NewAuth(store dbx.Database[ports.ReadTx, ports.WriteTx], opts ...AuthOpt) ports.Auth {
+	a := &authImpl{
+		store:      store,
+		linkPolicy: models.LinkPolicyVerified,
+		sessionTTL: DefaultSessionTTL,
+	}
+
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	return a
+}
+
+// CreateUser creates a new user.
+func (a *authImpl) CreateUser(ctx context.Context, user models.User) (models.User, error) {
+	err := hooks.Defer(ctx, a.hooks, func(run hooks.RunFunc) error {
+		created := user
+
+		return a.store.ReadWriteTx(ctx, func(ctx context.Context, tx ports.WriteTx) error {
+			if err := createUser(ctx, tx, run, &created); err != nil {
+				return err
+			}
+
+			user = created
+
+			return nil
+		})
+	})
+
+	return user, err
+}
+
+// createUser applies the hooks, creates a user and records it together with its accounts.
+func createUser(ctx context.Context, tx ports.WriteTx, run hooks.RunFunc, user *models.User) error {
+	if err := run(ports.HookBeforeUserCreated, user); err != nil {
+		return err
+	}
+
+	if err := tx.CreateUser(ctx, user); err != nil {
+		return err
+	}
+
+	if err := audit.Record(ctx, tx, audit.ActionUserCreated, audit.User(user.ID), audit.Diff(models.User{}, user)); err != nil {
+		return err
+	}
+
+	for _, account := range user.Accounts {
+		if err := audit.Record(ctx, tx, audit.ActionAccountLinked, audit.Account(account), nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CreateAnonymousUser creates an anonymous user.
+func (a *authImpl) CreateAnonymousUser(ctx context.Context) (models.User, error) {
+	user := models.User{IsAnonymous: true, LastSignedInAt: time.Now()}
+	user.ReauthenticatedAt = user.LastSignedInAt
+
+	return a.CreateUser(ctx, user)
+}
+
+// UpsertUser finds the user by the provider account of the profile and
+// updates it, or links or creates the user according to the link policy.
+func (a *authImpl) UpsertUser(ctx context.Context, profile models.User) (models.User, error) {
+	if len(profile.Accounts) == 0 {
+		return models.User{}, ports.ErrNoAccount
+	}
+
+	user := models.User{}
+
+	err := hooks.Defer(ctx, a.hooks, func(run hooks.RunFunc) error {
+		return a.store.ReadWriteTx(ctx, func(ctx context.Context, tx ports.WriteTx) error {
+			var err error
+			user, err = a.upsertUser(ctx, tx, run, profile)
+
+			return err
+		})
+	})
+	if err != nil {
+		return models.User{}, err
+	}
+
+	return a.GetUser(ctx, user.ID)
+}
+
+// upsertUser finds, links or creates the user of the profile.
+func (a *authImpl) upsertUser(ctx context.Context, tx ports.WriteTx, run hooks.RunFunc, profile models.User) (models.User, error) {
+	profile.Accounts = slices.Clone(profile.Accounts)
+	account := profile.Accounts[0]
+	user := models.User{}
+
+	if err := vouch(ctx, tx, &profile); err != nil {
+		return user, err
+	}
+
+	existing := models.Account{Tenant: account.Tenant, Provider: account.Provider, ProviderAccountID: account.ProviderAccountID}
+
+	err := tx.GetAccountByProvider(ctx, &existing)
+	if err == nil {
+		updateTokens(&existing, account)
+
+		if err := tx.UpdateAccount(ctx, &existing); err != nil {
+			return user, err
+		}
+
+		user.ID = cast.Value(existing.UserID)
+		if err := tx.GetUser(ctx, &user); err != nil {
+			return user, err
+		}
+
+		if user.IsBanned() {
+			return user, ports.ErrUserBanned
+		}
+
+		return user, updateProfile(ctx, tx, &user, profile)
+	}
+
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return user, err
+	}
+
+	if utilx.NotEmpty(profile.Email) {
+		user.Email = profile.Email
+
+		err = tx.GetUserByEmail(ctx, &user)
+		if err == nil {
+			if user.IsBanned() {
+				return user, ports.ErrUserBanned
+			}
+
+			if !a.canLink(profile, user) {
+				return user, ports.ErrAccountNotLinked
+			}
+
+			if err := createAccount(ctx, tx, &account, user.ID); err != nil {
+				return user, err
+			}
+
+			return user, updateProfile(ctx, tx, &user, profile)
+		}
+
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return user, err
+		}
+	}
+
+	user = profile
+	user.LastSignedInAt = time.Now()
+	user.ReauthenticatedAt = user.LastSignedInAt
+
+	if err := createUser(ctx, tx, run, &user); err != nil {
+		return user, err
+	}
+
+	return user, syncRoles(ctx, tx, user.ID, profile)
+}
+
+// convertAnonymous makes an anonymous user permanent with the profile of
+// a linked account. The user keeps its ID and metadata. If another user
+// has the email of the profile, the guest has to sign in to that user.
+func convertAnonymous(ctx context.Context, tx ports.WriteTx, run hooks.RunFunc, user *models.User, profile models.User) error {
+	before := snapshot(*user)
+
+	if utilx.NotEmpty(profile.Email) {
+		other := models.User{Email: profile.Email}
+
+		err := tx.GetUserByEmail(ctx, &other)
+		if err == nil && other.ID != user.ID {
+			return ports.ErrAccountNotLinked
+		}
+
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		user.Email = profile.Email
+	}
+
+	mergeProfile(user, profile)
+	user.IsAnonymous = false
+
+	if err := run(ports.HookBeforeUserCreated, user); err != nil {
+		return err
+	}
+
+	if err := tx.UpdateUser(ctx, user); err != nil {
+		return err
+	}
+
+	if err := audit.RecordChange(ctx, tx, audit.ActionUserUpdated, audit.User(user.ID), before, user); err != nil {
+		return err
+	}
+
+	return syncRoles(ctx, tx, user.ID, profile)
+}
+
+// updateProfile updates the user with the profile of a fresh login and records the changes.
+func updateProfile(ctx context.Context, tx ports.WriteTx, user *models.User, profile models.User) error {
+	before := snapshot(*user)
+
+	mergeProfile(user, profile)
+
+	if err := tx.UpdateUser(ctx, user); err != nil {
+		return err
+	}
+
+	if err := audit.RecordChange(ctx, tx, audit.ActionUserUpdated, audit.User(user.ID), before, user); err != nil {
+		return err
+	}
+
+	return syncRoles(ctx, tx, user.ID, profile)
+}
+
+// syncRoles assigns the roles of the profile, if its provider manages roles.
+func syncRoles(ctx context.Context, tx ports.WriteTx, userID uuid.UUID, profile models.User) error {
+	if profile.Roles == nil || len(profile.Accounts) == 0 {
+		return nil
+	}
+
+	return rbac.Sync(ctx, tx, userID, profile.Accounts[0].ProviderKey(), profile.Roles)
+}
+
+// snapshot copies the user before a change, mergeProfile and hooks update the metadata in place.
+func snapshot(user models.User) models.User {
+	user.AppMetadata = maps.Clone(user.AppMetadata)
+	user.UserMetadata = maps.Clone(user.UserMetadata)
+	return user
+}
+
+// createAccount creates a provider account of the user and records the link.
+func createAccount(ctx context.Context, tx ports.WriteTx, account *models.Account, userID uuid.UUID) error {
+	account.UserID = &userID
+	if err := tx.CreateAccount(ctx, account); err != nil {
+		return err
+	}
+
+	return audit.Record(ctx, tx, audit.ActionAccountLinked, audit.Account(*account), nil)
+}
+
+// vouch verifies the email of a profile of a tenant provider if the tenant captures
+// its domain, and otherwise drops it. Email addresses are global, so that a tenant
+// must not claim the address of a user outside the tenant, not even unverified.
+// Of the global providers only GitHub verifies emails, OIDC and SAML issuers
+// may assert any address and so never verify one.
+func vouch(ctx context.Context, tx ports.ReadTx, profile *models.User) error {
+	tenant := profile.Accounts[0].Tenant
+	if utilx.Empty(tenant) {
+		return nil
+	}
+
+	ok, err := organization.Vouches(ctx, tx, tenant, profile.Email)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		profile.Email = ""
+		profile.EmailVerifiedAt = time.Time{}
+
+		return nil
+	}
+
+	profile.EmailVerifiedAt = time.Now()
+
+	return nil
+}
+
+// canLink returns true if the new account of the profile may be linked to the
+// existing user with the same email. The email of a tenant provider is only
+// verified at the domains of the tenant, so that a tenant never links to global
+// users or the users of other tenants, whatever the policy.
+func (a *authImpl) canLink(profile, user models.User) bool {
+	if utilx.NotEmpty(profile.Accounts[0].Tenant) && profile.EmailVerifiedAt.IsZero() {
+		return false
+	}
+
+	switch a.linkPolicy {
+	case models.LinkPolicyAlways:
+		return true
+	case models.LinkPolicyVerified:
+		return !profile.EmailVerifiedAt.IsZero() && !user.EmailVerifiedAt.IsZero()
+	default:
+		return false
+	}
+}
+
+// updateTokens copies the tokens of a fresh login to the stored account.
+func updateTokens(account *models.Account, login models.Account) {
+	account.AccessToken = login.AccessToken
+	account.RefreshToken = utilx.IfElse(utilx.NotEmpty(cast.Value(login.RefreshToken)), login.RefreshToken, account.RefreshToken)
+	account.ExpiresAt = login.ExpiresAt
+	account.TokenType = login.TokenType
+	account.Scope = login.Scope
+	account.IDToken = login.IDToken
+	account.SessionState = login.SessionState
+}
+
+// mergeProfile updates the user with the profile of a fresh login.
+// Providers that manage roles set AppMetadata, which is merged. The managed
+// keys that the profile no longer sets are removed.
+func mergeProfile(user *models.User, profile models.User) {
+	user.Name = utilx.Or(profile.Name, user.Name)
+	user.Image = utilx.Or(profile.Image, user.Image)
+	user.LastSignedInAt = time.Now()
+	user.ReauthenticatedAt = user.LastSignedInAt
+
+	if user.EmailVerifiedAt.IsZero() && strings.EqualFold(user.Email, profile.Email) {
+		user.EmailVerifiedAt = profile.EmailVerifiedAt
+	}
+
+	if profile.AppMetadata != nil {
+		if user.AppMetadata == nil {
+			user.AppMetadata = map[string]string{}
+		}
+
+		for k, v := range profile.AppMetadata {
+			user.AppMetadata[k] = v
+		}
+	}
+
+	for _, k := range profile.ManagedMetadata {
+		if _, ok := profile.AppMetadata[k]; !ok {
+			delete(user.AppMetadata, k)
+		}
+	}
+}
+
+// GetUser retrieves a user by ID.
+func (a *authImpl) GetUser(ctx context.Context, id uuid.UUID) (models.User, error) {
+	user := models.User{ID: id}
+
+	err := a.store.ReadTx(ctx, func(ctx context.Context, tx ports.ReadTx) error {
+		return tx.GetUser(ctx, &user)
+	})
+
+	return user, err
+}
+
+// GetUserByEmail retrieves a user by email.
+func (a *authImpl) GetUserByEmail(ctx context.Context, email string) (models.User, error) {
+	user := models.User{Email: email}
+
+	err := a.store.ReadTx(ctx, func(ctx context.Context, tx ports.ReadTx) error {
+		return tx.GetUserByEmail(ctx, &user)
+	})
+
+	return user, err
+}
+
+// UpdateUser updates a user.
+func (a *authImpl) UpdateUser(ctx context.Context, user models.User) (models.User, error) {
+	err := a.store.ReadWriteTx(ctx, func(ctx context.Context, tx ports.WriteTx) error {
+		before := models.User{ID: user.ID}
+		if err := tx.GetUser(ctx, &before); err != nil {
+			return err
+		}
+
+		if err := tx.UpdateUser(ctx, &user); err != nil {
+			return err
+		}
+
+		return audit.RecordChange(ctx, tx, audit.ActionUserUpdated, audit.User(user.ID), before, user)
+	})
+
+	return user, err
+}
+
+// DeleteUser deletes a user by ID.
+func (a *authImpl) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	return a.store.ReadWriteTx(ctx, func(ctx context.Context, tx ports.WriteTx) error {
+		if err := tx.DeleteUser(ctx, &models.User{ID: id}); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, tx, audit.ActionUserDeleted, audit.User(id), nil)
+	})
+}
+
+// LinkAccount links an account to a user.
+func (a *authImpl) LinkAccount(ctx context.Context, accountID, userID uuid.UUID) error {
+	return a.store.ReadWriteTx(ctx, func(ctx context.Context, tx ports.WriteTx) error {
+		account := models.Account{ID: accountID}
+		if err := tx.GetAccount(ctx, &account); err != nil {
+			return err
+		}
+
+		if err := tx.LinkAccount(ctx, &account, &models.User{ID: userID}); err != nil {
+			return err
+		}
+
+		account.UserID = &userID
+
+		return audit.Record(ctx, tx, audit.ActionAccountLinked, audit.Account(account), nil)
+	})
+}
+
+// UnlinkAccount unlinks and removes an account from a user.
+// The last login method of a user can not be unlinked.
+func (a *authImpl) UnlinkAccount(ctx context.Context, accountID, userID uuid.UUID) error {
+	return a.store.ReadWriteTx(ctx, func(ctx context.Context, tx ports.WriteTx) error {
+		account := models.Account{ID: accountID}
+		if err := tx.GetAccount(ctx, &account); err != nil {
+			return err
+		}
+
+		user := models.User{ID: userID}
+		if err := tx.UnlinkAccount(ctx, &account, &user); err != nil {
+			return err
+		}
+
+		remaining, err := loginMethods(ctx, tx, &user)
+		if err != nil {
+			return err
+		}
+
+		if remaining == 0 {
+			return ports.ErrLastLoginMethod
+		}
+
+		if err := audit.Record(ctx, tx, audit.ActionAccountUnlinked, audit.Account(account), nil); err != nil {
+			return err
+		}
+
+		return tx.DeleteAccount(ctx, &account)
+	})
+}
+
+// LinkUser links the provider account of the profile to an existing user.
+func (a *authImpl) LinkUser(ctx context.Context, userID uuid.UUID, profile models.User) (models.User, error) {
+	if len(profile.Accounts) == 0 {
+		return models.User{}, ports.ErrNoAccount
+	}
+
+	err := hooks.Defer(ctx, a.hooks, func(run hooks.RunFunc) error {
+		return a.store.ReadWriteTx(ctx, func(ctx context.Context, tx ports.WriteTx) error {
+			return a.linkUser(ctx, tx, run, userID, profile)
+		})
+	})
+	if err != nil {
+		return models.User{}, err
+	}
+
+	return a.GetUser(ctx, userID)
+}
+
+// linkUser links the provider account of the profile to the user, and makes an anonymous user permanent.
+func (a *authImpl) linkUser(ctx context.Context, tx ports.WriteTx, run hooks.RunFunc, userID uuid.UUID, profile models.User) error {
+	account := profile.Accounts[0]
+
+	user := models.User{ID: userID}
+	if err := tx.GetUser(ctx, &user); err != nil {
+		return err
+	}
+
+	if user.IsBanned() {
+		return ports.ErrUserBanned
+	}
+
+	if err := vouch(ctx, tx, &profile); err != nil {
+		return err
+	}
+
+	existing := models.Account{Tenant: account.Tenant, Provider: account.Provider, ProviderAccountID: account.ProviderAccountID}
+
+	err := tx.GetAccountByProvider(ctx, &existing)
+	if err == nil {
+		if cast.Value(existing.UserID) != userID {
+			return ports.ErrAccountInUse
+		}
+
+		updateTokens(&existing, account)
+
+		return tx.UpdateAccount(ctx, &existing)
+	}
+
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	if err := createAccount(ctx, tx, &account, userID); err != nil {
+		return err
+	}
+
+	if !user.IsAnonymous {
+		return nil
+	}
+
+	return convertAnonymous(ctx, tx, run, &user, profile)
+}
+
+// BanUser bans a user until the given time and revokes all sessions and
+// provider tokens of the user.
+func (a *authImpl) BanUser(ctx context.Context, userID uuid.UUID, until time.Time, reason string) (models.User, error) {
+	user := models.User{ID: userID}
+
+	err := a.store.ReadWriteTx(ctx, func(ctx context.Context, tx ports.WriteTx) error {
+		if err := tx.GetUser(ctx, &user); err != nil {
+			return err
+		}
+
+		before := user
+		user.BannedUntil = until
+		user.BanReason = reason
+
+		if err := tx.UpdateUser(ctx, &user); err != nil {
+			return err
+		}
+
+		if err := audit.Record(ctx, tx, audit.ActionUserBanned, audit.User(userID), audit.Diff(before, user)); err != nil {
+			return err
+		}
+
+		var revoked []models.Session
+		if err := tx.DeleteUserSessions(ctx, &user, &revoked); err != nil {
+			return err
+		}
+
+		if err := audit.RecordRevoked(ctx, tx, revoked); err != nil {
+			return err
+		}
+
+		return tx.RevokeAccountTokens(ctx, &user)
+	})
+
+	return user, err
+}
+
+// UnbanUser lifts the ban of a user.
+func (a *authImpl) UnbanUser(ctx context.Context, userID uuid.UUID) (models.User, error) {
+	user := models.User{ID: userID}
+
+	err := a.store.ReadWriteTx(ctx, func(ctx context.Context, tx ports.WriteTx) error {
+		if err := tx.GetUser(ctx, &user); err != nil {
+			return err
+		}
+
+		before := user
+		user.BannedUntil = time.Time{}
+		user.BanReason = ""
+
+		if err := tx.UpdateUser(ctx, &user); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, tx, audit.ActionUserUnbanned, audit.User(userID), audit.Diff(before, user))
+	})
+
+	return user, err
+}
+
+// ListAccounts lists the accounts linked to a user.
+func (a *authImpl) ListAccounts(ctx context.Context, userID uuid.UUID) ([]models.Account, error) {
+	user := models.User{ID: userID}
+
+	err := a.store.ReadTx(ctx, func(ctx context.Context, tx ports.ReadTx) error {
+		return tx.ListAccounts(ctx, &user)
+	})
+
+	return user.Accounts, err
+}
+
+// loginMethods counts the ways a user is able to log in.
+// A verified phone number logs in with a code sent by SMS,
+// a password logs in together with the email address.
+func loginMethods(ctx context.Context, tx ports.ReadTx, user *models.User) (int, error) {
+	if err := tx.GetUser(ctx, user); err != nil {
+		return 0, err
+	}
+
+	if err := tx.ListAccounts(ctx, user); err != nil {
+		return 0, err
+	}
+
+	n := len(user.Accounts) + utilx.IfElse(user.PhoneNumberVerifiedAt.IsZero(), 0, 1)
+
+	if utilx.Empty(user.Email) {
+		return n, nil
+	}
+
+	err := tx.GetPasswordCredential(ctx, &models.PasswordCredential{UserID: user.ID})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return n, nil
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	return n + 1, nil
+}
+
+// CreateSession creates a new session of the user logged in with the provider.
+func (a *authImpl) CreateSession(ctx context.Context, userID uuid.UUID, provider string, expires time.Time) (models.Session, error) {
+	sessionToken, err := newToken()
+	if err != nil {
+		return models.Session{}, err
+	}
+
+	csrfToken, err := newToken()
+	if err != nil {
+		return models.Session{}, err
+	}
+
+	session := models.Session{
+		SessionToken: sessionToken,
+		UserID:       userID,
+		Provider:     provider,
+		ExpiresAt:    expires,
+		CsrfToken: models.CsrfToken{
+			Token:     csrfToken,
+			ExpiresAt: expires,
+		},
+	}
+
+	patch, err := a.runSessionHooks(ctx, userID)
+	if err != nil {
+		return models.Session{}, err
+	}
+
+	err = a.store.ReadWriteTx(ctx, func(ctx context.Context, tx ports.WriteTx) error {
+		user := models.User{ID: userID}
+		if err := tx.GetUser(ctx, &user); err != nil {
+			return err
+		}
+
+		if user.IsBanned() {
+			return ports.ErrUserBanned
+		}
+
+		if err := tx.ListMFAFactors(ctx, &user); err != nil {
+			return err
+		}
+
+		session.MFARequired = slices.ContainsFunc(user.MfaFactors, verifiedFactor)
+
+		if err := applyPatch(ctx, tx, &user, patch); err != nil {
+			return err
+		}
+
+		org, err := organization.Enter(ctx, tx, user, session)
+		if err != nil {
+			return err
+		}
+
+		if org != nil {
+			session.OrganizationID = &org.ID
+		}
+
+		if err := tx.CreateSession(ctx, &session); err != nil {
+			return err
+		}
+
+		if err := audit.Record(ctx, tx, audit.ActionSessionCreated, audit.Session(session), nil); err != nil {
+			return err
+		}
+
+		session.Organization = org
+
+		return nil
+	})
+
+	return session, err
+}
+
+// runSessionHooks evaluates the hooks before a session of the user is created.
+func (a *authImpl) runSessionHooks(ctx context.Context, userID uuid.UUID) (ports.HookPatch, error) {
+	if a.hooks == nil {
+		return ports.HookPatch{}, nil
+	}
+
+	user := models.User{ID: userID}
+
+	err := a.store.ReadTx(ctx, func(ctx context.Context, tx ports.ReadTx) error {
+		if err := tx.GetUser(ctx, &user); err != nil {
+			return err
+		}
+
+		return tx.ListAccounts(ctx, &user)
+	})
+	if err != nil {
+		return ports.HookPatch{}, err
+	}
+
+	return a.hooks.Run(ctx, ports.HookBeforeSessionCreated, user)
+}
+
+// applyPatch saves and records the changes of hooks to the user.
+func applyPatch(ctx context.Context, tx ports.WriteTx, user *models.User, patch ports.HookPatch) error {
+	before := snapshot(*user)
+
+	patch.Apply(user)
+
+	if maps.Equal(before.AppMetadata, user.AppMetadata) && maps.Equal(before.UserMetadata, user.UserMetadata) {
+		return nil
+	}
+
+	if err := tx.UpdateUser(ctx, user); err != nil {
+		return err
+	}
+
+	return audit.RecordChange(ctx, tx, audit.ActionUserUpdated, audit.User(user.ID), before, user)
+}
+
+// GetSession retrieves a session by session token.
+func (a *authImpl) GetSession(ctx context.Context, sessionToken string) (models.Session, error) {
+	session := models.Session{SessionToken: sessionToken}
+
+	err := a.store.ReadTx(ctx, func(ctx context.Context, tx ports.ReadTx) error {
+		return tx.GetSession(ctx, &session)
+	})
+	if err != nil {
+		return models.Session{}, err
+	}
+
+	if time.Now().After(session.ExpiresAt) {
+		return models.Session{}, ports.ErrSessionExpired
+	}
+
+	if session.User.IsBanned() {
+		return models.Session{}, ports.ErrUserBanned
+	}
+
+	return session, nil
+}
+
+// UpdateSession updates a session.
+func (a *authImpl) UpdateSession(ctx context.Context, session models.Session) (models.Session, error) {
+	err := a.store.ReadWriteTx(ctx, func(ctx context.Context, tx ports.WriteTx) error {
+		return tx.UpdateSession(ctx, &session)
+	})
+
+	return session, err
+}
+
+// RefreshSession rotates the session token and extends the session by the session TTL.
+func (a *authImpl) RefreshSession(ctx context.Context, session models.Session) (models.Session, error) {
+	sessionToken, err := newToken()
+	if err != nil {
+		return models.Session{}, err
+	}
+
+	err = a.store.ReadWriteTx(ctx, func(ctx context.Context, tx ports.WriteTx) error {
+		if err := tx.GetSession(ctx, &session); err != nil {
+			return err
+		}
+
+		if time.Now().After(session.ExpiresAt) {
+			return ports.ErrSessionExpired
+		}
+
+		if session.User.IsBanned() {
+			return ports.ErrUserBanned
+		}
+
+		session.ExpiresAt = time.Now().Add(a.sessionTTL)
+		session.SessionToken = sessionToken
+
+		return tx.UpdateSession(ctx, &session)
+	})
+
+	return session, err
+}
+
+// DeleteSession deletes a session by session token.
+func (a *authImpl) DeleteSession(ctx context.Context, sessionToken string) error {
+	return a.store.ReadWriteTx(ctx, func(ctx context.Context, tx ports.WriteTx) error {
+		session := models.Session{SessionToken: sessionToken}
+
+		err := tx.GetSession(ctx, &session)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if err := tx.DeleteSession(ctx, &session); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, tx, audit.ActionSessionRevoked, audit.Session(session), nil)
+	})
+}
+
+// CreateVerificationToken creates a new verification token.
+func (a *authImpl) CreateVerificationToken(ctx context.Context, verficationToken models.VerificationToken) (models.VerificationToken, error) {
+	err := a.store.ReadWriteTx(ctx, func(ctx context.Context, tx ports.WriteTx) error {
+		return tx.CreateVerificationToken(ctx, &verficationToken)
+	})
+
+	return verficationToken, err
+}
+
+// UseVerficationToken uses a verification token.
+func (a *authImpl) UseVerficationToken(ctx context.Context, identifier, token string) (models.VerificationToken, error) {
+	verificationToken := models.VerificationToken{Identifier: identifier, Token: token}
+
+	err := a.store.ReadWriteTx(ctx, func(ctx context.Context, tx ports.WriteTx) error {
+		return tx.UseVerificationToken(ctx, &verificationToken)
+	})
+
+	return verificationToken, err
+}
+
+func verifiedFactor(f *models.MFAFactor) bool {
+	return f.Status == models.MFAFactorStatus_MFA_FACTOR_STATUS_VERIFIED
+}
+
+func newToken() (string, error) {
+	b := make([]byte, tokenLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
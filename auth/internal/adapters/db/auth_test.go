@@ -0,0 +1,221 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/open-cloud-initiative/glue/auth/internal/models"
+	"github.com/open-cloud-initiative/glue/auth/internal/ports"
+
+	"gorm.io/gorm"
+)
+
+// store keeps the sessions in memory.
+type store struct {
+	sessions map[string]models.Session
+}
+
+func (s *store) ReadTx(ctx context.Context, fn func(context.Context, ports.ReadTx) error) error {
+	return fn(ctx, &writeTx{store: s})
+}
+
+func (s *store) ReadWriteTx(ctx context.Context, fn func(context.Context, ports.WriteTx) error) error {
+	return fn(ctx, &writeTx{store: s})
+}
+
+func (s *store) Migrate(context.Context, ...any) error {
+	return nil
+}
+
+func (s *store) Close() error {
+	return nil
+}
+
+type writeTx struct {
+	ports.WriteTx
+	store *store
+}
+
+func (t *writeTx) GetSession(_ context.Context, session *models.Session) error {
+	s, ok := t.store.sessions[session.SessionToken]
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+
+	*session = s
+
+	return nil
+}
+
+func (t *writeTx) UpdateSession(_ context.Context, session *models.Session) error {
+	for token, s := range t.store.sessions {
+		if s.ID == session.ID {
+			delete(t.store.sessions, token)
+		}
+	}
+
+	t.store.sessions[session.SessionToken] = *session
+
+	return nil
+}
+
+func TestCanLink(t *testing.T) {
+	verified := time.Now()
+
+	profile := func(tenant string, verifiedAt time.Time) models.User {
+		return models.User{
+			Email:           "ada@example.com",
+			EmailVerifiedAt: verifiedAt,
+			Accounts:        []models.Account{{Tenant: tenant, Provider: "github"}},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		policy  models.LinkPolicy
+		profile models.User
+		user    models.User
+		want    bool
+	}{
+		{
+			name:    "never",
+			policy:  models.LinkPolicyNever,
+			profile: profile("", verified),
+			user:    models.User{EmailVerifiedAt: verified},
+			want:    false,
+		},
+		{
+			name:    "verified both",
+			policy:  models.LinkPolicyVerified,
+			profile: profile("", verified),
+			user:    models.User{EmailVerifiedAt: verified},
+			want:    true,
+		},
+		{
+			name:    "verified profile only",
+			policy:  models.LinkPolicyVerified,
+			profile: profile("", verified),
+			user:    models.User{},
+			want:    false,
+		},
+		{
+			name:    "verified user only",
+			policy:  models.LinkPolicyVerified,
+			profile: profile("", time.Time{}),
+			user:    models.User{EmailVerifiedAt: verified},
+			want:    false,
+		},
+		{
+			name:    "always",
+			policy:  models.LinkPolicyAlways,
+			profile: profile("", time.Time{}),
+			user:    models.User{},
+			want:    true,
+		},
+		{
+			name:    "tenant vouched",
+			policy:  models.LinkPolicyVerified,
+			profile: profile("acme", verified),
+			user:    models.User{EmailVerifiedAt: verified},
+			want:    true,
+		},
+		{
+			name:    "tenant not vouched always",
+			policy:  models.LinkPolicyAlways,
+			profile: profile("acme", time.Time{}),
+			user:    models.User{EmailVerifiedAt: verified},
+			want:    false,
+		},
+		{
+			name:    "unknown policy",
+			policy:  models.LinkPolicy("sometimes"),
+			profile: profile("", verified),
+			user:    models.User{EmailVerifiedAt: verified},
+			want:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &authImpl{linkPolicy: tt.policy}
+
+			if got := a.canLink(tt.profile, tt.user); got != tt.want {
+				t.Errorf("canLink() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRefreshSession(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name    string
+		session models.Session
+		err     error
+	}{
+		{
+			name:    "active session",
+			session: models.Session{SessionToken: "token", CreatedAt: now.Add(-30 * 24 * time.Hour), ExpiresAt: now.Add(time.Hour)},
+		},
+		{
+			name:    "expired session",
+			session: models.Session{SessionToken: "token", CreatedAt: now.Add(-2 * time.Hour), ExpiresAt: now.Add(-time.Hour)},
+			err:     ports.ErrSessionExpired,
+		},
+		{
+			name: "banned user",
+			session: models.Session{
+				SessionToken: "token",
+				User:         models.User{BannedUntil: now.Add(time.Hour)},
+				CreatedAt:    now.Add(-time.Hour),
+				ExpiresAt:    now.Add(time.Hour),
+			},
+			err: ports.ErrUserBanned,
+		},
+		{
+			name:    "unknown session",
+			session: models.Session{SessionToken: "other"},
+			err:     gorm.ErrRecordNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &store{sessions: map[string]models.Session{tt.session.SessionToken: tt.session}}
+			a := NewAuth(s, WithSessionTTL(time.Hour))
+
+			session, err := a.RefreshSession(t.Context(), models.Session{SessionToken: "token"})
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("RefreshSession() error = %v, want %v", err, tt.err)
+			}
+
+			if tt.err != nil {
+				return
+			}
+
+			if session.SessionToken == "token" {
+				t.Error("RefreshSession() did not rotate the session token")
+			}
+
+			if _, ok := s.sessions["token"]; ok {
+				t.Error("RefreshSession() kept the previous session token")
+			}
+
+			if d := time.Until(session.ExpiresAt); d <= 59*time.Minute || d > time.Hour {
+				t.Errorf("RefreshSession() expires in %v, want the session TTL of 1h", d)
+			}
+
+			again, err := a.RefreshSession(t.Context(), session)
+			if err != nil {
+				t.Fatalf("RefreshSession() error = %v", err)
+			}
+
+			if again.ExpiresAt.Sub(session.ExpiresAt) > time.Minute {
+				t.Errorf("RefreshSession() grew the lifetime from %v to %v", session.ExpiresAt, again.ExpiresAt)
+			}
+		})
+	}
+}
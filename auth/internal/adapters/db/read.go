@@ -2,10 +2,12 @@ package db
 
 import (
 	"context"
+	"time"
 
 	"github.com/open-cloud-initiative/glue/auth/internal/models"
 	"github.com/open-cloud-initiative/glue/auth/internal/ports"
 
+	"github.com/google/uuid"
 	"github.com/katallaxie/pkg/dbx"
 	"gorm.io/gorm"
 )
@@ -28,7 +30,223 @@ func (r *readTxImpl) GetUser(ctx context.Context, user *models.User) error {
 	return r.conn.WithContext(ctx).First(user, "id = ?", user.ID).Error
 }
 
+// GetUserByEmail retrieves a user by email.
+func (r *readTxImpl) GetUserByEmail(ctx context.Context, user *models.User) error {
+	return r.conn.WithContext(ctx).First(user, "email = ?", user.Email).Error
+}
+
+// GetUserByPhone retrieves a user by verified phone number.
+func (r *readTxImpl) GetUserByPhone(ctx context.Context, user *models.User) error {
+	return r.conn.WithContext(ctx).
+		First(user, "phone_number = ? AND phone_number_verified_at > ?", user.PhoneNumber, time.Time{}).Error
+}
+
+// GetPasswordCredential retrieves the password of a user by user ID.
+func (r *readTxImpl) GetPasswordCredential(ctx context.Context, credential *models.PasswordCredential) error {
+	return r.conn.WithContext(ctx).First(credential, "user_id = ?", credential.UserID).Error
+}
+
+// ListMFAFactors retrieves the MFA factors of a user into user.MfaFactors.
+func (r *readTxImpl) ListMFAFactors(ctx context.Context, user *models.User) error {
+	return r.conn.WithContext(ctx).Order("created_at").Find(&user.MfaFactors, "user_id = ?", user.ID).Error
+}
+
 // GetAccount retrieves an external account by ID.
 func (r *readTxImpl) GetAccount(ctx context.Context, account *models.Account) error {
 	return r.conn.WithContext(ctx).First(account, "id = ?", account.ID).Error
 }
+
+// ListAccounts retrieves the accounts of a user into user.Accounts.
+func (r *readTxImpl) ListAccounts(ctx context.Context, user *models.User) error {
+	return r.conn.WithContext(ctx).Order("created_at").Find(&user.Accounts, "user_id = ?", user.ID).Error
+}
+
+// ListAccountsAfter retrieves up to limit accounts, including deleted ones, ordered by ID after the given ID.
+func (r *readTxImpl) ListAccountsAfter(ctx context.Context, after uuid.UUID, limit int, accounts *[]models.Account) error {
+	return r.conn.WithContext(ctx).Unscoped().Order("id").Limit(limit).Find(accounts, "id > ?", after).Error
+}
+
+// GetAccountByProvider retrieves an external account by tenant, provider and provider account ID.
+func (r *readTxImpl) GetAccountByProvider(ctx context.Context, account *models.Account) error {
+	return r.conn.WithContext(ctx).
+		First(account, "tenant = ? AND provider = ? AND provider_account_id = ?", account.Tenant, account.Provider, account.ProviderAccountID).Error
+}
+
+// GetUserAccount retrieves the most recently used account of a user with a provider of a tenant.
+func (r *readTxImpl) GetUserAccount(ctx context.Context, account *models.Account) error {
+	return r.conn.WithContext(ctx).Order("updated_at DESC").
+		First(account, "user_id = ? AND tenant = ? AND provider = ?", account.UserID, account.Tenant, account.Provider).Error
+}
+
+// GetSession retrieves a session by session token.
+func (r *readTxImpl) GetSession(ctx context.Context, session *models.Session) error {
+	return r.conn.WithContext(ctx).Preload("User").Preload("Organization.Domains").First(session, "session_token = ?", session.SessionToken).Error
+}
+
+// ListAuditEvents retrieves the audit events matching the filter in the order they were written.
+func (r *readTxImpl) ListAuditEvents(ctx context.Context, filter ports.AuditFilter, events *[]models.AuditEvent) error {
+	query := r.conn.WithContext(ctx).Where("id > ?", filter.After)
+
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+
+	if filter.ActorID != uuid.Nil {
+		query = query.Where("actor_id = ?", filter.ActorID)
+	}
+
+	if filter.UserID != uuid.Nil {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+
+	if !filter.Since.IsZero() {
+		query = query.Where("created_at >= ?", filter.Since)
+	}
+
+	if !filter.Until.IsZero() {
+		query = query.Where("created_at < ?", filter.Until)
+	}
+
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+
+	return query.Order("id").Find(events).Error
+}
+
+// GetAuditEvent retrieves an audit event by ID.
+func (r *readTxImpl) GetAuditEvent(ctx context.Context, event *models.AuditEvent) error {
+	return r.conn.WithContext(ctx).First(event, "id = ?", event.ID).Error
+}
+
+// GetLastAuditEvent retrieves the last chained audit event.
+func (r *readTxImpl) GetLastAuditEvent(ctx context.Context, event *models.AuditEvent) error {
+	return r.conn.WithContext(ctx).Where("seq IS NOT NULL").Order("seq DESC").First(event).Error
+}
+
+// ListChainedAuditEvents retrieves up to limit chained audit events after the position in the chain, in chain order.
+func (r *readTxImpl) ListChainedAuditEvents(ctx context.Context, after int64, limit int, events *[]models.AuditEvent) error {
+	return r.conn.WithContext(ctx).Where("seq > ?", after).Order("seq").Limit(limit).Find(events).Error
+}
+
+// ListUnchainedAuditEvents retrieves up to limit audit events that are not chained yet, in the order they were written.
+func (r *readTxImpl) ListUnchainedAuditEvents(ctx context.Context, limit int, events *[]models.AuditEvent) error {
+	return r.conn.WithContext(ctx).Where("seq IS NULL").Order("id").Limit(limit).Find(events).Error
+}
+
+// ListAuditCheckpoints retrieves the checkpoints after the checkpoint with the ID in the order they were written.
+func (r *readTxImpl) ListAuditCheckpoints(ctx context.Context, after int64, limit int, checkpoints *[]models.AuditCheckpoint) error {
+	return r.conn.WithContext(ctx).Where("id > ?", after).Order("id").Limit(limit).Find(checkpoints).Error
+}
+
+// GetLastAuditCheckpoint retrieves the last audit checkpoint.
+func (r *readTxImpl) GetLastAuditCheckpoint(ctx context.Context, checkpoint *models.AuditCheckpoint) error {
+	return r.conn.WithContext(ctx).Last(checkpoint).Error
+}
+
+// GetOutboxEvent retrieves an outbox event by ID.
+func (r *readTxImpl) GetOutboxEvent(ctx context.Context, event *models.OutboxEvent) error {
+	return r.conn.WithContext(ctx).First(event, "id = ?", event.ID).Error
+}
+
+// ListWebhookDeliveries retrieves the webhook deliveries matching the filter ordered by ID.
+func (r *readTxImpl) ListWebhookDeliveries(ctx context.Context, filter ports.WebhookFilter, deliveries *[]models.WebhookDelivery) error {
+	query := r.conn.WithContext(ctx).Where("id > ?", filter.After)
+
+	if filter.EventID != 0 {
+		query = query.Where("event_id = ?", filter.EventID)
+	}
+
+	if filter.Endpoint != "" {
+		query = query.Where("endpoint = ?", filter.Endpoint)
+	}
+
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+
+	return query.Order("id").Find(deliveries).Error
+}
+
+// ListPermissions retrieves all permissions ordered by name.
+func (r *readTxImpl) ListPermissions(ctx context.Context, permissions *[]models.Permission) error {
+	return r.conn.WithContext(ctx).Order("name").Find(permissions).Error
+}
+
+// GetRoleByName retrieves a role with its permissions by name.
+func (r *readTxImpl) GetRoleByName(ctx context.Context, role *models.Role) error {
+	return r.conn.WithContext(ctx).Preload("Permissions").First(role, "name = ?", role.Name).Error
+}
+
+// ListRoles retrieves all roles with their permissions ordered by name.
+func (r *readTxImpl) ListRoles(ctx context.Context, roles *[]models.Role) error {
+	return r.conn.WithContext(ctx).Preload("Permissions").Order("name").Find(roles).Error
+}
+
+// ListUserRoles retrieves the roles of a user with their permissions ordered by name.
+func (r *readTxImpl) ListUserRoles(ctx context.Context, userID uuid.UUID, roles *[]models.Role) error {
+	return r.conn.WithContext(ctx).
+		Preload("Permissions").
+		Joins("JOIN user_roles ON user_roles.role_id = roles.id").
+		Where("user_roles.user_id = ?", userID).
+		Order("roles.name").
+		Find(roles).Error
+}
+
+// ListUserRoleAssignments retrieves the role assignments of a user.
+func (r *readTxImpl) ListUserRoleAssignments(ctx context.Context, userID uuid.UUID, assignments *[]models.UserRole) error {
+	return r.conn.WithContext(ctx).Order("created_at").Find(assignments, "user_id = ?", userID).Error
+}
+
+// GetOrganization retrieves an organization with its domains by ID.
+func (r *readTxImpl) GetOrganization(ctx context.Context, org *models.Organization) error {
+	return r.conn.WithContext(ctx).Preload("Domains").First(org, "id = ?", org.ID).Error
+}
+
+// GetOrganizationBySlug retrieves an organization with its domains by slug.
+func (r *readTxImpl) GetOrganizationBySlug(ctx context.Context, org *models.Organization) error {
+	return r.conn.WithContext(ctx).Preload("Domains").First(org, "slug = ?", org.Slug).Error
+}
+
+// GetOrganizationByDomain retrieves the organization with its domains that captures the email domain.
+func (r *readTxImpl) GetOrganizationByDomain(ctx context.Context, domain string, org *models.Organization) error {
+	return r.conn.WithContext(ctx).
+		Preload("Domains").
+		Joins("JOIN organization_domains ON organization_domains.organization_id = organizations.id").
+		Where("organization_domains.domain = ?", domain).
+		First(org).Error
+}
+
+// ListOrganizations retrieves all organizations with their domains ordered by slug.
+func (r *readTxImpl) ListOrganizations(ctx context.Context, orgs *[]models.Organization) error {
+	return r.conn.WithContext(ctx).Preload("Domains").Order("slug").Find(orgs).Error
+}
+
+// GetMembership retrieves the membership of a user in an organization with its role.
+func (r *readTxImpl) GetMembership(ctx context.Context, membership *models.Membership) error {
+	return r.conn.WithContext(ctx).
+		Preload("Role.Permissions").
+		First(membership, "organization_id = ? AND user_id = ?", membership.OrganizationID, membership.UserID).Error
+}
+
+// ListMemberships retrieves the memberships of a user with their organizations and roles in the order they were created.
+func (r *readTxImpl) ListMemberships(ctx context.Context, userID uuid.UUID, memberships *[]models.Membership) error {
+	return r.conn.WithContext(ctx).
+		Preload("Organization.Domains").
+		Preload("Role.Permissions").
+		Order("created_at").
+		Find(memberships, "user_id = ?", userID).Error
+}
+
+// ListMembers retrieves the memberships of an organization with their users and roles in the order they were created.
+func (r *readTxImpl) ListMembers(ctx context.Context, orgID uuid.UUID, memberships *[]models.Membership) error {
+	return r.conn.WithContext(ctx).
+		Preload("User").
+		Preload("Role.Permissions").
+		Order("created_at").
+		Find(memberships, "organization_id = ?", orgID).Error
+}
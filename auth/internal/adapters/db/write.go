@@ -2,24 +2,30 @@ package db
 
 import (
 	"context"
+	"time"
 
 	"github.com/open-cloud-initiative/glue/auth/internal/models"
 	"github.com/open-cloud-initiative/glue/auth/internal/ports"
 
 	"github.com/katallaxie/pkg/dbx"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 var _ ports.WriteTx = (*writeTxImpl)(nil)
 
+// auditLockKey is the key of the Postgres advisory lock that serializes audit events.
+const auditLockKey int64 = 0x676c756561756474 // "glueaudt"
+
 type writeTxImpl struct {
+	readTxImpl
 	conn *gorm.DB
 }
 
 // NewWriteTx ...
 func NewWriteTx() dbx.ReadWriteTxFactory[ports.WriteTx] {
 	return func(db *gorm.DB) (ports.WriteTx, error) {
-		return &writeTxImpl{conn: db}, nil
+		return &writeTxImpl{readTxImpl: readTxImpl{conn: db}, conn: db}, nil
 	}
 }
 
@@ -63,7 +69,361 @@ func (w *writeTxImpl) UpdateAccount(ctx context.Context, account *models.Account
 	return w.conn.WithContext(ctx).Save(account).Error
 }
 
+// LockAccount retrieves an external account by ID and locks it until the transaction ends.
+func (w *writeTxImpl) LockAccount(ctx context.Context, account *models.Account) error {
+	return w.conn.WithContext(ctx).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(account, "id = ?", account.ID).Error
+}
+
+// UpdateAccountTokens rewrites the tokens of an external account.
+func (w *writeTxImpl) UpdateAccountTokens(ctx context.Context, account *models.Account) error {
+	return w.conn.WithContext(ctx).Unscoped().Model(account).
+		Select("access_token", "refresh_token", "id_token", "expires_at", "token_type", "scope").
+		Updates(account).Error
+}
+
 // DeleteAccount deletes an external account by ID.
+// Accounts are hard deleted to drop the tokens and free the provider account ID.
 func (w *writeTxImpl) DeleteAccount(ctx context.Context, account *models.Account) error {
-	return w.conn.WithContext(ctx).Delete(account, "id = ?", account.ID).Error
+	return w.conn.WithContext(ctx).Unscoped().Delete(account, "id = ?", account.ID).Error
+}
+
+// RevokeAccountTokens removes the provider tokens of all accounts of a user.
+func (w *writeTxImpl) RevokeAccountTokens(ctx context.Context, user *models.User) error {
+	return w.conn.WithContext(ctx).Model(&models.Account{}).Where("user_id = ?", user.ID).
+		Updates(map[string]any{"access_token": nil, "refresh_token": nil, "id_token": nil}).Error
+}
+
+// CreateMFAFactor creates a new MFA factor.
+func (w *writeTxImpl) CreateMFAFactor(ctx context.Context, factor *models.MFAFactor) error {
+	return w.conn.WithContext(ctx).Create(factor).Error
+}
+
+// UpdateMFAFactor updates an existing MFA factor.
+func (w *writeTxImpl) UpdateMFAFactor(ctx context.Context, factor *models.MFAFactor) error {
+	return w.conn.WithContext(ctx).Save(factor).Error
+}
+
+// DeleteMFAFactor deletes an MFA factor of a user by ID.
+func (w *writeTxImpl) DeleteMFAFactor(ctx context.Context, factor *models.MFAFactor) error {
+	res := w.conn.WithContext(ctx).Delete(factor, "id = ? AND user_id = ?", factor.Id, factor.UserID)
+	if res.Error == nil && res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	return res.Error
+}
+
+// SavePasswordCredential creates or replaces the password of a user.
+func (w *writeTxImpl) SavePasswordCredential(ctx context.Context, credential *models.PasswordCredential) error {
+	return w.conn.WithContext(ctx).Omit(clause.Associations).
+		Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "user_id"}}, DoUpdates: clause.AssignmentColumns([]string{"hash", "updated_at"})}).
+		Create(credential).Error
+}
+
+// CreateSession creates a new session.
+func (w *writeTxImpl) CreateSession(ctx context.Context, session *models.Session) error {
+	return w.conn.WithContext(ctx).Omit("Organization").Create(session).Error
+}
+
+// UpdateSession updates an existing session.
+func (w *writeTxImpl) UpdateSession(ctx context.Context, session *models.Session) error {
+	return w.conn.WithContext(ctx).Omit("User", "Organization").Save(session).Error
+}
+
+// DeleteSession deletes a session by session token.
+func (w *writeTxImpl) DeleteSession(ctx context.Context, session *models.Session) error {
+	return w.conn.WithContext(ctx).Delete(session, "session_token = ?", session.SessionToken).Error
+}
+
+// DeleteUserSessions deletes all sessions of a user and returns them in sessions.
+func (w *writeTxImpl) DeleteUserSessions(ctx context.Context, user *models.User, sessions *[]models.Session) error {
+	return w.conn.WithContext(ctx).Raw(`UPDATE sessions SET deleted_at = @now
+WHERE user_id = @user AND deleted_at IS NULL
+RETURNING *`, map[string]any{
+		"user": user.ID,
+		"now":  time.Now(),
+	}).Scan(sessions).Error
+}
+
+// DeleteOtherSessions deletes all sessions of the user of the session but the
+// session, and returns them in sessions.
+func (w *writeTxImpl) DeleteOtherSessions(ctx context.Context, session *models.Session, sessions *[]models.Session) error {
+	return w.conn.WithContext(ctx).Raw(`UPDATE sessions SET deleted_at = @now
+WHERE user_id = @user AND session_token <> @token AND deleted_at IS NULL
+RETURNING *`, map[string]any{
+		"user":  session.UserID,
+		"token": session.SessionToken,
+		"now":   time.Now(),
+	}).Scan(sessions).Error
+}
+
+// CreateVerificationToken creates a new verification token.
+func (w *writeTxImpl) CreateVerificationToken(ctx context.Context, token *models.VerificationToken) error {
+	return w.conn.WithContext(ctx).Create(token).Error
+}
+
+// UseVerificationToken retrieves and deletes a verification token by identifier and token.
+func (w *writeTxImpl) UseVerificationToken(ctx context.Context, token *models.VerificationToken) error {
+	err := w.conn.WithContext(ctx).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(token, "identifier = ? AND token = ? AND expires_at > ?", token.Identifier, token.Token, time.Now()).Error
+	if err != nil {
+		return err
+	}
+
+	return w.conn.WithContext(ctx).Unscoped().Delete(token).Error
+}
+
+// ConsumeVerificationToken retrieves and deletes an unexpired verification token by token.
+func (w *writeTxImpl) ConsumeVerificationToken(ctx context.Context, token *models.VerificationToken) error {
+	err := w.conn.WithContext(ctx).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(token, "token = ? AND expires_at > ?", token.Token, time.Now()).Error
+	if err != nil {
+		return err
+	}
+
+	return w.conn.WithContext(ctx).Unscoped().Delete(token).Error
+}
+
+// DeleteVerificationTokens deletes all verification tokens of an identifier.
+func (w *writeTxImpl) DeleteVerificationTokens(ctx context.Context, identifier string) error {
+	return w.conn.WithContext(ctx).Unscoped().Delete(&models.VerificationToken{}, "identifier = ?", identifier).Error
+}
+
+// RecordLoginFailure counts a failed attempt of the counter with the subject and IP of attempt
+// and retrieves the counter. Counters with no failure since the given time start over.
+func (w *writeTxImpl) RecordLoginFailure(ctx context.Context, attempt *models.LoginAttempt, since time.Time) error {
+	now := time.Now()
+
+	return w.conn.WithContext(ctx).Raw(`INSERT INTO login_attempts (subject, ip, failures, last_failure_at, locked_until, expires_at, created_at, updated_at)
+VALUES (@subject, @ip, 1, @now, @zero, @now, @now, @now)
+ON CONFLICT (subject, ip) DO UPDATE SET
+	failures = CASE WHEN login_attempts.last_failure_at < @since THEN 1 ELSE login_attempts.failures + 1 END,
+	last_failure_at = EXCLUDED.last_failure_at,
+	updated_at = EXCLUDED.updated_at
+RETURNING *`, map[string]any{
+		"subject": attempt.Subject,
+		"ip":      attempt.IP,
+		"now":     now,
+		"zero":    time.Time{},
+		"since":   since,
+	}).Scan(attempt).Error
+}
+
+// RefundLoginFailure uncounts a failed attempt of the counter with the subject and IP of attempt.
+func (w *writeTxImpl) RefundLoginFailure(ctx context.Context, attempt *models.LoginAttempt) error {
+	return w.conn.WithContext(ctx).Raw(`UPDATE login_attempts SET failures = GREATEST(failures - 1, 0), updated_at = @now
+WHERE subject = @subject AND ip = @ip
+RETURNING *`, map[string]any{
+		"subject": attempt.Subject,
+		"ip":      attempt.IP,
+		"now":     time.Now(),
+	}).Scan(attempt).Error
+}
+
+// UpdateLoginAttempt updates an existing attempt counter.
+func (w *writeTxImpl) UpdateLoginAttempt(ctx context.Context, attempt *models.LoginAttempt) error {
+	return w.conn.WithContext(ctx).Save(attempt).Error
+}
+
+// DeleteSubjectLoginAttempts deletes the attempt counters of the subjects.
+func (w *writeTxImpl) DeleteSubjectLoginAttempts(ctx context.Context, subjects ...string) error {
+	return w.conn.WithContext(ctx).Delete(&models.LoginAttempt{}, "subject IN ?", subjects).Error
+}
+
+// DeleteIPLoginAttempts deletes the attempt counters of an IP.
+func (w *writeTxImpl) DeleteIPLoginAttempts(ctx context.Context, ip string) error {
+	return w.conn.WithContext(ctx).Delete(&models.LoginAttempt{}, "ip = ?", ip).Error
+}
+
+// LockRateLimit retrieves the state of a rate limit key and locks it until the transaction ends.
+func (w *writeTxImpl) LockRateLimit(ctx context.Context, limit *models.RateLimit) error {
+	conn := w.conn.WithContext(ctx)
+
+	if err := conn.Clauses(clause.OnConflict{DoNothing: true}).Create(limit).Error; err != nil {
+		return err
+	}
+
+	return conn.Clauses(clause.Locking{Strength: "UPDATE"}).First(limit, "key = ?", limit.Key).Error
+}
+
+// UpdateRateLimit updates the state of a rate limit key.
+func (w *writeTxImpl) UpdateRateLimit(ctx context.Context, limit *models.RateLimit) error {
+	return w.conn.WithContext(ctx).Save(limit).Error
+}
+
+// LockAuditLog locks the audit log until the transaction ends.
+func (w *writeTxImpl) LockAuditLog(ctx context.Context) error {
+	return w.conn.WithContext(ctx).Exec("SELECT pg_advisory_xact_lock(?)", auditLockKey).Error
+}
+
+// ChainAuditEvent sets the position in the chain, the previous hash and the hash of an audit event.
+func (w *writeTxImpl) ChainAuditEvent(ctx context.Context, event *models.AuditEvent) error {
+	return w.conn.WithContext(ctx).Model(event).
+		Updates(map[string]any{"seq": event.Seq, "prev_hash": event.PrevHash, "hash": event.Hash}).Error
+}
+
+// CreateAuditEvent appends an audit event.
+func (w *writeTxImpl) CreateAuditEvent(ctx context.Context, event *models.AuditEvent) error {
+	return w.conn.WithContext(ctx).Create(event).Error
+}
+
+// CreateAuditCheckpoint appends an audit checkpoint.
+func (w *writeTxImpl) CreateAuditCheckpoint(ctx context.Context, checkpoint *models.AuditCheckpoint) error {
+	return w.conn.WithContext(ctx).Create(checkpoint).Error
+}
+
+// CreateOutboxEvent appends an outbox event.
+func (w *writeTxImpl) CreateOutboxEvent(ctx context.Context, event *models.OutboxEvent) error {
+	return w.conn.WithContext(ctx).Create(event).Error
+}
+
+// LockUndispatchedOutboxEvents retrieves up to limit outbox events without deliveries and locks them.
+func (w *writeTxImpl) LockUndispatchedOutboxEvents(ctx context.Context, limit int, events *[]models.OutboxEvent) error {
+	return w.conn.WithContext(ctx).
+		Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+		Where("dispatched_at IS NULL").
+		Order("id").
+		Limit(limit).
+		Find(events).Error
+}
+
+// UpdateOutboxEvent updates an existing outbox event.
+func (w *writeTxImpl) UpdateOutboxEvent(ctx context.Context, event *models.OutboxEvent) error {
+	return w.conn.WithContext(ctx).Save(event).Error
+}
+
+// CreateWebhookDelivery creates a webhook delivery, unless the event already has a delivery to the endpoint.
+func (w *writeTxImpl) CreateWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	return w.conn.WithContext(ctx).
+		Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "event_id"}, {Name: "endpoint"}}, DoNothing: true}).
+		Create(delivery).Error
+}
+
+// LockDueWebhookDeliveries retrieves up to limit pending deliveries due at now and locks them.
+func (w *writeTxImpl) LockDueWebhookDeliveries(ctx context.Context, now time.Time, limit int, deliveries *[]models.WebhookDelivery) error {
+	return w.conn.WithContext(ctx).
+		Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+		Where("status = ? AND next_attempt_at <= ?", models.WebhookPending, now).
+		Order("next_attempt_at").
+		Limit(limit).
+		Find(deliveries).Error
+}
+
+// UpdateWebhookDelivery updates an existing webhook delivery.
+func (w *writeTxImpl) UpdateWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	return w.conn.WithContext(ctx).Save(delivery).Error
+}
+
+// SavePermission creates a permission or updates its description.
+func (w *writeTxImpl) SavePermission(ctx context.Context, permission *models.Permission) error {
+	return w.conn.WithContext(ctx).
+		Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "name"}}, DoUpdates: clause.AssignmentColumns([]string{"description"})}).
+		Create(permission).Error
+}
+
+// CreateRole creates a role with its permissions.
+func (w *writeTxImpl) CreateRole(ctx context.Context, role *models.Role) error {
+	return w.conn.WithContext(ctx).Create(role).Error
+}
+
+// UpdateRole updates a role and replaces its permissions.
+func (w *writeTxImpl) UpdateRole(ctx context.Context, role *models.Role) error {
+	if err := w.conn.WithContext(ctx).Omit("Permissions").Save(role).Error; err != nil {
+		return err
+	}
+
+	if err := w.conn.WithContext(ctx).Delete(&models.RolePermission{}, "role_id = ?", role.ID).Error; err != nil {
+		return err
+	}
+
+	for i := range role.Permissions {
+		role.Permissions[i].RoleID = role.ID
+	}
+
+	if len(role.Permissions) == 0 {
+		return nil
+	}
+
+	return w.conn.WithContext(ctx).Create(&role.Permissions).Error
+}
+
+// DeleteRole deletes a role by ID together with its assignments.
+func (w *writeTxImpl) DeleteRole(ctx context.Context, role *models.Role) error {
+	return w.conn.WithContext(ctx).Delete(role, "id = ?", role.ID).Error
+}
+
+// AssignRole assigns a role to a user, unless the user already has the role.
+func (w *writeTxImpl) AssignRole(ctx context.Context, assignment *models.UserRole) error {
+	return w.conn.WithContext(ctx).
+		Omit("User", "Role").
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(assignment).Error
+}
+
+// UnassignRole removes a role from a user.
+func (w *writeTxImpl) UnassignRole(ctx context.Context, assignment *models.UserRole) error {
+	return w.conn.WithContext(ctx).
+		Delete(&models.UserRole{}, "user_id = ? AND role_id = ?", assignment.UserID, assignment.RoleID).Error
+}
+
+// CreateOrganization creates an organization with its domains.
+func (w *writeTxImpl) CreateOrganization(ctx context.Context, org *models.Organization) error {
+	return w.conn.WithContext(ctx).Create(org).Error
+}
+
+// UpdateOrganization updates an organization and replaces its domains.
+func (w *writeTxImpl) UpdateOrganization(ctx context.Context, org *models.Organization) error {
+	if err := w.conn.WithContext(ctx).Omit("Domains").Save(org).Error; err != nil {
+		return err
+	}
+
+	if err := w.conn.WithContext(ctx).Delete(&models.OrganizationDomain{}, "organization_id = ?", org.ID).Error; err != nil {
+		return err
+	}
+
+	for i := range org.Domains {
+		org.Domains[i].OrganizationID = org.ID
+	}
+
+	if len(org.Domains) == 0 {
+		return nil
+	}
+
+	return w.conn.WithContext(ctx).Create(&org.Domains).Error
+}
+
+// DeleteOrganization deletes an organization by ID together with its domains and memberships.
+func (w *writeTxImpl) DeleteOrganization(ctx context.Context, org *models.Organization) error {
+	return w.conn.WithContext(ctx).Delete(org, "id = ?", org.ID).Error
+}
+
+// CreateMembership creates a membership.
+func (w *writeTxImpl) CreateMembership(ctx context.Context, membership *models.Membership) error {
+	return w.conn.WithContext(ctx).Omit("Organization", "User", "Role").Create(membership).Error
+}
+
+// UpdateMembership updates the role of a membership.
+func (w *writeTxImpl) UpdateMembership(ctx context.Context, membership *models.Membership) error {
+	return w.conn.WithContext(ctx).
+		Model(&models.Membership{}).
+		Where("organization_id = ? AND user_id = ?", membership.OrganizationID, membership.UserID).
+		Updates(map[string]any{"role_id": membership.RoleID, "updated_at": time.Now()}).Error
+}
+
+// DeleteMembership deletes a membership and leaves the organization in the sessions of the user.
+func (w *writeTxImpl) DeleteMembership(ctx context.Context, membership *models.Membership) error {
+	err := w.conn.WithContext(ctx).
+		Model(&models.Session{}).
+		Where("user_id = ? AND organization_id = ?", membership.UserID, membership.OrganizationID).
+		Update("organization_id", nil).Error
+	if err != nil {
+		return err
+	}
+
+	return w.conn.WithContext(ctx).
+		Delete(&models.Membership{}, "organization_id = ? AND user_id = ?", membership.OrganizationID, membership.UserID).Error
 }
@@ -0,0 +1,26 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/open-cloud-initiative/glue/auth/internal/ports"
+)
+
+var _ ports.Mailer = (*logMailer)(nil)
+
+type logMailer struct {
+	w io.Writer
+}
+
+// NewLog returns a mailer that writes emails to w instead of sending them, e.g. for development.
+func NewLog(w io.Writer) ports.Mailer {
+	return &logMailer{w: w}
+}
+
+// Send writes an email.
+func (m *logMailer) Send(_ context.Context, mail ports.Mail) error {
+	_, err := fmt.Fprintf(m.w, "mail: to %s: %s\n%s\n", mail.To, mail.Subject, mail.Text)
+	return err
+}
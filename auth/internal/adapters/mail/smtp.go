@@ -0,0 +1,82 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"time"
+
+	"github.com/open-cloud-initiative/glue/auth/internal/ports"
+	"github.com/open-cloud-initiative/glue/auth/internal/secrets"
+
+	"github.com/google/uuid"
+)
+
+var _ ports.Mailer = (*smtpMailer)(nil)
+
+type smtpMailer struct {
+	addr     string
+	host     string
+	from     string
+	username string
+	password secrets.Secret
+}
+
+// Opt is a function that configures the SMTP mailer.
+type Opt func(*smtpMailer)
+
+// WithAuth authenticates with the SMTP server. The server must support
+// TLS unless it runs on localhost.
+func WithAuth(username string, password secrets.Secret) Opt {
+	return func(m *smtpMailer) {
+		m.username = username
+		m.password = password
+	}
+}
+
+// NewSMTP returns a mailer that sends emails with the SMTP server at host and port.
+// The connection is upgraded with STARTTLS if the server supports it.
+func NewSMTP(host string, port int, from string, opts ...Opt) ports.Mailer {
+	m := &smtpMailer{
+		addr: net.JoinHostPort(host, strconv.Itoa(port)),
+		host: host,
+		from: from,
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	return m
+}
+
+// Send sends an email.
+func (m *smtpMailer) Send(_ context.Context, mail ports.Mail) error {
+	var auth smtp.Auth
+	if m.username != "" {
+		auth = smtp.PlainAuth("", m.username, m.password.Value(), m.host)
+	}
+
+	return smtp.SendMail(m.addr, auth, m.from, []string{mail.To}, m.message(mail))
+}
+
+func (m *smtpMailer) message(mail ports.Mail) []byte {
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, "From: %s\r\n", m.from)
+	fmt.Fprintf(&b, "To: %s\r\n", mail.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", mail.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "Message-ID: <%s@%s>\r\n", uuid.NewString(), m.host)
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(mail.Text)
+
+	return b.Bytes()
+}
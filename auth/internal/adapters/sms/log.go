@@ -0,0 +1,62 @@
+package sms
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/open-cloud-initiative/glue/auth/internal/ports"
+)
+
+var _ ports.SMSSender = (*logSender)(nil)
+
+type logSender struct {
+	w io.Writer
+}
+
+// NewLog returns a sender that writes text messages to w instead of sending them, e.g. for development.
+func NewLog(w io.Writer) ports.SMSSender {
+	return &logSender{w: w}
+}
+
+// SendSMS writes a text message.
+func (s *logSender) SendSMS(_ context.Context, to, body string) error {
+	_, err := fmt.Fprintf(s.w, "sms: to %s: %s\n", to, body)
+	return err
+}
+
+var _ ports.SMSSender = (*fileSender)(nil)
+
+type fileSender struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFile returns a sender that appends text messages as JSON lines to a file,
+// e.g. to read the codes in end-to-end tests.
+func NewFile(path string) ports.SMSSender {
+	return &fileSender{path: filepath.Clean(path)}
+}
+
+// SendSMS appends a text message to the file.
+func (s *fileSender) SendSMS(_ context.Context, to, body string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return json.NewEncoder(f).Encode(struct {
+		To   string    `json:"to"`
+		Body string    `json:"body"`
+		Sent time.Time `json:"sent"`
+	}{To: to, Body: body, Sent: time.Now()})
+}
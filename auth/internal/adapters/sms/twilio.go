@@ -0,0 +1,102 @@
+package sms
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/open-cloud-initiative/glue/auth/internal/adapters/auth"
+	"github.com/open-cloud-initiative/glue/auth/internal/ports"
+	"github.com/open-cloud-initiative/glue/auth/internal/secrets"
+)
+
+// DefaultTwilioURL is the base URL of the Twilio API.
+const DefaultTwilioURL = "https://api.twilio.com"
+
+var _ ports.SMSSender = (*twilio)(nil)
+
+type twilio struct {
+	baseURL    string
+	accountSID string
+	authToken  secrets.Secret
+	from       string
+	client     *http.Client
+}
+
+// Opt is a function that configures the Twilio sender.
+type Opt func(*twilio)
+
+// WithBaseURL sets the base URL of the API, for services compatible with the Twilio API.
+func WithBaseURL(baseURL string) Opt {
+	return func(t *twilio) {
+		t.baseURL = strings.TrimSuffix(baseURL, "/")
+	}
+}
+
+// WithClient sets the HTTP client.
+func WithClient(client *http.Client) Opt {
+	return func(t *twilio) {
+		t.client = client
+	}
+}
+
+// NewTwilio returns a sender that sends text messages with the Twilio Messages API.
+func NewTwilio(accountSID string, authToken secrets.Secret, from string, opts ...Opt) ports.SMSSender {
+	t := &twilio{
+		baseURL:    DefaultTwilioURL,
+		accountSID: accountSID,
+		authToken:  authToken,
+		from:       from,
+		client:     auth.DefaultClient,
+	}
+
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	return t
+}
+
+// SendSMS sends a text message.
+func (t *twilio) SendSMS(ctx context.Context, to, body string) error {
+	form := url.Values{}
+	form.Set("To", to)
+	form.Set("From", t.from)
+	form.Set("Body", body)
+
+	uri := fmt.Sprintf("%s/2010-04-01/Accounts/%s/Messages.json", t.baseURL, url.PathEscape(t.accountSID))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, uri, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+
+	req.SetBasicAuth(t.accountSID, t.authToken.Value())
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	res, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= http.StatusBadRequest {
+		apiErr := struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		}{}
+
+		data, _ := io.ReadAll(io.LimitReader(res.Body, 1<<16))
+		if json.Unmarshal(data, &apiErr) == nil && apiErr.Message != "" {
+			return fmt.Errorf("sms: twilio: %s (code %d)", apiErr.Message, apiErr.Code)
+		}
+
+		return fmt.Errorf("sms: twilio: unexpected status %s", res.Status)
+	}
+
+	return nil
+}
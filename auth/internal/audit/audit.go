@@ -0,0 +1,304 @@
+// Package audit records security-relevant events in an append-only log.
+// Events are written with the WriteTx of the change they describe, so that
+// a change is never committed without its event. Events are also published
+// to the outbox of the webhooks in the same transaction. Each event is
+// chained to the previous one by its hash, and checkpoints sign the hash of
+// the latest event, so that changes to written events can be detected.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/open-cloud-initiative/glue/auth/internal/models"
+	"github.com/open-cloud-initiative/glue/auth/internal/ports"
+	"github.com/open-cloud-initiative/glue/auth/internal/webhook"
+
+	"github.com/google/uuid"
+	"github.com/katallaxie/pkg/cast"
+)
+
+// Actions of audit events.
+const (
+	ActionUserCreated     = "user.created"
+	ActionUserUpdated     = "user.updated"
+	ActionUserDeleted     = "user.deleted"
+	ActionUserBanned      = "user.banned"
+	ActionUserUnbanned    = "user.unbanned"
+	ActionAccountLinked   = "account.linked"
+	ActionAccountUnlinked = "account.unlinked"
+	ActionSessionCreated  = "session.created"
+	ActionSessionRevoked  = "session.revoked"
+	ActionPasswordChanged = "password.changed"
+	ActionPasswordReset   = "password.reset"
+	ActionEmailVerified   = "email.verified"
+	ActionEmailChanged    = "email.changed"
+	ActionPhoneVerified   = "phone.verified"
+	ActionMFAEnrolled     = "mfa.enrolled"
+	ActionMFARemoved      = "mfa.removed"
+	ActionRoleCreated     = "role.created"
+	ActionRoleUpdated     = "role.updated"
+	ActionRoleDeleted     = "role.deleted"
+	ActionRoleAssigned    = "role.assigned"
+	ActionRoleUnassigned  = "role.unassigned"
+	ActionOrgCreated      = "organization.created"
+	ActionOrgUpdated      = "organization.updated"
+	ActionOrgDeleted      = "organization.deleted"
+	ActionMemberInvited   = "membership.invited"
+	ActionMemberAdded     = "membership.created"
+	ActionMemberUpdated   = "membership.updated"
+	ActionMemberRemoved   = "membership.deleted"
+)
+
+// Actions are all actions of audit events.
+var Actions = []string{
+	ActionUserCreated,
+	ActionUserUpdated,
+	ActionUserDeleted,
+	ActionUserBanned,
+	ActionUserUnbanned,
+	ActionAccountLinked,
+	ActionAccountUnlinked,
+	ActionSessionCreated,
+	ActionSessionRevoked,
+	ActionPasswordChanged,
+	ActionPasswordReset,
+	ActionEmailVerified,
+	ActionEmailChanged,
+	ActionPhoneVerified,
+	ActionMFAEnrolled,
+	ActionMFARemoved,
+	ActionRoleCreated,
+	ActionRoleUpdated,
+	ActionRoleDeleted,
+	ActionRoleAssigned,
+	ActionRoleUnassigned,
+	ActionOrgCreated,
+	ActionOrgUpdated,
+	ActionOrgDeleted,
+	ActionMemberInvited,
+	ActionMemberAdded,
+	ActionMemberUpdated,
+	ActionMemberRemoved,
+}
+
+// Kinds of actors.
+const (
+	// ActorUser is a user authenticated by a session.
+	ActorUser = "user"
+	// ActorAdmin is a caller of the admin service.
+	ActorAdmin = "admin"
+	// ActorSystem is the server itself, e.g. a background job.
+	ActorSystem = "system"
+)
+
+// ignored are the fields that change with every login and are left out of diffs.
+var ignored = map[string]bool{
+	"created_at":        true,
+	"updated_at":        true,
+	"last_signed_in_at": true,
+	"ReauthenticatedAt": true,
+	"accounts":          true,
+	"identities":        true,
+	"MfaFactors":        true,
+	"roles":             true,
+}
+
+// maxHeaderLength is the length in bytes that client supplied metadata is truncated to.
+const maxHeaderLength = 512
+
+// Metadata describes the request that causes events.
+type Metadata struct {
+	ActorType string
+	ActorID   *uuid.UUID
+	IP        string
+	UserAgent string
+	RequestID string
+}
+
+type metadataKey struct{}
+
+// MetadataKey is the context key of the request metadata. Fiber contexts
+// return their locals as values, so it is also the key of the locals.
+var MetadataKey any = metadataKey{}
+
+// NewContext returns a context with the request metadata.
+func NewContext(ctx context.Context, md *Metadata) context.Context {
+	return context.WithValue(ctx, MetadataKey, md)
+}
+
+// FromContext returns the request metadata of the context, or nil.
+func FromContext(ctx context.Context) *Metadata {
+	md, _ := ctx.Value(MetadataKey).(*Metadata)
+	return md
+}
+
+// Target is the object an event changed.
+type Target struct {
+	Type   string
+	ID     string
+	UserID uuid.UUID
+}
+
+// User returns the target of a user.
+func User(id uuid.UUID) Target {
+	return Target{Type: "user", ID: id.String(), UserID: id}
+}
+
+// Account returns the target of a provider account.
+func Account(account models.Account) Target {
+	return Target{Type: "account", ID: account.ID.String(), UserID: cast.Value(account.UserID)}
+}
+
+// Session returns the target of a session.
+func Session(session models.Session) Target {
+	return Target{Type: "session", ID: session.ID.String(), UserID: session.UserID}
+}
+
+// Factor returns the target of an MFA factor.
+func Factor(factor models.MFAFactor) Target {
+	return Target{Type: "mfa_factor", ID: factor.Id, UserID: factor.UserID}
+}
+
+// Role returns the target of a role.
+func Role(role models.Role) Target {
+	return Target{Type: "role", ID: role.ID.String()}
+}
+
+// Assignment returns the target of the assignment of a role to a user.
+func Assignment(assignment models.UserRole) Target {
+	return Target{Type: "user_role", ID: assignment.RoleID.String(), UserID: assignment.UserID}
+}
+
+// Organization returns the target of an organization.
+func Organization(org models.Organization) Target {
+	return Target{Type: "organization", ID: org.ID.String()}
+}
+
+// Membership returns the target of the membership of a user in an organization.
+func Membership(membership models.Membership) Target {
+	return Target{Type: "membership", ID: membership.OrganizationID.String(), UserID: membership.UserID}
+}
+
+// Record appends an event with the metadata of the request in ctx, and
+// publishes it to the webhook outbox without the metadata. The event is
+// chained by Log.Chain after the transaction commits, so that transactions
+// do not wait for each other to record events.
+func Record(ctx context.Context, tx ports.WriteTx, action string, target Target, diff map[string]models.AuditChange) error {
+	event := models.AuditEvent{
+		Action:     action,
+		TargetType: target.Type,
+		TargetID:   target.ID,
+		Diff:       diff,
+	}
+
+	if target.UserID != uuid.Nil {
+		event.UserID = &target.UserID
+	}
+
+	if md := FromContext(ctx); md != nil {
+		event.ActorType = md.ActorType
+		event.ActorID = md.ActorID
+		event.IP = md.IP
+		event.UserAgent = clean(md.UserAgent)
+		event.RequestID = clean(md.RequestID)
+	}
+
+	// Postgres keeps microseconds, the hash must match the stored time.
+	event.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+
+	if err := tx.CreateAuditEvent(ctx, &event); err != nil {
+		return err
+	}
+
+	data := models.OutboxData{TargetType: target.Type, TargetID: target.ID, Changes: diff}
+	if target.UserID != uuid.Nil {
+		data.UserID = target.UserID.String()
+	}
+
+	return webhook.Publish(ctx, tx, action, data)
+}
+
+// RecordChange appends an event with the diff of before and after, unless nothing changed.
+func RecordChange(ctx context.Context, tx ports.WriteTx, action string, target Target, before, after any) error {
+	diff := Diff(before, after)
+	if len(diff) == 0 {
+		return nil
+	}
+
+	return Record(ctx, tx, action, target, diff)
+}
+
+// RecordRevoked appends a session.revoked event for each of the sessions.
+func RecordRevoked(ctx context.Context, tx ports.WriteTx, sessions []models.Session) error {
+	for _, session := range sessions {
+		if err := Record(ctx, tx, ActionSessionRevoked, Session(session), nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Diff returns the fields of the JSON representation that differ between
+// before and after. Fields that change with every login are left out.
+func Diff(before, after any) map[string]models.AuditChange {
+	from, to := fields(before), fields(after)
+	diff := map[string]models.AuditChange{}
+
+	for k, v := range to {
+		if !ignored[k] && !reflect.DeepEqual(from[k], v) {
+			diff[k] = models.AuditChange{From: from[k], To: v}
+		}
+	}
+
+	for k, v := range from {
+		if _, ok := to[k]; !ok && !ignored[k] {
+			diff[k] = models.AuditChange{From: v}
+		}
+	}
+
+	return diff
+}
+
+func fields(v any) map[string]any {
+	m := map[string]any{}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return m
+	}
+
+	_ = json.Unmarshal(b, &m)
+
+	return m
+}
+
+// clean makes client supplied metadata safe to store: invalid UTF-8 and control
+// characters, which the database rejects or logs would render, are replaced, and
+// it is truncated to maxHeaderLength bytes at a rune boundary.
+func clean(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return utf8.RuneError
+		}
+
+		return r
+	}, strings.ToValidUTF8(s, string(utf8.RuneError)))
+
+	if len(s) <= maxHeaderLength {
+		return s
+	}
+
+	n := maxHeaderLength
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+
+	return s[:n]
+}
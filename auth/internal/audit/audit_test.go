@@ -0,0 +1,37 @@
+package audit
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestClean(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "plain", in: "Mozilla/5.0 (X11; Linux x86_64)", want: "Mozilla/5.0 (X11; Linux x86_64)"},
+		{name: "empty", in: "", want: ""},
+		{name: "unicode", in: "Näive 🦊", want: "Näive 🦊"},
+		{name: "control characters", in: "a\nb\x00c\x1b[31m", want: "a�b�c�[31m"},
+		{name: "invalid utf-8", in: "a\xffb", want: "a�b"},
+		{name: "truncated", in: strings.Repeat("a", maxHeaderLength+10), want: strings.Repeat("a", maxHeaderLength)},
+		{name: "truncated at a rune boundary", in: strings.Repeat("a", maxHeaderLength-1) + "ä", want: strings.Repeat("a", maxHeaderLength-1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := clean(tt.in)
+
+			if got != tt.want {
+				t.Errorf("clean(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+
+			if !utf8.ValidString(got) || len(got) > maxHeaderLength {
+				t.Errorf("clean(%q) = %q is not valid UTF-8 of at most %d bytes", tt.in, got, maxHeaderLength)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package audit
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/open-cloud-initiative/glue/auth/internal/models"
+
+	"github.com/katallaxie/pkg/cast"
+)
+
+// ErrBrokenChain is returned when the audit log was changed after it was written.
+var ErrBrokenChain = errors.New("audit: broken chain")
+
+// ErrInvalidKey is returned when a signing key is not an Ed25519 key.
+var ErrInvalidKey = errors.New("audit: signing key must be a base64 encoded Ed25519 seed or private key")
+
+// BrokenLinkError is returned for the first event or checkpoint that does not match the chain.
+type BrokenLinkError struct {
+	// EventID is the event that does not match.
+	EventID int64
+	// CheckpointID is the checkpoint that does not match, if any.
+	CheckpointID int64
+	// Reason describes the mismatch.
+	Reason string
+}
+
+// Error implements the error interface.
+func (e *BrokenLinkError) Error() string {
+	if e.CheckpointID != 0 {
+		return fmt.Sprintf("%s: checkpoint %d of event %d: %s", ErrBrokenChain, e.CheckpointID, e.EventID, e.Reason)
+	}
+
+	return fmt.Sprintf("%s: event %d: %s", ErrBrokenChain, e.EventID, e.Reason)
+}
+
+// Is makes errors.Is(err, ErrBrokenChain) match.
+func (e *BrokenLinkError) Is(target error) bool {
+	return target == ErrBrokenChain
+}
+
+// chained are the fields of an event covered by its hash.
+type chained struct {
+	Action     string                        `json:"action"`
+	ActorType  string                        `json:"actor_type"`
+	ActorID    string                        `json:"actor_id"`
+	UserID     string                        `json:"user_id"`
+	TargetType string                        `json:"target_type"`
+	TargetID   string                        `json:"target_id"`
+	IP         string                        `json:"ip"`
+	UserAgent  string                        `json:"user_agent"`
+	RequestID  string                        `json:"request_id"`
+	Diff       map[string]models.AuditChange `json:"diff"`
+	CreatedAt  string                        `json:"created_at"`
+	PrevHash   string                        `json:"prev_hash"`
+}
+
+// Hash returns the hex encoded SHA-256 hash of the event and its PrevHash.
+func Hash(event models.AuditEvent) (string, error) {
+	c := chained{
+		Action:     event.Action,
+		ActorType:  event.ActorType,
+		TargetType: event.TargetType,
+		TargetID:   event.TargetID,
+		IP:         event.IP,
+		UserAgent:  event.UserAgent,
+		RequestID:  event.RequestID,
+		Diff:       event.Diff,
+		CreatedAt:  event.CreatedAt.UTC().Format(time.RFC3339Nano),
+		PrevHash:   event.PrevHash,
+	}
+
+	if event.ActorID != nil {
+		c.ActorID = event.ActorID.String()
+	}
+
+	if event.UserID != nil {
+		c.UserID = event.UserID.String()
+	}
+
+	b, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(b)
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// link sets the Seq, PrevHash and Hash of an event that follows last in the chain.
+func link(event *models.AuditEvent, last models.AuditEvent) error {
+	event.Seq = cast.Ptr(cast.Value(last.Seq) + 1)
+	event.PrevHash = last.Hash
+
+	var err error
+	event.Hash, err = Hash(*event)
+
+	return err
+}
+
+// ParseKey parses a base64 encoded Ed25519 seed or private key.
+func ParseKey(s string) (ed25519.PrivateKey, error) {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidKey
+	}
+
+	switch len(b) {
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(b), nil
+	case ed25519.PrivateKeySize:
+		return ed25519.PrivateKey(b), nil
+	default:
+		return nil, ErrInvalidKey
+	}
+}
+
+// ParsePublicKey parses a base64 encoded Ed25519 public key.
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil || len(b) != ed25519.PublicKeySize {
+		return nil, errors.New("audit: public key must be a base64 encoded Ed25519 public key")
+	}
+
+	return ed25519.PublicKey(b), nil
+}
+
+// KeyID returns the ID of a public key, the first 8 bytes of its SHA-256 hash in hex.
+func KeyID(key ed25519.PublicKey) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+// checkpointMessage is the message signed by a checkpoint.
+func checkpointMessage(eventID int64, hash string) []byte {
+	return fmt.Appendf(nil, "glue.audit.checkpoint.v1\n%d\n%s", eventID, hash)
+}
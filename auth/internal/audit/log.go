@@ -0,0 +1,441 @@
+package audit
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/open-cloud-initiative/glue/auth/internal/models"
+	"github.com/open-cloud-initiative/glue/auth/internal/ports"
+
+	"github.com/katallaxie/pkg/dbx"
+	"gorm.io/gorm"
+)
+
+const (
+	// DefaultPageSize is the default number of events per page.
+	DefaultPageSize = 100
+	// MaxPageSize is the maximum number of events per page.
+	MaxPageSize = 1000
+	// exportBatchSize is the number of events read per query of an export.
+	exportBatchSize = 500
+	// DefaultCheckpointInterval is the default time between checkpoints.
+	DefaultCheckpointInterval = time.Hour
+	// DefaultChainInterval is the default time between runs of Chain.
+	DefaultChainInterval = time.Second
+)
+
+// Log chains the events of the audit log, queries them and signs checkpoints of them.
+type Log struct {
+	store         dbx.Database[ports.ReadTx, ports.WriteTx]
+	key           ed25519.PrivateKey
+	interval      time.Duration
+	chainInterval time.Duration
+	onError       func(error)
+}
+
+// LogOpt is a function that configures the Log.
+type LogOpt func(*Log)
+
+// WithSigningKey signs checkpoints with the key.
+func WithSigningKey(key ed25519.PrivateKey) LogOpt {
+	return func(l *Log) {
+		l.key = key
+	}
+}
+
+// WithCheckpointInterval sets the time between checkpoints.
+func WithCheckpointInterval(interval time.Duration) LogOpt {
+	return func(l *Log) {
+		l.interval = interval
+	}
+}
+
+// WithChainInterval sets the time between runs of Chain.
+func WithChainInterval(interval time.Duration) LogOpt {
+	return func(l *Log) {
+		l.chainInterval = interval
+	}
+}
+
+// WithErrorHandler sets the function the failures of Start are passed to.
+func WithErrorHandler(fn func(error)) LogOpt {
+	return func(l *Log) {
+		l.onError = fn
+	}
+}
+
+// NewLog returns a new Log.
+func NewLog(store dbx.Database[ports.ReadTx, ports.WriteTx], opts ...LogOpt) *Log {
+	l := &Log{store: store, interval: DefaultCheckpointInterval, chainInterval: DefaultChainInterval, onError: func(error) {}}
+
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	return l
+}
+
+// List returns a page of events matching the filter and the After of the next
+// page, which is zero on the last page. The limit of the filter defaults to
+// DefaultPageSize and is capped at MaxPageSize.
+func (l *Log) List(ctx context.Context, filter ports.AuditFilter) ([]models.AuditEvent, int64, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultPageSize
+	}
+
+	filter.Limit = min(filter.Limit, MaxPageSize)
+	events := []models.AuditEvent{}
+
+	err := l.store.ReadTx(ctx, func(ctx context.Context, tx ports.ReadTx) error {
+		return tx.ListAuditEvents(ctx, filter, &events)
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if len(events) < filter.Limit {
+		return events, 0, nil
+	}
+
+	return events, events[len(events)-1].ID, nil
+}
+
+// Export calls fn with all events matching the filter in the order they were
+// written. The events are read in batches, so that the log is never held in
+// memory. The limit of the filter caps the number of events, if it is set.
+func (l *Log) Export(ctx context.Context, filter ports.AuditFilter, fn func(models.AuditEvent) error) error {
+	remaining := filter.Limit
+
+	for {
+		filter.Limit = exportBatchSize
+		if remaining > 0 {
+			filter.Limit = min(exportBatchSize, remaining)
+		}
+
+		events := []models.AuditEvent{}
+
+		err := l.store.ReadTx(ctx, func(ctx context.Context, tx ports.ReadTx) error {
+			return tx.ListAuditEvents(ctx, filter, &events)
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, e := range events {
+			if err := fn(e); err != nil {
+				return err
+			}
+		}
+
+		if remaining > 0 {
+			remaining -= len(events)
+			if remaining <= 0 {
+				return nil
+			}
+		}
+
+		if len(events) < filter.Limit {
+			return nil
+		}
+
+		filter.After = events[len(events)-1].ID
+	}
+}
+
+// Chain appends the events recorded since the last run to the hash chain, in
+// the order they become visible. It only holds the lock of the audit log for
+// its own short transactions, and returns the number of events chained.
+func (l *Log) Chain(ctx context.Context) (int, error) {
+	chained := 0
+
+	for {
+		events := []models.AuditEvent{}
+
+		err := l.store.ReadWriteTx(ctx, func(ctx context.Context, tx ports.WriteTx) error {
+			if err := tx.LockAuditLog(ctx); err != nil {
+				return err
+			}
+
+			last := models.AuditEvent{}
+
+			err := tx.GetLastAuditEvent(ctx, &last)
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+
+			if err := tx.ListUnchainedAuditEvents(ctx, exportBatchSize, &events); err != nil {
+				return err
+			}
+
+			for i := range events {
+				if err := link(&events[i], last); err != nil {
+					return err
+				}
+
+				if err := tx.ChainAuditEvent(ctx, &events[i]); err != nil {
+					return err
+				}
+
+				last = events[i]
+			}
+
+			return nil
+		})
+		if err != nil {
+			return chained, err
+		}
+
+		chained += len(events)
+
+		if len(events) < exportBatchSize {
+			return chained, nil
+		}
+	}
+}
+
+// Checkpoint signs the hash of the last chained event. It returns false if there
+// are no events, or the last checkpoint already covers the last event.
+func (l *Log) Checkpoint(ctx context.Context) (models.AuditCheckpoint, bool, error) {
+	checkpoint := models.AuditCheckpoint{}
+
+	if l.key == nil {
+		return checkpoint, false, ErrInvalidKey
+	}
+
+	created := false
+
+	err := l.store.ReadWriteTx(ctx, func(ctx context.Context, tx ports.WriteTx) error {
+		if err := tx.LockAuditLog(ctx); err != nil {
+			return err
+		}
+
+		event := models.AuditEvent{}
+
+		err := tx.GetLastAuditEvent(ctx, &event)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		last := models.AuditCheckpoint{}
+
+		err = tx.GetLastAuditCheckpoint(ctx, &last)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		if last.EventID == event.ID {
+			return nil
+		}
+
+		checkpoint = models.AuditCheckpoint{
+			EventID:   event.ID,
+			Hash:      event.Hash,
+			KeyID:     KeyID(l.key.Public().(ed25519.PublicKey)),
+			Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(l.key, checkpointMessage(event.ID, event.Hash))),
+		}
+		created = true
+
+		return tx.CreateAuditCheckpoint(ctx, &checkpoint)
+	})
+
+	return checkpoint, created, err
+}
+
+// Start chains the recorded events at every chain interval, and signs a
+// checkpoint at every interval if it has a signing key, until the context is done.
+func (l *Log) Start(ctx context.Context) {
+	chain := time.NewTicker(l.chainInterval)
+	defer chain.Stop()
+
+	checkpoint := time.NewTicker(l.interval)
+	defer checkpoint.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-chain.C:
+			if _, err := l.Chain(ctx); err != nil && ctx.Err() == nil {
+				l.onError(fmt.Errorf("chain: %w", err))
+			}
+		case <-checkpoint.C:
+			if l.key == nil {
+				continue
+			}
+
+			if _, _, err := l.Checkpoint(ctx); err != nil && ctx.Err() == nil {
+				l.onError(fmt.Errorf("checkpoint: %w", err))
+			}
+		}
+	}
+}
+
+// Report is the result of a verification of the audit log.
+type Report struct {
+	// Events is the number of events read.
+	Events int `json:"events"`
+	// Unchained is the number of leading events written before the chain was introduced.
+	// Events that are not chained yet are not read.
+	Unchained int `json:"unchained"`
+	// Checkpoints is the number of checkpoints verified.
+	Checkpoints int `json:"checkpoints"`
+	// LastEventID is the ID of the last event.
+	LastEventID int64 `json:"lastEventId"`
+	// LastHash is the hash of the last event.
+	LastHash string `json:"lastHash"`
+}
+
+// Verify walks the chain of events and checks the hash of each event and its
+// link to the previous event, and the signature of each checkpoint made with
+// one of the keys. Without keys, only the hashes of the checkpoints are checked.
+// It returns a *BrokenLinkError for the first event or checkpoint that does not match.
+func (l *Log) Verify(ctx context.Context, keys ...ed25519.PublicKey) (Report, error) {
+	report := Report{}
+
+	checkpoints, err := l.checkpoints(ctx)
+	if err != nil {
+		return report, err
+	}
+
+	known := make(map[string]ed25519.PublicKey, len(keys))
+	for _, k := range keys {
+		known[KeyID(k)] = k
+	}
+
+	chained := false
+
+	err = l.chained(ctx, func(e models.AuditEvent) error {
+		report.Events++
+
+		switch {
+		case !chained && e.Hash == "":
+			report.Unchained++
+		case e.PrevHash != report.LastHash:
+			return &BrokenLinkError{EventID: e.ID, Reason: "previous hash does not match the hash of the previous event"}
+		default:
+			hash, err := Hash(e)
+			if err != nil {
+				return err
+			}
+
+			if hash != e.Hash {
+				return &BrokenLinkError{EventID: e.ID, Reason: "hash does not match the event"}
+			}
+
+			chained = true
+		}
+
+		for _, c := range checkpoints[e.ID] {
+			if err := verifyCheckpoint(c, e.Hash, known); err != nil {
+				return err
+			}
+
+			report.Checkpoints++
+		}
+
+		delete(checkpoints, e.ID)
+
+		report.LastEventID = e.ID
+		report.LastHash = e.Hash
+
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+
+	// Checkpoints left over cover events that were removed.
+	if len(checkpoints) > 0 {
+		id := slices.Min(slices.Collect(maps.Keys(checkpoints)))
+		return report, &BrokenLinkError{EventID: id, CheckpointID: checkpoints[id][0].ID, Reason: "event of the checkpoint is missing"}
+	}
+
+	return report, nil
+}
+
+// chained calls fn with all chained events in chain order.
+func (l *Log) chained(ctx context.Context, fn func(models.AuditEvent) error) error {
+	after := int64(0)
+
+	for {
+		events := []models.AuditEvent{}
+
+		err := l.store.ReadTx(ctx, func(ctx context.Context, tx ports.ReadTx) error {
+			return tx.ListChainedAuditEvents(ctx, after, exportBatchSize, &events)
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, e := range events {
+			if err := fn(e); err != nil {
+				return err
+			}
+		}
+
+		if len(events) < exportBatchSize {
+			return nil
+		}
+
+		after = *events[len(events)-1].Seq
+	}
+}
+
+// checkpoints returns all checkpoints by the event they cover.
+func (l *Log) checkpoints(ctx context.Context) (map[int64][]models.AuditCheckpoint, error) {
+	checkpoints := map[int64][]models.AuditCheckpoint{}
+	after := int64(0)
+
+	for {
+		batch := []models.AuditCheckpoint{}
+
+		err := l.store.ReadTx(ctx, func(ctx context.Context, tx ports.ReadTx) error {
+			return tx.ListAuditCheckpoints(ctx, after, exportBatchSize, &batch)
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, c := range batch {
+			checkpoints[c.EventID] = append(checkpoints[c.EventID], c)
+		}
+
+		if len(batch) < exportBatchSize {
+			return checkpoints, nil
+		}
+
+		after = batch[len(batch)-1].ID
+	}
+}
+
+func verifyCheckpoint(c models.AuditCheckpoint, hash string, keys map[string]ed25519.PublicKey) error {
+	if c.Hash != hash {
+		return &BrokenLinkError{EventID: c.EventID, CheckpointID: c.ID, Reason: "hash does not match the event"}
+	}
+
+	if len(keys) == 0 {
+		return nil
+	}
+
+	key, ok := keys[c.KeyID]
+	if !ok {
+		return &BrokenLinkError{EventID: c.EventID, CheckpointID: c.ID, Reason: "signed with unknown key " + c.KeyID}
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(c.Signature)
+	if err != nil || !ed25519.Verify(key, checkpointMessage(c.EventID, c.Hash), sig) {
+		return &BrokenLinkError{EventID: c.EventID, CheckpointID: c.ID, Reason: "signature is invalid"}
+	}
+
+	return nil
+}
@@ -0,0 +1,356 @@
+package audit
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/open-cloud-initiative/glue/auth/internal/models"
+	"github.com/open-cloud-initiative/glue/auth/internal/ports"
+
+	"github.com/katallaxie/pkg/cast"
+	"gorm.io/gorm"
+)
+
+// store keeps the audit log in memory.
+type store struct {
+	events      []models.AuditEvent
+	checkpoints []models.AuditCheckpoint
+}
+
+func (s *store) ReadTx(ctx context.Context, fn func(context.Context, ports.ReadTx) error) error {
+	return fn(ctx, &tx{store: s})
+}
+
+func (s *store) ReadWriteTx(ctx context.Context, fn func(context.Context, ports.WriteTx) error) error {
+	return fn(ctx, &tx{store: s})
+}
+
+func (s *store) Migrate(context.Context, ...any) error {
+	return nil
+}
+
+func (s *store) Close() error {
+	return nil
+}
+
+// record appends an unchained event.
+func (s *store) record(action string) {
+	s.events = append(s.events, models.AuditEvent{
+		ID:         int64(len(s.events) + 1),
+		Action:     action,
+		TargetType: "user",
+		TargetID:   action,
+		CreatedAt:  time.Date(2026, 1, 1, 0, 0, len(s.events), 0, time.UTC),
+	})
+}
+
+type tx struct {
+	ports.WriteTx
+	store *store
+}
+
+func (t *tx) LockAuditLog(context.Context) error {
+	return nil
+}
+
+func (t *tx) GetLastAuditEvent(_ context.Context, event *models.AuditEvent) error {
+	found := false
+
+	for _, e := range t.store.events {
+		if e.Seq != nil && (!found || *e.Seq > *event.Seq) {
+			*event = e
+			found = true
+		}
+	}
+
+	if !found {
+		return gorm.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func (t *tx) ListChainedAuditEvents(_ context.Context, after int64, limit int, events *[]models.AuditEvent) error {
+	chained := []models.AuditEvent{}
+
+	for _, e := range t.store.events {
+		if e.Seq != nil && *e.Seq > after {
+			chained = append(chained, e)
+		}
+	}
+
+	slices.SortFunc(chained, func(a, b models.AuditEvent) int { return int(*a.Seq - *b.Seq) })
+	*events = chained[:min(limit, len(chained))]
+
+	return nil
+}
+
+func (t *tx) ListUnchainedAuditEvents(_ context.Context, limit int, events *[]models.AuditEvent) error {
+	for _, e := range t.store.events {
+		if e.Seq == nil && len(*events) < limit {
+			*events = append(*events, e)
+		}
+	}
+
+	return nil
+}
+
+func (t *tx) ChainAuditEvent(_ context.Context, event *models.AuditEvent) error {
+	for i, e := range t.store.events {
+		if e.ID == event.ID {
+			t.store.events[i].Seq = event.Seq
+			t.store.events[i].PrevHash = event.PrevHash
+			t.store.events[i].Hash = event.Hash
+		}
+	}
+
+	return nil
+}
+
+func (t *tx) ListAuditCheckpoints(_ context.Context, after int64, limit int, checkpoints *[]models.AuditCheckpoint) error {
+	for _, c := range t.store.checkpoints {
+		if c.ID > after && len(*checkpoints) < limit {
+			*checkpoints = append(*checkpoints, c)
+		}
+	}
+
+	return nil
+}
+
+func (t *tx) GetLastAuditCheckpoint(_ context.Context, checkpoint *models.AuditCheckpoint) error {
+	if len(t.store.checkpoints) == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	*checkpoint = t.store.checkpoints[len(t.store.checkpoints)-1]
+
+	return nil
+}
+
+func (t *tx) CreateAuditCheckpoint(_ context.Context, checkpoint *models.AuditCheckpoint) error {
+	checkpoint.ID = int64(len(t.store.checkpoints) + 1)
+	t.store.checkpoints = append(t.store.checkpoints, *checkpoint)
+
+	return nil
+}
+
+func TestLink(t *testing.T) {
+	first := models.AuditEvent{ID: 1, Action: ActionUserCreated}
+	if err := link(&first, models.AuditEvent{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if cast.Value(first.Seq) != 1 || first.PrevHash != "" || first.Hash == "" {
+		t.Fatalf("first = seq %d, prev %q, hash %q", cast.Value(first.Seq), first.PrevHash, first.Hash)
+	}
+
+	second := models.AuditEvent{ID: 5, Action: ActionUserCreated}
+	if err := link(&second, first); err != nil {
+		t.Fatal(err)
+	}
+
+	if cast.Value(second.Seq) != 2 || second.PrevHash != first.Hash {
+		t.Errorf("second = seq %d, prev %q, want seq 2, prev %q", cast.Value(second.Seq), second.PrevHash, first.Hash)
+	}
+
+	if second.Hash == first.Hash {
+		t.Error("events with the same fields but different predecessors have the same hash")
+	}
+
+	hash, err := Hash(second)
+	if err != nil || hash != second.Hash {
+		t.Errorf("Hash() = %q, %v, want %q", hash, err, second.Hash)
+	}
+}
+
+func TestLogChain(t *testing.T) {
+	ctx := context.Background()
+	s := &store{}
+	l := NewLog(s)
+
+	s.record("a")
+	s.record("b")
+
+	if n, err := l.Chain(ctx); err != nil || n != 2 {
+		t.Fatalf("Chain() = %d, %v, want 2", n, err)
+	}
+
+	if n, err := l.Chain(ctx); err != nil || n != 0 {
+		t.Fatalf("Chain() = %d, %v, want 0", n, err)
+	}
+
+	s.record("c")
+
+	if n, err := l.Chain(ctx); err != nil || n != 1 {
+		t.Fatalf("Chain() = %d, %v, want 1", n, err)
+	}
+
+	for i, e := range s.events {
+		if cast.Value(e.Seq) != int64(i+1) {
+			t.Errorf("events[%d].Seq = %d, want %d", i, cast.Value(e.Seq), i+1)
+		}
+
+		if i > 0 && e.PrevHash != s.events[i-1].Hash {
+			t.Errorf("events[%d] does not link to its predecessor", i)
+		}
+	}
+}
+
+func TestLogVerify(t *testing.T) {
+	pub, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		legacy int
+		events int
+		// checkpoint signs a checkpoint after the events are chained.
+		checkpoint bool
+		tamper     func(s *store)
+		keys       []ed25519.PublicKey
+		want       Report
+		broken     *BrokenLinkError
+	}{
+		{
+			name: "empty log",
+		},
+		{
+			name:   "intact chain",
+			events: 3,
+			want:   Report{Events: 3, LastEventID: 3},
+		},
+		{
+			name:   "legacy events precede the chain",
+			legacy: 2,
+			events: 2,
+			want:   Report{Events: 4, Unchained: 2, LastEventID: 4},
+		},
+		{
+			name:       "signed checkpoint",
+			events:     2,
+			checkpoint: true,
+			keys:       []ed25519.PublicKey{pub},
+			want:       Report{Events: 2, Checkpoints: 1, LastEventID: 2},
+		},
+		{
+			name:       "checkpoint without keys",
+			events:     2,
+			checkpoint: true,
+			want:       Report{Events: 2, Checkpoints: 1, LastEventID: 2},
+		},
+		{
+			name:   "changed event",
+			events: 3,
+			tamper: func(s *store) { s.events[1].Action = ActionUserDeleted },
+			broken: &BrokenLinkError{EventID: 2, Reason: "hash does not match the event"},
+		},
+		{
+			name:   "removed event",
+			events: 3,
+			tamper: func(s *store) { s.events = slices.Delete(s.events, 1, 2) },
+			broken: &BrokenLinkError{EventID: 3, Reason: "previous hash does not match the hash of the previous event"},
+		},
+		{
+			name:   "rehashed event",
+			events: 3,
+			tamper: func(s *store) {
+				s.events[1].Action = ActionUserDeleted
+				s.events[1].Hash, _ = Hash(s.events[1])
+			},
+			broken: &BrokenLinkError{EventID: 3, Reason: "previous hash does not match the hash of the previous event"},
+		},
+		{
+			name:       "checkpoint signed with an unknown key",
+			events:     2,
+			checkpoint: true,
+			keys:       []ed25519.PublicKey{other},
+			broken:     &BrokenLinkError{EventID: 2, CheckpointID: 1, Reason: "signed with unknown key " + KeyID(pub)},
+		},
+		{
+			name:       "forged checkpoint",
+			events:     2,
+			checkpoint: true,
+			tamper:     func(s *store) { s.checkpoints[0].Signature = s.checkpoints[0].Signature[4:] },
+			keys:       []ed25519.PublicKey{pub},
+			broken:     &BrokenLinkError{EventID: 2, CheckpointID: 1, Reason: "signature is invalid"},
+		},
+		{
+			name:       "truncated log",
+			events:     2,
+			checkpoint: true,
+			tamper:     func(s *store) { s.events = s.events[:1] },
+			keys:       []ed25519.PublicKey{pub},
+			broken:     &BrokenLinkError{EventID: 2, CheckpointID: 1, Reason: "event of the checkpoint is missing"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := &store{}
+			l := NewLog(s, WithSigningKey(key))
+
+			for i := range tt.legacy {
+				s.record("legacy")
+				s.events[i].Seq = cast.Ptr(s.events[i].ID)
+			}
+
+			for range tt.events {
+				s.record("event")
+			}
+
+			if _, err := l.Chain(ctx); err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.checkpoint {
+				if _, ok, err := l.Checkpoint(ctx); err != nil || !ok {
+					t.Fatalf("Checkpoint() = %v, %v", ok, err)
+				}
+			}
+
+			if tt.tamper != nil {
+				tt.tamper(s)
+			}
+
+			report, err := l.Verify(ctx, tt.keys...)
+
+			if tt.broken != nil {
+				var broken *BrokenLinkError
+				if !errors.As(err, &broken) || *broken != *tt.broken {
+					t.Fatalf("Verify() = %v, want %v", err, tt.broken)
+				}
+
+				if !errors.Is(err, ErrBrokenChain) {
+					t.Errorf("Verify() = %v, want ErrBrokenChain", err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Verify() = %v", err)
+			}
+
+			tt.want.LastHash = report.LastHash
+			if tt.events > 0 && report.LastHash != s.events[len(s.events)-1].Hash {
+				t.Errorf("LastHash = %q, want the hash of the last event", report.LastHash)
+			}
+
+			if report != tt.want {
+				t.Errorf("Verify() = %+v, want %+v", report, tt.want)
+			}
+		})
+	}
+}
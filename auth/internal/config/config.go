@@ -14,6 +14,17 @@ type Flags struct {
 	DatabaseURI string `envconfig:"TAGS_DATABASE_URI" default:""`
 	// Environment ...
 	Environment string `envconfig:"TAGS_ENV" default:"production"`
+	// AdminAddr is the address of the gRPC admin service.
+	AdminAddr string `envconfig:"TAGS_ADMIN_ADDR" default:":4041"`
+	// InternalToken is the bearer token of the internal API and the admin service,
+	// it may be a secret reference. Both are disabled if it is empty. The admin
+	// service also accepts the session tokens of users with the required permissions.
+	InternalToken string `envconfig:"TAGS_INTERNAL_TOKEN" default:""`
+	// DebugAddr is the address of the internal listener that serves /debug/vars.
+	// It is disabled if it is empty, and should not be reachable publicly.
+	DebugAddr string `envconfig:"TAGS_DEBUG_ADDR" default:"127.0.0.1:4042"`
+	// ConfigFile is the path to the declarative configuration file.
+	ConfigFile string `envconfig:"TAGS_CONFIG_FILE" default:""`
 }
 
 // NewFlags returns a new flags.
@@ -25,6 +36,8 @@ func NewFlags() *Flags {
 type Config struct {
 	// Flags ...
 	Flags *Flags
+	// File is the loaded configuration file.
+	File *File
 	// Stdin ...
 	Stdin *os.File
 	// Stdout ...
@@ -40,6 +53,7 @@ func New() *Config {
 		Stdout: os.Stdout,
 		Stderr: os.Stderr,
 		Flags:  NewFlags(),
+		File:   &File{},
 	}
 }
 
@@ -53,6 +67,22 @@ func (c *Config) InitDefaultConfig() error {
 	return nil
 }
 
+// LoadConfigFile loads and validates the configuration file, if one is set.
+func (c *Config) LoadConfigFile() error {
+	if c.Flags.ConfigFile == "" {
+		return nil
+	}
+
+	f, err := LoadFile(c.Flags.ConfigFile)
+	if err != nil {
+		return err
+	}
+
+	c.File = f
+
+	return nil
+}
+
 // Cwd returns the current working directory.
 func (c *Config) Cwd() (string, error) {
 	return os.Getwd()
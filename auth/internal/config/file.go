@@ -0,0 +1,899 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/open-cloud-initiative/glue/auth/internal/models"
+	"github.com/open-cloud-initiative/glue/auth/internal/secrets"
+
+	"github.com/goccy/go-yaml"
+)
+
+// ProviderKind is the kind of a configured provider.
+type ProviderKind string
+
+const (
+	// ProviderKindGitHub configures a GitHub (or GitHub Enterprise) OAuth2 provider.
+	ProviderKindGitHub ProviderKind = "github"
+	// ProviderKindSAML configures a SAML 2.0 identity provider.
+	ProviderKindSAML ProviderKind = "saml"
+	// ProviderKindOIDC configures an OpenID Connect provider.
+	ProviderKindOIDC ProviderKind = "oidc"
+)
+
+var (
+	// ErrMissingID is returned when a provider has no ID.
+	ErrMissingID = errors.New("id is required")
+	// ErrInvalidID is returned when the ID of a provider contains a slash, which
+	// separates the tenant from the ID in the key of a provider.
+	ErrInvalidID = errors.New("id must not contain a slash")
+	// ErrDuplicateID is returned when two entries share the same ID.
+	ErrDuplicateID = errors.New("id is already used by another entry")
+	// ErrInvalidTenant is returned when a provider has a tenant that is not an organization slug.
+	ErrInvalidTenant = errors.New("tenant must be an organization slug")
+	// ErrUnknownKind is returned when a provider has an unknown type.
+	ErrUnknownKind = errors.New("unknown provider type")
+	// ErrMissingClientID is returned when an OAuth2 provider has no client ID.
+	ErrMissingClientID = errors.New("clientId is required")
+	// ErrMissingClientSecret is returned when an OAuth2 provider has no client secret.
+	ErrMissingClientSecret = errors.New("clientSecret is required")
+	// ErrMissingIssuer is returned when an OIDC provider has no issuer.
+	ErrMissingIssuer = errors.New("issuer is required for type oidc")
+	// ErrMissingSAML is returned when a SAML provider has no saml section.
+	ErrMissingSAML = errors.New("saml section is required for type saml")
+	// ErrMissingMetadata is returned when a SAML provider has no IdP metadata.
+	ErrMissingMetadata = errors.New("exactly one of saml.metadata, saml.metadataUrl or saml.metadataFile is required")
+	// ErrMissingEntityID is returned when a SAML provider has no entity ID.
+	ErrMissingEntityID = errors.New("saml.entityId is required")
+	// ErrUnsupportedFormat is returned when the configuration file has an unknown extension.
+	ErrUnsupportedFormat = errors.New("unsupported configuration file format, use .yaml, .yml or .json")
+)
+
+// File is the declarative configuration file. Only the providers are
+// reloaded at runtime, the other settings take effect after a restart.
+type File struct {
+	// AccountLinking is the policy for linking a new provider account to an
+	// existing user with the same email: never, verified or always.
+	AccountLinking models.LinkPolicy `json:"accountLinking,omitempty" yaml:"accountLinking,omitempty"`
+	// Providers are the enabled authentication providers.
+	Providers []Provider `json:"providers" yaml:"providers"`
+	// Encryption configures the encryption of provider tokens at rest.
+	Encryption Encryption `json:"encryption,omitempty" yaml:"encryption,omitempty"`
+	// Janitor configures the background cleanup of expired and deleted rows.
+	Janitor Janitor `json:"janitor,omitempty" yaml:"janitor,omitempty"`
+	// BaseURL is the public URL of the server, used for links sent to users.
+	BaseURL string `json:"baseUrl,omitempty" yaml:"baseUrl,omitempty"`
+	// Mail configures how emails are sent.
+	Mail Mail `json:"mail,omitempty" yaml:"mail,omitempty"`
+	// SMS configures how text messages are sent. SMS is disabled if it is not set.
+	SMS *SMS `json:"sms,omitempty" yaml:"sms,omitempty"`
+	// Password enables the login with email address and password. Passwords are disabled if it is not set.
+	Password *Password `json:"password,omitempty" yaml:"password,omitempty"`
+	// Lockout configures the lockout after failed logins.
+	Lockout Lockout `json:"lockout,omitempty" yaml:"lockout,omitempty"`
+	// RateLimit configures the rate limits of route groups.
+	RateLimit RateLimit `json:"rateLimit,omitempty" yaml:"rateLimit,omitempty"`
+	// Audit configures the audit log.
+	Audit Audit `json:"audit,omitempty" yaml:"audit,omitempty"`
+	// Webhooks configures the delivery of user lifecycle events. Webhooks are disabled if it is not set.
+	Webhooks *Webhooks `json:"webhooks,omitempty" yaml:"webhooks,omitempty"`
+	// Hooks run before users are created and sessions are created, in order.
+	Hooks []Hook `json:"hooks,omitempty" yaml:"hooks,omitempty"`
+	// Organizations configures the invitations to organizations.
+	Organizations Organizations `json:"organizations,omitempty" yaml:"organizations,omitempty"`
+	// Tenancy configures how the tenant of a login is resolved from the request host.
+	Tenancy Tenancy `json:"tenancy,omitempty" yaml:"tenancy,omitempty"`
+}
+
+// Tenancy resolves the tenant of a login, that selects the providers of an
+// organization. The tenant can also be selected by the path prefix /t/<slug>.
+type Tenancy struct {
+	// Domain is the parent domain of the tenant subdomains, e.g. login.example.com
+	// resolves acme.login.example.com to the tenant acme.
+	Domain string `json:"domain,omitempty" yaml:"domain,omitempty"`
+	// Hosts maps custom hosts to tenants, e.g. login.acme.com: acme.
+	Hosts map[string]string `json:"hosts,omitempty" yaml:"hosts,omitempty"`
+}
+
+// Organizations configures the invitations to organizations. Zero values use the defaults.
+type Organizations struct {
+	// InviteURL is the page invitation links point to, defaults to <baseUrl>/auth/invitations/accept.
+	InviteURL string `json:"inviteUrl,omitempty" yaml:"inviteUrl,omitempty"`
+	// InviteTTL is the lifetime of an invitation, defaults to 7 days.
+	InviteTTL Duration `json:"inviteTtl,omitempty" yaml:"inviteTtl,omitempty"`
+}
+
+// Hook is a CEL expression or HTTP endpoint that allows, denies or changes a signup or login.
+type Hook struct {
+	// ID identifies the hook in errors and logs.
+	ID string `json:"id" yaml:"id"`
+	// Events are the events the hook runs at: before_user_created and before_session_created.
+	Events []string `json:"events" yaml:"events"`
+	// CEL is an expression that returns a bool, or a map with the fields of a result.
+	CEL string `json:"cel,omitempty" yaml:"cel,omitempty"`
+	// Message is the reason shown to the user if the CEL expression returns false.
+	Message string `json:"message,omitempty" yaml:"message,omitempty"`
+	// HTTP posts the events to an endpoint.
+	HTTP *HTTPHook `json:"http,omitempty" yaml:"http,omitempty"`
+	// Failure is the policy if the hook fails: closed (default) denies the event, open allows it.
+	Failure string `json:"failure,omitempty" yaml:"failure,omitempty"`
+}
+
+// HTTPHook is an endpoint that evaluates hook events.
+type HTTPHook struct {
+	// URL is where events are posted to.
+	URL string `json:"url" yaml:"url"`
+	// Secret is a reference to the key of the HMAC signature of requests.
+	Secret secrets.Ref `json:"secret" yaml:"secret"`
+	// Timeout is the timeout of a request, defaults to 5s.
+	Timeout Duration `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+}
+
+// Webhooks configures the delivery of events to webhook endpoints. Zero values use the defaults.
+type Webhooks struct {
+	// Endpoints are the receivers of events.
+	Endpoints []WebhookEndpoint `json:"endpoints" yaml:"endpoints"`
+	// Interval is the time between two polls of the outbox, defaults to 5s.
+	Interval Duration `json:"interval,omitempty" yaml:"interval,omitempty"`
+	// Timeout is the timeout of a delivery attempt, defaults to 10s.
+	Timeout Duration `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	// MaxAttempts is the number of attempts before a delivery is dead, defaults to 10.
+	MaxAttempts int `json:"maxAttempts,omitempty" yaml:"maxAttempts,omitempty"`
+	// Backoff is the delay after the first failed attempt, it doubles with each further failure. Defaults to 30s.
+	Backoff Duration `json:"backoff,omitempty" yaml:"backoff,omitempty"`
+	// MaxBackoff is the longest delay between two attempts, defaults to 6h.
+	MaxBackoff Duration `json:"maxBackoff,omitempty" yaml:"maxBackoff,omitempty"`
+}
+
+// WebhookEndpoint is a receiver of events.
+type WebhookEndpoint struct {
+	// ID identifies the endpoint in deliveries, it must not change while deliveries are pending.
+	ID string `json:"id" yaml:"id"`
+	// URL is where events are posted to.
+	URL string `json:"url" yaml:"url"`
+	// Secret is a reference to the key of the HMAC signature of deliveries.
+	Secret secrets.Ref `json:"secret" yaml:"secret"`
+	// Events are the event types sent to the endpoint, e.g. user.created. All events are sent if it is empty.
+	Events []string `json:"events,omitempty" yaml:"events,omitempty"`
+}
+
+// Audit configures the audit log.
+type Audit struct {
+	// SigningKey is a reference to the base64 encoded Ed25519 seed or private key that
+	// signs checkpoints of the audit log. Checkpoints are not signed if it is not set.
+	SigningKey secrets.Ref `json:"signingKey,omitempty" yaml:"signingKey,omitempty"`
+	// CheckpointInterval is the time between checkpoints, defaults to 1h.
+	CheckpointInterval Duration `json:"checkpointInterval,omitempty" yaml:"checkpointInterval,omitempty"`
+}
+
+const (
+	// RateLimitStorageMemory counts requests per replica.
+	RateLimitStorageMemory = "memory"
+	// RateLimitStoragePostgres counts requests in the database, shared by all replicas.
+	RateLimitStoragePostgres = "postgres"
+)
+
+// RateLimitGroups are the route groups that can be rate limited. The ip, number
+// and attempt groups are always limited, with defaults if they are not configured.
+var RateLimitGroups = []string{"login", "signup", "token", "admin", "ip", "number", "attempt"}
+
+// RateLimit configures the rate limits of route groups. Groups without a limit are not limited.
+type RateLimit struct {
+	// Storage is where requests are counted, memory (default) or postgres.
+	// Postgres shares the counts between replicas, at the cost of a transaction
+	// that locks the row of the key per request, see ratelimit.Database.
+	Storage string `json:"storage,omitempty" yaml:"storage,omitempty"`
+	// TrustedProxies are the IPs and CIDR ranges of proxies whose ProxyHeader is trusted.
+	TrustedProxies []string `json:"trustedProxies,omitempty" yaml:"trustedProxies,omitempty"`
+	// ProxyHeader is the header with the client IP set by proxies, defaults to X-Forwarded-For.
+	ProxyHeader string `json:"proxyHeader,omitempty" yaml:"proxyHeader,omitempty"`
+	// Groups are the limits per route group: login, signup, token or admin, and
+	// the built-in limits: ip limits the codes, links and passwords tried per
+	// client IP, number the SMS sent per phone number and attempt the tries to
+	// enter an SMS code.
+	Groups map[string]RateLimitGroup `json:"groups,omitempty" yaml:"groups,omitempty"`
+}
+
+// RateLimitGroup is the rate limit of a route group per client IP.
+type RateLimitGroup struct {
+	// Algorithm is sliding-window (default) or token-bucket.
+	Algorithm string `json:"algorithm,omitempty" yaml:"algorithm,omitempty"`
+	// Limit is the number of requests allowed per window.
+	Limit int `json:"limit" yaml:"limit"`
+	// Window is the period of the limit.
+	Window Duration `json:"window" yaml:"window"`
+}
+
+// Proxies parses the trusted proxies. Single IPs are ranges of one address.
+func (r RateLimit) Proxies() ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(r.TrustedProxies))
+
+	for i, p := range r.TrustedProxies {
+		if addr, err := netip.ParseAddr(p); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(p)
+		if err != nil {
+			return nil, fmt.Errorf("rateLimit.trustedProxies[%d]: %q is neither an IP nor a CIDR range", i, p)
+		}
+
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	return prefixes, nil
+}
+
+// Lockout configures the lockout after failed logins, codes and tokens.
+// Zero values use the defaults.
+type Lockout struct {
+	// SubjectThreshold is the number of failures of a login name before it is locked, defaults to 10.
+	SubjectThreshold int `json:"subjectThreshold,omitempty" yaml:"subjectThreshold,omitempty"`
+	// IPThreshold is the number of failures of a client IP before it is locked, defaults to 50.
+	IPThreshold int `json:"ipThreshold,omitempty" yaml:"ipThreshold,omitempty"`
+	// SubjectIPThreshold is the number of failures of a login name from a client IP before it is locked, defaults to 5.
+	SubjectIPThreshold int `json:"subjectIpThreshold,omitempty" yaml:"subjectIpThreshold,omitempty"`
+	// BaseDelay is the first lock, it doubles with each further failure. Defaults to 30s.
+	BaseDelay Duration `json:"baseDelay,omitempty" yaml:"baseDelay,omitempty"`
+	// MaxDelay is the longest lock, defaults to 1h.
+	MaxDelay Duration `json:"maxDelay,omitempty" yaml:"maxDelay,omitempty"`
+	// Window is the time without failures after which the counts start over, defaults to 24h.
+	Window Duration `json:"window,omitempty" yaml:"window,omitempty"`
+}
+
+// Password configures passwords. Zero values use the defaults.
+type Password struct {
+	// MinLength is the minimum number of characters, defaults to 12.
+	MinLength int `json:"minLength,omitempty" yaml:"minLength,omitempty"`
+	// MaxLength is the maximum number of characters, defaults to 128.
+	MaxLength int `json:"maxLength,omitempty" yaml:"maxLength,omitempty"`
+	// RequireUpper requires an upper case letter.
+	RequireUpper bool `json:"requireUpper,omitempty" yaml:"requireUpper,omitempty"`
+	// RequireLower requires a lower case letter.
+	RequireLower bool `json:"requireLower,omitempty" yaml:"requireLower,omitempty"`
+	// RequireDigit requires a digit.
+	RequireDigit bool `json:"requireDigit,omitempty" yaml:"requireDigit,omitempty"`
+	// RequireSymbol requires a character that is neither a letter nor a digit.
+	RequireSymbol bool `json:"requireSymbol,omitempty" yaml:"requireSymbol,omitempty"`
+	// Argon2 sets the hash parameters. Hashes with other parameters are upgraded at the next login.
+	Argon2 Argon2 `json:"argon2,omitempty" yaml:"argon2,omitempty"`
+	// BreachCorpus is a file or directory of breached password hashes in the range format of
+	// Have I Been Pwned. New passwords found in it are rejected.
+	BreachCorpus string `json:"breachCorpus,omitempty" yaml:"breachCorpus,omitempty"`
+	// BreachMinCount skips hashes of the corpus seen less often, to reduce memory.
+	BreachMinCount int `json:"breachMinCount,omitempty" yaml:"breachMinCount,omitempty"`
+	// ResetURL is the page reset links point to, defaults to <baseUrl>/auth/password/reset.
+	ResetURL string `json:"resetUrl,omitempty" yaml:"resetUrl,omitempty"`
+	// ResetTTL is the lifetime of reset links, defaults to 1 hour.
+	ResetTTL Duration `json:"resetTtl,omitempty" yaml:"resetTtl,omitempty"`
+}
+
+// Argon2 are the parameters of Argon2id.
+type Argon2 struct {
+	// Memory is the memory used in KiB, defaults to 65536.
+	Memory uint32 `json:"memory,omitempty" yaml:"memory,omitempty"`
+	// Iterations is the number of passes over the memory, defaults to 3.
+	Iterations uint32 `json:"iterations,omitempty" yaml:"iterations,omitempty"`
+	// Parallelism is the number of threads, defaults to 2.
+	Parallelism uint8 `json:"parallelism,omitempty" yaml:"parallelism,omitempty"`
+}
+
+// SMS configures how text messages are sent. Exactly one of the senders must be set.
+type SMS struct {
+	// Twilio sends text messages with the Twilio API, or a service compatible with it.
+	Twilio *Twilio `json:"twilio,omitempty" yaml:"twilio,omitempty"`
+	// Log logs text messages instead of sending them, e.g. for development.
+	Log bool `json:"log,omitempty" yaml:"log,omitempty"`
+	// File appends text messages to the file instead of sending them, e.g. for tests.
+	File string `json:"file,omitempty" yaml:"file,omitempty"`
+	// CodeTTL is the lifetime of codes, defaults to 10 minutes.
+	CodeTTL Duration `json:"codeTtl,omitempty" yaml:"codeTtl,omitempty"`
+}
+
+// Twilio is the configuration of the Twilio Messages API.
+type Twilio struct {
+	// AccountSID is the account to send messages with.
+	AccountSID string `json:"accountSid" yaml:"accountSid"`
+	// AuthToken is a reference to the auth token of the account.
+	AuthToken secrets.Ref `json:"authToken" yaml:"authToken"`
+	// From is the phone number or messaging service messages are sent from.
+	From string `json:"from" yaml:"from"`
+	// BaseURL is the base URL of the API, defaults to https://api.twilio.com.
+	BaseURL string `json:"baseUrl,omitempty" yaml:"baseUrl,omitempty"`
+}
+
+// Mail configures how emails are sent. Emails are logged if no SMTP server is configured.
+type Mail struct {
+	// From is the sender address.
+	From string `json:"from,omitempty" yaml:"from,omitempty"`
+	// SMTP is the SMTP server used to send emails.
+	SMTP *SMTP `json:"smtp,omitempty" yaml:"smtp,omitempty"`
+}
+
+// SMTP is the configuration of an SMTP server.
+type SMTP struct {
+	// Host is the host name of the server.
+	Host string `json:"host" yaml:"host"`
+	// Port is the port of the server, defaults to 587.
+	Port int `json:"port,omitempty" yaml:"port,omitempty"`
+	// Username is the user to authenticate with, if any.
+	Username string `json:"username,omitempty" yaml:"username,omitempty"`
+	// Password is a reference to the password of the user.
+	Password secrets.Ref `json:"password,omitempty" yaml:"password,omitempty"`
+}
+
+// Janitor configures the background cleanup of expired and deleted rows.
+// Zero values use the defaults of the janitor.
+type Janitor struct {
+	// Enabled runs the janitor in the background of the server.
+	Enabled bool `json:"enabled,omitempty" yaml:"enabled,omitempty"`
+	// Interval is the time between two runs.
+	Interval Duration `json:"interval,omitempty" yaml:"interval,omitempty"`
+	// Retention is the time soft-deleted rows are kept before they are removed.
+	Retention Duration `json:"retention,omitempty" yaml:"retention,omitempty"`
+	// AnonymousRetention is the time anonymous users that never converted are kept after their last activity.
+	AnonymousRetention Duration `json:"anonymousRetention,omitempty" yaml:"anonymousRetention,omitempty"`
+	// BatchSize is the number of rows removed per statement.
+	BatchSize int `json:"batchSize,omitempty" yaml:"batchSize,omitempty"`
+}
+
+// Encryption configures the keys used to encrypt data at rest.
+type Encryption struct {
+	// Keys are the key encryption keys. The first key encrypts new values,
+	// all keys decrypt. Rotate by adding a new key at the top.
+	Keys []EncryptionKey `json:"keys,omitempty" yaml:"keys,omitempty"`
+}
+
+// EncryptionKey is a key encryption key.
+type EncryptionKey struct {
+	// ID identifies the key in encrypted values.
+	ID string `json:"id" yaml:"id"`
+	// Secret is a reference to the base64 encoded 32 byte key.
+	Secret secrets.Ref `json:"secret" yaml:"secret"`
+}
+
+// Provider is the configuration of a single authentication provider.
+type Provider struct {
+	// ID is the unique ID of the provider within its tenant, used in routes and accounts.
+	ID string `json:"id" yaml:"id"`
+	// Tenant is the slug of the organization the provider is registered for.
+	// Tenant providers are offered on the hosts and paths of the tenant only.
+	// Their users become members of the organization, their sessions are
+	// active in no other organization, and they only assert email addresses
+	// at the domains the organization captures.
+	Tenant string `json:"tenant,omitempty" yaml:"tenant,omitempty"`
+	// Type is the kind of the provider.
+	Type ProviderKind `json:"type" yaml:"type"`
+	// Name is the display name of the provider.
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+	// ClientID is the OAuth2 client ID.
+	ClientID string `json:"clientId,omitempty" yaml:"clientId,omitempty"`
+	// ClientSecret is a reference to the OAuth2 client secret, e.g. env://GITHUB_SECRET.
+	ClientSecret secrets.Ref `json:"clientSecret,omitempty" yaml:"clientSecret,omitempty"`
+	// CallbackURL is the URL the provider redirects back to.
+	CallbackURL string `json:"callbackUrl,omitempty" yaml:"callbackUrl,omitempty"`
+	// Scopes are additional scopes to request.
+	Scopes []string `json:"scopes,omitempty" yaml:"scopes,omitempty"`
+	// AllowedOrgs restricts the login to members of these organizations.
+	AllowedOrgs []string `json:"allowedOrgs,omitempty" yaml:"allowedOrgs,omitempty"`
+	// Roles maps GitHub organizations and teams to roles and AppMetadata.
+	Roles []RoleMapping `json:"roles,omitempty" yaml:"roles,omitempty"`
+	// EnterpriseURL is the base URL of a GitHub Enterprise server.
+	EnterpriseURL string `json:"enterpriseUrl,omitempty" yaml:"enterpriseUrl,omitempty"`
+	// Issuer is the issuer URL of an OIDC provider. Its endpoints and keys are
+	// discovered from /.well-known/openid-configuration below the issuer.
+	Issuer string `json:"issuer,omitempty" yaml:"issuer,omitempty"`
+	// SAML is the configuration of a SAML provider.
+	SAML *SAMLProvider `json:"saml,omitempty" yaml:"saml,omitempty"`
+}
+
+// RoleMapping maps the membership in an organization or team to a role.
+type RoleMapping struct {
+	// Org is the organization.
+	Org string `json:"org" yaml:"org"`
+	// Team is the team slug within the organization. If empty, any member matches.
+	Team string `json:"team,omitempty" yaml:"team,omitempty"`
+	// Role is the name of the role assigned to matching users. The roles assigned by
+	// the mappings of a provider are synced at every login, unknown roles are skipped.
+	Role string `json:"role,omitempty" yaml:"role,omitempty"`
+	// Metadata are AppMetadata entries set for matching users.
+	Metadata map[string]string `json:"metadata,omitempty" yaml:"metadata,omitempty"`
+}
+
+// SAMLProvider is the SAML specific configuration of a provider.
+type SAMLProvider struct {
+	// EntityID is the entity ID of this service provider.
+	EntityID string `json:"entityId" yaml:"entityId"`
+	// Metadata is the inline IdP metadata XML.
+	Metadata string `json:"metadata,omitempty" yaml:"metadata,omitempty"`
+	// MetadataURL is the URL to fetch the IdP metadata from.
+	MetadataURL string `json:"metadataUrl,omitempty" yaml:"metadataUrl,omitempty"`
+	// MetadataFile is the path to a file containing the IdP metadata.
+	MetadataFile string `json:"metadataFile,omitempty" yaml:"metadataFile,omitempty"`
+	// CertificateFile is the path to the PEM encoded service provider certificate.
+	CertificateFile string `json:"certificateFile,omitempty" yaml:"certificateFile,omitempty"`
+	// Key is a reference to the PEM encoded service provider private key,
+	// e.g. file:///run/secrets/saml.key.
+	Key secrets.Ref `json:"key,omitempty" yaml:"key,omitempty"`
+}
+
+// ProviderError is returned when a provider entry is invalid.
+type ProviderError struct {
+	// Index is the position of the entry in the providers list.
+	Index int
+	// ID is the ID of the entry, if any.
+	ID string
+	// Err is the underlying error.
+	Err error
+}
+
+// Error implements the error interface.
+func (e *ProviderError) Error() string {
+	return fmt.Sprintf("providers[%d] (id %q): %s", e.Index, e.ID, e.Err)
+}
+
+// Unwrap implements the errors.Wrapper interface.
+func (e *ProviderError) Unwrap() error { return e.Err }
+
+// NewProviderError returns a new ProviderError.
+func NewProviderError(index int, id string, err error) *ProviderError {
+	return &ProviderError{Index: index, ID: id, Err: err}
+}
+
+// LoadFile reads, parses and validates the configuration file at path.
+func LoadFile(path string) (*File, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := ParseFile(filepath.Ext(path), data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if err := f.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return f, nil
+}
+
+// ParseFile parses the configuration from data in the format given by ext.
+func ParseFile(ext string, data []byte) (*File, error) {
+	f := &File{}
+
+	switch strings.ToLower(ext) {
+	case ".yaml", ".yml":
+		if err := yaml.UnmarshalWithOptions(data, f, yaml.Strict()); err != nil {
+			return nil, errors.New(yaml.FormatError(err, false, true))
+		}
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+
+		if err := dec.Decode(f); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+
+	return f, nil
+}
+
+// Validate validates the configuration file and returns all errors found.
+func (f *File) Validate() error {
+	errs := []error{}
+	ids := map[string]bool{}
+
+	switch f.AccountLinking {
+	case "", models.LinkPolicyNever, models.LinkPolicyVerified, models.LinkPolicyAlways:
+	default:
+		errs = append(errs, fmt.Errorf("accountLinking: must be one of never, verified or always, got %q", f.AccountLinking))
+	}
+
+	keys := map[string]bool{}
+	for i, k := range f.Encryption.Keys {
+		switch {
+		case k.ID == "" || strings.Contains(k.ID, ":"):
+			errs = append(errs, fmt.Errorf("encryption.keys[%d]: id must be non-empty and must not contain ':'", i))
+		case keys[k.ID]:
+			errs = append(errs, fmt.Errorf("encryption.keys[%d] (id %q): %w", i, k.ID, ErrDuplicateID))
+		case k.Secret.IsZero():
+			errs = append(errs, fmt.Errorf("encryption.keys[%d] (id %q): secret is required", i, k.ID))
+		}
+
+		keys[k.ID] = true
+	}
+
+	if f.Audit.CheckpointInterval < 0 {
+		errs = append(errs, errors.New("audit.checkpointInterval must not be negative"))
+	}
+
+	if f.Janitor.Interval < 0 || f.Janitor.Retention < 0 || f.Janitor.AnonymousRetention < 0 || f.Janitor.BatchSize < 0 {
+		errs = append(errs, errors.New("janitor: interval, retention, anonymousRetention and batchSize must not be negative"))
+	}
+
+	if err := validateURL("baseUrl", f.BaseURL, false); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := validateURL("organizations.inviteUrl", f.Organizations.InviteURL, false); err != nil {
+		errs = append(errs, err)
+	}
+
+	if f.Organizations.InviteTTL < 0 {
+		errs = append(errs, errors.New("organizations.inviteTtl must not be negative"))
+	}
+
+	errs = append(errs, f.Tenancy.validate()...)
+
+	if f.Mail.SMTP != nil {
+		if f.Mail.SMTP.Host == "" {
+			errs = append(errs, errors.New("mail.smtp.host is required"))
+		}
+
+		if f.Mail.From == "" {
+			errs = append(errs, errors.New("mail.from is required to send emails with smtp"))
+		}
+	}
+
+	if f.SMS != nil {
+		errs = append(errs, f.SMS.validate()...)
+	}
+
+	if f.Password != nil {
+		errs = append(errs, f.Password.validate()...)
+	}
+
+	if l := f.Lockout; l.SubjectThreshold < 0 || l.IPThreshold < 0 || l.SubjectIPThreshold < 0 ||
+		l.BaseDelay < 0 || l.MaxDelay < 0 || l.Window < 0 {
+		errs = append(errs, errors.New("lockout: thresholds, delays and window must not be negative"))
+	}
+
+	errs = append(errs, f.RateLimit.validate()...)
+
+	if f.Webhooks != nil {
+		errs = append(errs, f.Webhooks.validate()...)
+	}
+
+	errs = append(errs, validateHooks(f.Hooks)...)
+
+	for i, p := range f.Providers {
+		for _, err := range unjoin(p.Validate()) {
+			errs = append(errs, NewProviderError(i, p.ID, err))
+		}
+
+		if p.Tenant != "" && !models.ValidSlug(p.Tenant) {
+			errs = append(errs, NewProviderError(i, p.ID, ErrInvalidTenant))
+		}
+
+		k := models.ProviderKey(p.Tenant, p.ID)
+		if p.ID != "" && ids[k] {
+			errs = append(errs, NewProviderError(i, p.ID, ErrDuplicateID))
+		}
+
+		ids[k] = true
+	}
+
+	return errors.Join(errs...)
+}
+
+func (t *Tenancy) validate() []error {
+	errs := []error{}
+
+	if strings.ContainsAny(t.Domain, "/:") {
+		errs = append(errs, fmt.Errorf("tenancy.domain: must be a host name, got %q", t.Domain))
+	}
+
+	for host, tenant := range t.Hosts {
+		if !models.ValidSlug(tenant) {
+			errs = append(errs, fmt.Errorf("tenancy.hosts[%s]: %w", host, ErrInvalidTenant))
+		}
+	}
+
+	return errs
+}
+
+func (s *SMS) validate() []error {
+	errs := []error{}
+
+	senders := 0
+	for _, set := range []bool{s.Twilio != nil, s.Log, s.File != ""} {
+		if set {
+			senders++
+		}
+	}
+
+	if senders != 1 {
+		errs = append(errs, errors.New("sms: exactly one of twilio, log or file is required"))
+	}
+
+	if t := s.Twilio; t != nil {
+		if t.AccountSID == "" || t.AuthToken.IsZero() || t.From == "" {
+			errs = append(errs, errors.New("sms.twilio: accountSid, authToken and from are required"))
+		}
+
+		if err := validateURL("sms.twilio.baseUrl", t.BaseURL, false); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if s.CodeTTL < 0 {
+		errs = append(errs, errors.New("sms.codeTtl must not be negative"))
+	}
+
+	return errs
+}
+
+func (p *Password) validate() []error {
+	errs := []error{}
+
+	if p.MinLength < 0 || p.MaxLength < 0 || p.BreachMinCount < 0 || p.ResetTTL < 0 {
+		errs = append(errs, errors.New("password: minLength, maxLength, breachMinCount and resetTtl must not be negative"))
+	}
+
+	if p.MaxLength > 0 && p.MinLength > p.MaxLength {
+		errs = append(errs, errors.New("password.minLength must not be greater than password.maxLength"))
+	}
+
+	if p.Argon2.Memory > 0 && p.Argon2.Memory < 8*uint32(max(p.Argon2.Parallelism, 1)) {
+		errs = append(errs, errors.New("password.argon2.memory must be at least 8 KiB per thread"))
+	}
+
+	if err := validateURL("password.resetUrl", p.ResetURL, false); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errs
+}
+
+func (r *RateLimit) validate() []error {
+	errs := []error{}
+
+	switch r.Storage {
+	case "", RateLimitStorageMemory, RateLimitStoragePostgres:
+	default:
+		errs = append(errs, fmt.Errorf("rateLimit.storage: must be memory or postgres, got %q", r.Storage))
+	}
+
+	if _, err := r.Proxies(); err != nil {
+		errs = append(errs, err)
+	}
+
+	for name, g := range r.Groups {
+		if !slices.Contains(RateLimitGroups, name) {
+			errs = append(errs, fmt.Errorf("rateLimit.groups: unknown group %q, must be one of %s", name, strings.Join(RateLimitGroups, ", ")))
+		}
+
+		if g.Limit <= 0 || g.Window <= 0 {
+			errs = append(errs, fmt.Errorf("rateLimit.groups.%s: limit and window must be positive", name))
+		}
+	}
+
+	return errs
+}
+
+func (w *Webhooks) validate() []error {
+	errs := []error{}
+
+	if w.Interval < 0 || w.Timeout < 0 || w.MaxAttempts < 0 || w.Backoff < 0 || w.MaxBackoff < 0 {
+		errs = append(errs, errors.New("webhooks: interval, timeout, maxAttempts, backoff and maxBackoff must not be negative"))
+	}
+
+	ids := map[string]bool{}
+	for i, e := range w.Endpoints {
+		switch {
+		case strings.TrimSpace(e.ID) == "":
+			errs = append(errs, fmt.Errorf("webhooks.endpoints[%d]: %w", i, ErrMissingID))
+		case ids[e.ID]:
+			errs = append(errs, fmt.Errorf("webhooks.endpoints[%d] (id %q): %w", i, e.ID, ErrDuplicateID))
+		}
+
+		ids[e.ID] = true
+
+		if err := validateURL(fmt.Sprintf("webhooks.endpoints[%d].url", i), e.URL, true); err != nil {
+			errs = append(errs, err)
+		}
+
+		if e.Secret.IsZero() {
+			errs = append(errs, fmt.Errorf("webhooks.endpoints[%d] (id %q): secret is required", i, e.ID))
+		}
+	}
+
+	return errs
+}
+
+func validateHooks(hs []Hook) []error {
+	errs := []error{}
+
+	ids := map[string]bool{}
+	for i, h := range hs {
+		switch {
+		case strings.TrimSpace(h.ID) == "":
+			errs = append(errs, fmt.Errorf("hooks[%d]: %w", i, ErrMissingID))
+		case ids[h.ID]:
+			errs = append(errs, fmt.Errorf("hooks[%d] (id %q): %w", i, h.ID, ErrDuplicateID))
+		}
+
+		ids[h.ID] = true
+
+		if len(h.Events) == 0 {
+			errs = append(errs, fmt.Errorf("hooks[%d] (id %q): events are required", i, h.ID))
+		}
+
+		switch {
+		case (h.CEL == "") == (h.HTTP == nil):
+			errs = append(errs, fmt.Errorf("hooks[%d] (id %q): exactly one of cel and http is required", i, h.ID))
+		case h.HTTP != nil:
+			if err := validateURL(fmt.Sprintf("hooks[%d].http.url", i), h.HTTP.URL, true); err != nil {
+				errs = append(errs, err)
+			}
+
+			if h.HTTP.Secret.IsZero() {
+				errs = append(errs, fmt.Errorf("hooks[%d] (id %q): http.secret is required", i, h.ID))
+			}
+
+			if h.HTTP.Timeout < 0 {
+				errs = append(errs, fmt.Errorf("hooks[%d] (id %q): http.timeout must not be negative", i, h.ID))
+			}
+		}
+	}
+
+	return errs
+}
+
+// Validate validates a single provider entry.
+func (p *Provider) Validate() error {
+	if strings.TrimSpace(p.ID) == "" {
+		return ErrMissingID
+	}
+
+	if strings.Contains(p.ID, "/") {
+		return ErrInvalidID
+	}
+
+	switch p.Type {
+	case ProviderKindGitHub:
+		return p.validateGitHub()
+	case ProviderKindSAML:
+		return p.validateSAML()
+	case ProviderKindOIDC:
+		return p.validateOIDC()
+	default:
+		return fmt.Errorf("%w %q", ErrUnknownKind, p.Type)
+	}
+}
+
+func (p *Provider) validateGitHub() error {
+	errs := []error{}
+
+	if p.ClientID == "" {
+		errs = append(errs, ErrMissingClientID)
+	}
+
+	if p.ClientSecret.IsZero() {
+		errs = append(errs, ErrMissingClientSecret)
+	}
+
+	if err := validateURL("callbackUrl", p.CallbackURL, true); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := validateURL("enterpriseUrl", p.EnterpriseURL, false); err != nil {
+		errs = append(errs, err)
+	}
+
+	for i, r := range p.Roles {
+		if strings.TrimSpace(r.Org) == "" {
+			errs = append(errs, fmt.Errorf("roles[%d]: org is required", i))
+		}
+
+		if r.Role == "" && len(r.Metadata) == 0 {
+			errs = append(errs, fmt.Errorf("roles[%d]: role or metadata is required", i))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (p *Provider) validateOIDC() error {
+	errs := []error{}
+
+	if p.ClientID == "" {
+		errs = append(errs, ErrMissingClientID)
+	}
+
+	if p.ClientSecret.IsZero() {
+		errs = append(errs, ErrMissingClientSecret)
+	}
+
+	if err := validateURL("callbackUrl", p.CallbackURL, true); err != nil {
+		errs = append(errs, err)
+	}
+
+	if p.Issuer == "" {
+		errs = append(errs, ErrMissingIssuer)
+	} else if err := validateURL("issuer", p.Issuer, true); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+func (p *Provider) validateSAML() error {
+	if p.SAML == nil {
+		return ErrMissingSAML
+	}
+
+	errs := []error{}
+
+	if p.SAML.EntityID == "" {
+		errs = append(errs, ErrMissingEntityID)
+	}
+
+	if err := validateURL("callbackUrl", p.CallbackURL, true); err != nil {
+		errs = append(errs, err)
+	}
+
+	sources := 0
+	for _, s := range []string{p.SAML.Metadata, p.SAML.MetadataURL, p.SAML.MetadataFile} {
+		if s != "" {
+			sources++
+		}
+	}
+
+	if sources != 1 {
+		errs = append(errs, ErrMissingMetadata)
+	}
+
+	if err := validateURL("saml.metadataUrl", p.SAML.MetadataURL, false); err != nil {
+		errs = append(errs, err)
+	}
+
+	if (p.SAML.CertificateFile == "") != p.SAML.Key.IsZero() {
+		errs = append(errs, errors.New("saml.certificateFile and saml.key must be set together"))
+	}
+
+	return errors.Join(errs...)
+}
+
+func unjoin(err error) []error {
+	if err == nil {
+		return nil
+	}
+
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		return joined.Unwrap()
+	}
+
+	return []error{err}
+}
+
+func validateURL(field, value string, required bool) error {
+	if value == "" {
+		if required {
+			return fmt.Errorf("%s is required", field)
+		}
+
+		return nil
+	}
+
+	u, err := url.Parse(value)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("%s must be an absolute URL, got %q", field, value)
+	}
+
+	return nil
+}
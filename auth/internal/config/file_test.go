@@ -0,0 +1,260 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const validYAML = `
+accountLinking: verified
+providers:
+  - id: github
+    type: github
+    clientId: client
+    clientSecret: env://GITHUB_SECRET
+    callbackUrl: https://auth.example.com/auth/github/callback
+  - id: okta
+    type: oidc
+    tenant: acme
+    clientId: client
+    clientSecret: env://OKTA_SECRET
+    callbackUrl: https://auth.example.com/auth/okta/callback
+    issuer: https://acme.okta.com
+janitor:
+  interval: 1h
+`
+
+const validJSON = `{
+  "providers": [
+    {
+      "id": "github",
+      "type": "github",
+      "clientId": "client",
+      "clientSecret": "env://GITHUB_SECRET",
+      "callbackUrl": "https://auth.example.com/auth/github/callback"
+    }
+  ],
+  "janitor": {"interval": "1h"}
+}`
+
+func writeFile(t *testing.T, name, data string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestParseFile(t *testing.T) {
+	tests := []struct {
+		name      string
+		ext       string
+		data      string
+		providers int
+		err       error
+		fails     bool
+	}{
+		{name: "yaml", ext: ".yaml", data: validYAML, providers: 2},
+		{name: "yml", ext: ".YML", data: validYAML, providers: 2},
+		{name: "json", ext: ".json", data: validJSON, providers: 1},
+		{name: "unknown yaml field", ext: ".yaml", data: "providerz: []\n", fails: true},
+		{name: "unknown json field", ext: ".json", data: `{"providerz": []}`, fails: true},
+		{name: "malformed json", ext: ".json", data: `{"providers": [`, fails: true},
+		{name: "unsupported format", ext: ".toml", data: validYAML, err: ErrUnsupportedFormat, fails: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := ParseFile(tt.ext, []byte(tt.data))
+			if (err != nil) != tt.fails || (tt.err != nil && !errors.Is(err, tt.err)) {
+				t.Fatalf("ParseFile() error = %v, want %v", err, tt.err)
+			}
+
+			if tt.fails {
+				return
+			}
+
+			if len(f.Providers) != tt.providers {
+				t.Errorf("ParseFile() providers = %d, want %d", len(f.Providers), tt.providers)
+			}
+
+			if f.Janitor.Interval.Duration() != time.Hour {
+				t.Errorf("ParseFile() janitor.interval = %v, want 1h", f.Janitor.Interval.Duration())
+			}
+		})
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	f, err := LoadFile(writeFile(t, "auth.yaml", validYAML))
+	if err != nil {
+		t.Fatalf("LoadFile() error = %v", err)
+	}
+
+	if p := f.Providers[1]; p.ID != "okta" || p.Tenant != "acme" || p.ClientSecret != "env://OKTA_SECRET" {
+		t.Errorf("LoadFile() provider = %+v", p)
+	}
+
+	if _, err := LoadFile(writeFile(t, "auth.json", validJSON)); err != nil {
+		t.Errorf("LoadFile() error = %v", err)
+	}
+
+	path := writeFile(t, "auth.yaml", "providers:\n  - id: github\n    type: gitlab\n")
+	if _, err := LoadFile(path); !errors.Is(err, ErrUnknownKind) || !strings.HasPrefix(err.Error(), path) {
+		t.Errorf("LoadFile() error = %v, want %v prefixed by the path", err, ErrUnknownKind)
+	}
+
+	if _, err := LoadFile(filepath.Join(t.TempDir(), "missing.yaml")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("LoadFile() error = %v, want %v", err, os.ErrNotExist)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	github := Provider{
+		ID:           "github",
+		Type:         ProviderKindGitHub,
+		ClientID:     "client",
+		ClientSecret: "env://GITHUB_SECRET",
+		CallbackURL:  "https://auth.example.com/auth/github/callback",
+	}
+
+	with := func(fn func(p *Provider)) Provider {
+		p := github
+		fn(&p)
+
+		return p
+	}
+
+	tests := []struct {
+		name  string
+		file  File
+		index int
+		id    string
+		err   error
+	}{
+		{name: "valid", file: File{Providers: []Provider{github}}},
+		{
+			name: "same id in another tenant",
+			file: File{Providers: []Provider{github, with(func(p *Provider) { p.Tenant = "acme" })}},
+		},
+		{
+			name:  "missing id",
+			file:  File{Providers: []Provider{github, with(func(p *Provider) { p.ID = " " })}},
+			index: 1,
+			id:    " ",
+			err:   ErrMissingID,
+		},
+		{
+			name: "slash in id",
+			file: File{Providers: []Provider{with(func(p *Provider) { p.ID = "acme/github" })}},
+			id:   "acme/github",
+			err:  ErrInvalidID,
+		},
+		{
+			name:  "duplicate id",
+			file:  File{Providers: []Provider{github, github}},
+			index: 1,
+			id:    "github",
+			err:   ErrDuplicateID,
+		},
+		{
+			name: "invalid tenant",
+			file: File{Providers: []Provider{with(func(p *Provider) { p.Tenant = "Acme Inc" })}},
+			id:   "github",
+			err:  ErrInvalidTenant,
+		},
+		{
+			name: "unknown type",
+			file: File{Providers: []Provider{with(func(p *Provider) { p.Type = "gitlab" })}},
+			id:   "github",
+			err:  ErrUnknownKind,
+		},
+		{
+			name: "missing client secret",
+			file: File{Providers: []Provider{with(func(p *Provider) { p.ClientSecret = "" })}},
+			id:   "github",
+			err:  ErrMissingClientSecret,
+		},
+		{
+			name: "oidc without issuer",
+			file: File{Providers: []Provider{with(func(p *Provider) { p.ID, p.Type = "okta", ProviderKindOIDC })}},
+			id:   "okta",
+			err:  ErrMissingIssuer,
+		},
+		{
+			name: "saml without metadata",
+			file: File{Providers: []Provider{with(func(p *Provider) {
+				p.ID, p.Type, p.SAML = "adfs", ProviderKindSAML, &SAMLProvider{EntityID: "https://auth.example.com"}
+			})}},
+			id:  "adfs",
+			err: ErrMissingMetadata,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.file.Validate()
+			if tt.err == nil {
+				if err != nil {
+					t.Fatalf("Validate() error = %v", err)
+				}
+
+				return
+			}
+
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Validate() error = %v, want %v", err, tt.err)
+			}
+
+			var perr *ProviderError
+			if !errors.As(err, &perr) {
+				t.Fatalf("Validate() error = %v, want a ProviderError", err)
+			}
+
+			if perr.Index != tt.index || perr.ID != tt.id {
+				t.Errorf("Validate() provider = [%d] %q, want [%d] %q", perr.Index, perr.ID, tt.index, tt.id)
+			}
+		})
+	}
+}
+
+func TestValidateSettings(t *testing.T) {
+	tests := []struct {
+		name string
+		file File
+		want string
+	}{
+		{name: "link policy", file: File{AccountLinking: "sometimes"}, want: "accountLinking"},
+		{name: "tenancy host", file: File{Tenancy: Tenancy{Hosts: map[string]string{"login.acme.com": "-acme"}}}, want: "tenancy.hosts[login.acme.com]"},
+		{name: "rate limit group", file: File{RateLimit: RateLimit{Groups: map[string]RateLimitGroup{"nope": {Limit: 1, Window: Duration(time.Minute)}}}}, want: "unknown group"},
+		{name: "webhook without secret", file: File{Webhooks: &Webhooks{Endpoints: []WebhookEndpoint{{ID: "crm", URL: "https://crm.example.com"}}}}, want: "secret is required"},
+		{name: "hook without expression", file: File{Hooks: []Hook{{ID: "deny", Events: []string{"before_user_created"}}}}, want: "exactly one of cel and http"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.file.Validate(); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestProviderError(t *testing.T) {
+	err := NewProviderError(2, "okta", ErrMissingIssuer)
+
+	if got, want := err.Error(), `providers[2] (id "okta"): issuer is required for type oidc`; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+
+	if !errors.Is(err, ErrMissingIssuer) {
+		t.Errorf("errors.Is(%v, ErrMissingIssuer) = false", err)
+	}
+}
@@ -0,0 +1,120 @@
+package controllers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/open-cloud-initiative/glue/auth/internal/models"
+	"github.com/open-cloud-initiative/glue/auth/internal/ports"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/katallaxie/pkg/cast"
+	"gorm.io/gorm"
+)
+
+// DefaultReauthWindow is the default time after a login in which
+// sensitive operations are allowed without logging in again.
+const DefaultReauthWindow = 5 * time.Minute
+
+// AccountController handles the linked accounts of the signed-in user.
+type AccountController struct {
+	adapter      ports.Auth
+	reauthWindow time.Duration
+}
+
+// AccountOpt is a function that configures the AccountController.
+type AccountOpt func(*AccountController)
+
+// WithReauthWindow sets the time after a login in which accounts can be unlinked.
+func WithReauthWindow(window time.Duration) AccountOpt {
+	return func(ac *AccountController) {
+		ac.reauthWindow = window
+	}
+}
+
+// NewAccountController creates a new AccountController.
+func NewAccountController(adapter ports.Auth, opts ...AccountOpt) *AccountController {
+	ac := &AccountController{
+		adapter:      adapter,
+		reauthWindow: DefaultReauthWindow,
+	}
+
+	for _, opt := range opts {
+		opt(ac)
+	}
+
+	return ac
+}
+
+// LinkedAccount is a linked account without its tokens.
+type LinkedAccount struct {
+	ID                uuid.UUID          `json:"id"`
+	Type              models.AccountType `json:"type"`
+	Tenant            string             `json:"tenant,omitempty"`
+	Provider          string             `json:"provider"`
+	ProviderAccountID string             `json:"providerAccountId"`
+	CreatedAt         time.Time          `json:"createdAt"`
+}
+
+// ListAccounts lists the accounts linked to the signed-in user.
+func (ac *AccountController) ListAccounts(ctx fiber.Ctx) error {
+	session, ok := SessionFromContext(ctx)
+	if !ok {
+		return fiber.ErrUnauthorized
+	}
+
+	accounts, err := ac.adapter.ListAccounts(ctx, session.UserID)
+	if err != nil {
+		return err
+	}
+
+	linked := make([]LinkedAccount, 0, len(accounts))
+	for _, a := range accounts {
+		linked = append(linked, LinkedAccount{
+			ID:                a.ID,
+			Type:              a.Type,
+			Tenant:            a.Tenant,
+			Provider:          a.Provider,
+			ProviderAccountID: cast.Value(a.ProviderAccountID),
+			CreatedAt:         a.CreatedAt,
+		})
+	}
+
+	return ctx.JSON(linked)
+}
+
+// UnlinkAccount unlinks an account from the signed-in user.
+// It requires a recent login and never removes the last login method.
+func (ac *AccountController) UnlinkAccount(ctx fiber.Ctx) error {
+	session, ok := SessionFromContext(ctx)
+	if !ok {
+		return fiber.ErrUnauthorized
+	}
+
+	id, err := uuid.Parse(ctx.Params("id"))
+	if err != nil {
+		return fiber.ErrBadRequest
+	}
+
+	user, err := ac.adapter.GetUser(ctx, session.UserID)
+	if err != nil {
+		return err
+	}
+
+	if time.Since(user.ReauthenticatedAt) > ac.reauthWindow {
+		return fiber.NewError(fiber.StatusUnauthorized, ports.ErrReauthenticationRequired.Error())
+	}
+
+	err = ac.adapter.UnlinkAccount(ctx, id, session.UserID)
+	switch {
+	case errors.Is(err, ports.ErrLastLoginMethod):
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return fiber.ErrNotFound
+	case err != nil:
+		return err
+	}
+
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
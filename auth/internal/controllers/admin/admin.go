@@ -0,0 +1,715 @@
+// Package admin is the gRPC admin service of the authentication server.
+package admin
+
+import (
+	"context"
+	"time"
+
+	"github.com/open-cloud-initiative/glue/auth/internal/models"
+
+	"google.golang.org/grpc"
+)
+
+// ServiceName is the full name of the admin service.
+const ServiceName = "glue.auth.admin.v1.AdminService"
+
+// GetUserRequest requests a user by ID.
+type GetUserRequest struct {
+	UserID string `json:"userId"`
+}
+
+// BanUserRequest bans a user.
+type BanUserRequest struct {
+	UserID string `json:"userId"`
+	// Duration of the ban, e.g. "72h". The user is banned indefinitely if it is empty.
+	Duration string `json:"duration,omitempty"`
+	// Reason is recorded with the ban.
+	Reason string `json:"reason"`
+}
+
+// UnbanUserRequest lifts the ban of a user.
+type UnbanUserRequest struct {
+	UserID string `json:"userId"`
+}
+
+// UnlockUserRequest resets the failed login attempts of a user.
+type UnlockUserRequest struct {
+	UserID string `json:"userId"`
+}
+
+// UnlockIPRequest resets the failed login attempts of a client IP.
+type UnlockIPRequest struct {
+	IP string `json:"ip"`
+}
+
+// UnlockIPResponse is returned when a client IP was unlocked.
+type UnlockIPResponse struct {
+	IP string `json:"ip"`
+}
+
+// RefreshBreachCorpusRequest reloads the breached password corpus from disk.
+type RefreshBreachCorpusRequest struct{}
+
+// BreachCorpus describes the loaded breached password corpus.
+type BreachCorpus struct {
+	Path   string `json:"path"`
+	Hashes int    `json:"hashes"`
+}
+
+// ListAuditEventsRequest filters audit events. Empty fields match all events.
+type ListAuditEventsRequest struct {
+	Action  string     `json:"action,omitempty"`
+	ActorID string     `json:"actorId,omitempty"`
+	UserID  string     `json:"userId,omitempty"`
+	Since   *time.Time `json:"since,omitempty"`
+	Until   *time.Time `json:"until,omitempty"`
+	// After is the ID of the last event of the previous page.
+	After int64 `json:"after,omitempty"`
+	// Limit is the page size of a list, or the maximum number of events of an export.
+	Limit int `json:"limit,omitempty"`
+}
+
+// ListAuditEventsResponse is a page of audit events.
+type ListAuditEventsResponse struct {
+	Events []*AuditEvent `json:"events"`
+	// Next is the After of the next page. It is zero on the last page.
+	Next int64 `json:"next,omitempty"`
+}
+
+// AuditEvent is an audit event as returned by the admin service.
+type AuditEvent struct {
+	ID         int64                         `json:"id"`
+	Action     string                        `json:"action"`
+	ActorType  string                        `json:"actorType,omitempty"`
+	ActorID    string                        `json:"actorId,omitempty"`
+	UserID     string                        `json:"userId,omitempty"`
+	TargetType string                        `json:"targetType"`
+	TargetID   string                        `json:"targetId"`
+	IP         string                        `json:"ip,omitempty"`
+	UserAgent  string                        `json:"userAgent,omitempty"`
+	RequestID  string                        `json:"requestId,omitempty"`
+	Diff       map[string]models.AuditChange `json:"diff,omitempty"`
+	CreatedAt  time.Time                     `json:"createdAt"`
+}
+
+// AuditEventStream sends the events of an export.
+type AuditEventStream interface {
+	Send(event *AuditEvent) error
+	Context() context.Context
+}
+
+// ListWebhookDeliveriesRequest filters webhook deliveries. Empty fields match all deliveries.
+type ListWebhookDeliveriesRequest struct {
+	EventID  int64  `json:"eventId,omitempty"`
+	Endpoint string `json:"endpoint,omitempty"`
+	// Status is pending, delivered or dead.
+	Status string `json:"status,omitempty"`
+	// After is the ID of the last delivery of the previous page.
+	After int64 `json:"after,omitempty"`
+	Limit int   `json:"limit,omitempty"`
+}
+
+// ListWebhookDeliveriesResponse is a page of webhook deliveries.
+type ListWebhookDeliveriesResponse struct {
+	Deliveries []*WebhookDelivery `json:"deliveries"`
+	// Next is the After of the next page. It is zero on the last page.
+	Next int64 `json:"next,omitempty"`
+}
+
+// ReplayWebhookEventRequest delivers an outbox event again.
+type ReplayWebhookEventRequest struct {
+	EventID int64 `json:"eventId"`
+	// Endpoint is the endpoint to deliver to. The event is delivered to all
+	// endpoints subscribed to it if it is empty.
+	Endpoint string `json:"endpoint,omitempty"`
+}
+
+// ReplayWebhookEventResponse lists the deliveries queued by a replay.
+type ReplayWebhookEventResponse struct {
+	Deliveries []*WebhookDelivery `json:"deliveries"`
+}
+
+// RetryDeadWebhookDeliveriesRequest queues the dead deliveries again.
+type RetryDeadWebhookDeliveriesRequest struct {
+	// Endpoint limits the retry to the deliveries to the endpoint.
+	Endpoint string `json:"endpoint,omitempty"`
+}
+
+// RetryDeadWebhookDeliveriesResponse is returned when dead deliveries were queued.
+type RetryDeadWebhookDeliveriesResponse struct {
+	Queued int `json:"queued"`
+}
+
+// WebhookDelivery is a webhook delivery as returned by the admin service.
+type WebhookDelivery struct {
+	ID             int64      `json:"id"`
+	EventID        int64      `json:"eventId"`
+	Endpoint       string     `json:"endpoint"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	NextAttemptAt  *time.Time `json:"nextAttemptAt,omitempty"`
+	LastStatusCode int        `json:"lastStatusCode,omitempty"`
+	LastError      string     `json:"lastError,omitempty"`
+	DeliveredAt    *time.Time `json:"deliveredAt,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt"`
+}
+
+// ListPermissionsRequest requests all permissions.
+type ListPermissionsRequest struct{}
+
+// ListPermissionsResponse lists all permissions.
+type ListPermissionsResponse struct {
+	Permissions []*Permission `json:"permissions"`
+}
+
+// Permission is a permission as returned by the admin service.
+type Permission struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// ListRolesRequest requests all roles.
+type ListRolesRequest struct{}
+
+// ListRolesResponse lists all roles.
+type ListRolesResponse struct {
+	Roles []*Role `json:"roles"`
+}
+
+// CreateRoleRequest creates a role.
+type CreateRoleRequest struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Permissions []string `json:"permissions"`
+}
+
+// UpdateRoleRequest replaces the description and permissions of a role.
+type UpdateRoleRequest struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Permissions []string `json:"permissions"`
+}
+
+// DeleteRoleRequest deletes a role and removes it from all users.
+type DeleteRoleRequest struct {
+	Name string `json:"name"`
+}
+
+// DeleteRoleResponse is returned when a role was deleted.
+type DeleteRoleResponse struct {
+	Name string `json:"name"`
+}
+
+// Role is a role as returned by the admin service.
+type Role struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	BuiltIn     bool     `json:"builtIn,omitempty"`
+	Permissions []string `json:"permissions"`
+}
+
+// ListUserRolesRequest requests the roles of a user.
+type ListUserRolesRequest struct {
+	UserID string `json:"userId"`
+}
+
+// AssignRoleRequest assigns a role to a user.
+type AssignRoleRequest struct {
+	UserID string `json:"userId"`
+	Role   string `json:"role"`
+}
+
+// UnassignRoleRequest removes a role from a user.
+type UnassignRoleRequest struct {
+	UserID string `json:"userId"`
+	Role   string `json:"role"`
+}
+
+// UserRoles are the roles of a user and the permissions they grant.
+type UserRoles struct {
+	UserID      string   `json:"userId"`
+	Roles       []*Role  `json:"roles"`
+	Permissions []string `json:"permissions"`
+}
+
+// CheckPermissionRequest checks a permission of a user.
+type CheckPermissionRequest struct {
+	UserID     string `json:"userId"`
+	Permission string `json:"permission"`
+}
+
+// CheckPermissionResponse tells if a user has a permission.
+type CheckPermissionResponse struct {
+	Allowed bool `json:"allowed"`
+}
+
+// ListOrganizationsRequest requests all organizations.
+type ListOrganizationsRequest struct{}
+
+// ListOrganizationsResponse lists all organizations.
+type ListOrganizationsResponse struct {
+	Organizations []*Organization `json:"organizations"`
+}
+
+// GetOrganizationRequest requests an organization by slug.
+type GetOrganizationRequest struct {
+	Slug string `json:"slug"`
+}
+
+// CreateOrganizationRequest creates an organization.
+type CreateOrganizationRequest struct {
+	Slug             string   `json:"slug"`
+	Name             string   `json:"name,omitempty"`
+	AllowedProviders []string `json:"allowedProviders,omitempty"`
+	RequireMFA       bool     `json:"requireMfa,omitempty"`
+	Domains          []string `json:"domains,omitempty"`
+}
+
+// UpdateOrganizationRequest replaces the name and login policy of an
+// organization. The domains are kept if they are null.
+type UpdateOrganizationRequest struct {
+	Slug             string   `json:"slug"`
+	Name             string   `json:"name,omitempty"`
+	AllowedProviders []string `json:"allowedProviders,omitempty"`
+	RequireMFA       bool     `json:"requireMfa,omitempty"`
+	Domains          []string `json:"domains"`
+}
+
+// DeleteOrganizationRequest deletes an organization and its memberships.
+type DeleteOrganizationRequest struct {
+	Slug string `json:"slug"`
+}
+
+// DeleteOrganizationResponse is returned when an organization was deleted.
+type DeleteOrganizationResponse struct {
+	Slug string `json:"slug"`
+}
+
+// Organization is an organization as returned by the admin service.
+type Organization struct {
+	ID               string   `json:"id"`
+	Slug             string   `json:"slug"`
+	Name             string   `json:"name,omitempty"`
+	AllowedProviders []string `json:"allowedProviders"`
+	RequireMFA       bool     `json:"requireMfa"`
+	Domains          []string `json:"domains"`
+}
+
+// ListMembersRequest requests the members of an organization.
+type ListMembersRequest struct {
+	Slug string `json:"slug"`
+}
+
+// ListMembersResponse lists the members of an organization.
+type ListMembersResponse struct {
+	Members []*Member `json:"members"`
+}
+
+// AddMemberRequest adds a user to an organization with a role.
+type AddMemberRequest struct {
+	Slug   string `json:"slug"`
+	UserID string `json:"userId"`
+	Role   string `json:"role"`
+}
+
+// UpdateMemberRequest changes the role of a member.
+type UpdateMemberRequest struct {
+	Slug   string `json:"slug"`
+	UserID string `json:"userId"`
+	Role   string `json:"role"`
+}
+
+// RemoveMemberRequest removes a member from an organization.
+type RemoveMemberRequest struct {
+	Slug   string `json:"slug"`
+	UserID string `json:"userId"`
+}
+
+// RemoveMemberResponse is returned when a member was removed.
+type RemoveMemberResponse struct {
+	Slug   string `json:"slug"`
+	UserID string `json:"userId"`
+}
+
+// InviteMemberRequest sends an invitation to join an organization with a role by email.
+type InviteMemberRequest struct {
+	Slug  string `json:"slug"`
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+// InviteMemberResponse is returned when an invitation was sent.
+type InviteMemberResponse struct {
+	Email string `json:"email"`
+}
+
+// Member is a member of an organization as returned by the admin service.
+type Member struct {
+	UserID      string    `json:"userId"`
+	Email       string    `json:"email,omitempty"`
+	Role        string    `json:"role"`
+	Permissions []string  `json:"permissions"`
+	JoinedAt    time.Time `json:"joinedAt"`
+}
+
+// User is a user as returned by the admin service.
+type User struct {
+	ID          string     `json:"id"`
+	Email       string     `json:"email"`
+	Name        string     `json:"name"`
+	Roles       []string   `json:"roles,omitempty"`
+	BannedUntil *time.Time `json:"bannedUntil,omitempty"`
+	BanReason   string     `json:"banReason,omitempty"`
+}
+
+// AdminServer is the server API of the admin service.
+type AdminServer interface {
+	// GetUser returns a user by ID.
+	GetUser(ctx context.Context, req *GetUserRequest) (*User, error)
+	// BanUser bans a user and revokes the sessions and provider tokens of the user.
+	BanUser(ctx context.Context, req *BanUserRequest) (*User, error)
+	// UnbanUser lifts the ban of a user.
+	UnbanUser(ctx context.Context, req *UnbanUserRequest) (*User, error)
+	// UnlockUser resets the failed login attempts of a user.
+	UnlockUser(ctx context.Context, req *UnlockUserRequest) (*User, error)
+	// UnlockIP resets the failed login attempts of a client IP.
+	UnlockIP(ctx context.Context, req *UnlockIPRequest) (*UnlockIPResponse, error)
+	// RefreshBreachCorpus reloads the breached password corpus of the server from disk.
+	RefreshBreachCorpus(ctx context.Context, req *RefreshBreachCorpusRequest) (*BreachCorpus, error)
+	// ListAuditEvents returns a page of audit events.
+	ListAuditEvents(ctx context.Context, req *ListAuditEventsRequest) (*ListAuditEventsResponse, error)
+	// ExportAuditEvents streams all audit events matching the filter.
+	ExportAuditEvents(req *ListAuditEventsRequest, stream AuditEventStream) error
+	// ListWebhookDeliveries returns a page of webhook deliveries.
+	ListWebhookDeliveries(ctx context.Context, req *ListWebhookDeliveriesRequest) (*ListWebhookDeliveriesResponse, error)
+	// ReplayWebhookEvent delivers an outbox event again.
+	ReplayWebhookEvent(ctx context.Context, req *ReplayWebhookEventRequest) (*ReplayWebhookEventResponse, error)
+	// RetryDeadWebhookDeliveries queues the dead webhook deliveries again.
+	RetryDeadWebhookDeliveries(ctx context.Context, req *RetryDeadWebhookDeliveriesRequest) (*RetryDeadWebhookDeliveriesResponse, error)
+	// ListPermissions returns all permissions.
+	ListPermissions(ctx context.Context, req *ListPermissionsRequest) (*ListPermissionsResponse, error)
+	// ListRoles returns all roles.
+	ListRoles(ctx context.Context, req *ListRolesRequest) (*ListRolesResponse, error)
+	// CreateRole creates a role.
+	CreateRole(ctx context.Context, req *CreateRoleRequest) (*Role, error)
+	// UpdateRole replaces the description and permissions of a role.
+	UpdateRole(ctx context.Context, req *UpdateRoleRequest) (*Role, error)
+	// DeleteRole deletes a role and removes it from all users.
+	DeleteRole(ctx context.Context, req *DeleteRoleRequest) (*DeleteRoleResponse, error)
+	// ListUserRoles returns the roles of a user.
+	ListUserRoles(ctx context.Context, req *ListUserRolesRequest) (*UserRoles, error)
+	// AssignRole assigns a role to a user.
+	AssignRole(ctx context.Context, req *AssignRoleRequest) (*UserRoles, error)
+	// UnassignRole removes a role from a user.
+	UnassignRole(ctx context.Context, req *UnassignRoleRequest) (*UserRoles, error)
+	// CheckPermission tells if a user has a permission.
+	CheckPermission(ctx context.Context, req *CheckPermissionRequest) (*CheckPermissionResponse, error)
+	// ListOrganizations returns all organizations.
+	ListOrganizations(ctx context.Context, req *ListOrganizationsRequest) (*ListOrganizationsResponse, error)
+	// GetOrganization returns an organization by slug.
+	GetOrganization(ctx context.Context, req *GetOrganizationRequest) (*Organization, error)
+	// CreateOrganization creates an organization.
+	CreateOrganization(ctx context.Context, req *CreateOrganizationRequest) (*Organization, error)
+	// UpdateOrganization replaces the name, login policy and domains of an organization.
+	UpdateOrganization(ctx context.Context, req *UpdateOrganizationRequest) (*Organization, error)
+	// DeleteOrganization deletes an organization and its memberships.
+	DeleteOrganization(ctx context.Context, req *DeleteOrganizationRequest) (*DeleteOrganizationResponse, error)
+	// ListMembers returns the members of an organization.
+	ListMembers(ctx context.Context, req *ListMembersRequest) (*ListMembersResponse, error)
+	// AddMember adds a user to an organization with a role.
+	AddMember(ctx context.Context, req *AddMemberRequest) (*Member, error)
+	// UpdateMember changes the role of a member.
+	UpdateMember(ctx context.Context, req *UpdateMemberRequest) (*Member, error)
+	// RemoveMember removes a member from an organization.
+	RemoveMember(ctx context.Context, req *RemoveMemberRequest) (*RemoveMemberResponse, error)
+	// InviteMember sends an invitation to join an organization by email.
+	InviteMember(ctx context.Context, req *InviteMemberRequest) (*InviteMemberResponse, error)
+}
+
+// RegisterAdminServer registers the admin service with a gRPC server.
+func RegisterAdminServer(s grpc.ServiceRegistrar, srv AdminServer) {
+	s.RegisterService(&ServiceDesc, srv)
+}
+
+// ServiceDesc is the description of the admin service.
+var ServiceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*AdminServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "GetUser", Handler: handler(AdminServer.GetUser)},
+		{MethodName: "BanUser", Handler: handler(AdminServer.BanUser)},
+		{MethodName: "UnbanUser", Handler: handler(AdminServer.UnbanUser)},
+		{MethodName: "UnlockUser", Handler: handler(AdminServer.UnlockUser)},
+		{MethodName: "UnlockIP", Handler: handler(AdminServer.UnlockIP)},
+		{MethodName: "RefreshBreachCorpus", Handler: handler(AdminServer.RefreshBreachCorpus)},
+		{MethodName: "ListAuditEvents", Handler: handler(AdminServer.ListAuditEvents)},
+		{MethodName: "ListWebhookDeliveries", Handler: handler(AdminServer.ListWebhookDeliveries)},
+		{MethodName: "ReplayWebhookEvent", Handler: handler(AdminServer.ReplayWebhookEvent)},
+		{MethodName: "RetryDeadWebhookDeliveries", Handler: handler(AdminServer.RetryDeadWebhookDeliveries)},
+		{MethodName: "ListPermissions", Handler: handler(AdminServer.ListPermissions)},
+		{MethodName: "ListRoles", Handler: handler(AdminServer.ListRoles)},
+		{MethodName: "CreateRole", Handler: handler(AdminServer.CreateRole)},
+		{MethodName: "UpdateRole", Handler: handler(AdminServer.UpdateRole)},
+		{MethodName: "DeleteRole", Handler: handler(AdminServer.DeleteRole)},
+		{MethodName: "ListUserRoles", Handler: handler(AdminServer.ListUserRoles)},
+		{MethodName: "AssignRole", Handler: handler(AdminServer.AssignRole)},
+		{MethodName: "UnassignRole", Handler: handler(AdminServer.UnassignRole)},
+		{MethodName: "CheckPermission", Handler: handler(AdminServer.CheckPermission)},
+		{MethodName: "ListOrganizations", Handler: handler(AdminServer.ListOrganizations)},
+		{MethodName: "GetOrganization", Handler: handler(AdminServer.GetOrganization)},
+		{MethodName: "CreateOrganization", Handler: handler(AdminServer.CreateOrganization)},
+		{MethodName: "UpdateOrganization", Handler: handler(AdminServer.UpdateOrganization)},
+		{MethodName: "DeleteOrganization", Handler: handler(AdminServer.DeleteOrganization)},
+		{MethodName: "ListMembers", Handler: handler(AdminServer.ListMembers)},
+		{MethodName: "AddMember", Handler: handler(AdminServer.AddMember)},
+		{MethodName: "UpdateMember", Handler: handler(AdminServer.UpdateMember)},
+		{MethodName: "RemoveMember", Handler: handler(AdminServer.RemoveMember)},
+		{MethodName: "InviteMember", Handler: handler(AdminServer.InviteMember)},
+	},
+	Streams: []grpc.StreamDesc{
+		{StreamName: "ExportAuditEvents", Handler: exportAuditEventsHandler, ServerStreams: true},
+	},
+}
+
+func exportAuditEventsHandler(srv any, stream grpc.ServerStream) error {
+	req := new(ListAuditEventsRequest)
+	if err := stream.RecvMsg(req); err != nil {
+		return err
+	}
+
+	return srv.(AdminServer).ExportAuditEvents(req, &auditEventStream{stream})
+}
+
+type auditEventStream struct {
+	grpc.ServerStream
+}
+
+func (s *auditEventStream) Send(event *AuditEvent) error {
+	return s.SendMsg(event)
+}
+
+// handler adapts a method of the AdminServer to a gRPC method handler.
+func handler[Req, Res any](method func(AdminServer, context.Context, *Req) (*Res, error)) grpc.MethodHandler {
+	return func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+		req := new(Req)
+		if err := dec(req); err != nil {
+			return nil, err
+		}
+
+		if interceptor == nil {
+			return method(srv.(AdminServer), ctx, req)
+		}
+
+		info := &grpc.UnaryServerInfo{Server: srv, FullMethod: fullMethod(ctx)}
+
+		return interceptor(ctx, req, info, func(ctx context.Context, req any) (any, error) {
+			return method(srv.(AdminServer), ctx, req.(*Req))
+		})
+	}
+}
+
+func fullMethod(ctx context.Context) string {
+	method, _ := grpc.Method(ctx)
+	return method
+}
+
+// Client is a client of the admin service.
+type Client struct {
+	conn grpc.ClientConnInterface
+}
+
+// NewClient returns a new client of the admin service.
+func NewClient(conn grpc.ClientConnInterface) *Client {
+	return &Client{conn: conn}
+}
+
+// GetUser returns a user by ID.
+func (c *Client) GetUser(ctx context.Context, req *GetUserRequest, opts ...grpc.CallOption) (*User, error) {
+	return invoke[User](ctx, c.conn, "GetUser", req, opts...)
+}
+
+// BanUser bans a user and revokes the sessions and provider tokens of the user.
+func (c *Client) BanUser(ctx context.Context, req *BanUserRequest, opts ...grpc.CallOption) (*User, error) {
+	return invoke[User](ctx, c.conn, "BanUser", req, opts...)
+}
+
+// UnbanUser lifts the ban of a user.
+func (c *Client) UnbanUser(ctx context.Context, req *UnbanUserRequest, opts ...grpc.CallOption) (*User, error) {
+	return invoke[User](ctx, c.conn, "UnbanUser", req, opts...)
+}
+
+// UnlockUser resets the failed login attempts of a user.
+func (c *Client) UnlockUser(ctx context.Context, req *UnlockUserRequest, opts ...grpc.CallOption) (*User, error) {
+	return invoke[User](ctx, c.conn, "UnlockUser", req, opts...)
+}
+
+// UnlockIP resets the failed login attempts of a client IP.
+func (c *Client) UnlockIP(ctx context.Context, req *UnlockIPRequest, opts ...grpc.CallOption) (*UnlockIPResponse, error) {
+	return invoke[UnlockIPResponse](ctx, c.conn, "UnlockIP", req, opts...)
+}
+
+// RefreshBreachCorpus reloads the breached password corpus of the server from disk.
+func (c *Client) RefreshBreachCorpus(ctx context.Context, req *RefreshBreachCorpusRequest, opts ...grpc.CallOption) (*BreachCorpus, error) {
+	return invoke[BreachCorpus](ctx, c.conn, "RefreshBreachCorpus", req, opts...)
+}
+
+// ListAuditEvents returns a page of audit events.
+func (c *Client) ListAuditEvents(ctx context.Context, req *ListAuditEventsRequest, opts ...grpc.CallOption) (*ListAuditEventsResponse, error) {
+	return invoke[ListAuditEventsResponse](ctx, c.conn, "ListAuditEvents", req, opts...)
+}
+
+// ExportAuditEvents streams all audit events matching the filter.
+func (c *Client) ExportAuditEvents(ctx context.Context, req *ListAuditEventsRequest, opts ...grpc.CallOption) (*AuditEventClient, error) {
+	opts = append([]grpc.CallOption{grpc.ForceCodec(Codec)}, opts...)
+
+	stream, err := c.conn.NewStream(ctx, &ServiceDesc.Streams[0], "/"+ServiceName+"/ExportAuditEvents", opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := stream.SendMsg(req); err != nil {
+		return nil, err
+	}
+
+	if err := stream.CloseSend(); err != nil {
+		return nil, err
+	}
+
+	return &AuditEventClient{stream: stream}, nil
+}
+
+// ListWebhookDeliveries returns a page of webhook deliveries.
+func (c *Client) ListWebhookDeliveries(ctx context.Context, req *ListWebhookDeliveriesRequest, opts ...grpc.CallOption) (*ListWebhookDeliveriesResponse, error) {
+	return invoke[ListWebhookDeliveriesResponse](ctx, c.conn, "ListWebhookDeliveries", req, opts...)
+}
+
+// ReplayWebhookEvent delivers an outbox event again.
+func (c *Client) ReplayWebhookEvent(ctx context.Context, req *ReplayWebhookEventRequest, opts ...grpc.CallOption) (*ReplayWebhookEventResponse, error) {
+	return invoke[ReplayWebhookEventResponse](ctx, c.conn, "ReplayWebhookEvent", req, opts...)
+}
+
+// RetryDeadWebhookDeliveries queues the dead webhook deliveries again.
+func (c *Client) RetryDeadWebhookDeliveries(ctx context.Context, req *RetryDeadWebhookDeliveriesRequest, opts ...grpc.CallOption) (*RetryDeadWebhookDeliveriesResponse, error) {
+	return invoke[RetryDeadWebhookDeliveriesResponse](ctx, c.conn, "RetryDeadWebhookDeliveries", req, opts...)
+}
+
+// ListPermissions returns all permissions.
+func (c *Client) ListPermissions(ctx context.Context, req *ListPermissionsRequest, opts ...grpc.CallOption) (*ListPermissionsResponse, error) {
+	return invoke[ListPermissionsResponse](ctx, c.conn, "ListPermissions", req, opts...)
+}
+
+// ListRoles returns all roles.
+func (c *Client) ListRoles(ctx context.Context, req *ListRolesRequest, opts ...grpc.CallOption) (*ListRolesResponse, error) {
+	return invoke[ListRolesResponse](ctx, c.conn, "ListRoles", req, opts...)
+}
+
+// CreateRole creates a role.
+func (c *Client) CreateRole(ctx context.Context, req *CreateRoleRequest, opts ...grpc.CallOption) (*Role, error) {
+	return invoke[Role](ctx, c.conn, "CreateRole", req, opts...)
+}
+
+// UpdateRole replaces the description and permissions of a role.
+func (c *Client) UpdateRole(ctx context.Context, req *UpdateRoleRequest, opts ...grpc.CallOption) (*Role, error) {
+	return invoke[Role](ctx, c.conn, "UpdateRole", req, opts...)
+}
+
+// DeleteRole deletes a role and removes it from all users.
+func (c *Client) DeleteRole(ctx context.Context, req *DeleteRoleRequest, opts ...grpc.CallOption) (*DeleteRoleResponse, error) {
+	return invoke[DeleteRoleResponse](ctx, c.conn, "DeleteRole", req, opts...)
+}
+
+// ListUserRoles returns the roles of a user.
+func (c *Client) ListUserRoles(ctx context.Context, req *ListUserRolesRequest, opts ...grpc.CallOption) (*UserRoles, error) {
+	return invoke[UserRoles](ctx, c.conn, "ListUserRoles", req, opts...)
+}
+
+// AssignRole assigns a role to a user.
+func (c *Client) AssignRole(ctx context.Context, req *AssignRoleRequest, opts ...grpc.CallOption) (*UserRoles, error) {
+	return invoke[UserRoles](ctx, c.conn, "AssignRole", req, opts...)
+}
+
+// UnassignRole removes a role from a user.
+func (c *Client) UnassignRole(ctx context.Context, req *UnassignRoleRequest, opts ...grpc.CallOption) (*UserRoles, error) {
+	return invoke[UserRoles](ctx, c.conn, "UnassignRole", req, opts...)
+}
+
+// CheckPermission tells if a user has a permission.
+func (c *Client) CheckPermission(ctx context.Context, req *CheckPermissionRequest, opts ...grpc.CallOption) (*CheckPermissionResponse, error) {
+	return invoke[CheckPermissionResponse](ctx, c.conn, "CheckPermission", req, opts...)
+}
+
+// ListOrganizations returns all organizations.
+func (c *Client) ListOrganizations(ctx context.Context, req *ListOrganizationsRequest, opts ...grpc.CallOption) (*ListOrganizationsResponse, error) {
+	return invoke[ListOrganizationsResponse](ctx, c.conn, "ListOrganizations", req, opts...)
+}
+
+// GetOrganization returns an organization by slug.
+func (c *Client) GetOrganization(ctx context.Context, req *GetOrganizationRequest, opts ...grpc.CallOption) (*Organization, error) {
+	return invoke[Organization](ctx, c.conn, "GetOrganization", req, opts...)
+}
+
+// CreateOrganization creates an organization.
+func (c *Client) CreateOrganization(ctx context.Context, req *CreateOrganizationRequest, opts ...grpc.CallOption) (*Organization, error) {
+	return invoke[Organization](ctx, c.conn, "CreateOrganization", req, opts...)
+}
+
+// UpdateOrganization replaces the name, login policy and domains of an organization.
+func (c *Client) UpdateOrganization(ctx context.Context, req *UpdateOrganizationRequest, opts ...grpc.CallOption) (*Organization, error) {
+	return invoke[Organization](ctx, c.conn, "UpdateOrganization", req, opts...)
+}
+
+// DeleteOrganization deletes an organization and its memberships.
+func (c *Client) DeleteOrganization(ctx context.Context, req *DeleteOrganizationRequest, opts ...grpc.CallOption) (*DeleteOrganizationResponse, error) {
+	return invoke[DeleteOrganizationResponse](ctx, c.conn, "DeleteOrganization", req, opts...)
+}
+
+// ListMembers returns the members of an organization.
+func (c *Client) ListMembers(ctx context.Context, req *ListMembersRequest, opts ...grpc.CallOption) (*ListMembersResponse, error) {
+	return invoke[ListMembersResponse](ctx, c.conn, "ListMembers", req, opts...)
+}
+
+// AddMember adds a user to an organization with a role.
+func (c *Client) AddMember(ctx context.Context, req *AddMemberRequest, opts ...grpc.CallOption) (*Member, error) {
+	return invoke[Member](ctx, c.conn, "AddMember", req, opts...)
+}
+
+// UpdateMember changes the role of a member.
+func (c *Client) UpdateMember(ctx context.Context, req *UpdateMemberRequest, opts ...grpc.CallOption) (*Member, error) {
+	return invoke[Member](ctx, c.conn, "UpdateMember", req, opts...)
+}
+
+// RemoveMember removes a member from an organization.
+func (c *Client) RemoveMember(ctx context.Context, req *RemoveMemberRequest, opts ...grpc.CallOption) (*RemoveMemberResponse, error) {
+	return invoke[RemoveMemberResponse](ctx, c.conn, "RemoveMember", req, opts...)
+}
+
+// InviteMember sends an invitation to join an organization by email.
+func (c *Client) InviteMember(ctx context.Context, req *InviteMemberRequest, opts ...grpc.CallOption) (*InviteMemberResponse, error) {
+	return invoke[InviteMemberResponse](ctx, c.conn, "InviteMember", req, opts...)
+}
+
+// AuditEventClient receives the events of an export.
+type AuditEventClient struct {
+	stream grpc.ClientStream
+}
+
+// Recv returns the next event. It returns io.EOF after the last event.
+func (c *AuditEventClient) Recv() (*AuditEvent, error) {
+	event := new(AuditEvent)
+	if err := c.stream.RecvMsg(event); err != nil {
+		return nil, err
+	}
+
+	return event, nil
+}
+
+func invoke[Res any](ctx context.Context, conn grpc.ClientConnInterface, method string, req any, opts ...grpc.CallOption) (*Res, error) {
+	res := new(Res)
+	opts = append([]grpc.CallOption{grpc.ForceCodec(Codec)}, opts...)
+
+	if err := conn.Invoke(ctx, "/"+ServiceName+"/"+method, req, res, opts...); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
@@ -0,0 +1,32 @@
+package admin
+
+import (
+	"encoding/json"
+
+	"google.golang.org/grpc/encoding"
+)
+
+// CodecName is the content subtype of the admin service.
+const CodecName = "glue-admin-json"
+
+// Codec encodes the messages of the admin service as JSON, so that the
+// service needs no generated protobuf code. It is not registered globally,
+// the server sets it with grpc.ForceServerCodec and the Client with grpc.ForceCodec.
+var Codec encoding.Codec = codec{}
+
+type codec struct{}
+
+// Marshal implements the encoding.Codec interface.
+func (codec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Unmarshal implements the encoding.Codec interface.
+func (codec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+// Name implements the encoding.Codec interface.
+func (codec) Name() string {
+	return CodecName
+}
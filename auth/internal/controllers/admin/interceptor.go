@@ -0,0 +1,252 @@
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"math"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/open-cloud-initiative/glue/auth/internal/audit"
+	"github.com/open-cloud-initiative/glue/auth/internal/ports"
+	"github.com/open-cloud-initiative/glue/auth/internal/ratelimit"
+	"github.com/open-cloud-initiative/glue/auth/internal/rbac"
+	"github.com/open-cloud-initiative/glue/auth/internal/secrets"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// permissions are the permissions required by the methods of the admin service.
+var permissions = map[string]string{
+	"GetUser":                    rbac.UsersRead,
+	"BanUser":                    rbac.UsersWrite,
+	"UnbanUser":                  rbac.UsersWrite,
+	"UnlockUser":                 rbac.LockoutsWrite,
+	"UnlockIP":                   rbac.LockoutsWrite,
+	"RefreshBreachCorpus":        rbac.BreachWrite,
+	"ListAuditEvents":            rbac.AuditRead,
+	"ExportAuditEvents":          rbac.AuditRead,
+	"ListWebhookDeliveries":      rbac.WebhooksRead,
+	"ReplayWebhookEvent":         rbac.WebhooksWrite,
+	"RetryDeadWebhookDeliveries": rbac.WebhooksWrite,
+	"ListPermissions":            rbac.RolesRead,
+	"ListRoles":                  rbac.RolesRead,
+	"CreateRole":                 rbac.RolesWrite,
+	"UpdateRole":                 rbac.RolesWrite,
+	"DeleteRole":                 rbac.RolesWrite,
+	"ListUserRoles":              rbac.RolesRead,
+	"AssignRole":                 rbac.RolesWrite,
+	"UnassignRole":               rbac.RolesWrite,
+	"CheckPermission":            rbac.RolesRead,
+	"ListOrganizations":          rbac.OrgsRead,
+	"GetOrganization":            rbac.OrgsRead,
+	"CreateOrganization":         rbac.OrgsWrite,
+	"UpdateOrganization":         rbac.OrgsWrite,
+	"DeleteOrganization":         rbac.OrgsWrite,
+	"ListMembers":                rbac.OrgsRead,
+	"AddMember":                  rbac.OrgsWrite,
+	"UpdateMember":               rbac.OrgsWrite,
+	"RemoveMember":               rbac.OrgsWrite,
+	"InviteMember":               rbac.OrgsWrite,
+}
+
+type actorKey struct{}
+
+// AuthInterceptor requires the shared internal token, which allows all calls,
+// or the session token of a user with the permission of the method as bearer token.
+func AuthInterceptor(token secrets.Secret, sessions ports.Auth, authz *rbac.Service) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, next grpc.UnaryHandler) (any, error) {
+		ctx, err := authorize(ctx, info.FullMethod, token, sessions, authz)
+		if err != nil {
+			return nil, err
+		}
+
+		return next(ctx, req)
+	}
+}
+
+// AuthStreamInterceptor is the AuthInterceptor of streaming calls.
+func AuthStreamInterceptor(token secrets.Secret, sessions ports.Auth, authz *rbac.Service) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, next grpc.StreamHandler) error {
+		if _, err := authorize(ss.Context(), info.FullMethod, token, sessions, authz); err != nil {
+			return err
+		}
+
+		return next(srv, ss)
+	}
+}
+
+// ErrorInterceptor reports the unexpected errors of calls, which the caller
+// only sees as a generic Internal status. It must run first.
+func ErrorInterceptor(report func(method string, err error)) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, next grpc.UnaryHandler) (any, error) {
+		res, err := next(ctx, req)
+		reportInternal(report, info.FullMethod, err)
+
+		return res, err
+	}
+}
+
+// ErrorStreamInterceptor is the ErrorInterceptor of streaming calls.
+func ErrorStreamInterceptor(report func(method string, err error)) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, next grpc.StreamHandler) error {
+		err := next(srv, ss)
+		reportInternal(report, info.FullMethod, err)
+
+		return err
+	}
+}
+
+func reportInternal(report func(method string, err error), method string, err error) {
+	var internal *internalError
+	if errors.As(err, &internal) {
+		report(method, internal.err)
+	}
+}
+
+// RateLimitInterceptor limits the calls per peer IP.
+func RateLimitInterceptor(limiter ratelimit.Limiter) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, next grpc.UnaryHandler) (any, error) {
+		if err := allow(ctx, limiter); err != nil {
+			return nil, err
+		}
+
+		return next(ctx, req)
+	}
+}
+
+// RateLimitStreamInterceptor is the RateLimitInterceptor of streaming calls.
+func RateLimitStreamInterceptor(limiter ratelimit.Limiter) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, next grpc.StreamHandler) error {
+		if err := allow(ss.Context(), limiter); err != nil {
+			return err
+		}
+
+		return next(srv, ss)
+	}
+}
+
+// AuditInterceptor records the peer IP, user agent and request ID of a call
+// for the audit events it causes. The actor is the user of the session token,
+// or the admin for calls with the internal token. It must run after the AuthInterceptor.
+func AuditInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, next grpc.UnaryHandler) (any, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+
+		m := &audit.Metadata{
+			ActorType: audit.ActorAdmin,
+			IP:        peerIP(ctx),
+			UserAgent: first(md.Get("user-agent")),
+			RequestID: first(md.Get("x-request-id")),
+		}
+
+		if id, ok := ctx.Value(actorKey{}).(uuid.UUID); ok {
+			m.ActorType = audit.ActorUser
+			m.ActorID = &id
+		}
+
+		return next(audit.NewContext(ctx, m), req)
+	}
+}
+
+// authorize authenticates the bearer token of a call to the method. It returns
+// the context with the user of a session token.
+func authorize(ctx context.Context, method string, token secrets.Secret, sessions ports.Auth, authz *rbac.Service) (context.Context, error) {
+	bearer := bearerToken(ctx)
+	if bearer == "" {
+		return ctx, status.Error(codes.Unauthenticated, "invalid token")
+	}
+
+	if subtle.ConstantTimeCompare([]byte(bearer), []byte(token.Value())) == 1 {
+		return ctx, nil
+	}
+
+	if sessions == nil || authz == nil {
+		return ctx, status.Error(codes.Unauthenticated, "invalid token")
+	}
+
+	session, err := sessions.GetSession(ctx, bearer)
+	if errors.Is(err, ports.ErrUserBanned) {
+		return ctx, Status(err)
+	}
+
+	if err != nil || session.MFARequired {
+		return ctx, status.Error(codes.Unauthenticated, "invalid token")
+	}
+
+	permission, ok := permissions[method[strings.LastIndex(method, "/")+1:]]
+	if !ok {
+		return ctx, status.Error(codes.PermissionDenied, rbac.ErrPermissionDenied.Error())
+	}
+
+	if err := authz.Check(ctx, session.UserID, permission); err != nil {
+		return ctx, Status(err)
+	}
+
+	return context.WithValue(ctx, actorKey{}, session.UserID), nil
+}
+
+// caller returns the user of the session token of a call, or rbac.Internal for the internal token.
+func caller(ctx context.Context) uuid.UUID {
+	if id, ok := ctx.Value(actorKey{}).(uuid.UUID); ok {
+		return id
+	}
+
+	return rbac.Internal
+}
+
+func bearerToken(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	for _, v := range md.Get("authorization") {
+		scheme, bearer, ok := strings.Cut(v, " ")
+		if ok && strings.EqualFold(scheme, "bearer") {
+			return strings.TrimSpace(bearer)
+		}
+	}
+
+	return ""
+}
+
+func allow(ctx context.Context, limiter ratelimit.Limiter) error {
+	res, err := limiter.Allow(ctx, "admin:"+peerIP(ctx))
+	if err != nil {
+		return Status(err)
+	}
+
+	if !res.Allowed {
+		_ = grpc.SetHeader(ctx, metadata.Pairs("retry-after", strconv.Itoa(int(math.Ceil(res.RetryAfter.Seconds())))))
+		return status.Error(codes.ResourceExhausted, "rate limit exceeded")
+	}
+
+	return nil
+}
+
+// peerIP returns the IP of the caller.
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	if ap, err := netip.ParseAddrPort(p.Addr.String()); err == nil {
+		return ap.Addr().Unmap().String()
+	}
+
+	return p.Addr.String()
+}
+
+func first(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+
+	return values[0]
+}
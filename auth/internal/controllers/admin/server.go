@@ -0,0 +1,856 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/open-cloud-initiative/glue/auth/internal/audit"
+	"github.com/open-cloud-initiative/glue/auth/internal/lockout"
+	"github.com/open-cloud-initiative/glue/auth/internal/models"
+	"github.com/open-cloud-initiative/glue/auth/internal/organization"
+	"github.com/open-cloud-initiative/glue/auth/internal/password"
+	"github.com/open-cloud-initiative/glue/auth/internal/ports"
+	"github.com/open-cloud-initiative/glue/auth/internal/rbac"
+	"github.com/open-cloud-initiative/glue/auth/internal/webhook"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+)
+
+var _ AdminServer = (*Server)(nil)
+
+// Server implements the admin service.
+type Server struct {
+	adapter ports.Auth
+	corpus  *password.Corpus
+	guard   *lockout.Guard
+	log     *audit.Log
+	hooks   *webhook.Dispatcher
+	rbac    *rbac.Service
+	orgs    *organization.Service
+}
+
+// Opt is a function that configures the Server.
+type Opt func(*Server)
+
+// WithCorpus refreshes the breached password corpus.
+func WithCorpus(corpus *password.Corpus) Opt {
+	return func(s *Server) {
+		s.corpus = corpus
+	}
+}
+
+// WithGuard unlocks users and IPs locked out after failed logins.
+func WithGuard(guard *lockout.Guard) Opt {
+	return func(s *Server) {
+		s.guard = guard
+	}
+}
+
+// WithAuditLog queries and exports the audit log.
+func WithAuditLog(log *audit.Log) Opt {
+	return func(s *Server) {
+		s.log = log
+	}
+}
+
+// WithDispatcher inspects and replays webhook deliveries.
+func WithDispatcher(dispatcher *webhook.Dispatcher) Opt {
+	return func(s *Server) {
+		s.hooks = dispatcher
+	}
+}
+
+// WithRBAC manages roles and checks permissions.
+func WithRBAC(authz *rbac.Service) Opt {
+	return func(s *Server) {
+		s.rbac = authz
+	}
+}
+
+// WithOrganizations manages organizations and their members.
+func WithOrganizations(orgs *organization.Service) Opt {
+	return func(s *Server) {
+		s.orgs = orgs
+	}
+}
+
+// NewServer creates a new Server.
+func NewServer(adapter ports.Auth, opts ...Opt) *Server {
+	s := &Server{adapter: adapter}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// GetUser returns a user by ID.
+func (s *Server) GetUser(ctx context.Context, req *GetUserRequest) (*User, error) {
+	id, err := uuid.Parse(req.UserID)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid user id")
+	}
+
+	user, err := s.adapter.GetUser(ctx, id)
+	if err != nil {
+		return nil, Status(err)
+	}
+
+	res := toUser(user)
+
+	if s.rbac != nil {
+		roles, err := s.rbac.UserRoles(ctx, id)
+		if err != nil {
+			return nil, Status(err)
+		}
+
+		for _, r := range roles {
+			res.Roles = append(res.Roles, r.Name)
+		}
+	}
+
+	return res, nil
+}
+
+// BanUser bans a user and revokes the sessions and provider tokens of the user.
+func (s *Server) BanUser(ctx context.Context, req *BanUserRequest) (*User, error) {
+	id, err := uuid.Parse(req.UserID)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid user id")
+	}
+
+	if strings.TrimSpace(req.Reason) == "" {
+		return nil, status.Error(codes.InvalidArgument, "a reason is required")
+	}
+
+	until := models.BannedForever
+	if req.Duration != "" {
+		d, err := time.ParseDuration(req.Duration)
+		if err != nil || d <= 0 {
+			return nil, status.Error(codes.InvalidArgument, "duration must be a positive duration, e.g. 72h")
+		}
+
+		until = time.Now().Add(d)
+	}
+
+	user, err := s.adapter.BanUser(ctx, id, until, req.Reason)
+	if err != nil {
+		return nil, Status(err)
+	}
+
+	return toUser(user), nil
+}
+
+// UnbanUser lifts the ban of a user.
+func (s *Server) UnbanUser(ctx context.Context, req *UnbanUserRequest) (*User, error) {
+	id, err := uuid.Parse(req.UserID)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid user id")
+	}
+
+	user, err := s.adapter.UnbanUser(ctx, id)
+	if err != nil {
+		return nil, Status(err)
+	}
+
+	return toUser(user), nil
+}
+
+// UnlockUser resets the failed login attempts of a user, by user ID,
+// email address and phone number.
+func (s *Server) UnlockUser(ctx context.Context, req *UnlockUserRequest) (*User, error) {
+	if s.guard == nil {
+		return nil, status.Error(codes.FailedPrecondition, "lockout is not enabled")
+	}
+
+	id, err := uuid.Parse(req.UserID)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid user id")
+	}
+
+	user, err := s.adapter.GetUser(ctx, id)
+	if err != nil {
+		return nil, Status(err)
+	}
+
+	if err := s.guard.Unlock(ctx, user.ID.String(), strings.ToLower(user.Email), user.PhoneNumber); err != nil {
+		return nil, Status(err)
+	}
+
+	return toUser(user), nil
+}
+
+// UnlockIP resets the failed login attempts of a client IP.
+func (s *Server) UnlockIP(ctx context.Context, req *UnlockIPRequest) (*UnlockIPResponse, error) {
+	if s.guard == nil {
+		return nil, status.Error(codes.FailedPrecondition, "lockout is not enabled")
+	}
+
+	ip, err := netip.ParseAddr(req.IP)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid ip")
+	}
+
+	if err := s.guard.UnlockIP(ctx, ip.String()); err != nil {
+		return nil, Status(err)
+	}
+
+	return &UnlockIPResponse{IP: ip.String()}, nil
+}
+
+// RefreshBreachCorpus reloads the breached password corpus from disk. Only
+// the replica that serves the request reloads, refresh each replica of a cluster.
+func (s *Server) RefreshBreachCorpus(_ context.Context, _ *RefreshBreachCorpusRequest) (*BreachCorpus, error) {
+	if s.corpus == nil {
+		return nil, status.Error(codes.FailedPrecondition, "no breach corpus is configured")
+	}
+
+	n, err := s.corpus.Load()
+	if err != nil {
+		return nil, Status(err)
+	}
+
+	return &BreachCorpus{Path: s.corpus.Path(), Hashes: n}, nil
+}
+
+// ListAuditEvents returns a page of audit events.
+func (s *Server) ListAuditEvents(ctx context.Context, req *ListAuditEventsRequest) (*ListAuditEventsResponse, error) {
+	if s.log == nil {
+		return nil, status.Error(codes.FailedPrecondition, "the audit log is not enabled")
+	}
+
+	filter, err := auditFilter(req)
+	if err != nil {
+		return nil, err
+	}
+
+	events, next, err := s.log.List(ctx, filter)
+	if err != nil {
+		return nil, Status(err)
+	}
+
+	res := &ListAuditEventsResponse{Events: make([]*AuditEvent, 0, len(events)), Next: next}
+	for _, e := range events {
+		res.Events = append(res.Events, toAuditEvent(e))
+	}
+
+	return res, nil
+}
+
+// ExportAuditEvents streams all audit events matching the filter.
+func (s *Server) ExportAuditEvents(req *ListAuditEventsRequest, stream AuditEventStream) error {
+	if s.log == nil {
+		return status.Error(codes.FailedPrecondition, "the audit log is not enabled")
+	}
+
+	filter, err := auditFilter(req)
+	if err != nil {
+		return err
+	}
+
+	err = s.log.Export(stream.Context(), filter, func(e models.AuditEvent) error {
+		return stream.Send(toAuditEvent(e))
+	})
+	if err != nil {
+		if _, ok := status.FromError(err); ok {
+			return err
+		}
+
+		return Status(err)
+	}
+
+	return nil
+}
+
+// ListWebhookDeliveries returns a page of webhook deliveries.
+func (s *Server) ListWebhookDeliveries(ctx context.Context, req *ListWebhookDeliveriesRequest) (*ListWebhookDeliveriesResponse, error) {
+	if s.hooks == nil {
+		return nil, status.Error(codes.FailedPrecondition, "webhooks are not enabled")
+	}
+
+	switch models.WebhookStatus(req.Status) {
+	case "", models.WebhookPending, models.WebhookDelivered, models.WebhookDead:
+	default:
+		return nil, status.Error(codes.InvalidArgument, "status must be pending, delivered or dead")
+	}
+
+	if req.Limit < 0 || req.After < 0 {
+		return nil, status.Error(codes.InvalidArgument, "limit and after must not be negative")
+	}
+
+	filter := ports.WebhookFilter{
+		EventID:  req.EventID,
+		Endpoint: req.Endpoint,
+		Status:   models.WebhookStatus(req.Status),
+		After:    req.After,
+		Limit:    min(req.Limit, audit.MaxPageSize),
+	}
+
+	deliveries, next, err := s.hooks.Deliveries(ctx, filter)
+	if err != nil {
+		return nil, Status(err)
+	}
+
+	return &ListWebhookDeliveriesResponse{Deliveries: toWebhookDeliveries(deliveries), Next: next}, nil
+}
+
+// ReplayWebhookEvent delivers an outbox event again.
+func (s *Server) ReplayWebhookEvent(ctx context.Context, req *ReplayWebhookEventRequest) (*ReplayWebhookEventResponse, error) {
+	if s.hooks == nil {
+		return nil, status.Error(codes.FailedPrecondition, "webhooks are not enabled")
+	}
+
+	if req.EventID <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "invalid event id")
+	}
+
+	deliveries, err := s.hooks.Replay(ctx, req.EventID, req.Endpoint)
+	if err != nil {
+		return nil, Status(err)
+	}
+
+	return &ReplayWebhookEventResponse{Deliveries: toWebhookDeliveries(deliveries)}, nil
+}
+
+// RetryDeadWebhookDeliveries queues the dead webhook deliveries again.
+func (s *Server) RetryDeadWebhookDeliveries(ctx context.Context, req *RetryDeadWebhookDeliveriesRequest) (*RetryDeadWebhookDeliveriesResponse, error) {
+	if s.hooks == nil {
+		return nil, status.Error(codes.FailedPrecondition, "webhooks are not enabled")
+	}
+
+	n, err := s.hooks.RetryDead(ctx, req.Endpoint)
+	if err != nil {
+		return nil, Status(err)
+	}
+
+	return &RetryDeadWebhookDeliveriesResponse{Queued: n}, nil
+}
+
+// ListPermissions returns all permissions.
+func (s *Server) ListPermissions(ctx context.Context, _ *ListPermissionsRequest) (*ListPermissionsResponse, error) {
+	if s.rbac == nil {
+		return nil, status.Error(codes.FailedPrecondition, "roles are not enabled")
+	}
+
+	permissions, err := s.rbac.Catalog(ctx)
+	if err != nil {
+		return nil, Status(err)
+	}
+
+	res := &ListPermissionsResponse{Permissions: make([]*Permission, 0, len(permissions))}
+	for _, p := range permissions {
+		res.Permissions = append(res.Permissions, &Permission{Name: p.Name, Description: p.Description})
+	}
+
+	return res, nil
+}
+
+// ListRoles returns all roles.
+func (s *Server) ListRoles(ctx context.Context, _ *ListRolesRequest) (*ListRolesResponse, error) {
+	if s.rbac == nil {
+		return nil, status.Error(codes.FailedPrecondition, "roles are not enabled")
+	}
+
+	roles, err := s.rbac.Roles(ctx)
+	if err != nil {
+		return nil, Status(err)
+	}
+
+	return &ListRolesResponse{Roles: toRoles(roles)}, nil
+}
+
+// CreateRole creates a role.
+func (s *Server) CreateRole(ctx context.Context, req *CreateRoleRequest) (*Role, error) {
+	if s.rbac == nil {
+		return nil, status.Error(codes.FailedPrecondition, "roles are not enabled")
+	}
+
+	role, err := s.rbac.CreateRole(ctx, caller(ctx), req.Name, req.Description, req.Permissions)
+	if err != nil {
+		return nil, Status(err)
+	}
+
+	return toRole(role), nil
+}
+
+// UpdateRole replaces the description and permissions of a role.
+func (s *Server) UpdateRole(ctx context.Context, req *UpdateRoleRequest) (*Role, error) {
+	if s.rbac == nil {
+		return nil, status.Error(codes.FailedPrecondition, "roles are not enabled")
+	}
+
+	role, err := s.rbac.UpdateRole(ctx, caller(ctx), req.Name, req.Description, req.Permissions)
+	if err != nil {
+		return nil, Status(err)
+	}
+
+	return toRole(role), nil
+}
+
+// DeleteRole deletes a role and removes it from all users.
+func (s *Server) DeleteRole(ctx context.Context, req *DeleteRoleRequest) (*DeleteRoleResponse, error) {
+	if s.rbac == nil {
+		return nil, status.Error(codes.FailedPrecondition, "roles are not enabled")
+	}
+
+	if err := s.rbac.DeleteRole(ctx, caller(ctx), req.Name); err != nil {
+		return nil, Status(err)
+	}
+
+	return &DeleteRoleResponse{Name: req.Name}, nil
+}
+
+// ListUserRoles returns the roles of a user.
+func (s *Server) ListUserRoles(ctx context.Context, req *ListUserRolesRequest) (*UserRoles, error) {
+	if s.rbac == nil {
+		return nil, status.Error(codes.FailedPrecondition, "roles are not enabled")
+	}
+
+	id, err := uuid.Parse(req.UserID)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid user id")
+	}
+
+	return s.userRoles(ctx, id)
+}
+
+// AssignRole assigns a role to a user.
+func (s *Server) AssignRole(ctx context.Context, req *AssignRoleRequest) (*UserRoles, error) {
+	if s.rbac == nil {
+		return nil, status.Error(codes.FailedPrecondition, "roles are not enabled")
+	}
+
+	id, err := uuid.Parse(req.UserID)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid user id")
+	}
+
+	if err := s.rbac.Assign(ctx, caller(ctx), id, req.Role); err != nil {
+		return nil, Status(err)
+	}
+
+	return s.userRoles(ctx, id)
+}
+
+// UnassignRole removes a role from a user.
+func (s *Server) UnassignRole(ctx context.Context, req *UnassignRoleRequest) (*UserRoles, error) {
+	if s.rbac == nil {
+		return nil, status.Error(codes.FailedPrecondition, "roles are not enabled")
+	}
+
+	id, err := uuid.Parse(req.UserID)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid user id")
+	}
+
+	if err := s.rbac.Unassign(ctx, caller(ctx), id, req.Role); err != nil {
+		return nil, Status(err)
+	}
+
+	return s.userRoles(ctx, id)
+}
+
+// CheckPermission tells if a user has a permission.
+func (s *Server) CheckPermission(ctx context.Context, req *CheckPermissionRequest) (*CheckPermissionResponse, error) {
+	if s.rbac == nil {
+		return nil, status.Error(codes.FailedPrecondition, "roles are not enabled")
+	}
+
+	id, err := uuid.Parse(req.UserID)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid user id")
+	}
+
+	if strings.TrimSpace(req.Permission) == "" {
+		return nil, status.Error(codes.InvalidArgument, "a permission is required")
+	}
+
+	err = s.rbac.Check(ctx, id, req.Permission)
+	if err != nil && !errors.Is(err, rbac.ErrPermissionDenied) {
+		return nil, Status(err)
+	}
+
+	return &CheckPermissionResponse{Allowed: err == nil}, nil
+}
+
+// ListOrganizations returns all organizations.
+func (s *Server) ListOrganizations(ctx context.Context, _ *ListOrganizationsRequest) (*ListOrganizationsResponse, error) {
+	if s.orgs == nil {
+		return nil, status.Error(codes.FailedPrecondition, "organizations are not enabled")
+	}
+
+	orgs, err := s.orgs.List(ctx)
+	if err != nil {
+		return nil, Status(err)
+	}
+
+	res := &ListOrganizationsResponse{Organizations: make([]*Organization, 0, len(orgs))}
+	for _, org := range orgs {
+		res.Organizations = append(res.Organizations, toOrganization(org))
+	}
+
+	return res, nil
+}
+
+// GetOrganization returns an organization by slug.
+func (s *Server) GetOrganization(ctx context.Context, req *GetOrganizationRequest) (*Organization, error) {
+	if s.orgs == nil {
+		return nil, status.Error(codes.FailedPrecondition, "organizations are not enabled")
+	}
+
+	org, err := s.orgs.Get(ctx, req.Slug)
+	if err != nil {
+		return nil, Status(err)
+	}
+
+	return toOrganization(org), nil
+}
+
+// CreateOrganization creates an organization.
+func (s *Server) CreateOrganization(ctx context.Context, req *CreateOrganizationRequest) (*Organization, error) {
+	if s.orgs == nil {
+		return nil, status.Error(codes.FailedPrecondition, "organizations are not enabled")
+	}
+
+	org, err := s.orgs.Create(ctx, req.Slug, organization.Settings{
+		Name:             req.Name,
+		AllowedProviders: req.AllowedProviders,
+		RequireMFA:       req.RequireMFA,
+		Domains:          req.Domains,
+	})
+	if err != nil {
+		return nil, Status(err)
+	}
+
+	return toOrganization(org), nil
+}
+
+// UpdateOrganization replaces the name, login policy and domains of an organization.
+func (s *Server) UpdateOrganization(ctx context.Context, req *UpdateOrganizationRequest) (*Organization, error) {
+	if s.orgs == nil {
+		return nil, status.Error(codes.FailedPrecondition, "organizations are not enabled")
+	}
+
+	org, err := s.orgs.Update(ctx, req.Slug, organization.Settings{
+		Name:             req.Name,
+		AllowedProviders: req.AllowedProviders,
+		RequireMFA:       req.RequireMFA,
+		Domains:          req.Domains,
+	})
+	if err != nil {
+		return nil, Status(err)
+	}
+
+	return toOrganization(org), nil
+}
+
+// DeleteOrganization deletes an organization and its memberships.
+func (s *Server) DeleteOrganization(ctx context.Context, req *DeleteOrganizationRequest) (*DeleteOrganizationResponse, error) {
+	if s.orgs == nil {
+		return nil, status.Error(codes.FailedPrecondition, "organizations are not enabled")
+	}
+
+	if err := s.orgs.Delete(ctx, req.Slug); err != nil {
+		return nil, Status(err)
+	}
+
+	return &DeleteOrganizationResponse{Slug: req.Slug}, nil
+}
+
+// ListMembers returns the members of an organization.
+func (s *Server) ListMembers(ctx context.Context, req *ListMembersRequest) (*ListMembersResponse, error) {
+	if s.orgs == nil {
+		return nil, status.Error(codes.FailedPrecondition, "organizations are not enabled")
+	}
+
+	memberships, err := s.orgs.Members(ctx, req.Slug)
+	if err != nil {
+		return nil, Status(err)
+	}
+
+	res := &ListMembersResponse{Members: make([]*Member, 0, len(memberships))}
+	for _, m := range memberships {
+		res.Members = append(res.Members, toMember(m))
+	}
+
+	return res, nil
+}
+
+// AddMember adds a user to an organization with a role.
+func (s *Server) AddMember(ctx context.Context, req *AddMemberRequest) (*Member, error) {
+	if s.orgs == nil {
+		return nil, status.Error(codes.FailedPrecondition, "organizations are not enabled")
+	}
+
+	id, err := uuid.Parse(req.UserID)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid user id")
+	}
+
+	membership, err := s.orgs.AddMember(ctx, caller(ctx), req.Slug, id, req.Role)
+	if err != nil {
+		return nil, Status(err)
+	}
+
+	return toMember(membership), nil
+}
+
+// UpdateMember changes the role of a member.
+func (s *Server) UpdateMember(ctx context.Context, req *UpdateMemberRequest) (*Member, error) {
+	if s.orgs == nil {
+		return nil, status.Error(codes.FailedPrecondition, "organizations are not enabled")
+	}
+
+	id, err := uuid.Parse(req.UserID)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid user id")
+	}
+
+	membership, err := s.orgs.SetRole(ctx, caller(ctx), req.Slug, id, req.Role)
+	if err != nil {
+		return nil, Status(err)
+	}
+
+	return toMember(membership), nil
+}
+
+// RemoveMember removes a member from an organization.
+func (s *Server) RemoveMember(ctx context.Context, req *RemoveMemberRequest) (*RemoveMemberResponse, error) {
+	if s.orgs == nil {
+		return nil, status.Error(codes.FailedPrecondition, "organizations are not enabled")
+	}
+
+	id, err := uuid.Parse(req.UserID)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid user id")
+	}
+
+	if err := s.orgs.RemoveMember(ctx, caller(ctx), req.Slug, id); err != nil {
+		return nil, Status(err)
+	}
+
+	return &RemoveMemberResponse{Slug: req.Slug, UserID: req.UserID}, nil
+}
+
+// InviteMember sends an invitation to join an organization by email.
+func (s *Server) InviteMember(ctx context.Context, req *InviteMemberRequest) (*InviteMemberResponse, error) {
+	if s.orgs == nil {
+		return nil, status.Error(codes.FailedPrecondition, "organizations are not enabled")
+	}
+
+	if !strings.Contains(req.Email, "@") {
+		return nil, status.Error(codes.InvalidArgument, "invalid email address")
+	}
+
+	if err := s.orgs.Invite(ctx, caller(ctx), req.Slug, req.Email, req.Role); err != nil {
+		return nil, Status(err)
+	}
+
+	return &InviteMemberResponse{Email: req.Email}, nil
+}
+
+func (s *Server) userRoles(ctx context.Context, userID uuid.UUID) (*UserRoles, error) {
+	roles, err := s.rbac.UserRoles(ctx, userID)
+	if err != nil {
+		return nil, Status(err)
+	}
+
+	return &UserRoles{UserID: userID.String(), Roles: toRoles(roles), Permissions: rbac.Union(roles)}, nil
+}
+
+// internalError hides an unexpected error behind a generic Internal status.
+// The ErrorInterceptor reports the error it wraps.
+type internalError struct {
+	err error
+}
+
+func (e *internalError) Error() string {
+	return e.err.Error()
+}
+
+func (e *internalError) Unwrap() error {
+	return e.err
+}
+
+// GRPCStatus implements the interface used by the status package.
+func (e *internalError) GRPCStatus() *status.Status {
+	return status.New(codes.Internal, "internal error")
+}
+
+// Status maps an error to a gRPC status. Banned users get PermissionDenied,
+// so that they can be told apart from Unauthenticated. Unexpected errors
+// get a generic Internal status, so that no internals reach the caller.
+func Status(err error) error {
+	switch {
+	case errors.Is(err, ports.ErrUserBanned):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, ports.ErrSessionExpired):
+		return status.Error(codes.Unauthenticated, err.Error())
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return status.Error(codes.NotFound, "not found")
+	case errors.Is(err, webhook.ErrUnknownEndpoint):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, webhook.ErrConcurrentReplay):
+		return status.Error(codes.Aborted, err.Error())
+	case errors.Is(err, rbac.ErrPermissionDenied):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, rbac.ErrInvalidRoleName), errors.Is(err, rbac.ErrUnknownPermission), errors.Is(err, rbac.ErrAllPermissions):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, rbac.ErrRoleExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, rbac.ErrBuiltInRole):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, organization.ErrNotMember):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, organization.ErrInvalidSlug), errors.Is(err, organization.ErrInvalidDomain), errors.Is(err, organization.ErrMemberRole):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, organization.ErrOrganizationExists), errors.Is(err, organization.ErrDomainCaptured),
+		errors.Is(err, organization.ErrAlreadyMember):
+		return status.Error(codes.AlreadyExists, err.Error())
+	default:
+		return &internalError{err: err}
+	}
+}
+
+func auditFilter(req *ListAuditEventsRequest) (ports.AuditFilter, error) {
+	filter := ports.AuditFilter{Action: req.Action, After: req.After, Limit: req.Limit}
+
+	if req.ActorID != "" {
+		id, err := uuid.Parse(req.ActorID)
+		if err != nil {
+			return filter, status.Error(codes.InvalidArgument, "invalid actor id")
+		}
+
+		filter.ActorID = id
+	}
+
+	if req.UserID != "" {
+		id, err := uuid.Parse(req.UserID)
+		if err != nil {
+			return filter, status.Error(codes.InvalidArgument, "invalid user id")
+		}
+
+		filter.UserID = id
+	}
+
+	if req.Since != nil {
+		filter.Since = *req.Since
+	}
+
+	if req.Until != nil {
+		filter.Until = *req.Until
+	}
+
+	if req.Limit < 0 || req.After < 0 {
+		return filter, status.Error(codes.InvalidArgument, "limit and after must not be negative")
+	}
+
+	return filter, nil
+}
+
+func toAuditEvent(e models.AuditEvent) *AuditEvent {
+	event := &AuditEvent{
+		ID:         e.ID,
+		Action:     e.Action,
+		ActorType:  e.ActorType,
+		TargetType: e.TargetType,
+		TargetID:   e.TargetID,
+		IP:         e.IP,
+		UserAgent:  e.UserAgent,
+		RequestID:  e.RequestID,
+		Diff:       e.Diff,
+		CreatedAt:  e.CreatedAt,
+	}
+
+	if e.ActorID != nil {
+		event.ActorID = e.ActorID.String()
+	}
+
+	if e.UserID != nil {
+		event.UserID = e.UserID.String()
+	}
+
+	return event
+}
+
+func toWebhookDeliveries(deliveries []models.WebhookDelivery) []*WebhookDelivery {
+	res := make([]*WebhookDelivery, 0, len(deliveries))
+
+	for _, d := range deliveries {
+		delivery := &WebhookDelivery{
+			ID:             d.ID,
+			EventID:        d.EventID,
+			Endpoint:       d.Endpoint,
+			Status:         string(d.Status),
+			Attempts:       d.Attempts,
+			LastStatusCode: d.LastStatusCode,
+			LastError:      d.LastError,
+			DeliveredAt:    d.DeliveredAt,
+			CreatedAt:      d.CreatedAt,
+		}
+
+		if d.Status == models.WebhookPending {
+			delivery.NextAttemptAt = &d.NextAttemptAt
+		}
+
+		res = append(res, delivery)
+	}
+
+	return res
+}
+
+func toRoles(roles []models.Role) []*Role {
+	res := make([]*Role, 0, len(roles))
+	for _, r := range roles {
+		res = append(res, toRole(r))
+	}
+
+	return res
+}
+
+func toRole(r models.Role) *Role {
+	return &Role{Name: r.Name, Description: r.Description, BuiltIn: r.BuiltIn, Permissions: r.PermissionNames()}
+}
+
+func toOrganization(org models.Organization) *Organization {
+	return &Organization{
+		ID:               org.ID.String(),
+		Slug:             org.Slug,
+		Name:             org.Name,
+		AllowedProviders: append([]string{}, org.AllowedProviders...),
+		RequireMFA:       org.RequireMFA,
+		Domains:          org.DomainNames(),
+	}
+}
+
+func toMember(m models.Membership) *Member {
+	return &Member{
+		UserID:      m.UserID.String(),
+		Email:       m.User.Email,
+		Role:        m.Role.Name,
+		Permissions: m.Role.PermissionNames(),
+		JoinedAt:    m.CreatedAt,
+	}
+}
+
+func toUser(u models.User) *User {
+	user := &User{
+		ID:        u.ID.String(),
+		Email:     u.Email,
+		Name:      u.Name,
+		BanReason: u.BanReason,
+	}
+
+	if u.IsBanned() {
+		user.BannedUntil = &u.BannedUntil
+	}
+
+	return user
+}
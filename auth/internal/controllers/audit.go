@@ -0,0 +1,23 @@
+package controllers
+
+import (
+	"github.com/open-cloud-initiative/glue/auth/internal/audit"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/middleware/requestid"
+)
+
+// RequestMetadata is a middleware that records the client IP, user agent and
+// request ID of the request for the audit events it causes. It runs after the
+// request ID and RealIP middlewares. The actor is set once a session is authenticated.
+func RequestMetadata() fiber.Handler {
+	return func(ctx fiber.Ctx) error {
+		ctx.Locals(audit.MetadataKey, &audit.Metadata{
+			IP:        ClientIP(ctx),
+			UserAgent: ctx.Get(fiber.HeaderUserAgent),
+			RequestID: requestid.FromContext(ctx),
+		})
+
+		return ctx.Next()
+	}
+}
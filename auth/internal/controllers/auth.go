@@ -0,0 +1,546 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/open-cloud-initiative/glue/auth/internal/adapters/auth"
+	"github.com/open-cloud-initiative/glue/auth/internal/lockout"
+	"github.com/open-cloud-initiative/glue/auth/internal/models"
+	"github.com/open-cloud-initiative/glue/auth/internal/organization"
+	"github.com/open-cloud-initiative/glue/auth/internal/password"
+	"github.com/open-cloud-initiative/glue/auth/internal/ports"
+	"github.com/open-cloud-initiative/glue/auth/internal/ratelimit"
+	"github.com/open-cloud-initiative/glue/auth/internal/tenancy"
+	"github.com/open-cloud-initiative/glue/auth/internal/verification"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/katallaxie/pkg/utilx"
+	"gorm.io/gorm"
+)
+
+const (
+	// SessionCookie is the name of the session cookie.
+	SessionCookie = "glue_session"
+	// StateCookie is the name of the cookie holding the state of a login flow.
+	StateCookie = "glue_state"
+	// VerifierCookie is the name of the cookie holding the code verifier of a login flow.
+	VerifierCookie = "glue_verifier"
+	// FlowCookie is the name of the cookie holding the kind of a flow.
+	FlowCookie = "glue_flow"
+	// LinkCookie is the name of the cookie holding the session token of a link flow,
+	// because the session cookie is not sent with the POST callback of a SAML provider.
+	LinkCookie = "glue_link"
+
+	// FlowLogin logs a user in, or creates the user.
+	FlowLogin = "login"
+	// FlowLink links a provider account to the signed-in user.
+	FlowLink = "link"
+
+	// DefaultSessionTTL is the default lifetime of a session.
+	DefaultSessionTTL = 7 * 24 * time.Hour
+	// DefaultFlowTTL is the default time a login flow has to complete.
+	DefaultFlowTTL = 10 * time.Minute
+	// DefaultIPLimit is the default number of codes sent and checked, or passwords tried, per client IP and hour.
+	DefaultIPLimit = 30
+
+	stateLength = 32
+)
+
+// ErrInvalidState is returned when the state of a login flow does not match.
+var ErrInvalidState = errors.New("invalid state")
+
+// AuthController handles the login flows of the registered providers.
+type AuthController struct {
+	registry   *auth.Registry
+	adapter    ports.Auth
+	sessionTTL time.Duration
+	secure     bool
+	verifier   *verification.Service
+	passwords  *password.Service
+	ipLimit    ratelimit.Limiter
+	guard      *lockout.Guard
+	tenancy    *tenancy.Resolver
+	orgs       *organization.Service
+	onError    func(error)
+}
+
+// AuthOpt is a function that configures the AuthController.
+type AuthOpt func(*AuthController)
+
+// WithSessionTTL sets the lifetime of new sessions.
+func WithSessionTTL(ttl time.Duration) AuthOpt {
+	return func(ac *AuthController) {
+		ac.sessionTTL = ttl
+	}
+}
+
+// WithInsecureCookies allows cookies to be sent over plain HTTP, e.g. for development.
+func WithInsecureCookies() AuthOpt {
+	return func(ac *AuthController) {
+		ac.secure = false
+	}
+}
+
+// WithVerifier sends a verification email to new users whose email
+// address is not verified by the provider.
+func WithVerifier(verifier *verification.Service) AuthOpt {
+	return func(ac *AuthController) {
+		ac.verifier = verifier
+	}
+}
+
+// WithPasswords enables the login with email address and password.
+func WithPasswords(passwords *password.Service) AuthOpt {
+	return func(ac *AuthController) {
+		ac.passwords = passwords
+	}
+}
+
+// WithIPLimit limits the codes sent and checked per client IP.
+func WithIPLimit(limiter ratelimit.Limiter) AuthOpt {
+	return func(ac *AuthController) {
+		ac.ipLimit = limiter
+	}
+}
+
+// WithGuard records failed logins and locks out subjects and IPs with too many failures.
+func WithGuard(guard *lockout.Guard) AuthOpt {
+	return func(ac *AuthController) {
+		ac.guard = guard
+	}
+}
+
+// WithTenancy resolves the tenant of a login from the request host, in
+// addition to the path prefix /t/<slug>. The tenant selects the providers.
+func WithTenancy(resolver *tenancy.Resolver) AuthOpt {
+	return func(ac *AuthController) {
+		ac.tenancy = resolver
+	}
+}
+
+// WithDiscovery enables the home realm discovery, that resolves the
+// tenant from the domain of an email address captured by an organization.
+func WithDiscovery(orgs *organization.Service) AuthOpt {
+	return func(ac *AuthController) {
+		ac.orgs = orgs
+	}
+}
+
+// WithErrorHandler sets the function the emails that could not be sent after
+// a login or signup are passed to. The request succeeds regardless.
+func WithErrorHandler(fn func(error)) AuthOpt {
+	return func(ac *AuthController) {
+		ac.onError = fn
+	}
+}
+
+// NewAuthController creates a new AuthController.
+func NewAuthController(registry *auth.Registry, adapter ports.Auth, opts ...AuthOpt) *AuthController {
+	ac := &AuthController{
+		registry:   registry,
+		adapter:    adapter,
+		sessionTTL: DefaultSessionTTL,
+		secure:     true,
+		ipLimit:    ratelimit.NewSlidingWindow(DefaultIPLimit, time.Hour),
+		onError:    func(error) {},
+	}
+
+	for _, opt := range opts {
+		opt(ac)
+	}
+
+	return ac
+}
+
+// ProviderInfo describes a registered provider.
+type ProviderInfo struct {
+	ID       string            `json:"id"`
+	Name     string            `json:"name"`
+	Type     auth.ProviderType `json:"type"`
+	LoginURL string            `json:"loginUrl"`
+}
+
+// DiscoverRequest is the email address entered at the home realm discovery.
+type DiscoverRequest struct {
+	Email string `json:"email"`
+}
+
+// Realm is the tenant of a login and the providers to log in with.
+type Realm struct {
+	Tenant    string         `json:"tenant,omitempty"`
+	Providers []ProviderInfo `json:"providers"`
+}
+
+// ListProviders lists the providers of the tenant of the request.
+func (ac *AuthController) ListProviders(ctx fiber.Ctx) error {
+	return ctx.JSON(ac.providers(ac.tenant(ctx)))
+}
+
+// Discover resolves the tenant from the domain of the email address and lists its
+// providers. Without a tenant provider the global providers are listed.
+func (ac *AuthController) Discover(ctx fiber.Ctx) error {
+	if ac.orgs == nil {
+		return fiber.ErrNotFound
+	}
+
+	var req DiscoverRequest
+	if err := ctx.Bind().JSON(&req); err != nil {
+		return fiber.ErrBadRequest
+	}
+
+	realm := Realm{Tenant: ac.tenant(ctx)}
+
+	org, err := ac.orgs.Discover(ctx, req.Email)
+	switch {
+	case errors.Is(err, organization.ErrInvalidDomain):
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	case err == nil:
+		realm.Tenant = org.Slug
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return err
+	}
+
+	realm.Providers = ac.providers(realm.Tenant)
+	if len(realm.Providers) == 0 {
+		realm.Providers = ac.providers("")
+	}
+
+	return ctx.JSON(realm)
+}
+
+func (ac *AuthController) providers(tenant string) []ProviderInfo {
+	providers := []ProviderInfo{}
+
+	for _, p := range ac.registry.List(tenant) {
+		providers = append(providers, ProviderInfo{ID: p.ID(), Name: p.Name(), Type: p.Type(), LoginURL: loginURL(p)})
+	}
+
+	slices.SortFunc(providers, func(a, b ProviderInfo) int { return strings.Compare(a.ID, b.ID) })
+
+	return providers
+}
+
+// tenant returns the tenant of the request from the path prefix /t/<slug>, or from the host.
+func (ac *AuthController) tenant(ctx fiber.Ctx) string {
+	if tenant := ctx.Params("tenant"); utilx.NotEmpty(tenant) {
+		return tenant
+	}
+
+	if ac.tenancy == nil {
+		return ""
+	}
+
+	return ac.tenancy.Resolve(ctx.Hostname())
+}
+
+// provider returns the provider of the route in the tenant of the request.
+func (ac *AuthController) provider(ctx fiber.Ctx) (auth.Provider, error) {
+	provider, err := ac.registry.Get(ac.tenant(ctx), ctx.Params("provider"))
+	if err != nil {
+		return nil, fiber.ErrNotFound
+	}
+
+	return provider, nil
+}
+
+func loginURL(p auth.Provider) string {
+	if utilx.Empty(p.Tenant()) {
+		return "/auth/" + p.ID() + "/login"
+	}
+
+	return "/t/" + p.Tenant() + "/auth/" + p.ID() + "/login"
+}
+
+// Login starts the login flow of a provider.
+func (ac *AuthController) Login(ctx fiber.Ctx) error {
+	return ac.begin(ctx, FlowLogin)
+}
+
+// Link starts a flow that links a provider account to the signed-in user.
+func (ac *AuthController) Link(ctx fiber.Ctx) error {
+	if _, ok := SessionFromContext(ctx); !ok {
+		return fiber.ErrUnauthorized
+	}
+
+	ac.setFlowCookie(ctx, LinkCookie, sessionToken(ctx))
+
+	return ac.begin(ctx, FlowLink)
+}
+
+func (ac *AuthController) begin(ctx fiber.Ctx, flow string) error {
+	provider, err := ac.provider(ctx)
+	if err != nil {
+		return err
+	}
+
+	state, err := newState()
+	if err != nil {
+		return err
+	}
+
+	intent, err := provider.BeginAuth(ctx, ac.adapter, state, &params{ctx: ctx})
+	if err != nil {
+		return err
+	}
+
+	uri, err := intent.GetAuthURL()
+	if err != nil {
+		return err
+	}
+
+	ac.setFlowCookie(ctx, StateCookie, state)
+	ac.setFlowCookie(ctx, VerifierCookie, intent.CodeVerifier())
+	ac.setFlowCookie(ctx, FlowCookie, flow)
+
+	return ctx.Redirect().To(uri)
+}
+
+// Callback completes the flow of a provider. A login flow creates a
+// session, a link flow links the account to the signed-in user.
+func (ac *AuthController) Callback(ctx fiber.Ctx) error {
+	provider, err := ac.provider(ctx)
+	if err != nil {
+		return err
+	}
+
+	p := &params{ctx: ctx, verifier: ctx.Cookies(VerifierCookie)}
+	flow := ctx.Cookies(FlowCookie)
+	link := ctx.Cookies(LinkCookie)
+
+	state := utilx.Or(p.Get("state"), p.Get("RelayState"))
+	expected := ctx.Cookies(StateCookie)
+
+	if utilx.Empty(expected) || subtle.ConstantTimeCompare([]byte(state), []byte(expected)) != 1 {
+		return fiber.NewError(fiber.StatusBadRequest, ErrInvalidState.Error())
+	}
+
+	ac.clearCookie(ctx, StateCookie)
+	ac.clearCookie(ctx, VerifierCookie)
+	ac.clearCookie(ctx, FlowCookie)
+	ac.clearCookie(ctx, LinkCookie)
+
+	if flow == FlowLink {
+		return ac.completeLink(ctx, provider, p, utilx.Or(link, sessionToken(ctx)))
+	}
+
+	var user models.User
+
+	err = guarded(ctx, ac.guard, claim(provider, p), failedLogin, func() error {
+		var err error
+		user, err = provider.CompleteAuth(ctx, ac.adapter, p)
+
+		return err
+	})
+	if err != nil {
+		return authError(err)
+	}
+
+	session, err := ac.adapter.CreateSession(ctx, user.ID, models.ProviderKey(provider.Tenant(), provider.ID()), time.Now().Add(ac.sessionTTL))
+	if forbidden(err) {
+		return authError(err)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	ac.setCookie(ctx, SessionCookie, session.SessionToken, ac.sessionTTL)
+
+	if session.MFARequired {
+		return ctx.Status(fiber.StatusAccepted).JSON(MFAChallenge{MFARequired: true})
+	}
+
+	if ac.verifier != nil && user.EmailVerifiedAt.IsZero() && user.ConfirmationSentAt.IsZero() {
+		if err := ac.verifier.SendEmailVerification(ctx, user.ID); err != nil {
+			ac.onError(fmt.Errorf("send verification email: %w", err))
+		}
+	}
+
+	return ctx.JSON(user)
+}
+
+func (ac *AuthController) completeLink(ctx fiber.Ctx, provider auth.Provider, p *params, token string) error {
+	session, err := ac.adapter.GetSession(ctx, token)
+	if errors.Is(err, ports.ErrUserBanned) {
+		return authError(err)
+	}
+
+	if err != nil {
+		return fiber.ErrUnauthorized
+	}
+
+	var user models.User
+
+	err = guarded(ctx, ac.guard, "link:"+session.UserID.String(), failedLogin, func() error {
+		var err error
+		user, err = provider.CompleteAuth(ctx, &linking{Auth: ac.adapter, userID: session.UserID}, p)
+
+		return err
+	})
+	if errors.Is(err, ports.ErrAccountInUse) || errors.Is(err, ports.ErrAccountNotLinked) {
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	}
+
+	if err != nil {
+		return authError(err)
+	}
+
+	return ctx.JSON(user)
+}
+
+// claim returns the lockout subject of the account a callback claims,
+// or an empty string if the provider can not tell it before verifying it.
+func claim(provider auth.Provider, p auth.AuthParams) string {
+	c, ok := provider.(auth.Claimant)
+	if !ok {
+		return ""
+	}
+
+	id := c.Claim(p)
+	if id == "" {
+		return ""
+	}
+
+	return "account:" + models.ProviderKey(provider.Tenant(), provider.ID()) + ":" + id
+}
+
+// authError maps an error of a login to a response. Banned users and logins
+// denied by a hook or a login policy get a 403, so that they can be told apart
+// from failed logins.
+func authError(err error) error {
+	var fe *fiber.Error
+	if errors.As(err, &fe) {
+		return err
+	}
+
+	if forbidden(err) {
+		return fiber.NewError(fiber.StatusForbidden, err.Error())
+	}
+
+	return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+}
+
+// forbidden returns true if the user may not log in, as opposed to a failed login.
+func forbidden(err error) bool {
+	return errors.Is(err, ports.ErrUserBanned) || errors.Is(err, ports.ErrHookDenied) || errors.Is(err, ports.ErrLoginPolicy)
+}
+
+// linking links the account completed by a provider to a user,
+// instead of logging in or creating a user.
+type linking struct {
+	ports.Auth
+	userID uuid.UUID
+}
+
+// UpsertUser links the provider account of the profile to the user.
+func (l *linking) UpsertUser(ctx context.Context, profile models.User) (models.User, error) {
+	return l.LinkUser(ctx, l.userID, profile)
+}
+
+// Anonymous creates an anonymous user and a session, e.g. for a guest checkout.
+// The user becomes permanent by linking an account, an email address or a phone number.
+func (ac *AuthController) Anonymous(ctx fiber.Ctx) error {
+	if err := limitIP(ctx, ac.ipLimit); err != nil {
+		return err
+	}
+
+	user, err := ac.adapter.CreateAnonymousUser(ctx)
+	if forbidden(err) {
+		return authError(err)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	session, err := ac.adapter.CreateSession(ctx, user.ID, models.LoginAnonymous, time.Now().Add(ac.sessionTTL))
+	if forbidden(err) {
+		return authError(err)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	ac.setCookie(ctx, SessionCookie, session.SessionToken, ac.sessionTTL)
+
+	return ctx.Status(fiber.StatusCreated).JSON(user)
+}
+
+// Logout deletes the current session.
+func (ac *AuthController) Logout(ctx fiber.Ctx) error {
+	token := ctx.Cookies(SessionCookie)
+	if utilx.NotEmpty(token) {
+		if err := ac.adapter.DeleteSession(ctx, token); err != nil {
+			return err
+		}
+	}
+
+	ac.clearCookie(ctx, SessionCookie)
+
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+// setCookie sets a cookie that is not sent with cross-site POST requests,
+// which protects the routes authenticated by the session cookie from CSRF.
+func (ac *AuthController) setCookie(ctx fiber.Ctx, name, value string, ttl time.Duration) {
+	ac.cookie(ctx, name, value, ttl, fiber.CookieSameSiteLaxMode)
+}
+
+// setFlowCookie sets a short-lived cookie of a login flow. In secure mode it is
+// also sent with cross-site requests, because SAML providers POST to the callback.
+// The callback only trusts them if the state matches.
+func (ac *AuthController) setFlowCookie(ctx fiber.Ctx, name, value string) {
+	ac.cookie(ctx, name, value, DefaultFlowTTL, utilx.IfElse(ac.secure, fiber.CookieSameSiteNoneMode, fiber.CookieSameSiteLaxMode))
+}
+
+func (ac *AuthController) cookie(ctx fiber.Ctx, name, value string, ttl time.Duration, sameSite string) {
+	ctx.Cookie(&fiber.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		Expires:  time.Now().Add(ttl),
+		Secure:   ac.secure,
+		HTTPOnly: true,
+		SameSite: sameSite,
+	})
+}
+
+func (ac *AuthController) clearCookie(ctx fiber.Ctx, name string) {
+	ac.setCookie(ctx, name, "", -time.Hour)
+}
+
+var _ auth.AuthParams = (*params)(nil)
+
+type params struct {
+	ctx      fiber.Ctx
+	verifier string
+}
+
+// Get returns the value of a query or form parameter by name.
+func (p *params) Get(name string) string {
+	return utilx.Or(p.ctx.Query(name), p.ctx.FormValue(name))
+}
+
+// CodeVerifier returns the code verifier of the login flow.
+func (p *params) CodeVerifier() string {
+	return p.verifier
+}
+
+func newState() (string, error) {
+	b := make([]byte, stateLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/open-cloud-initiative/glue/auth/internal/lockout"
+	"github.com/open-cloud-initiative/glue/auth/internal/models"
+	"github.com/open-cloud-initiative/glue/auth/internal/ports"
+	"github.com/open-cloud-initiative/glue/auth/internal/verification"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// EmailController handles the verification and change of email addresses.
+type EmailController struct {
+	adapter      ports.Auth
+	verifier     *verification.Service
+	reauthWindow time.Duration
+	guard        *lockout.Guard
+}
+
+// EmailOpt is a function that configures the EmailController.
+type EmailOpt func(*EmailController)
+
+// WithEmailReauthWindow sets the time after a login in which the email address can be changed.
+func WithEmailReauthWindow(window time.Duration) EmailOpt {
+	return func(ec *EmailController) {
+		ec.reauthWindow = window
+	}
+}
+
+// WithEmailGuard records invalid links and locks out IPs with too many of them.
+func WithEmailGuard(guard *lockout.Guard) EmailOpt {
+	return func(ec *EmailController) {
+		ec.guard = guard
+	}
+}
+
+// NewEmailController creates a new EmailController.
+func NewEmailController(adapter ports.Auth, verifier *verification.Service, opts ...EmailOpt) *EmailController {
+	ec := &EmailController{
+		adapter:      adapter,
+		verifier:     verifier,
+		reauthWindow: DefaultReauthWindow,
+	}
+
+	for _, opt := range opts {
+		opt(ec)
+	}
+
+	return ec
+}
+
+// ChangeEmailRequest is the body of a request to change the email address.
+type ChangeEmailRequest struct {
+	Email string `json:"email"`
+}
+
+// SendVerification sends a new verification link to the email address of the signed-in user.
+func (ec *EmailController) SendVerification(ctx fiber.Ctx) error {
+	session, ok := SessionFromContext(ctx)
+	if !ok {
+		return fiber.ErrUnauthorized
+	}
+
+	if err := ec.verifier.SendEmailVerification(ctx, session.UserID); err != nil {
+		return emailError(err)
+	}
+
+	return ctx.SendStatus(fiber.StatusAccepted)
+}
+
+// ChangeEmail sends a link to the new email address of the signed-in user,
+// that changes the address once it is opened. It requires a recent login.
+func (ec *EmailController) ChangeEmail(ctx fiber.Ctx) error {
+	session, ok := SessionFromContext(ctx)
+	if !ok {
+		return fiber.ErrUnauthorized
+	}
+
+	req := ChangeEmailRequest{}
+	if err := ctx.Bind().JSON(&req); err != nil {
+		return fiber.ErrBadRequest
+	}
+
+	if !validEmail(req.Email) {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid email address")
+	}
+
+	user, err := ec.adapter.GetUser(ctx, session.UserID)
+	if err != nil {
+		return err
+	}
+
+	// Anonymous users have no address that could be taken over.
+	if !user.IsAnonymous && time.Since(user.ReauthenticatedAt) > ec.reauthWindow {
+		return fiber.NewError(fiber.StatusUnauthorized, ports.ErrReauthenticationRequired.Error())
+	}
+
+	if err := ec.verifier.RequestEmailChange(ctx, session.UserID, req.Email); err != nil {
+		return emailError(err)
+	}
+
+	return ctx.SendStatus(fiber.StatusAccepted)
+}
+
+// Confirm consumes the token of a verification or change link.
+func (ec *EmailController) Confirm(ctx fiber.Ctx) error {
+	var user models.User
+
+	err := guarded(ctx, ec.guard, "", wrongSecret, func() error {
+		var err error
+		user, err = ec.verifier.ConfirmEmail(ctx, ctx.Query("token"))
+
+		return err
+	})
+	if err != nil {
+		return emailError(err)
+	}
+
+	return ctx.JSON(user)
+}
+
+func emailError(err error) error {
+	switch {
+	case errors.Is(err, verification.ErrThrottled):
+		return fiber.NewError(fiber.StatusTooManyRequests, err.Error())
+	case errors.Is(err, verification.ErrAlreadyVerified), errors.Is(err, verification.ErrEmailInUse):
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	case errors.Is(err, verification.ErrInvalidToken), errors.Is(err, verification.ErrNoEmail):
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	default:
+		return err
+	}
+}
@@ -0,0 +1,59 @@
+package controllers
+
+import (
+	"net/netip"
+	"slices"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+type clientIPKey struct{}
+
+// RealIP is a middleware that takes the client IP from the proxy header, e.g.
+// X-Forwarded-For, if the request comes from a trusted proxy. The header is
+// read from the right, so that the client IP is the last one not added by a
+// trusted proxy and a client cannot choose its IP by sending the header.
+func RealIP(trusted []netip.Prefix, header string) fiber.Handler {
+	isTrusted := func(addr netip.Addr) bool {
+		return slices.ContainsFunc(trusted, func(p netip.Prefix) bool {
+			return p.Contains(addr.Unmap())
+		})
+	}
+
+	return func(ctx fiber.Ctx) error {
+		remote, err := netip.ParseAddr(ctx.RequestCtx().RemoteIP().String())
+		if err != nil || !isTrusted(remote) {
+			return ctx.Next()
+		}
+
+		client := remote
+		hops := strings.Split(ctx.Get(header), ",")
+
+		for i := len(hops) - 1; i >= 0; i-- {
+			addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+			if err != nil {
+				break
+			}
+
+			client = addr
+			if !isTrusted(addr) {
+				break
+			}
+		}
+
+		ctx.Locals(clientIPKey{}, client.Unmap().String())
+
+		return ctx.Next()
+	}
+}
+
+// ClientIP returns the client IP set by the RealIP middleware,
+// or the IP of the peer if the request did not come through a trusted proxy.
+func ClientIP(ctx fiber.Ctx) string {
+	if ip, ok := ctx.Locals(clientIPKey{}).(string); ok {
+		return ip
+	}
+
+	return ctx.IP()
+}
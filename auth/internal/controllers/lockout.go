@@ -0,0 +1,52 @@
+package controllers
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/open-cloud-initiative/glue/auth/internal/adapters/auth"
+	"github.com/open-cloud-initiative/glue/auth/internal/lockout"
+	"github.com/open-cloud-initiative/glue/auth/internal/password"
+	"github.com/open-cloud-initiative/glue/auth/internal/verification"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// guarded runs an attempt of the subject from the client IP, unless they are
+// locked out. The attempt counts as failure, unless it succeeds or fails with
+// an error not matched by failed. A successful attempt resets the counters of
+// the subject. Without a guard, fn runs unchecked.
+func guarded(ctx fiber.Ctx, guard *lockout.Guard, subject string, failed func(error) bool, fn func() error) error {
+	if guard == nil {
+		return fn()
+	}
+
+	var locked *lockout.LockedError
+
+	err := guard.Acquire(ctx, subject, ClientIP(ctx))
+	if errors.As(err, &locked) {
+		ctx.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(time.Until(locked.Until).Seconds())+1))
+		return fiber.NewError(fiber.StatusTooManyRequests, err.Error())
+	}
+
+	if err != nil {
+		return err
+	}
+
+	err = fn()
+	guard.Settle(ctx, subject, ClientIP(ctx), err, failed)
+
+	return err
+}
+
+// failedLogin matches the callbacks a provider rejected, e.g. with a wrong code
+// or an invalid assertion, as opposed to failures of the provider or the store.
+func failedLogin(err error) bool {
+	return errors.Is(err, auth.ErrInvalidCredentials)
+}
+
+// wrongSecret matches failures caused by a wrong password, code or token.
+func wrongSecret(err error) bool {
+	return errors.Is(err, password.ErrInvalidCredentials) || errors.Is(err, verification.ErrInvalidToken)
+}
@@ -0,0 +1,431 @@
+package controllers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/open-cloud-initiative/glue/auth/internal/models"
+	"github.com/open-cloud-initiative/glue/auth/internal/organization"
+	"github.com/open-cloud-initiative/glue/auth/internal/ports"
+	"github.com/open-cloud-initiative/glue/auth/internal/rbac"
+	"github.com/open-cloud-initiative/glue/auth/internal/verification"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// RequireOrgPermission is a middleware that requires the signed-in user to be
+// a member of the organization in the slug parameter with a role that grants
+// the permission. It must run after Authenticated.
+func RequireOrgPermission(orgs *organization.Service, permission string) fiber.Handler {
+	return func(ctx fiber.Ctx) error {
+		session, ok := SessionFromContext(ctx)
+		if !ok {
+			return fiber.ErrUnauthorized
+		}
+
+		if err := orgs.Check(ctx, ctx.Params("slug"), session.UserID, permission); err != nil {
+			return orgError(err)
+		}
+
+		return ctx.Next()
+	}
+}
+
+// OrganizationController manages organizations, their members and the
+// active organization of the session.
+type OrganizationController struct {
+	orgs *organization.Service
+}
+
+// NewOrganizationController creates a new OrganizationController.
+func NewOrganizationController(orgs *organization.Service) *OrganizationController {
+	return &OrganizationController{orgs: orgs}
+}
+
+// Organization is an organization with its login policy.
+type Organization struct {
+	Slug             string   `json:"slug"`
+	Name             string   `json:"name,omitempty"`
+	AllowedProviders []string `json:"allowedProviders"`
+	RequireMFA       bool     `json:"requireMfa"`
+	Domains          []string `json:"domains"`
+}
+
+// OrganizationRequest is the body of a request to create or update an
+// organization. Domains are kept if they are omitted.
+type OrganizationRequest struct {
+	Slug             string   `json:"slug"`
+	Name             string   `json:"name,omitempty"`
+	AllowedProviders []string `json:"allowedProviders,omitempty"`
+	RequireMFA       bool     `json:"requireMfa,omitempty"`
+	Domains          []string `json:"domains,omitempty"`
+}
+
+// Member is a member of an organization.
+type Member struct {
+	UserID      uuid.UUID `json:"userId"`
+	Email       string    `json:"email,omitempty"`
+	Name        string    `json:"name,omitempty"`
+	Role        string    `json:"role"`
+	Permissions []string  `json:"permissions"`
+	JoinedAt    time.Time `json:"joinedAt"`
+}
+
+// MemberRequest is the body of a request to add a member or change its role.
+type MemberRequest struct {
+	UserID uuid.UUID `json:"userId"`
+	Role   string    `json:"role"`
+}
+
+// InviteRequest is the body of a request to invite a member by email.
+type InviteRequest struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+// Membership is an organization of the signed-in user.
+type Membership struct {
+	Organization Organization `json:"organization"`
+	Role         string       `json:"role"`
+	Permissions  []string     `json:"permissions"`
+	Active       bool         `json:"active"`
+}
+
+// SwitchRequest is the body of a request to change the active organization.
+// An empty organization leaves the active organization.
+type SwitchRequest struct {
+	Organization string `json:"organization"`
+}
+
+// AcceptRequest is the body of a request to accept an invitation.
+type AcceptRequest struct {
+	Token string `json:"token"`
+}
+
+// Session is the session of the signed-in user.
+type Session struct {
+	ID           uuid.UUID     `json:"id"`
+	UserID       uuid.UUID     `json:"userId"`
+	Provider     string        `json:"provider"`
+	ExpiresAt    time.Time     `json:"expiresAt"`
+	Organization *Organization `json:"organization,omitempty"`
+}
+
+// ListOrganizations lists all organizations.
+func (oc *OrganizationController) ListOrganizations(ctx fiber.Ctx) error {
+	orgs, err := oc.orgs.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	res := make([]Organization, 0, len(orgs))
+	for _, org := range orgs {
+		res = append(res, toOrganization(org))
+	}
+
+	return ctx.JSON(res)
+}
+
+// GetOrganization returns an organization.
+func (oc *OrganizationController) GetOrganization(ctx fiber.Ctx) error {
+	org, err := oc.orgs.Get(ctx, ctx.Params("slug"))
+	if err != nil {
+		return orgError(err)
+	}
+
+	return ctx.JSON(toOrganization(org))
+}
+
+// CreateOrganization creates an organization.
+func (oc *OrganizationController) CreateOrganization(ctx fiber.Ctx) error {
+	req := OrganizationRequest{}
+	if err := ctx.Bind().JSON(&req); err != nil {
+		return fiber.ErrBadRequest
+	}
+
+	org, err := oc.orgs.Create(ctx, req.Slug, settings(req))
+	if err != nil {
+		return orgError(err)
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(toOrganization(org))
+}
+
+// UpdateOrganization replaces the name, login policy and domains of an organization.
+func (oc *OrganizationController) UpdateOrganization(ctx fiber.Ctx) error {
+	req := OrganizationRequest{}
+	if err := ctx.Bind().JSON(&req); err != nil {
+		return fiber.ErrBadRequest
+	}
+
+	org, err := oc.orgs.Update(ctx, ctx.Params("slug"), settings(req))
+	if err != nil {
+		return orgError(err)
+	}
+
+	return ctx.JSON(toOrganization(org))
+}
+
+// UpdatePolicy replaces the name and login policy of an organization.
+// The captured domains can only be changed by an admin.
+func (oc *OrganizationController) UpdatePolicy(ctx fiber.Ctx) error {
+	req := OrganizationRequest{}
+	if err := ctx.Bind().JSON(&req); err != nil {
+		return fiber.ErrBadRequest
+	}
+
+	req.Domains = nil
+
+	org, err := oc.orgs.Update(ctx, ctx.Params("slug"), settings(req))
+	if err != nil {
+		return orgError(err)
+	}
+
+	return ctx.JSON(toOrganization(org))
+}
+
+// DeleteOrganization deletes an organization.
+func (oc *OrganizationController) DeleteOrganization(ctx fiber.Ctx) error {
+	if err := oc.orgs.Delete(ctx, ctx.Params("slug")); err != nil {
+		return orgError(err)
+	}
+
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+// ListMembers lists the members of an organization.
+func (oc *OrganizationController) ListMembers(ctx fiber.Ctx) error {
+	memberships, err := oc.orgs.Members(ctx, ctx.Params("slug"))
+	if err != nil {
+		return orgError(err)
+	}
+
+	res := make([]Member, 0, len(memberships))
+	for _, m := range memberships {
+		res = append(res, toMember(m))
+	}
+
+	return ctx.JSON(res)
+}
+
+// AddMember adds a user to an organization.
+func (oc *OrganizationController) AddMember(ctx fiber.Ctx) error {
+	session, ok := SessionFromContext(ctx)
+	if !ok {
+		return fiber.ErrUnauthorized
+	}
+
+	req := MemberRequest{}
+	if err := ctx.Bind().JSON(&req); err != nil {
+		return fiber.ErrBadRequest
+	}
+
+	membership, err := oc.orgs.AddMember(ctx, session.UserID, ctx.Params("slug"), req.UserID, req.Role)
+	if err != nil {
+		return orgError(err)
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(toMember(membership))
+}
+
+// UpdateMember changes the role of a member.
+func (oc *OrganizationController) UpdateMember(ctx fiber.Ctx) error {
+	session, ok := SessionFromContext(ctx)
+	if !ok {
+		return fiber.ErrUnauthorized
+	}
+
+	userID, err := uuid.Parse(ctx.Params("id"))
+	if err != nil {
+		return fiber.ErrBadRequest
+	}
+
+	req := MemberRequest{}
+	if err := ctx.Bind().JSON(&req); err != nil {
+		return fiber.ErrBadRequest
+	}
+
+	membership, err := oc.orgs.SetRole(ctx, session.UserID, ctx.Params("slug"), userID, req.Role)
+	if err != nil {
+		return orgError(err)
+	}
+
+	return ctx.JSON(toMember(membership))
+}
+
+// RemoveMember removes a member from an organization.
+func (oc *OrganizationController) RemoveMember(ctx fiber.Ctx) error {
+	session, ok := SessionFromContext(ctx)
+	if !ok {
+		return fiber.ErrUnauthorized
+	}
+
+	userID, err := uuid.Parse(ctx.Params("id"))
+	if err != nil {
+		return fiber.ErrBadRequest
+	}
+
+	if err := oc.orgs.RemoveMember(ctx, session.UserID, ctx.Params("slug"), userID); err != nil {
+		return orgError(err)
+	}
+
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+// Invite sends an invitation to join an organization by email.
+func (oc *OrganizationController) Invite(ctx fiber.Ctx) error {
+	session, ok := SessionFromContext(ctx)
+	if !ok {
+		return fiber.ErrUnauthorized
+	}
+
+	req := InviteRequest{}
+	if err := ctx.Bind().JSON(&req); err != nil {
+		return fiber.ErrBadRequest
+	}
+
+	if !validEmail(req.Email) {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid email address")
+	}
+
+	if err := oc.orgs.Invite(ctx, session.UserID, ctx.Params("slug"), req.Email, req.Role); err != nil {
+		return orgError(err)
+	}
+
+	return ctx.SendStatus(fiber.StatusAccepted)
+}
+
+// AcceptInvitation makes the signed-in user a member of the organization of an invitation.
+func (oc *OrganizationController) AcceptInvitation(ctx fiber.Ctx) error {
+	session, ok := SessionFromContext(ctx)
+	if !ok {
+		return fiber.ErrUnauthorized
+	}
+
+	req := AcceptRequest{}
+	if err := ctx.Bind().JSON(&req); err != nil {
+		return fiber.ErrBadRequest
+	}
+
+	membership, err := oc.orgs.Accept(ctx, session.UserID, req.Token)
+	if err != nil {
+		return orgError(err)
+	}
+
+	return ctx.JSON(toMember(membership))
+}
+
+// MyOrganizations lists the organizations of the signed-in user.
+func (oc *OrganizationController) MyOrganizations(ctx fiber.Ctx) error {
+	session, ok := SessionFromContext(ctx)
+	if !ok {
+		return fiber.ErrUnauthorized
+	}
+
+	memberships, err := oc.orgs.Memberships(ctx, session.UserID)
+	if err != nil {
+		return err
+	}
+
+	res := make([]Membership, 0, len(memberships))
+	for _, m := range memberships {
+		res = append(res, Membership{
+			Organization: toOrganization(m.Organization),
+			Role:         m.Role.Name,
+			Permissions:  m.Role.PermissionNames(),
+			Active:       session.OrganizationID != nil && *session.OrganizationID == m.OrganizationID,
+		})
+	}
+
+	return ctx.JSON(res)
+}
+
+// MySession returns the session of the signed-in user with its active organization.
+func (oc *OrganizationController) MySession(ctx fiber.Ctx) error {
+	session, ok := SessionFromContext(ctx)
+	if !ok {
+		return fiber.ErrUnauthorized
+	}
+
+	return ctx.JSON(toSession(session))
+}
+
+// SwitchOrganization changes the active organization of the session.
+func (oc *OrganizationController) SwitchOrganization(ctx fiber.Ctx) error {
+	session, ok := SessionFromContext(ctx)
+	if !ok {
+		return fiber.ErrUnauthorized
+	}
+
+	req := SwitchRequest{}
+	if err := ctx.Bind().JSON(&req); err != nil {
+		return fiber.ErrBadRequest
+	}
+
+	session, err := oc.orgs.Switch(ctx, session, req.Organization)
+	if err != nil {
+		return orgError(err)
+	}
+
+	return ctx.JSON(toSession(session))
+}
+
+func settings(req OrganizationRequest) organization.Settings {
+	return organization.Settings{
+		Name:             req.Name,
+		AllowedProviders: req.AllowedProviders,
+		RequireMFA:       req.RequireMFA,
+		Domains:          req.Domains,
+	}
+}
+
+func toOrganization(org models.Organization) Organization {
+	return Organization{
+		Slug:             org.Slug,
+		Name:             org.Name,
+		AllowedProviders: append([]string{}, org.AllowedProviders...),
+		RequireMFA:       org.RequireMFA,
+		Domains:          org.DomainNames(),
+	}
+}
+
+func toMember(m models.Membership) Member {
+	return Member{
+		UserID:      m.UserID,
+		Email:       m.User.Email,
+		Name:        m.User.Name,
+		Role:        m.Role.Name,
+		Permissions: m.Role.PermissionNames(),
+		JoinedAt:    m.CreatedAt,
+	}
+}
+
+func toSession(session models.Session) Session {
+	res := Session{ID: session.ID, UserID: session.UserID, Provider: session.Provider, ExpiresAt: session.ExpiresAt}
+
+	if session.Organization != nil {
+		org := toOrganization(*session.Organization)
+		res.Organization = &org
+	}
+
+	return res
+}
+
+func orgError(err error) error {
+	switch {
+	case errors.Is(err, rbac.ErrPermissionDenied), errors.Is(err, ports.ErrLoginPolicy), errors.Is(err, organization.ErrInvitationEmail):
+		return fiber.NewError(fiber.StatusForbidden, err.Error())
+	case errors.Is(err, gorm.ErrRecordNotFound), errors.Is(err, organization.ErrNotMember):
+		return fiber.ErrNotFound
+	case errors.Is(err, organization.ErrInvalidSlug), errors.Is(err, organization.ErrInvalidDomain),
+		errors.Is(err, organization.ErrMemberRole), errors.Is(err, verification.ErrInvalidToken):
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	case errors.Is(err, organization.ErrOrganizationExists), errors.Is(err, organization.ErrDomainCaptured),
+		errors.Is(err, organization.ErrAlreadyMember):
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	default:
+		return err
+	}
+}
@@ -0,0 +1,273 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/open-cloud-initiative/glue/auth/internal/lockout"
+	"github.com/open-cloud-initiative/glue/auth/internal/models"
+	"github.com/open-cloud-initiative/glue/auth/internal/password"
+	"github.com/open-cloud-initiative/glue/auth/internal/ports"
+	"github.com/open-cloud-initiative/glue/auth/internal/ratelimit"
+	"github.com/open-cloud-initiative/glue/auth/internal/verification"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// PasswordLoginRequest is the body of a login or signup with email address and password.
+type PasswordLoginRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+	Name     string `json:"name,omitempty"`
+	// Metadata is stored as user metadata at signup, e.g. an invitation code checked by a hook.
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// ChangePasswordRequest is the body of a request to change the password.
+type ChangePasswordRequest struct {
+	Current  string `json:"current,omitempty"`
+	Password string `json:"password"`
+}
+
+// ForgotPasswordRequest is the body of a request to send a password reset link.
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+// ResetPasswordRequest is the body of a request to reset the password.
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// PasswordLogin logs in with email address and password and creates a session.
+func (ac *AuthController) PasswordLogin(ctx fiber.Ctx) error {
+	if err := limitIP(ctx, ac.ipLimit); err != nil {
+		return err
+	}
+
+	req := PasswordLoginRequest{}
+	if err := ctx.Bind().JSON(&req); err != nil {
+		return fiber.ErrBadRequest
+	}
+
+	var user models.User
+
+	err := guarded(ctx, ac.guard, strings.ToLower(req.Email), wrongSecret, func() error {
+		var err error
+		user, err = ac.passwords.Login(ctx, req.Email, req.Password)
+
+		return err
+	})
+	if err != nil {
+		return passwordError(err)
+	}
+
+	session, err := ac.adapter.CreateSession(ctx, user.ID, models.LoginPassword, time.Now().Add(ac.sessionTTL))
+	if forbidden(err) {
+		return authError(err)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	ac.setCookie(ctx, SessionCookie, session.SessionToken, ac.sessionTTL)
+
+	if session.MFARequired {
+		return ctx.Status(fiber.StatusAccepted).JSON(MFAChallenge{MFARequired: true})
+	}
+
+	return ctx.JSON(user)
+}
+
+// PasswordSignup creates a user with email address and password and sends a
+// verification email. To not reveal which addresses are registered, it responds
+// the same if the address is taken and notifies its owner instead. The user
+// logs in after signing up.
+func (ac *AuthController) PasswordSignup(ctx fiber.Ctx) error {
+	if err := limitIP(ctx, ac.ipLimit); err != nil {
+		return err
+	}
+
+	req := PasswordLoginRequest{}
+	if err := ctx.Bind().JSON(&req); err != nil {
+		return fiber.ErrBadRequest
+	}
+
+	if !validEmail(req.Email) {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid email address")
+	}
+
+	user, err := ac.passwords.Signup(ctx, req.Email, req.Name, req.Password, req.Metadata)
+	if errors.Is(err, password.ErrEmailInUse) {
+		if ac.verifier != nil {
+			if err := ac.verifier.SendSignupNotice(ctx, req.Email); err != nil {
+				ac.onError(fmt.Errorf("send signup notice: %w", err))
+			}
+		}
+
+		return ctx.SendStatus(fiber.StatusAccepted)
+	}
+
+	if err != nil {
+		return passwordError(err)
+	}
+
+	if ac.verifier != nil {
+		if err := ac.verifier.SendEmailVerification(ctx, user.ID); err != nil {
+			ac.onError(fmt.Errorf("send verification email: %w", err))
+		}
+	}
+
+	return ctx.SendStatus(fiber.StatusAccepted)
+}
+
+// PasswordController handles the password of the signed-in user and password resets.
+type PasswordController struct {
+	adapter      ports.Auth
+	passwords    *password.Service
+	verifier     *verification.Service
+	reauthWindow time.Duration
+	ipLimit      ratelimit.Limiter
+	guard        *lockout.Guard
+}
+
+// PasswordOpt is a function that configures the PasswordController.
+type PasswordOpt func(*PasswordController)
+
+// WithPasswordIPLimit limits the reset links sent and used per client IP.
+func WithPasswordIPLimit(limiter ratelimit.Limiter) PasswordOpt {
+	return func(pc *PasswordController) {
+		pc.ipLimit = limiter
+	}
+}
+
+// WithPasswordGuard records wrong passwords and reset tokens and locks out
+// users and IPs with too many of them.
+func WithPasswordGuard(guard *lockout.Guard) PasswordOpt {
+	return func(pc *PasswordController) {
+		pc.guard = guard
+	}
+}
+
+// NewPasswordController creates a new PasswordController.
+func NewPasswordController(adapter ports.Auth, passwords *password.Service, verifier *verification.Service, opts ...PasswordOpt) *PasswordController {
+	pc := &PasswordController{
+		adapter:      adapter,
+		passwords:    passwords,
+		verifier:     verifier,
+		reauthWindow: DefaultReauthWindow,
+		ipLimit:      ratelimit.NewSlidingWindow(DefaultIPLimit, time.Hour),
+	}
+
+	for _, opt := range opts {
+		opt(pc)
+	}
+
+	return pc
+}
+
+// Change changes the password of the signed-in user and revokes the other
+// sessions of the user. Without a current password, e.g. to add the first
+// password, it requires a recent login.
+func (pc *PasswordController) Change(ctx fiber.Ctx) error {
+	session, ok := SessionFromContext(ctx)
+	if !ok {
+		return fiber.ErrUnauthorized
+	}
+
+	req := ChangePasswordRequest{}
+	if err := ctx.Bind().JSON(&req); err != nil {
+		return fiber.ErrBadRequest
+	}
+
+	if req.Current == "" {
+		user, err := pc.adapter.GetUser(ctx, session.UserID)
+		if err != nil {
+			return err
+		}
+
+		if time.Since(user.ReauthenticatedAt) > pc.reauthWindow {
+			return fiber.NewError(fiber.StatusUnauthorized, ports.ErrReauthenticationRequired.Error())
+		}
+	}
+
+	err := guarded(ctx, pc.guard, session.UserID.String(), wrongSecret, func() error {
+		return pc.passwords.Change(ctx, session.UserID, session.SessionToken, req.Current, req.Password)
+	})
+	if err != nil {
+		return passwordError(err)
+	}
+
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+// Forgot sends a password reset link to the email address.
+func (pc *PasswordController) Forgot(ctx fiber.Ctx) error {
+	if err := limitIP(ctx, pc.ipLimit); err != nil {
+		return err
+	}
+
+	req := ForgotPasswordRequest{}
+	if err := ctx.Bind().JSON(&req); err != nil {
+		return fiber.ErrBadRequest
+	}
+
+	if err := pc.verifier.SendPasswordReset(ctx, req.Email); err != nil {
+		return passwordError(err)
+	}
+
+	return ctx.SendStatus(fiber.StatusAccepted)
+}
+
+// Reset sets a new password with the token of a reset link.
+func (pc *PasswordController) Reset(ctx fiber.Ctx) error {
+	if err := limitIP(ctx, pc.ipLimit); err != nil {
+		return err
+	}
+
+	req := ResetPasswordRequest{}
+	if err := ctx.Bind().JSON(&req); err != nil {
+		return fiber.ErrBadRequest
+	}
+
+	var user models.User
+
+	err := guarded(ctx, pc.guard, "", wrongSecret, func() error {
+		var err error
+		user, err = pc.verifier.ResetPassword(ctx, req.Token, req.Password)
+
+		return err
+	})
+	if err != nil {
+		return passwordError(err)
+	}
+
+	return ctx.JSON(user)
+}
+
+func validEmail(email string) bool {
+	addr, err := mail.ParseAddress(email)
+	return err == nil && addr.Address == email
+}
+
+func passwordError(err error) error {
+	var policy *password.PolicyError
+
+	switch {
+	case errors.As(err, &policy):
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	case errors.Is(err, password.ErrInvalidCredentials):
+		return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+	case forbidden(err):
+		return authError(err)
+	case errors.Is(err, verification.ErrPasswordsDisabled):
+		return fiber.NewError(fiber.StatusNotImplemented, err.Error())
+	default:
+		return emailError(err)
+	}
+}
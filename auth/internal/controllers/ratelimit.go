@@ -0,0 +1,48 @@
+package controllers
+
+import (
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/open-cloud-initiative/glue/auth/internal/ratelimit"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+const (
+	// HeaderRateLimitLimit is the number of requests allowed per window.
+	HeaderRateLimitLimit = "RateLimit-Limit"
+	// HeaderRateLimitRemaining is the number of requests left.
+	HeaderRateLimitRemaining = "RateLimit-Remaining"
+	// HeaderRateLimitReset is the number of seconds until the limit is fully restored.
+	HeaderRateLimitReset = "RateLimit-Reset"
+)
+
+// RateLimit is a middleware that limits the requests of a client IP to the
+// routes of a group. Responses carry the RateLimit headers, rejected requests
+// are answered with 429 and a Retry-After header.
+func RateLimit(group string, limiter ratelimit.Limiter) fiber.Handler {
+	return func(ctx fiber.Ctx) error {
+		res, err := limiter.Allow(ctx, group+":"+ClientIP(ctx))
+		if err != nil {
+			return err
+		}
+
+		ctx.Set(HeaderRateLimitLimit, strconv.Itoa(res.Limit))
+		ctx.Set(HeaderRateLimitRemaining, strconv.Itoa(res.Remaining))
+		ctx.Set(HeaderRateLimitReset, seconds(res.Reset))
+
+		if !res.Allowed {
+			ctx.Set(fiber.HeaderRetryAfter, seconds(res.RetryAfter))
+			return fiber.NewError(fiber.StatusTooManyRequests, "rate limit exceeded")
+		}
+
+		return ctx.Next()
+	}
+}
+
+// seconds formats a duration as whole seconds, rounded up.
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,229 @@
+package controllers
+
+import (
+	"errors"
+
+	"github.com/open-cloud-initiative/glue/auth/internal/models"
+	"github.com/open-cloud-initiative/glue/auth/internal/rbac"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// RequirePermission is a middleware that requires a role of the signed-in
+// user that grants the permission. It must run after Authenticated.
+func RequirePermission(authz *rbac.Service, permission string) fiber.Handler {
+	return func(ctx fiber.Ctx) error {
+		session, ok := SessionFromContext(ctx)
+		if !ok {
+			return fiber.ErrUnauthorized
+		}
+
+		if err := authz.Check(ctx, session.UserID, permission); err != nil {
+			return roleError(err)
+		}
+
+		return ctx.Next()
+	}
+}
+
+// RoleController manages roles and the roles of users.
+type RoleController struct {
+	authz *rbac.Service
+}
+
+// NewRoleController creates a new RoleController.
+func NewRoleController(authz *rbac.Service) *RoleController {
+	return &RoleController{authz: authz}
+}
+
+// Role is a role with the names of its permissions.
+type Role struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	BuiltIn     bool     `json:"builtIn,omitempty"`
+	Permissions []string `json:"permissions"`
+}
+
+// RoleRequest is the body of a request to create or update a role.
+type RoleRequest struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Permissions []string `json:"permissions"`
+}
+
+// UserRoles are the roles of a user and the permissions they grant.
+type UserRoles struct {
+	Roles       []Role   `json:"roles"`
+	Permissions []string `json:"permissions"`
+}
+
+// ListPermissions lists all permissions.
+func (rc *RoleController) ListPermissions(ctx fiber.Ctx) error {
+	permissions, err := rc.authz.Catalog(ctx)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(permissions)
+}
+
+// ListRoles lists all roles.
+func (rc *RoleController) ListRoles(ctx fiber.Ctx) error {
+	roles, err := rc.authz.Roles(ctx)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(toRoles(roles))
+}
+
+// CreateRole creates a role.
+func (rc *RoleController) CreateRole(ctx fiber.Ctx) error {
+	session, ok := SessionFromContext(ctx)
+	if !ok {
+		return fiber.ErrUnauthorized
+	}
+
+	req := RoleRequest{}
+	if err := ctx.Bind().JSON(&req); err != nil {
+		return fiber.ErrBadRequest
+	}
+
+	role, err := rc.authz.CreateRole(ctx, session.UserID, req.Name, req.Description, req.Permissions)
+	if err != nil {
+		return roleError(err)
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(toRole(role))
+}
+
+// UpdateRole replaces the description and permissions of a role.
+func (rc *RoleController) UpdateRole(ctx fiber.Ctx) error {
+	session, ok := SessionFromContext(ctx)
+	if !ok {
+		return fiber.ErrUnauthorized
+	}
+
+	req := RoleRequest{}
+	if err := ctx.Bind().JSON(&req); err != nil {
+		return fiber.ErrBadRequest
+	}
+
+	role, err := rc.authz.UpdateRole(ctx, session.UserID, ctx.Params("name"), req.Description, req.Permissions)
+	if err != nil {
+		return roleError(err)
+	}
+
+	return ctx.JSON(toRole(role))
+}
+
+// DeleteRole deletes a role and removes it from all users.
+func (rc *RoleController) DeleteRole(ctx fiber.Ctx) error {
+	session, ok := SessionFromContext(ctx)
+	if !ok {
+		return fiber.ErrUnauthorized
+	}
+
+	if err := rc.authz.DeleteRole(ctx, session.UserID, ctx.Params("name")); err != nil {
+		return roleError(err)
+	}
+
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+// ListUserRoles lists the roles of a user.
+func (rc *RoleController) ListUserRoles(ctx fiber.Ctx) error {
+	userID, err := uuid.Parse(ctx.Params("id"))
+	if err != nil {
+		return fiber.ErrBadRequest
+	}
+
+	return rc.userRoles(ctx, userID)
+}
+
+// AssignRole assigns a role to a user.
+func (rc *RoleController) AssignRole(ctx fiber.Ctx) error {
+	session, ok := SessionFromContext(ctx)
+	if !ok {
+		return fiber.ErrUnauthorized
+	}
+
+	userID, err := uuid.Parse(ctx.Params("id"))
+	if err != nil {
+		return fiber.ErrBadRequest
+	}
+
+	if err := rc.authz.Assign(ctx, session.UserID, userID, ctx.Params("role")); err != nil {
+		return roleError(err)
+	}
+
+	return rc.userRoles(ctx, userID)
+}
+
+// UnassignRole removes a role from a user.
+func (rc *RoleController) UnassignRole(ctx fiber.Ctx) error {
+	session, ok := SessionFromContext(ctx)
+	if !ok {
+		return fiber.ErrUnauthorized
+	}
+
+	userID, err := uuid.Parse(ctx.Params("id"))
+	if err != nil {
+		return fiber.ErrBadRequest
+	}
+
+	if err := rc.authz.Unassign(ctx, session.UserID, userID, ctx.Params("role")); err != nil {
+		return roleError(err)
+	}
+
+	return rc.userRoles(ctx, userID)
+}
+
+// MyRoles lists the roles of the signed-in user.
+func (rc *RoleController) MyRoles(ctx fiber.Ctx) error {
+	session, ok := SessionFromContext(ctx)
+	if !ok {
+		return fiber.ErrUnauthorized
+	}
+
+	return rc.userRoles(ctx, session.UserID)
+}
+
+func (rc *RoleController) userRoles(ctx fiber.Ctx, userID uuid.UUID) error {
+	roles, err := rc.authz.UserRoles(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(UserRoles{Roles: toRoles(roles), Permissions: rbac.Union(roles)})
+}
+
+func toRoles(roles []models.Role) []Role {
+	res := make([]Role, 0, len(roles))
+	for _, r := range roles {
+		res = append(res, toRole(r))
+	}
+
+	return res
+}
+
+func toRole(r models.Role) Role {
+	return Role{Name: r.Name, Description: r.Description, BuiltIn: r.BuiltIn, Permissions: r.PermissionNames()}
+}
+
+func roleError(err error) error {
+	switch {
+	case errors.Is(err, rbac.ErrPermissionDenied):
+		return fiber.NewError(fiber.StatusForbidden, err.Error())
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return fiber.ErrNotFound
+	case errors.Is(err, rbac.ErrInvalidRoleName), errors.Is(err, rbac.ErrUnknownPermission), errors.Is(err, rbac.ErrAllPermissions):
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	case errors.Is(err, rbac.ErrRoleExists), errors.Is(err, rbac.ErrBuiltInRole):
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	default:
+		return err
+	}
+}
@@ -0,0 +1,78 @@
+package controllers
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/open-cloud-initiative/glue/auth/internal/audit"
+	"github.com/open-cloud-initiative/glue/auth/internal/models"
+	"github.com/open-cloud-initiative/glue/auth/internal/ports"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/katallaxie/pkg/utilx"
+)
+
+type sessionKey struct{}
+
+// Authenticated is a middleware that requires a valid session,
+// either from the session cookie or a bearer token. Sessions that
+// still require a second factor are rejected.
+func Authenticated(adapter ports.Auth) fiber.Handler {
+	return authenticate(adapter, false)
+}
+
+// PendingMFA is like Authenticated, but also accepts sessions that still
+// require a second factor, e.g. for the endpoints that complete it.
+func PendingMFA(adapter ports.Auth) fiber.Handler {
+	return authenticate(adapter, true)
+}
+
+func authenticate(adapter ports.Auth, pending bool) fiber.Handler {
+	return func(ctx fiber.Ctx) error {
+		token := sessionToken(ctx)
+		if utilx.Empty(token) {
+			return fiber.ErrUnauthorized
+		}
+
+		session, err := adapter.GetSession(ctx, token)
+		if errors.Is(err, ports.ErrUserBanned) {
+			return fiber.NewError(fiber.StatusForbidden, err.Error())
+		}
+
+		if err != nil {
+			return fiber.ErrUnauthorized
+		}
+
+		if session.MFARequired && !pending {
+			return fiber.NewError(fiber.StatusUnauthorized, ports.ErrMFARequired.Error())
+		}
+
+		ctx.Locals(sessionKey{}, session)
+
+		if md := audit.FromContext(ctx); md != nil {
+			md.ActorType = audit.ActorUser
+			md.ActorID = &session.UserID
+		}
+
+		return ctx.Next()
+	}
+}
+
+// SessionFromContext returns the session set by the Authenticated middleware.
+func SessionFromContext(ctx fiber.Ctx) (models.Session, bool) {
+	session, ok := ctx.Locals(sessionKey{}).(models.Session)
+	return session, ok
+}
+
+func sessionToken(ctx fiber.Ctx) string {
+	if token := ctx.Cookies(SessionCookie); utilx.NotEmpty(token) {
+		return token
+	}
+
+	scheme, token, ok := strings.Cut(ctx.Get(fiber.HeaderAuthorization), " ")
+	if ok && strings.EqualFold(scheme, "bearer") {
+		return strings.TrimSpace(token)
+	}
+
+	return ""
+}
@@ -0,0 +1,344 @@
+package controllers
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/open-cloud-initiative/glue/auth/internal/lockout"
+	"github.com/open-cloud-initiative/glue/auth/internal/models"
+	"github.com/open-cloud-initiative/glue/auth/internal/ports"
+	"github.com/open-cloud-initiative/glue/auth/internal/ratelimit"
+	"github.com/open-cloud-initiative/glue/auth/internal/verification"
+
+	"github.com/gofiber/fiber/v3"
+	"gorm.io/gorm"
+)
+
+// MFAChallenge is returned when a login requires a second factor.
+type MFAChallenge struct {
+	MFARequired bool `json:"mfaRequired"`
+}
+
+// PhoneRequest is the body of a request that sends a code to a phone number.
+type PhoneRequest struct {
+	Phone string `json:"phone"`
+}
+
+// CodeRequest is the body of a request that checks a code.
+type CodeRequest struct {
+	Phone string `json:"phone,omitempty"`
+	Code  string `json:"code"`
+}
+
+// SMSLogin sends a login code to a verified phone number.
+func (ac *AuthController) SMSLogin(ctx fiber.Ctx) error {
+	if err := limitIP(ctx, ac.ipLimit); err != nil {
+		return err
+	}
+
+	req := PhoneRequest{}
+	if err := ctx.Bind().JSON(&req); err != nil {
+		return fiber.ErrBadRequest
+	}
+
+	if err := ac.verifier.SendLoginCode(ctx, req.Phone); err != nil {
+		return smsError(err)
+	}
+
+	return ctx.SendStatus(fiber.StatusAccepted)
+}
+
+// SMSLoginVerify logs in with a code sent by SMSLogin and creates a session.
+func (ac *AuthController) SMSLoginVerify(ctx fiber.Ctx) error {
+	if err := limitIP(ctx, ac.ipLimit); err != nil {
+		return err
+	}
+
+	req := CodeRequest{}
+	if err := ctx.Bind().JSON(&req); err != nil {
+		return fiber.ErrBadRequest
+	}
+
+	var user models.User
+
+	err := guarded(ctx, ac.guard, req.Phone, wrongSecret, func() error {
+		var err error
+		user, err = ac.verifier.VerifyLoginCode(ctx, req.Phone, req.Code)
+
+		return err
+	})
+	if errors.Is(err, ports.ErrUserBanned) {
+		return authError(err)
+	}
+
+	if err != nil {
+		return smsError(err)
+	}
+
+	session, err := ac.adapter.CreateSession(ctx, user.ID, models.LoginSMS, time.Now().Add(ac.sessionTTL))
+	if forbidden(err) {
+		return authError(err)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	ac.setCookie(ctx, SessionCookie, session.SessionToken, ac.sessionTTL)
+
+	if session.MFARequired {
+		return ctx.Status(fiber.StatusAccepted).JSON(MFAChallenge{MFARequired: true})
+	}
+
+	return ctx.JSON(user)
+}
+
+// MFAChallenge sends a code to the SMS factor of the user of a session that requires a second factor.
+func (ac *AuthController) MFAChallenge(ctx fiber.Ctx) error {
+	session, ok := SessionFromContext(ctx)
+	if !ok {
+		return fiber.ErrUnauthorized
+	}
+
+	if err := limitIP(ctx, ac.ipLimit); err != nil {
+		return err
+	}
+
+	if err := ac.verifier.SendMFACode(ctx, session.UserID); err != nil {
+		return smsError(err)
+	}
+
+	return ctx.SendStatus(fiber.StatusAccepted)
+}
+
+// MFAVerify completes the second factor of a session with a code sent by MFAChallenge.
+func (ac *AuthController) MFAVerify(ctx fiber.Ctx) error {
+	session, ok := SessionFromContext(ctx)
+	if !ok {
+		return fiber.ErrUnauthorized
+	}
+
+	if err := limitIP(ctx, ac.ipLimit); err != nil {
+		return err
+	}
+
+	req := CodeRequest{}
+	if err := ctx.Bind().JSON(&req); err != nil {
+		return fiber.ErrBadRequest
+	}
+
+	err := guarded(ctx, ac.guard, session.UserID.String(), wrongSecret, func() error {
+		return ac.verifier.VerifyMFACode(ctx, session.UserID, req.Code)
+	})
+	if err != nil {
+		return smsError(err)
+	}
+
+	session.MFARequired = false
+	session.MFAVerifiedAt = time.Now()
+	if _, err := ac.adapter.UpdateSession(ctx, session); err != nil {
+		return err
+	}
+
+	return ctx.JSON(session.User)
+}
+
+// PhoneController handles the phone number and the SMS factor of the signed-in user.
+type PhoneController struct {
+	adapter      ports.Auth
+	verifier     *verification.Service
+	reauthWindow time.Duration
+	ipLimit      ratelimit.Limiter
+	guard        *lockout.Guard
+}
+
+// PhoneOpt is a function that configures the PhoneController.
+type PhoneOpt func(*PhoneController)
+
+// WithPhoneIPLimit limits the codes sent and checked per client IP.
+func WithPhoneIPLimit(limiter ratelimit.Limiter) PhoneOpt {
+	return func(pc *PhoneController) {
+		pc.ipLimit = limiter
+	}
+}
+
+// WithPhoneGuard records wrong codes and locks out users with too many of them.
+func WithPhoneGuard(guard *lockout.Guard) PhoneOpt {
+	return func(pc *PhoneController) {
+		pc.guard = guard
+	}
+}
+
+// NewPhoneController creates a new PhoneController.
+func NewPhoneController(adapter ports.Auth, verifier *verification.Service, opts ...PhoneOpt) *PhoneController {
+	pc := &PhoneController{
+		adapter:      adapter,
+		verifier:     verifier,
+		reauthWindow: DefaultReauthWindow,
+		ipLimit:      ratelimit.NewSlidingWindow(DefaultIPLimit, time.Hour),
+	}
+
+	for _, opt := range opts {
+		opt(pc)
+	}
+
+	return pc
+}
+
+// SendVerification sends a code that verifies a phone number for the signed-in user.
+// It requires a recent login, unless the user is anonymous.
+func (pc *PhoneController) SendVerification(ctx fiber.Ctx) error {
+	if err := pc.mayChangePhone(ctx); err != nil {
+		return err
+	}
+
+	session, _ := SessionFromContext(ctx)
+
+	if err := limitIP(ctx, pc.ipLimit); err != nil {
+		return err
+	}
+
+	req := PhoneRequest{}
+	if err := ctx.Bind().JSON(&req); err != nil {
+		return fiber.ErrBadRequest
+	}
+
+	if err := pc.verifier.SendPhoneVerification(ctx, session.UserID, req.Phone); err != nil {
+		return smsError(err)
+	}
+
+	return ctx.SendStatus(fiber.StatusAccepted)
+}
+
+// Verify sets the phone number of the signed-in user with a code sent by SendVerification.
+// It requires a recent login, unless the user is anonymous.
+func (pc *PhoneController) Verify(ctx fiber.Ctx) error {
+	if err := pc.mayChangePhone(ctx); err != nil {
+		return err
+	}
+
+	session, _ := SessionFromContext(ctx)
+
+	if err := limitIP(ctx, pc.ipLimit); err != nil {
+		return err
+	}
+
+	req := CodeRequest{}
+	if err := ctx.Bind().JSON(&req); err != nil {
+		return fiber.ErrBadRequest
+	}
+
+	var user models.User
+
+	err := guarded(ctx, pc.guard, session.UserID.String(), wrongSecret, func() error {
+		var err error
+		user, err = pc.verifier.ConfirmPhone(ctx, session.UserID, req.Phone, req.Code)
+
+		return err
+	})
+	if err != nil {
+		return smsError(err)
+	}
+
+	return ctx.JSON(user)
+}
+
+// EnrollSMS adds the verified phone number of the signed-in user as second factor.
+// It requires a recent login.
+func (pc *PhoneController) EnrollSMS(ctx fiber.Ctx) error {
+	if err := pc.reauthenticated(ctx); err != nil {
+		return err
+	}
+
+	session, _ := SessionFromContext(ctx)
+
+	factor, err := pc.verifier.EnrollSMSFactor(ctx, session.UserID)
+	if err != nil {
+		return smsError(err)
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(factor)
+}
+
+// RemoveFactor removes a second factor of the signed-in user. It requires a recent login.
+func (pc *PhoneController) RemoveFactor(ctx fiber.Ctx) error {
+	if err := pc.reauthenticated(ctx); err != nil {
+		return err
+	}
+
+	session, _ := SessionFromContext(ctx)
+
+	if err := pc.verifier.RemoveMFAFactor(ctx, session.UserID, ctx.Params("id")); err != nil {
+		return smsError(err)
+	}
+
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+func (pc *PhoneController) reauthenticated(ctx fiber.Ctx) error {
+	return pc.recentLogin(ctx, false)
+}
+
+// mayChangePhone requires a recent login to change the phone number.
+// Anonymous users have no number that could be taken over.
+func (pc *PhoneController) mayChangePhone(ctx fiber.Ctx) error {
+	return pc.recentLogin(ctx, true)
+}
+
+func (pc *PhoneController) recentLogin(ctx fiber.Ctx, anonymous bool) error {
+	session, ok := SessionFromContext(ctx)
+	if !ok {
+		return fiber.ErrUnauthorized
+	}
+
+	user, err := pc.adapter.GetUser(ctx, session.UserID)
+	if err != nil {
+		return err
+	}
+
+	if anonymous && user.IsAnonymous {
+		return nil
+	}
+
+	if time.Since(user.ReauthenticatedAt) > pc.reauthWindow {
+		return fiber.NewError(fiber.StatusUnauthorized, ports.ErrReauthenticationRequired.Error())
+	}
+
+	return nil
+}
+
+// limitIP counts a request of the client IP and rejects it if the limit is exceeded.
+func limitIP(ctx fiber.Ctx, limiter ratelimit.Limiter) error {
+	res, err := limiter.Allow(ctx, "ip:"+ClientIP(ctx))
+	if err != nil {
+		return err
+	}
+
+	if !res.Allowed {
+		ctx.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(res.RetryAfter.Seconds())))
+		return fiber.NewError(fiber.StatusTooManyRequests, verification.ErrThrottled.Error())
+	}
+
+	return nil
+}
+
+func smsError(err error) error {
+	switch {
+	case errors.Is(err, verification.ErrThrottled):
+		return fiber.NewError(fiber.StatusTooManyRequests, err.Error())
+	case errors.Is(err, verification.ErrInvalidToken):
+		return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+	case errors.Is(err, verification.ErrInvalidPhone), errors.Is(err, verification.ErrPhoneNotVerified),
+		errors.Is(err, verification.ErrNoFactor):
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	case errors.Is(err, verification.ErrAlreadyVerified), errors.Is(err, verification.ErrPhoneInUse):
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return fiber.ErrNotFound
+	case errors.Is(err, verification.ErrSMSDisabled):
+		return fiber.NewError(fiber.StatusNotImplemented, err.Error())
+	default:
+		return emailError(err)
+	}
+}
@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/open-cloud-initiative/glue/auth/internal/ports"
+	"github.com/open-cloud-initiative/glue/auth/internal/secrets"
+	"github.com/open-cloud-initiative/glue/auth/internal/vault"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// TokenController hands out provider access tokens to internal apps.
+type TokenController struct {
+	vault *vault.Vault
+}
+
+// NewTokenController creates a new TokenController.
+func NewTokenController(v *vault.Vault) *TokenController {
+	return &TokenController{vault: v}
+}
+
+// AccessToken is a valid access token of a provider account.
+type AccessToken struct {
+	AccessToken string     `json:"accessToken"`
+	TokenType   string     `json:"tokenType"`
+	ExpiresAt   *time.Time `json:"expiresAt,omitempty"`
+}
+
+// GetToken returns a valid access token of a user for a provider.
+// The tenant query parameter selects a provider of a tenant.
+func (tc *TokenController) GetToken(ctx fiber.Ctx) error {
+	userID, err := uuid.Parse(ctx.Params("id"))
+	if err != nil {
+		return fiber.ErrBadRequest
+	}
+
+	token, err := tc.vault.Token(ctx, userID, ctx.Query("tenant"), ctx.Params("provider"))
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound), errors.Is(err, vault.ErrNoToken):
+		return fiber.ErrNotFound
+	case errors.Is(err, ports.ErrUserBanned):
+		return fiber.NewError(fiber.StatusForbidden, err.Error())
+	case errors.Is(err, vault.ErrReauthorizationRequired):
+		return fiber.NewError(fiber.StatusConflict, vault.ErrReauthorizationRequired.Error())
+	case err != nil:
+		return err
+	}
+
+	at := AccessToken{AccessToken: token.AccessToken, TokenType: token.Type()}
+	if !token.Expiry.IsZero() {
+		at.ExpiresAt = &token.Expiry
+	}
+
+	ctx.Set(fiber.HeaderCacheControl, "no-store")
+
+	return ctx.JSON(at)
+}
+
+// Internal is a middleware that requires the shared internal token as bearer token.
+func Internal(token secrets.Secret) fiber.Handler {
+	return func(ctx fiber.Ctx) error {
+		scheme, bearer, ok := strings.Cut(ctx.Get(fiber.HeaderAuthorization), " ")
+		if !ok || !strings.EqualFold(scheme, "bearer") {
+			return fiber.ErrUnauthorized
+		}
+
+		if subtle.ConstantTimeCompare([]byte(strings.TrimSpace(bearer)), []byte(token.Value())) != 1 {
+			return fiber.ErrUnauthorized
+		}
+
+		return ctx.Next()
+	}
+}
@@ -0,0 +1,221 @@
+package envelope
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	// Prefix is the prefix of values encrypted with a Keyring.
+	Prefix = "enc:v2:"
+	// LegacyPrefix is the prefix of values encrypted before values were bound to
+	// additional data. They are still decrypted, `migrate reencrypt` rewrites them.
+	LegacyPrefix = "enc:v1:"
+)
+
+// KeySize is the size of a key encryption key and a data key.
+const KeySize = 32
+
+var (
+	// ErrNoKeys is returned when a keyring has no keys.
+	ErrNoKeys = errors.New("envelope: keyring has no keys")
+	// ErrUnknownKey is returned when a value was encrypted with a key that is not in the keyring.
+	ErrUnknownKey = errors.New("envelope: unknown key")
+	// ErrMalformed is returned when an encrypted value can not be parsed.
+	ErrMalformed = errors.New("envelope: malformed value")
+	// ErrInvalidKeyID is returned when a key ID is empty or contains a colon.
+	ErrInvalidKeyID = errors.New("envelope: key ID must be non-empty and must not contain ':'")
+	// ErrInvalidKeySize is returned when a key is not 32 bytes long.
+	ErrInvalidKeySize = fmt.Errorf("envelope: key must be %d bytes", KeySize)
+)
+
+// Key is a key encryption key.
+type Key struct {
+	// ID identifies the key in encrypted values.
+	ID string
+	// Secret is the 32 byte AES-256 key.
+	Secret []byte
+}
+
+// Keyring encrypts values with a random data key per value, which is
+// wrapped with the primary key encryption key. All keys of the keyring
+// can decrypt, so keys can be rotated by adding a new primary key. The
+// ciphertext is bound to additional data, e.g. the row and column of the
+// value, so that it can not be moved to another row or column.
+//
+// Encrypted values have the format
+//
+//	enc:v2:<key id>:<wrapped data key>:<ciphertext>
+type Keyring struct {
+	primary string
+	keys    map[string]cipher.AEAD
+}
+
+// NewKeyring returns a new keyring. The first key is the primary key used for encryption.
+func NewKeyring(keys ...Key) (*Keyring, error) {
+	if len(keys) == 0 {
+		return nil, ErrNoKeys
+	}
+
+	k := &Keyring{primary: keys[0].ID, keys: map[string]cipher.AEAD{}}
+
+	for _, key := range keys {
+		if key.ID == "" || strings.Contains(key.ID, ":") {
+			return nil, ErrInvalidKeyID
+		}
+
+		aead, err := newAEAD(key.Secret)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", key.ID, err)
+		}
+
+		k.keys[key.ID] = aead
+	}
+
+	return k, nil
+}
+
+// Primary returns the ID of the primary key.
+func (k *Keyring) Primary() string {
+	return k.primary
+}
+
+// Encrypt encrypts the plaintext with a new data key wrapped by the primary key.
+// The value can only be decrypted with the same additional data.
+func (k *Keyring) Encrypt(plaintext, additionalData []byte) (string, error) {
+	dek := make([]byte, KeySize)
+	if _, err := rand.Read(dek); err != nil {
+		return "", err
+	}
+
+	data, err := newAEAD(dek)
+	if err != nil {
+		return "", err
+	}
+
+	wrapped, err := seal(k.keys[k.primary], dek, []byte(k.primary))
+	if err != nil {
+		return "", err
+	}
+
+	ciphertext, err := seal(data, plaintext, bind(wrapped, additionalData))
+	if err != nil {
+		return "", err
+	}
+
+	return Prefix + k.primary + ":" +
+		base64.RawURLEncoding.EncodeToString(wrapped) + ":" +
+		base64.RawURLEncoding.EncodeToString(ciphertext), nil
+}
+
+// Decrypt decrypts a value encrypted by Encrypt with any key of the keyring and
+// the same additional data. Legacy values are not bound to additional data.
+func (k *Keyring) Decrypt(value string, additionalData []byte) ([]byte, error) {
+	id, wrapped, ciphertext, err := parse(value)
+	if err != nil {
+		return nil, err
+	}
+
+	kek, ok := k.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w %s", ErrUnknownKey, id)
+	}
+
+	dek, err := open(kek, wrapped, []byte(id))
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := newAEAD(dek)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.HasPrefix(value, LegacyPrefix) {
+		return open(data, ciphertext, wrapped)
+	}
+
+	return open(data, ciphertext, bind(wrapped, additionalData))
+}
+
+// IsEncrypted returns true if the value has been encrypted by a keyring.
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, Prefix) || strings.HasPrefix(value, LegacyPrefix)
+}
+
+// KeyID returns the ID of the key the value has been encrypted with.
+func KeyID(value string) (string, error) {
+	id, _, _, err := parse(value)
+	return id, err
+}
+
+func parse(value string) (string, []byte, []byte, error) {
+	rest, ok := strings.CutPrefix(value, Prefix)
+	if !ok {
+		rest, ok = strings.CutPrefix(value, LegacyPrefix)
+	}
+
+	if !ok {
+		return "", nil, nil, ErrMalformed
+	}
+
+	parts := strings.Split(rest, ":")
+	if len(parts) != 3 { //nolint:mnd
+		return "", nil, nil, ErrMalformed
+	}
+
+	wrapped, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", nil, nil, ErrMalformed
+	}
+
+	ciphertext, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", nil, nil, ErrMalformed
+	}
+
+	return parts[0], wrapped, ciphertext, nil
+}
+
+// bind returns the additional data of a ciphertext. The wrapped data key
+// has a fixed length, so the concatenation is unambiguous.
+func bind(wrapped, additionalData []byte) []byte {
+	return append(append(make([]byte, 0, len(wrapped)+len(additionalData)), wrapped...), additionalData...)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, ErrInvalidKeySize
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func seal(aead cipher.AEAD, plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func open(aead cipher.AEAD, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, ErrMalformed
+	}
+
+	nonce, ciphertext := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+
+	return aead.Open(nil, nonce, ciphertext, additionalData)
+}
@@ -0,0 +1,166 @@
+package envelope
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func key(id string, b byte) Key {
+	return Key{ID: id, Secret: bytes.Repeat([]byte{b}, KeySize)}
+}
+
+// legacy encrypts the plaintext like values with the legacy prefix,
+// which are not bound to additional data.
+func legacy(t *testing.T, k Key, plaintext []byte) string {
+	t.Helper()
+
+	kek, err := newAEAD(k.Secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dek := bytes.Repeat([]byte{7}, KeySize)
+
+	data, err := newAEAD(dek)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wrapped, err := seal(kek, dek, []byte(k.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ciphertext, err := seal(data, plaintext, wrapped)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return LegacyPrefix + k.ID + ":" +
+		base64.RawURLEncoding.EncodeToString(wrapped) + ":" +
+		base64.RawURLEncoding.EncodeToString(ciphertext)
+}
+
+func TestNewKeyring(t *testing.T) {
+	tests := []struct {
+		name string
+		keys []Key
+		err  error
+	}{
+		{name: "keys", keys: []Key{key("a", 1), key("b", 2)}},
+		{name: "no keys", keys: nil, err: ErrNoKeys},
+		{name: "empty ID", keys: []Key{key("", 1)}, err: ErrInvalidKeyID},
+		{name: "ID with a colon", keys: []Key{key("a:b", 1)}, err: ErrInvalidKeyID},
+		{name: "short key", keys: []Key{{ID: "a", Secret: []byte("short")}}, err: ErrInvalidKeySize},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k, err := NewKeyring(tt.keys...)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("NewKeyring() = %v, want %v", err, tt.err)
+			}
+
+			if err == nil && k.Primary() != tt.keys[0].ID {
+				t.Errorf("Primary() = %q, want %q", k.Primary(), tt.keys[0].ID)
+			}
+		})
+	}
+}
+
+func TestKeyring(t *testing.T) {
+	old, err := NewKeyring(key("old", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated, err := NewKeyring(key("new", 2), key("old", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plaintext := []byte("refresh-token")
+	ad := []byte("accounts.refresh_token:42")
+
+	value, err := old.Encrypt(plaintext, ad)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !IsEncrypted(value) || strings.Contains(value, string(plaintext)) {
+		t.Fatalf("Encrypt() = %q", value)
+	}
+
+	if id, err := KeyID(value); err != nil || id != "old" {
+		t.Errorf("KeyID() = %q, %v, want old", id, err)
+	}
+
+	tampered := []byte(value)
+	tampered[len(tampered)-2] ^= 1
+
+	tests := []struct {
+		name    string
+		keyring *Keyring
+		value   string
+		ad      []byte
+		want    []byte
+		err     error
+		fails   bool
+	}{
+		{name: "same key", keyring: old, value: value, ad: ad, want: plaintext},
+		{name: "rotated keyring", keyring: rotated, value: value, ad: ad, want: plaintext},
+		{name: "other additional data", keyring: old, value: value, ad: []byte("accounts.refresh_token:43"), fails: true},
+		{name: "tampered", keyring: old, value: string(tampered), ad: ad, fails: true},
+		{name: "unknown key", keyring: old, value: strings.Replace(value, ":old:", ":gone:", 1), ad: ad, err: ErrUnknownKey, fails: true},
+		{name: "plaintext", keyring: old, value: "refresh-token", ad: ad, err: ErrMalformed, fails: true},
+		{name: "missing part", keyring: old, value: Prefix + "old:abc", ad: ad, err: ErrMalformed, fails: true},
+		{name: "invalid base64", keyring: old, value: Prefix + "old:!!:!!", ad: ad, err: ErrMalformed, fails: true},
+		{name: "legacy", keyring: rotated, value: legacy(t, key("old", 1), plaintext), ad: ad, want: plaintext},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.keyring.Decrypt(tt.value, tt.ad)
+
+			if tt.fails {
+				if err == nil || (tt.err != nil && !errors.Is(err, tt.err)) {
+					t.Fatalf("Decrypt() = %q, %v, want %v", got, err, tt.err)
+				}
+
+				return
+			}
+
+			if err != nil || !bytes.Equal(got, tt.want) {
+				t.Errorf("Decrypt() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncryptWithPrimary(t *testing.T) {
+	k, err := NewKeyring(key("new", 2), key("old", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := k.Encrypt([]byte("secret"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := k.Encrypt([]byte("secret"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if id, _ := KeyID(a); id != "new" {
+		t.Errorf("KeyID() = %q, want the primary key new", id)
+	}
+
+	if a == b {
+		t.Error("Encrypt() returns the same value for the same plaintext")
+	}
+}
@@ -0,0 +1,142 @@
+package envelope
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+
+	"gorm.io/gorm/schema"
+)
+
+// SerializerName is the name of the gorm serializer, use it as
+// `gorm:"serializer:encrypted"` on string and *string fields.
+const SerializerName = "encrypted"
+
+var (
+	// ErrNoKeyring is returned when an encrypted value is read without a keyring.
+	ErrNoKeyring = errors.New("envelope: no keyring configured")
+	// ErrNoPrimaryKey is returned when an encrypted value is read or written without the primary key of its row.
+	ErrNoPrimaryKey = errors.New("envelope: the primary key of the row is required")
+)
+
+// DefaultSerializer is the serializer registered with gorm.
+var DefaultSerializer = &Serializer{}
+
+func init() {
+	schema.RegisterSerializer(SerializerName, DefaultSerializer)
+}
+
+var _ schema.SerializerInterface = (*Serializer)(nil)
+
+// Serializer is a gorm serializer that transparently encrypts and
+// decrypts string columns with a keyring. Plaintext values that have
+// been written before encryption was enabled are read as is.
+//
+// Values are bound to their table, column and primary key, which must
+// be set before the row is created and be selected with the value.
+type Serializer struct {
+	keyring atomic.Pointer[Keyring]
+}
+
+// SetKeyring sets the keyring used by the serializer.
+func (s *Serializer) SetKeyring(k *Keyring) {
+	s.keyring.Store(k)
+}
+
+// Keyring returns the keyring used by the serializer.
+func (s *Serializer) Keyring() *Keyring {
+	return s.keyring.Load()
+}
+
+// Scan implements the schema.SerializerInterface interface.
+func (s *Serializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue any) error {
+	fieldValue := reflect.New(field.FieldType)
+
+	if dbValue != nil {
+		var value string
+
+		switch v := dbValue.(type) {
+		case string:
+			value = v
+		case []byte:
+			value = string(v)
+		default:
+			return fmt.Errorf("envelope: unsupported database value %T", dbValue)
+		}
+
+		if IsEncrypted(value) {
+			k := s.Keyring()
+			if k == nil {
+				return ErrNoKeyring
+			}
+
+			aad, err := additionalData(ctx, field, dst)
+			if err != nil {
+				return err
+			}
+
+			plaintext, err := k.Decrypt(value, aad)
+			if err != nil {
+				return fmt.Errorf("%s.%s: %w", field.Schema.Table, field.DBName, err)
+			}
+
+			value = string(plaintext)
+		}
+
+		if field.FieldType.Kind() == reflect.Ptr {
+			fieldValue.Elem().Set(reflect.New(field.FieldType.Elem()))
+			fieldValue.Elem().Elem().SetString(value)
+		} else {
+			fieldValue.Elem().SetString(value)
+		}
+	}
+
+	return field.Set(ctx, dst, fieldValue.Elem().Interface())
+}
+
+// Value implements the schema.SerializerValuerInterface interface.
+func (s *Serializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue any) (any, error) {
+	var value string
+
+	switch v := fieldValue.(type) {
+	case string:
+		value = v
+	case *string:
+		if v == nil {
+			return nil, nil
+		}
+
+		value = *v
+	default:
+		return nil, fmt.Errorf("envelope: unsupported field value %T", fieldValue)
+	}
+
+	k := s.Keyring()
+	if k == nil {
+		return value, nil
+	}
+
+	aad, err := additionalData(ctx, field, dst)
+	if err != nil {
+		return nil, err
+	}
+
+	return k.Encrypt([]byte(value), aad)
+}
+
+// additionalData returns the table, column and primary key of the value in dst.
+func additionalData(ctx context.Context, field *schema.Field, dst reflect.Value) ([]byte, error) {
+	pk := field.Schema.PrioritizedPrimaryField
+	if pk == nil {
+		return nil, fmt.Errorf("%s.%s: %w", field.Schema.Table, field.DBName, ErrNoPrimaryKey)
+	}
+
+	id, zero := pk.ValueOf(ctx, dst)
+	if zero {
+		return nil, fmt.Errorf("%s.%s: %w", field.Schema.Table, field.DBName, ErrNoPrimaryKey)
+	}
+
+	return fmt.Appendf(nil, "%s.%s:%v", field.Schema.Table, field.DBName, id), nil
+}
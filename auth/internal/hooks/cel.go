@@ -0,0 +1,107 @@
+package hooks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/google/cel-go/cel"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+var _ Hook = (*CEL)(nil)
+
+// CEL is a hook that evaluates a CEL expression in-process. The expression
+// sees the variables event, user and request of the Input. It returns a
+// bool that allows or denies the event, or a map with the fields of a Result,
+// e.g. {"app_metadata": {"plan": "free"}}. Maps of different types in the
+// branches of a condition have to be wrapped in dyn().
+type CEL struct {
+	program cel.Program
+	message string
+}
+
+// NewCEL compiles the expression. The message is the reason of a denial by a false result.
+func NewCEL(expr, message string) (*CEL, error) {
+	env, err := cel.NewEnv(
+		cel.OptionalTypes(),
+		cel.Variable("event", cel.StringType),
+		cel.Variable("user", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("request", cel.MapType(cel.StringType, cel.DynType)),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	ast, iss := env.Compile(expr)
+	if iss.Err() != nil {
+		return nil, iss.Err()
+	}
+
+	switch ast.OutputType().Kind() {
+	case cel.BoolType.Kind(), cel.MapType(cel.StringType, cel.DynType).Kind(), cel.DynType.Kind():
+	default:
+		return nil, fmt.Errorf("expression must return a bool or a map, got %s", ast.OutputType())
+	}
+
+	program, err := env.Program(ast, cel.InterruptCheckFrequency(100))
+	if err != nil {
+		return nil, err
+	}
+
+	return &CEL{program: program, message: message}, nil
+}
+
+// Evaluate implements the Hook interface.
+func (c *CEL) Evaluate(ctx context.Context, input Input) (Result, error) {
+	vars := map[string]any{}
+	if err := convert(input, &vars); err != nil {
+		return Result{}, err
+	}
+
+	out, _, err := c.program.ContextEval(ctx, vars)
+	if err != nil {
+		return Result{}, err
+	}
+
+	if allow, ok := out.Value().(bool); ok {
+		if allow {
+			return Result{Decision: DecisionAllow}, nil
+		}
+
+		return Result{Decision: DecisionDeny, Reason: c.message}, nil
+	}
+
+	v, err := out.ConvertToNative(reflect.TypeFor[*structpb.Value]())
+	if err != nil {
+		return Result{}, fmt.Errorf("expression must return a bool or a map: %w", err)
+	}
+
+	b, err := protojson.Marshal(v.(*structpb.Value))
+	if err != nil {
+		return Result{}, err
+	}
+
+	res := Result{}
+	if err := json.Unmarshal(b, &res); err != nil {
+		return Result{}, fmt.Errorf("expression must return a bool or a map with the fields of a result: %w", err)
+	}
+
+	if res.Decision == DecisionDeny && res.Reason == "" {
+		res.Reason = c.message
+	}
+
+	return res, nil
+}
+
+// convert converts v to the JSON types of out.
+func convert(v, out any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(b, out)
+}
@@ -0,0 +1,282 @@
+// Package hooks runs custom logic before a user is created or a login
+// completes, e.g. to block email domains, enrich the metadata of users or
+// require invitation codes.
+//
+// Hooks are CEL expressions evaluated in-process, or HTTP endpoints. They
+// run before the transaction that creates the user or the session, in the
+// order they are configured. Each hook allows, denies or changes the metadata
+// of the user, later hooks see the changes of earlier ones. The changes are
+// applied within the transaction.
+package hooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/open-cloud-initiative/glue/auth/internal/audit"
+	"github.com/open-cloud-initiative/glue/auth/internal/models"
+	"github.com/open-cloud-initiative/glue/auth/internal/ports"
+
+	"github.com/google/uuid"
+)
+
+// Decisions of a hook.
+const (
+	DecisionAllow = "allow"
+	DecisionDeny  = "deny"
+)
+
+// Failure policies of a hook.
+const (
+	// FailClosed denies the event if the hook fails, e.g. times out.
+	FailClosed = "closed"
+	// FailOpen allows the event if the hook fails.
+	FailOpen = "open"
+)
+
+// Events are all events hooks can run at.
+var Events = []ports.HookEvent{ports.HookBeforeUserCreated, ports.HookBeforeSessionCreated}
+
+// User is the user as seen by hooks.
+type User struct {
+	// ID is empty before the user is created.
+	ID            string            `json:"id"`
+	Email         string            `json:"email"`
+	Name          string            `json:"name"`
+	PhoneNumber   string            `json:"phone_number"`
+	EmailVerified bool              `json:"email_verified"`
+	PhoneVerified bool              `json:"phone_verified"`
+	IsAnonymous   bool              `json:"is_anonymous"`
+	Providers     []string          `json:"providers"`
+	AppMetadata   map[string]string `json:"app_metadata"`
+	UserMetadata  map[string]string `json:"user_metadata"`
+}
+
+// Request describes the request that causes the event.
+type Request struct {
+	IP        string `json:"ip"`
+	UserAgent string `json:"user_agent"`
+}
+
+// Input is the input of a hook.
+type Input struct {
+	Event   ports.HookEvent `json:"event"`
+	User    User            `json:"user"`
+	Request Request         `json:"request"`
+}
+
+// Result is the outcome of a hook. A nil value in the metadata removes the key.
+type Result struct {
+	// Decision is allow (default) or deny.
+	Decision string `json:"decision,omitempty"`
+	// Reason is shown to the user if the event is denied.
+	Reason string `json:"reason,omitempty"`
+	// AppMetadata are the keys of the app metadata to set or remove.
+	AppMetadata map[string]*string `json:"app_metadata,omitempty"`
+	// UserMetadata are the keys of the user metadata to set or remove.
+	UserMetadata map[string]*string `json:"user_metadata,omitempty"`
+}
+
+// Hook evaluates an event.
+type Hook interface {
+	// Evaluate returns the outcome of the event.
+	Evaluate(ctx context.Context, input Input) (Result, error)
+}
+
+// Config configures a hook in the pipeline.
+type Config struct {
+	// ID identifies the hook in errors and logs.
+	ID string
+	// Events are the events the hook runs at.
+	Events []ports.HookEvent
+	// Failure is the policy if the hook fails: closed (default) or open.
+	Failure string
+	// Hook evaluates the events.
+	Hook Hook
+}
+
+var _ ports.Hooks = (*Pipeline)(nil)
+
+// Pipeline runs hooks in order.
+type Pipeline struct {
+	hooks   []Config
+	onError func(error)
+}
+
+// PipelineOpt is a function that configures the Pipeline.
+type PipelineOpt func(*Pipeline)
+
+// WithErrorHandler sets the function failing hooks are passed to.
+func WithErrorHandler(fn func(error)) PipelineOpt {
+	return func(p *Pipeline) {
+		p.onError = fn
+	}
+}
+
+// NewPipeline returns a new Pipeline.
+func NewPipeline(hooks []Config, opts ...PipelineOpt) *Pipeline {
+	p := &Pipeline{hooks: hooks, onError: func(error) {}}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	return p
+}
+
+// Run evaluates the hooks of the event for the user and returns their changes.
+// It returns a *ports.HookDeniedError for the first hook that denies the
+// event, or that fails and fails closed.
+func (p *Pipeline) Run(ctx context.Context, event ports.HookEvent, user models.User) (ports.HookPatch, error) {
+	user.AppMetadata = maps.Clone(user.AppMetadata)
+	user.UserMetadata = maps.Clone(user.UserMetadata)
+
+	changes := ports.HookPatch{}
+
+	for _, h := range p.hooks {
+		if !slices.Contains(h.Events, event) {
+			continue
+		}
+
+		res, err := h.Hook.Evaluate(ctx, newInput(ctx, event, user))
+		if err != nil {
+			p.onError(fmt.Errorf("%s: %w", h.ID, err))
+
+			if h.Failure == FailOpen {
+				continue
+			}
+
+			return ports.HookPatch{}, &ports.HookDeniedError{Hook: h.ID}
+		}
+
+		switch res.Decision {
+		case "", DecisionAllow:
+		case DecisionDeny:
+			return ports.HookPatch{}, &ports.HookDeniedError{Hook: h.ID, Reason: res.Reason}
+		default:
+			p.onError(fmt.Errorf("%s: unknown decision %q", h.ID, res.Decision))
+			return ports.HookPatch{}, &ports.HookDeniedError{Hook: h.ID}
+		}
+
+		patch := ports.HookPatch{AppMetadata: res.AppMetadata, UserMetadata: res.UserMetadata}
+		patch.Apply(&user)
+
+		changes.AppMetadata = merge(changes.AppMetadata, res.AppMetadata)
+		changes.UserMetadata = merge(changes.UserMetadata, res.UserMetadata)
+	}
+
+	return changes, nil
+}
+
+// RunFunc applies the changes of the hooks of the event to the user within a transaction.
+type RunFunc func(event ports.HookEvent, user *models.User) error
+
+// pendingError ends an attempt of a transaction that needs hooks that did not run yet.
+type pendingError struct {
+	event ports.HookEvent
+	user  models.User
+}
+
+// Error implements the error interface.
+func (e *pendingError) Error() string {
+	return fmt.Sprintf("hooks: %s pending", e.event)
+}
+
+// Defer runs tx, a function that runs a transaction, for transactions that
+// decide within whether hooks run, e.g. whether a login creates a user. The
+// first call of run for an event fails and rolls back the transaction, the
+// hooks of the event are evaluated outside of it, and tx runs again with run
+// applying their changes. run does nothing if hooks is nil.
+func Defer(ctx context.Context, hooks ports.Hooks, tx func(run RunFunc) error) error {
+	if hooks == nil {
+		return tx(func(ports.HookEvent, *models.User) error { return nil })
+	}
+
+	patches := map[ports.HookEvent]ports.HookPatch{}
+
+	run := func(event ports.HookEvent, user *models.User) error {
+		patch, ok := patches[event]
+		if !ok {
+			return &pendingError{event: event, user: *user}
+		}
+
+		patch.Apply(user)
+
+		return nil
+	}
+
+	for {
+		err := tx(run)
+
+		var pending *pendingError
+		if !errors.As(err, &pending) {
+			return err
+		}
+
+		patch, err := hooks.Run(ctx, pending.event, pending.user)
+		if err != nil {
+			return err
+		}
+
+		patches[pending.event] = patch
+	}
+}
+
+func newInput(ctx context.Context, event ports.HookEvent, user models.User) Input {
+	input := Input{
+		Event: event,
+		User: User{
+			Email:         user.Email,
+			Name:          user.Name,
+			PhoneNumber:   user.PhoneNumber,
+			EmailVerified: !user.EmailVerifiedAt.IsZero(),
+			PhoneVerified: !user.PhoneNumberVerifiedAt.IsZero(),
+			IsAnonymous:   user.IsAnonymous,
+			Providers:     []string{},
+			AppMetadata:   maps.Clone(user.AppMetadata),
+			UserMetadata:  maps.Clone(user.UserMetadata),
+		},
+	}
+
+	if user.AppMetadata == nil {
+		input.User.AppMetadata = map[string]string{}
+	}
+
+	if user.UserMetadata == nil {
+		input.User.UserMetadata = map[string]string{}
+	}
+
+	if user.ID != uuid.Nil {
+		input.User.ID = user.ID.String()
+	}
+
+	for _, a := range user.Accounts {
+		if !slices.Contains(input.User.Providers, a.Provider) {
+			input.User.Providers = append(input.User.Providers, a.Provider)
+		}
+	}
+
+	if md := audit.FromContext(ctx); md != nil {
+		input.Request = Request{IP: md.IP, UserAgent: md.UserAgent}
+	}
+
+	return input
+}
+
+// merge adds the changes of a later hook to the changes of earlier ones.
+func merge(m, changes map[string]*string) map[string]*string {
+	if len(changes) == 0 {
+		return m
+	}
+
+	if m == nil {
+		m = map[string]*string{}
+	}
+
+	maps.Copy(m, changes)
+
+	return m
+}
@@ -0,0 +1,139 @@
+package hooks
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"testing"
+
+	"github.com/open-cloud-initiative/glue/auth/internal/models"
+	"github.com/open-cloud-initiative/glue/auth/internal/ports"
+)
+
+// fake sets the app metadata key of the event and counts its runs.
+type fake struct {
+	runs map[ports.HookEvent]int
+	deny ports.HookEvent
+}
+
+func (f *fake) Run(_ context.Context, event ports.HookEvent, user models.User) (ports.HookPatch, error) {
+	f.runs[event]++
+
+	if event == f.deny {
+		return ports.HookPatch{}, ports.ErrHookDenied
+	}
+
+	value := user.Email
+	return ports.HookPatch{AppMetadata: map[string]*string{string(event): &value}}, nil
+}
+
+func TestDefer(t *testing.T) {
+	errDB := errors.New("connection reset")
+
+	tests := []struct {
+		name   string
+		events []ports.HookEvent
+		deny   ports.HookEvent
+		fail   error
+		// txs is the number of times the transaction runs.
+		txs  int
+		want map[string]string
+		err  error
+	}{
+		{
+			name: "no events",
+			txs:  1,
+			want: map[string]string{},
+		},
+		{
+			name:   "one event",
+			events: []ports.HookEvent{ports.HookBeforeUserCreated},
+			txs:    2,
+			want:   map[string]string{"before_user_created": "ada@example.com"},
+		},
+		{
+			name:   "two events",
+			events: []ports.HookEvent{ports.HookBeforeUserCreated, ports.HookBeforeSessionCreated},
+			txs:    3,
+			want: map[string]string{
+				"before_user_created":    "ada@example.com",
+				"before_session_created": "ada@example.com",
+			},
+		},
+		{
+			name:   "denied",
+			events: []ports.HookEvent{ports.HookBeforeUserCreated, ports.HookBeforeSessionCreated},
+			deny:   ports.HookBeforeSessionCreated,
+			txs:    2,
+			err:    ports.ErrHookDenied,
+		},
+		{
+			name:   "failed transaction",
+			events: []ports.HookEvent{ports.HookBeforeUserCreated},
+			fail:   errDB,
+			txs:    2,
+			err:    errDB,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hooks := &fake{runs: map[ports.HookEvent]int{}, deny: tt.deny}
+			txs := 0
+			got := map[string]string{}
+
+			err := Defer(context.Background(), hooks, func(run RunFunc) error {
+				txs++
+				user := models.User{Email: "ada@example.com"}
+
+				for _, event := range tt.events {
+					if err := run(event, &user); err != nil {
+						return err
+					}
+				}
+
+				if tt.fail != nil {
+					return tt.fail
+				}
+
+				got = maps.Clone(user.AppMetadata)
+				if got == nil {
+					got = map[string]string{}
+				}
+
+				return nil
+			})
+
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Defer() = %v, want %v", err, tt.err)
+			}
+
+			if txs != tt.txs {
+				t.Errorf("transaction ran %d times, want %d", txs, tt.txs)
+			}
+
+			for event, n := range hooks.runs {
+				if n != 1 {
+					t.Errorf("hooks of %s ran %d times, want once", event, n)
+				}
+			}
+
+			if tt.err == nil && !maps.Equal(got, tt.want) {
+				t.Errorf("app metadata = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeferWithoutHooks(t *testing.T) {
+	txs := 0
+
+	err := Defer(context.Background(), nil, func(run RunFunc) error {
+		txs++
+		return run(ports.HookBeforeUserCreated, &models.User{})
+	})
+
+	if err != nil || txs != 1 {
+		t.Errorf("Defer() = %v after %d transactions, want nil after 1", err, txs)
+	}
+}
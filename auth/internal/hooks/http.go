@@ -0,0 +1,103 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/open-cloud-initiative/glue/auth/internal/webhook"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultTimeout is the default timeout of an HTTP hook.
+	DefaultTimeout = 5 * time.Second
+
+	// maxResponseSize is the maximum size of the response of an HTTP hook.
+	maxResponseSize = 1 << 20
+)
+
+var _ Hook = (*HTTP)(nil)
+
+// HTTP is a hook that posts the Input to an endpoint and reads the Result
+// from the response. Requests are signed like webhook deliveries.
+type HTTP struct {
+	url     string
+	secret  []byte
+	client  *http.Client
+	timeout time.Duration
+}
+
+// HTTPOpt is a function that configures the HTTP hook.
+type HTTPOpt func(*HTTP)
+
+// WithTimeout sets the timeout of a request.
+func WithTimeout(timeout time.Duration) HTTPOpt {
+	return func(h *HTTP) {
+		h.timeout = timeout
+	}
+}
+
+// WithClient sets the HTTP client requests are sent with.
+func WithClient(client *http.Client) HTTPOpt {
+	return func(h *HTTP) {
+		h.client = client
+	}
+}
+
+// NewHTTP returns a new HTTP hook.
+func NewHTTP(url string, secret []byte, opts ...HTTPOpt) *HTTP {
+	h := &HTTP{url: url, secret: secret, client: http.DefaultClient, timeout: DefaultTimeout}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return h
+}
+
+// Evaluate implements the Hook interface. Responses other than 200 are errors.
+func (h *HTTP) Evaluate(ctx context.Context, input Input) (Result, error) {
+	body, err := json.Marshal(input)
+	if err != nil {
+		return Result{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url, bytes.NewReader(body))
+	if err != nil {
+		return Result{}, err
+	}
+
+	id := uuid.NewString()
+	now := time.Now()
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhook.HeaderID, id)
+	req.Header.Set(webhook.HeaderTimestamp, fmt.Sprint(now.Unix()))
+	req.Header.Set(webhook.HeaderSignature, webhook.Sign(h.secret, id, now, body))
+
+	res, err := h.client.Do(req)
+	if err != nil {
+		return Result{}, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return Result{}, fmt.Errorf("unexpected status %s", res.Status)
+	}
+
+	result := Result{}
+	if err := json.NewDecoder(io.LimitReader(res.Body, maxResponseSize)).Decode(&result); err != nil {
+		return Result{}, fmt.Errorf("invalid response: %w", err)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,308 @@
+// Package janitor removes expired sessions and tokens and purges
+// soft-deleted rows from the database.
+package janitor
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"time"
+
+	"github.com/open-cloud-initiative/glue/auth/internal/models"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// DefaultInterval is the default time between two runs.
+	DefaultInterval = time.Hour
+	// DefaultRetention is the default time soft-deleted rows are kept.
+	DefaultRetention = 30 * 24 * time.Hour
+	// DefaultBatchSize is the default number of rows removed per statement.
+	DefaultBatchSize = 1000
+	// DefaultAnonymousRetention is the default time anonymous users are kept after their last activity.
+	DefaultAnonymousRetention = 30 * 24 * time.Hour
+
+	// lockKey is the key of the Postgres advisory lock held by the running janitor.
+	lockKey int64 = 0x676c75656a616e69 // "gluejani"
+)
+
+// ErrLocked is returned when another replica holds the janitor lock.
+var ErrLocked = errors.New("janitor: another replica is running")
+
+var (
+	runsTotal    = expvar.NewInt("janitor_runs_total")
+	runFailures  = expvar.NewInt("janitor_run_failures_total")
+	rowsPurged   = expvar.NewMap("janitor_rows_purged_total")
+	lastRunStart = expvar.NewInt("janitor_last_run_timestamp_seconds")
+)
+
+// Target is a table cleaned up by the janitor.
+type Target struct {
+	// Model is the model of the table.
+	Model any
+	// Expires removes rows with an expires_at in the past.
+	Expires bool
+	// SoftDeleted removes rows soft-deleted before the retention period.
+	SoftDeleted bool
+}
+
+// DefaultTargets are the tables cleaned up by default.
+//
+// Users are not purged, their DeletedAt is not a soft delete. Anonymous
+// users that were never converted are removed after their own retention
+// without activity.
+var DefaultTargets = []Target{
+	{Model: &models.Session{}, Expires: true, SoftDeleted: true},
+	{Model: &models.CsrfToken{}, Expires: true, SoftDeleted: true},
+	{Model: &models.VerificationToken{}, Expires: true, SoftDeleted: true},
+	{Model: &models.Account{}, SoftDeleted: true},
+	{Model: &models.LoginAttempt{}, Expires: true},
+	{Model: &models.RateLimit{}, Expires: true},
+	{Model: &models.OutboxEvent{}, Expires: true},
+	{Model: &models.WebhookDelivery{}, Expires: true},
+}
+
+// Janitor removes expired and soft-deleted rows in batches. Only one
+// replica runs at a time, which is ensured by a Postgres advisory lock.
+type Janitor struct {
+	conn      *gorm.DB
+	targets   []Target
+	interval  time.Duration
+	retention time.Duration
+	batchSize int
+	anonymous time.Duration
+	onError   func(error)
+}
+
+// Opt is a function that configures the Janitor.
+type Opt func(*Janitor)
+
+// WithInterval sets the time between two runs.
+func WithInterval(interval time.Duration) Opt {
+	return func(j *Janitor) {
+		j.interval = interval
+	}
+}
+
+// WithRetention sets the time soft-deleted rows are kept.
+func WithRetention(retention time.Duration) Opt {
+	return func(j *Janitor) {
+		j.retention = retention
+	}
+}
+
+// WithAnonymousRetention sets the time anonymous users are kept after their last activity.
+// Zero keeps anonymous users forever.
+func WithAnonymousRetention(retention time.Duration) Opt {
+	return func(j *Janitor) {
+		j.anonymous = retention
+	}
+}
+
+// WithBatchSize sets the number of rows removed per statement.
+func WithBatchSize(size int) Opt {
+	return func(j *Janitor) {
+		j.batchSize = size
+	}
+}
+
+// WithTargets sets the tables cleaned up by the janitor.
+func WithTargets(targets ...Target) Opt {
+	return func(j *Janitor) {
+		j.targets = targets
+	}
+}
+
+// WithErrorHandler sets the function the failed runs of Start are passed to.
+func WithErrorHandler(fn func(error)) Opt {
+	return func(j *Janitor) {
+		j.onError = fn
+	}
+}
+
+// New returns a new Janitor.
+func New(conn *gorm.DB, opts ...Opt) *Janitor {
+	j := &Janitor{
+		conn:      conn,
+		targets:   DefaultTargets,
+		interval:  DefaultInterval,
+		retention: DefaultRetention,
+		batchSize: DefaultBatchSize,
+		anonymous: DefaultAnonymousRetention,
+		onError:   func(error) {},
+	}
+
+	for _, opt := range opts {
+		opt(j)
+	}
+
+	return j
+}
+
+// Start runs the janitor every interval until the context is canceled.
+// Runs skipped because another replica holds the lock are not an error.
+func (j *Janitor) Start(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := j.Run(ctx); err != nil && !errors.Is(err, ErrLocked) && ctx.Err() == nil {
+			j.onError(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Run cleans up all targets once and returns the number of removed rows per table.
+// It returns ErrLocked if another replica is running.
+func (j *Janitor) Run(ctx context.Context) (map[string]int64, error) {
+	sqlDB, err := j.conn.DB()
+	if err != nil {
+		return nil, err
+	}
+
+	// The advisory lock belongs to the session, so it is taken and released on the same connection.
+	lock, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Close()
+
+	var locked bool
+	if err := lock.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", lockKey).Scan(&locked); err != nil {
+		return nil, err
+	}
+
+	if !locked {
+		return nil, ErrLocked
+	}
+
+	defer func() {
+		// Unlock with a fresh context, the run may have been canceled.
+		if _, err := lock.ExecContext(context.WithoutCancel(ctx), "SELECT pg_advisory_unlock($1)", lockKey); err != nil {
+			j.onError(fmt.Errorf("unlock: %w", err))
+		}
+	}()
+
+	runsTotal.Add(1)
+	lastRunStart.Set(time.Now().Unix())
+
+	purged, err := j.run(ctx)
+	if err != nil {
+		runFailures.Add(1)
+	}
+
+	return purged, err
+}
+
+func (j *Janitor) run(ctx context.Context) (map[string]int64, error) {
+	purged := map[string]int64{}
+	now := time.Now()
+
+	for _, t := range j.targets {
+		table, err := j.table(t.Model)
+		if err != nil {
+			return purged, err
+		}
+
+		if t.Expires {
+			n, err := j.purge(ctx, table, "expires_at < ?", now)
+			purged[table] += n
+
+			if err != nil {
+				return purged, fmt.Errorf("%s: %w", table, err)
+			}
+		}
+
+		if t.SoftDeleted {
+			n, err := j.purge(ctx, table, "deleted_at IS NOT NULL AND deleted_at < ?", now.Add(-j.retention))
+			purged[table] += n
+
+			if err != nil {
+				return purged, fmt.Errorf("%s: %w", table, err)
+			}
+		}
+	}
+
+	if j.anonymous > 0 {
+		if err := j.purgeAnonymous(ctx, purged, now.Add(-j.anonymous), now); err != nil {
+			return purged, err
+		}
+	}
+
+	return purged, nil
+}
+
+// purgeAnonymous removes the anonymous users that were last active before the
+// cutoff. A user is active when it signs in and while it has a live session,
+// refreshing the session keeps it. Their expired sessions are removed first,
+// accounts are removed by the database.
+func (j *Janitor) purgeAnonymous(ctx context.Context, purged map[string]int64, cutoff, now time.Time) error {
+	users, err := j.table(&models.User{})
+	if err != nil {
+		return err
+	}
+
+	sessions, err := j.table(&models.Session{})
+	if err != nil {
+		return err
+	}
+
+	stale := fmt.Sprintf(`is_anonymous AND GREATEST(created_at, last_signed_in_at) < ? AND NOT EXISTS (SELECT 1 FROM %[1]q s WHERE s.user_id = %[2]q.id AND s.expires_at >= ?)`, sessions, users)
+
+	n, err := j.purge(ctx, sessions, fmt.Sprintf("user_id IN (SELECT id FROM %q WHERE %s)", users, stale), cutoff, now)
+	purged[sessions] += n
+
+	if err != nil {
+		return fmt.Errorf("%s: %w", sessions, err)
+	}
+
+	n, err = j.purge(ctx, users, stale, cutoff, now)
+	purged[users] += n
+
+	if err != nil {
+		return fmt.Errorf("%s: %w", users, err)
+	}
+
+	return nil
+}
+
+func (j *Janitor) table(model any) (string, error) {
+	stmt := &gorm.Statement{DB: j.conn}
+	if err := stmt.Parse(model); err != nil {
+		return "", err
+	}
+
+	return stmt.Schema.Table, nil
+}
+
+// purge deletes the rows of the table matching the condition in batches, so
+// that no statement holds locks on a large number of rows.
+func (j *Janitor) purge(ctx context.Context, table, cond string, args ...any) (int64, error) {
+	query := fmt.Sprintf(`DELETE FROM %[1]q WHERE ctid IN (SELECT ctid FROM %[1]q WHERE %[2]s LIMIT ?)`, table, cond)
+
+	args = append(args, j.batchSize)
+
+	var total int64
+
+	for {
+		res := j.conn.WithContext(ctx).Exec(query, args...)
+		if res.Error != nil {
+			return total, res.Error
+		}
+
+		total += res.RowsAffected
+		rowsPurged.Add(table, res.RowsAffected)
+
+		if res.RowsAffected < int64(j.batchSize) {
+			return total, nil
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package janitor
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	// Registers the serializer of the encrypted columns, that is needed to parse the models.
+	_ "github.com/open-cloud-initiative/glue/auth/internal/envelope"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+var deleteFrom = regexp.MustCompile(`^DELETE FROM "(\w+)"`)
+
+// statement is a statement run by the janitor.
+type statement struct {
+	table string
+	query string
+	args  []any
+}
+
+// conn records the statements and deletes the rows given per table.
+type conn struct {
+	statements []statement
+	rows       map[string][]int64
+}
+
+func (c *conn) ExecContext(_ context.Context, query string, args ...any) (sql.Result, error) {
+	table := deleteFrom.FindStringSubmatch(query)[1]
+	c.statements = append(c.statements, statement{table: table, query: query, args: args})
+
+	var n int64
+	if rows := c.rows[table]; len(rows) > 0 {
+		n, c.rows[table] = rows[0], rows[1:]
+	}
+
+	return result(n), nil
+}
+
+func (c *conn) PrepareContext(context.Context, string) (*sql.Stmt, error) {
+	return nil, errors.New("not supported")
+}
+
+func (c *conn) QueryContext(context.Context, string, ...any) (*sql.Rows, error) {
+	return nil, errors.New("not supported")
+}
+
+func (c *conn) QueryRowContext(context.Context, string, ...any) *sql.Row {
+	return nil
+}
+
+// of returns the statements on the table.
+func (c *conn) of(table string) []statement {
+	statements := []statement{}
+
+	for _, s := range c.statements {
+		if s.table == table {
+			statements = append(statements, s)
+		}
+	}
+
+	return statements
+}
+
+type result int64
+
+func (r result) LastInsertId() (int64, error) { return 0, nil }
+func (r result) RowsAffected() (int64, error) { return int64(r), nil }
+
+func newJanitor(t *testing.T, c *conn, opts ...Opt) *Janitor {
+	t.Helper()
+
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: c}), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return New(db, opts...)
+}
+
+// near returns true if the argument is a time within a second of want.
+func near(arg any, want time.Time) bool {
+	got, ok := arg.(time.Time)
+
+	return ok && got.Sub(want).Abs() < time.Second
+}
+
+func TestRun(t *testing.T) {
+	c := &conn{}
+	j := newJanitor(t, c, WithRetention(24*time.Hour), WithAnonymousRetention(7*24*time.Hour), WithBatchSize(10))
+
+	purged, err := j.run(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+
+	tests := []struct {
+		name  string
+		table string
+		index int
+		cond  string
+		args  []time.Time
+	}{
+		{name: "expired sessions", table: "sessions", index: 0, cond: "expires_at < $1", args: []time.Time{now}},
+		{name: "soft-deleted sessions", table: "sessions", index: 1, cond: "deleted_at IS NOT NULL AND deleted_at < $1", args: []time.Time{now.Add(-24 * time.Hour)}},
+		{name: "expired login attempts", table: "login_attempts", index: 0, cond: "expires_at < $1", args: []time.Time{now}},
+		{name: "soft-deleted accounts", table: "accounts", index: 0, cond: "deleted_at IS NOT NULL AND deleted_at < $1", args: []time.Time{now.Add(-24 * time.Hour)}},
+		{
+			name:  "sessions of stale anonymous users",
+			table: "sessions",
+			index: 2,
+			cond:  `user_id IN (SELECT id FROM "users" WHERE is_anonymous AND GREATEST(created_at, last_signed_in_at) < $1`,
+			args:  []time.Time{now.Add(-7 * 24 * time.Hour), now},
+		},
+		{
+			name:  "stale anonymous users",
+			table: "users",
+			index: 0,
+			cond:  `is_anonymous AND GREATEST(created_at, last_signed_in_at) < $1 AND NOT EXISTS (SELECT 1 FROM "sessions" s WHERE s.user_id = "users".id AND s.expires_at >= $2)`,
+			args:  []time.Time{now.Add(-7 * 24 * time.Hour), now},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statements := c.of(tt.table)
+			if len(statements) <= tt.index {
+				t.Fatalf("%d statements on %s, want at least %d", len(statements), tt.table, tt.index+1)
+			}
+
+			s := statements[tt.index]
+			if !strings.Contains(s.query, tt.cond) {
+				t.Errorf("query = %s, want condition %s", s.query, tt.cond)
+			}
+
+			if len(s.args) != len(tt.args)+1 || s.args[len(s.args)-1] != 10 {
+				t.Fatalf("args = %v, want %v and the batch size", s.args, tt.args)
+			}
+
+			for i, want := range tt.args {
+				if !near(s.args[i], want) {
+					t.Errorf("args[%d] = %v, want %v", i, s.args[i], want)
+				}
+			}
+		})
+	}
+
+	if len(c.of("users")) != 1 {
+		t.Errorf("%d statements on users, want only the anonymous users", len(c.of("users")))
+	}
+
+	if len(purged) == 0 {
+		t.Error("run() returned no purged tables")
+	}
+}
+
+func TestRunWithoutAnonymousRetention(t *testing.T) {
+	c := &conn{}
+	j := newJanitor(t, c, WithAnonymousRetention(0))
+
+	if _, err := j.run(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(c.of("users")); n != 0 {
+		t.Errorf("%d statements on users, want none", n)
+	}
+
+	if n := len(c.of("sessions")); n != 2 {
+		t.Errorf("%d statements on sessions, want the expired and soft-deleted ones", n)
+	}
+}
+
+func TestPurge(t *testing.T) {
+	tests := []struct {
+		name       string
+		rows       []int64
+		total      int64
+		statements int
+	}{
+		{name: "nothing to purge", rows: nil, total: 0, statements: 1},
+		{name: "less than a batch", rows: []int64{3}, total: 3, statements: 1},
+		{name: "several batches", rows: []int64{10, 10, 4}, total: 24, statements: 3},
+		{name: "exact batches", rows: []int64{10, 10, 0}, total: 20, statements: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &conn{rows: map[string][]int64{"sessions": tt.rows}}
+			j := newJanitor(t, c, WithBatchSize(10))
+
+			total, err := j.purge(t.Context(), "sessions", "expires_at < ?", time.Now())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if total != tt.total || len(c.statements) != tt.statements {
+				t.Errorf("purge() = %d in %d statements, want %d in %d", total, len(c.statements), tt.total, tt.statements)
+			}
+		})
+	}
+}
@@ -0,0 +1,294 @@
+// Package lockout counts failed login attempts per subject, per IP and per
+// subject from an IP in the database, so that all replicas share the counts,
+// and locks further attempts with an exponential backoff.
+package lockout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/open-cloud-initiative/glue/auth/internal/models"
+	"github.com/open-cloud-initiative/glue/auth/internal/ports"
+
+	"github.com/google/uuid"
+	"github.com/katallaxie/pkg/dbx"
+)
+
+const (
+	// DefaultSubjectThreshold is the default number of failures of a subject before it is locked.
+	DefaultSubjectThreshold = 10
+	// DefaultIPThreshold is the default number of failures of an IP before it is locked.
+	// It is higher than the others, as many clients may share an IP.
+	DefaultIPThreshold = 50
+	// DefaultSubjectIPThreshold is the default number of failures of a subject from an IP before it is locked.
+	DefaultSubjectIPThreshold = 5
+	// DefaultBaseDelay is the default lock after the threshold is reached. It doubles with each further failure.
+	DefaultBaseDelay = 30 * time.Second
+	// DefaultMaxDelay is the default longest lock.
+	DefaultMaxDelay = time.Hour
+	// DefaultWindow is the default time without failures after which the counts start over.
+	DefaultWindow = 24 * time.Hour
+)
+
+// ErrLocked is returned when attempts are locked.
+var ErrLocked = errors.New("too many failed attempts, try again later")
+
+// LockedError is returned when attempts are locked until a time.
+type LockedError struct {
+	// Until is the time the lock ends.
+	Until time.Time
+}
+
+// Error implements the error interface.
+func (e *LockedError) Error() string {
+	return ErrLocked.Error()
+}
+
+// Is makes errors.Is(err, ErrLocked) match.
+func (e *LockedError) Is(target error) bool {
+	return target == ErrLocked
+}
+
+// Thresholds are the numbers of failures before a counter is locked.
+type Thresholds struct {
+	Subject   int
+	IP        int
+	SubjectIP int
+}
+
+// Guard checks and records login attempts.
+type Guard struct {
+	store      dbx.Database[ports.ReadTx, ports.WriteTx]
+	thresholds Thresholds
+	baseDelay  time.Duration
+	maxDelay   time.Duration
+	window     time.Duration
+	onError    func(error)
+}
+
+// Opt is a function that configures the Guard.
+type Opt func(*Guard)
+
+// WithThresholds sets the numbers of failures before a counter is locked.
+// Zero values keep the defaults.
+func WithThresholds(t Thresholds) Opt {
+	return func(g *Guard) {
+		if t.Subject > 0 {
+			g.thresholds.Subject = t.Subject
+		}
+
+		if t.IP > 0 {
+			g.thresholds.IP = t.IP
+		}
+
+		if t.SubjectIP > 0 {
+			g.thresholds.SubjectIP = t.SubjectIP
+		}
+	}
+}
+
+// WithBackoff sets the first and the longest lock.
+func WithBackoff(base, max time.Duration) Opt {
+	return func(g *Guard) {
+		g.baseDelay = base
+		g.maxDelay = max
+	}
+}
+
+// WithWindow sets the time without failures after which the counts start over.
+func WithWindow(window time.Duration) Opt {
+	return func(g *Guard) {
+		g.window = window
+	}
+}
+
+// WithErrorHandler sets the function the failures of Settle are passed to.
+func WithErrorHandler(fn func(error)) Opt {
+	return func(g *Guard) {
+		g.onError = fn
+	}
+}
+
+// New returns a new Guard.
+func New(store dbx.Database[ports.ReadTx, ports.WriteTx], opts ...Opt) *Guard {
+	g := &Guard{
+		store: store,
+		thresholds: Thresholds{
+			Subject:   DefaultSubjectThreshold,
+			IP:        DefaultIPThreshold,
+			SubjectIP: DefaultSubjectIPThreshold,
+		},
+		baseDelay: DefaultBaseDelay,
+		maxDelay:  DefaultMaxDelay,
+		window:    DefaultWindow,
+		onError:   func(error) {},
+	}
+
+	for _, opt := range opts {
+		opt(g)
+	}
+
+	return g
+}
+
+// Acquire counts an attempt of the subject from the IP as failure, unless the
+// subject, the IP or the subject from the IP is locked, in which case it returns
+// a LockedError. The check and the count are atomic, so that concurrent attempts
+// can not exceed the thresholds. The subject is the login name of the attempt,
+// e.g. an email address, a phone number or a user ID. It is empty if the attempt
+// has no subject. Attempts that do not fail are given back with Succeed or Release.
+func (g *Guard) Acquire(ctx context.Context, subject, ip string) error {
+	return g.store.ReadWriteTx(ctx, func(ctx context.Context, tx ports.WriteTx) error {
+		now := time.Now()
+		until := now
+
+		for _, c := range g.counters(subject, ip) {
+			attempt := models.LoginAttempt{Subject: c.subject, IP: c.ip}
+			if err := tx.RecordLoginFailure(ctx, &attempt, now.Add(-g.window)); err != nil {
+				return err
+			}
+
+			if attempt.LockedUntil.After(until) {
+				until = attempt.LockedUntil
+				continue
+			}
+
+			if attempt.Failures >= c.threshold {
+				attempt.LockedUntil = now.Add(g.delay(attempt.Failures - c.threshold))
+			}
+
+			attempt.ExpiresAt = now.Add(g.window)
+			if attempt.LockedUntil.After(attempt.ExpiresAt) {
+				attempt.ExpiresAt = attempt.LockedUntil
+			}
+
+			if err := tx.UpdateLoginAttempt(ctx, &attempt); err != nil {
+				return err
+			}
+		}
+
+		// Rolls back the counts of the attempt.
+		if until.After(now) {
+			return &LockedError{Until: until}
+		}
+
+		return nil
+	})
+}
+
+// Release gives back an attempt counted by Acquire that did not fail,
+// e.g. because of an error of the store or the provider.
+func (g *Guard) Release(ctx context.Context, subject, ip string) error {
+	return g.store.ReadWriteTx(ctx, func(ctx context.Context, tx ports.WriteTx) error {
+		for _, c := range g.counters(subject, ip) {
+			attempt := models.LoginAttempt{Subject: c.subject, IP: c.ip}
+			if err := tx.RefundLoginFailure(ctx, &attempt); err != nil {
+				return err
+			}
+
+			// The counter was reset in the meantime, or the attempt did not lock it.
+			if attempt.ID == uuid.Nil || attempt.Failures >= c.threshold {
+				continue
+			}
+
+			attempt.LockedUntil = time.Time{}
+			if err := tx.UpdateLoginAttempt(ctx, &attempt); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Succeed resets the counters of the subject after a successful attempt.
+// The attempt is given back to the counter of the IP, which is shared with other subjects.
+func (g *Guard) Succeed(ctx context.Context, subject, ip string) error {
+	if err := g.Release(ctx, "", ip); err != nil {
+		return err
+	}
+
+	return g.Unlock(ctx, subject)
+}
+
+// Settle ends an attempt counted by Acquire with the error of the attempt.
+// A successful attempt resets the counters of the subject, an attempt that
+// fails with an error not matched by failed is given back. The attempt has
+// already been answered, so failures are reported instead of returned.
+func (g *Guard) Settle(ctx context.Context, subject, ip string, err error, failed func(error) bool) {
+	switch {
+	case err == nil:
+		if err := g.Succeed(ctx, subject, ip); err != nil {
+			g.onError(fmt.Errorf("reset: %w", err))
+		}
+	case !failed(err):
+		if err := g.Release(ctx, subject, ip); err != nil {
+			g.onError(fmt.Errorf("release: %w", err))
+		}
+	}
+}
+
+// Unlock resets the counters of the subjects.
+func (g *Guard) Unlock(ctx context.Context, subjects ...string) error {
+	nonEmpty := []string{}
+	for _, s := range subjects {
+		if s != "" {
+			nonEmpty = append(nonEmpty, s)
+		}
+	}
+
+	if len(nonEmpty) == 0 {
+		return nil
+	}
+
+	return g.store.ReadWriteTx(ctx, func(ctx context.Context, tx ports.WriteTx) error {
+		return tx.DeleteSubjectLoginAttempts(ctx, nonEmpty...)
+	})
+}
+
+// UnlockIP resets the counters of an IP.
+func (g *Guard) UnlockIP(ctx context.Context, ip string) error {
+	if ip == "" {
+		return nil
+	}
+
+	return g.store.ReadWriteTx(ctx, func(ctx context.Context, tx ports.WriteTx) error {
+		return tx.DeleteIPLoginAttempts(ctx, ip)
+	})
+}
+
+// delay returns the lock after n failures beyond the threshold.
+func (g *Guard) delay(n int) time.Duration {
+	d := g.baseDelay
+	for i := 0; i < n && d < g.maxDelay; i++ {
+		d *= 2
+	}
+
+	return min(d, g.maxDelay)
+}
+
+type counter struct {
+	subject   string
+	ip        string
+	threshold int
+}
+
+func (g *Guard) counters(subject, ip string) []counter {
+	counters := []counter{}
+
+	if subject != "" {
+		counters = append(counters, counter{subject: subject, threshold: g.thresholds.Subject})
+	}
+
+	if ip != "" {
+		counters = append(counters, counter{ip: ip, threshold: g.thresholds.IP})
+	}
+
+	if subject != "" && ip != "" {
+		counters = append(counters, counter{subject: subject, ip: ip, threshold: g.thresholds.SubjectIP})
+	}
+
+	return counters
+}
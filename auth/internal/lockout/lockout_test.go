@@ -0,0 +1,303 @@
+package lockout
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"testing"
+	"time"
+
+	"github.com/open-cloud-initiative/glue/auth/internal/models"
+	"github.com/open-cloud-initiative/glue/auth/internal/ports"
+
+	"github.com/google/uuid"
+)
+
+type key struct {
+	subject string
+	ip      string
+}
+
+// store keeps the attempt counters in memory. Transactions that fail are rolled back.
+type store struct {
+	attempts map[key]models.LoginAttempt
+}
+
+func (s *store) ReadTx(ctx context.Context, fn func(context.Context, ports.ReadTx) error) error {
+	return errors.New("not implemented")
+}
+
+func (s *store) ReadWriteTx(ctx context.Context, fn func(context.Context, ports.WriteTx) error) error {
+	tx := &writeTx{attempts: maps.Clone(s.attempts)}
+	if err := fn(ctx, tx); err != nil {
+		return err
+	}
+
+	s.attempts = tx.attempts
+
+	return nil
+}
+
+func (s *store) Migrate(context.Context, ...any) error {
+	return nil
+}
+
+func (s *store) Close() error {
+	return nil
+}
+
+type writeTx struct {
+	ports.WriteTx
+	attempts map[key]models.LoginAttempt
+}
+
+func (tx *writeTx) RecordLoginFailure(_ context.Context, attempt *models.LoginAttempt, since time.Time) error {
+	k := key{attempt.Subject, attempt.IP}
+
+	a, ok := tx.attempts[k]
+	if !ok {
+		a = models.LoginAttempt{ID: uuid.New(), Subject: attempt.Subject, IP: attempt.IP}
+	}
+
+	if a.LastFailureAt.Before(since) {
+		a.Failures = 0
+	}
+
+	a.Failures++
+	a.LastFailureAt = time.Now()
+	tx.attempts[k] = a
+	*attempt = a
+
+	return nil
+}
+
+func (tx *writeTx) RefundLoginFailure(_ context.Context, attempt *models.LoginAttempt) error {
+	k := key{attempt.Subject, attempt.IP}
+
+	a, ok := tx.attempts[k]
+	if !ok {
+		return nil
+	}
+
+	a.Failures = max(a.Failures-1, 0)
+	tx.attempts[k] = a
+	*attempt = a
+
+	return nil
+}
+
+func (tx *writeTx) UpdateLoginAttempt(_ context.Context, attempt *models.LoginAttempt) error {
+	tx.attempts[key{attempt.Subject, attempt.IP}] = *attempt
+	return nil
+}
+
+func (tx *writeTx) DeleteSubjectLoginAttempts(_ context.Context, subjects ...string) error {
+	for k := range tx.attempts {
+		for _, s := range subjects {
+			if k.subject == s {
+				delete(tx.attempts, k)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (tx *writeTx) DeleteIPLoginAttempts(_ context.Context, ip string) error {
+	for k := range tx.attempts {
+		if k.ip == ip {
+			delete(tx.attempts, k)
+		}
+	}
+
+	return nil
+}
+
+type op int
+
+const (
+	acquire op = iota
+	release
+	succeed
+)
+
+type attempt struct {
+	op      op
+	subject string
+	ip      string
+	locked  bool
+}
+
+func TestGuard(t *testing.T) {
+	tests := []struct {
+		name       string
+		thresholds Thresholds
+		attempts   []attempt
+	}{
+		{
+			name:       "locks the subject",
+			thresholds: Thresholds{Subject: 2, IP: 100, SubjectIP: 100},
+			attempts: []attempt{
+				{op: acquire, subject: "ada", ip: "10.0.0.1"},
+				{op: acquire, subject: "ada", ip: "10.0.0.2"},
+				{op: acquire, subject: "ada", ip: "10.0.0.3", locked: true},
+				{op: acquire, subject: "bob", ip: "10.0.0.1"},
+			},
+		},
+		{
+			name:       "locks the IP",
+			thresholds: Thresholds{Subject: 100, IP: 2, SubjectIP: 100},
+			attempts: []attempt{
+				{op: acquire, subject: "ada", ip: "10.0.0.1"},
+				{op: acquire, subject: "bob", ip: "10.0.0.1"},
+				{op: acquire, subject: "eve", ip: "10.0.0.1", locked: true},
+				{op: acquire, ip: "10.0.0.1", locked: true},
+				{op: acquire, subject: "eve", ip: "10.0.0.2"},
+			},
+		},
+		{
+			name:       "locks the subject from the IP",
+			thresholds: Thresholds{Subject: 100, IP: 100, SubjectIP: 2},
+			attempts: []attempt{
+				{op: acquire, subject: "ada", ip: "10.0.0.1"},
+				{op: acquire, subject: "ada", ip: "10.0.0.1"},
+				{op: acquire, subject: "ada", ip: "10.0.0.1", locked: true},
+				{op: acquire, subject: "ada", ip: "10.0.0.2"},
+			},
+		},
+		{
+			name:       "release gives back the attempt",
+			thresholds: Thresholds{Subject: 2, IP: 100, SubjectIP: 100},
+			attempts: []attempt{
+				{op: acquire, subject: "ada", ip: "10.0.0.1"},
+				{op: release, subject: "ada", ip: "10.0.0.1"},
+				{op: acquire, subject: "ada", ip: "10.0.0.1"},
+				{op: release, subject: "ada", ip: "10.0.0.1"},
+				{op: acquire, subject: "ada", ip: "10.0.0.1"},
+				{op: acquire, subject: "ada", ip: "10.0.0.1"},
+				{op: acquire, subject: "ada", ip: "10.0.0.1", locked: true},
+			},
+		},
+		{
+			name:       "release lifts the lock of the attempt",
+			thresholds: Thresholds{Subject: 2, IP: 100, SubjectIP: 100},
+			attempts: []attempt{
+				{op: acquire, subject: "ada", ip: "10.0.0.1"},
+				{op: acquire, subject: "ada", ip: "10.0.0.1"},
+				{op: release, subject: "ada", ip: "10.0.0.1"},
+				{op: acquire, subject: "ada", ip: "10.0.0.1"},
+				{op: acquire, subject: "ada", ip: "10.0.0.1", locked: true},
+			},
+		},
+		{
+			name:       "succeed resets the subject but not the IP",
+			thresholds: Thresholds{Subject: 2, IP: 3, SubjectIP: 100},
+			attempts: []attempt{
+				{op: acquire, subject: "ada", ip: "10.0.0.1"},
+				{op: acquire, subject: "ada", ip: "10.0.0.1"},
+				{op: succeed, subject: "ada", ip: "10.0.0.1"},
+				{op: acquire, subject: "ada", ip: "10.0.0.1"},
+				{op: acquire, subject: "bob", ip: "10.0.0.1"},
+				{op: acquire, subject: "eve", ip: "10.0.0.1", locked: true},
+			},
+		},
+		{
+			name:       "locked attempts are not counted",
+			thresholds: Thresholds{Subject: 1, IP: 2, SubjectIP: 100},
+			attempts: []attempt{
+				{op: acquire, subject: "ada", ip: "10.0.0.1"},
+				{op: acquire, subject: "ada", ip: "10.0.0.1", locked: true},
+				{op: acquire, subject: "ada", ip: "10.0.0.1", locked: true},
+				{op: acquire, subject: "bob", ip: "10.0.0.1"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			g := New(&store{attempts: map[key]models.LoginAttempt{}}, WithThresholds(tt.thresholds))
+
+			for i, a := range tt.attempts {
+				var err error
+
+				switch a.op {
+				case acquire:
+					err = g.Acquire(ctx, a.subject, a.ip)
+				case release:
+					err = g.Release(ctx, a.subject, a.ip)
+				case succeed:
+					err = g.Succeed(ctx, a.subject, a.ip)
+				}
+
+				var locked *LockedError
+				if errors.As(err, &locked) != a.locked {
+					t.Fatalf("attempt %d (%s from %s): err = %v, want locked %v", i, a.subject, a.ip, err, a.locked)
+				}
+
+				if a.locked && !errors.Is(err, ErrLocked) {
+					t.Errorf("attempt %d: err = %v, want ErrLocked", i, err)
+				}
+
+				if !a.locked && err != nil {
+					t.Fatalf("attempt %d (%s from %s): err = %v", i, a.subject, a.ip, err)
+				}
+			}
+		})
+	}
+}
+
+func TestGuardSettle(t *testing.T) {
+	errWrong := errors.New("wrong password")
+	errDown := errors.New("provider down")
+	failed := func(err error) bool { return errors.Is(err, errWrong) }
+
+	tests := []struct {
+		name     string
+		err      error
+		failures int
+	}{
+		{name: "success resets", err: nil, failures: 0},
+		{name: "failure counts", err: errWrong, failures: 1},
+		{name: "other error is given back", err: errDown, failures: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := &store{attempts: map[key]models.LoginAttempt{}}
+			g := New(s, WithErrorHandler(func(err error) { t.Errorf("settle: %v", err) }))
+
+			if err := g.Acquire(ctx, "ada", "10.0.0.1"); err != nil {
+				t.Fatal(err)
+			}
+
+			g.Settle(ctx, "ada", "10.0.0.1", tt.err, failed)
+
+			if got := s.attempts[key{subject: "ada"}].Failures; got != tt.failures {
+				t.Errorf("failures = %d, want %d", got, tt.failures)
+			}
+		})
+	}
+}
+
+func TestGuardDelay(t *testing.T) {
+	g := New(&store{}, WithBackoff(time.Second, 10*time.Second))
+
+	tests := []struct {
+		n    int
+		want time.Duration
+	}{
+		{n: 0, want: time.Second},
+		{n: 1, want: 2 * time.Second},
+		{n: 3, want: 8 * time.Second},
+		{n: 4, want: 10 * time.Second},
+		{n: 100, want: 10 * time.Second},
+	}
+
+	for _, tt := range tests {
+		if got := g.delay(tt.n); got != tt.want {
+			t.Errorf("delay(%d) = %s, want %s", tt.n, got, tt.want)
+		}
+	}
+}
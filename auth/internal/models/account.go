@@ -3,6 +3,7 @@ package models
 import (
 	"encoding/gob"
 	"errors"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -33,20 +34,40 @@ const (
 	AccountTypeWebAuthn AccountType = "webauthn"
 )
 
+// LinkPolicy decides if a new provider account is linked to an
+// existing user with the same email.
+type LinkPolicy string
+
+const (
+	// LinkPolicyNever never links accounts by email.
+	LinkPolicyNever LinkPolicy = "never"
+	// LinkPolicyVerified links accounts if both emails are verified. Emails
+	// are verified by GitHub, by tenant providers at the domains their tenant
+	// captures, and by the verification of the user, never by global OIDC or
+	// SAML issuers.
+	LinkPolicyVerified LinkPolicy = "verified"
+	// LinkPolicyAlways always links accounts with the same email.
+	LinkPolicyAlways LinkPolicy = "always"
+)
+
 // Account represents an external account linked to a user.
+// The tokens are encrypted at rest by the "encrypted" serializer.
 type Account struct {
 	// ID is the unique identifier of the account.
 	ID uuid.UUID `json:"id" gorm:"primaryKey;type:uuid;column:id;default:gen_random_uuid();"`
 	// Type is the type of the account.
 	Type AccountType `json:"type" validate:"required"`
+	// Tenant is the organization slug of the provider, empty for global providers.
+	// Provider IDs are unique within a tenant only.
+	Tenant string `json:"tenant" gorm:"uniqueIndex:idx_accounts_tenant_provider_account;not null;default:''"`
 	// Provider is the provider of the account.
-	Provider string `json:"provider" validate:"required"`
+	Provider string `json:"provider" validate:"required" gorm:"uniqueIndex:idx_accounts_tenant_provider_account"`
 	// ProviderAccountID is the account ID in the provider.
-	ProviderAccountID *string `json:"provider_account_id"`
+	ProviderAccountID *string `json:"provider_account_id" gorm:"uniqueIndex:idx_accounts_tenant_provider_account"`
 	// RefreshToken is the refresh token of the account.
-	RefreshToken *string `json:"refresh_token"`
+	RefreshToken *string `json:"refresh_token" gorm:"serializer:encrypted"`
 	// AccessToken is the access token of the account.
-	AccessToken *string `json:"access_token"`
+	AccessToken *string `json:"access_token" gorm:"serializer:encrypted"`
 	// ExpiresAt is the expiry time of the account.
 	ExpiresAt *time.Time `json:"expires_at"`
 	// TokenType is the token type of the account.
@@ -54,7 +75,7 @@ type Account struct {
 	// Scope is the scope of the account.
 	Scope *string `json:"scope"`
 	// IDToken is the ID token of the account.
-	IDToken *string `json:"id_token"`
+	IDToken *string `json:"id_token" gorm:"serializer:encrypted"`
 	// SessionState is the session state of the account.
 	SessionState string `json:"session_state"`
 	// UserID is the user ID of the account.
@@ -68,3 +89,38 @@ type Account struct {
 	// DeletedAt is the deletion time of the account.
 	DeletedAt gorm.DeletedAt `json:"deleted_at"`
 }
+
+// BeforeCreate sets the ID of a new account, as the encrypted tokens are bound to it.
+func (a *Account) BeforeCreate(*gorm.DB) error {
+	if a.ID == uuid.Nil {
+		a.ID = uuid.New()
+	}
+
+	return nil
+}
+
+// ProviderKey returns the key of the provider of the account, that is unique across tenants.
+func (a Account) ProviderKey() string {
+	return ProviderKey(a.Tenant, a.Provider)
+}
+
+// ProviderKey returns the key of a provider, that is unique across tenants.
+// The key of a global provider is its ID.
+func ProviderKey(tenant, provider string) string {
+	if tenant == "" {
+		return provider
+	}
+
+	return tenant + "/" + provider
+}
+
+// ProviderTenant returns the tenant of a provider key, or an empty string
+// for a global provider or another login method.
+func ProviderTenant(key string) string {
+	tenant, _, ok := strings.Cut(key, "/")
+	if !ok {
+		return ""
+	}
+
+	return tenant
+}
@@ -0,0 +1,46 @@
+package models
+
+import "testing"
+
+func TestProviderKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		tenant   string
+		provider string
+		key      string
+	}{
+		{name: "global provider", tenant: "", provider: "github", key: "github"},
+		{name: "tenant provider", tenant: "acme", provider: "okta", key: "acme/okta"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := ProviderKey(tt.tenant, tt.provider)
+			if key != tt.key {
+				t.Errorf("ProviderKey(%q, %q) = %q, want %q", tt.tenant, tt.provider, key, tt.key)
+			}
+
+			if tenant := ProviderTenant(key); tenant != tt.tenant {
+				t.Errorf("ProviderTenant(%q) = %q, want %q", key, tenant, tt.tenant)
+			}
+		})
+	}
+}
+
+func TestProviderTenant(t *testing.T) {
+	tests := []struct {
+		key    string
+		tenant string
+	}{
+		{key: "", tenant: ""},
+		{key: "password", tenant: ""},
+		{key: "acme/okta", tenant: "acme"},
+		{key: "/okta", tenant: ""},
+	}
+
+	for _, tt := range tests {
+		if tenant := ProviderTenant(tt.key); tenant != tt.tenant {
+			t.Errorf("ProviderTenant(%q) = %q, want %q", tt.key, tenant, tt.tenant)
+		}
+	}
+}
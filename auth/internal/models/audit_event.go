@@ -0,0 +1,69 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AuditChange is the change of a field in an audit event.
+type AuditChange struct {
+	// From is the value before the change.
+	From any `json:"from"`
+	// To is the value after the change.
+	To any `json:"to"`
+}
+
+// AuditEvent is a security-relevant event. Events are only ever appended.
+type AuditEvent struct {
+	// ID is the position of the event in the log.
+	ID int64 `json:"id" gorm:"primaryKey;autoIncrement"`
+	// Seq is the position of the event in the hash chain. It is empty until the
+	// event is chained, shortly after it was committed.
+	Seq *int64 `json:"seq,omitempty" gorm:"uniqueIndex"`
+	// Action is the kind of the event, e.g. user.banned.
+	Action string `json:"action" gorm:"index;not null"`
+	// ActorType is the kind of the actor: user, admin or system. It is empty
+	// for unauthenticated requests, e.g. logins, which are made by the target user.
+	ActorType string `json:"actor_type,omitempty"`
+	// ActorID is the user that made the request, if it was authenticated by a session.
+	ActorID *uuid.UUID `json:"actor_id,omitempty" gorm:"type:uuid;index"`
+	// UserID is the user the event concerns.
+	UserID *uuid.UUID `json:"user_id,omitempty" gorm:"type:uuid;index"`
+	// TargetType is the kind of the changed object, e.g. user, account or session.
+	TargetType string `json:"target_type"`
+	// TargetID is the ID of the changed object.
+	TargetID string `json:"target_id"`
+	// IP is the client IP of the request.
+	IP string `json:"ip,omitempty"`
+	// UserAgent is the user agent of the request.
+	UserAgent string `json:"user_agent,omitempty"`
+	// RequestID is the ID of the request, as set by the request ID middleware.
+	RequestID string `json:"request_id,omitempty"`
+	// Diff are the changed fields of the target.
+	Diff map[string]AuditChange `json:"diff,omitempty" gorm:"serializer:json;type:jsonb"`
+	// CreatedAt is the time of the event.
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+	// PrevHash is the hash of the previous event in the chain, it is empty for the first event.
+	PrevHash string `json:"prev_hash"`
+	// Hash is the hash of the event and PrevHash, which chains the events.
+	Hash string `json:"hash" gorm:"uniqueIndex"`
+}
+
+// AuditCheckpoint is a signature over the hash of an audit event, and so over
+// all events before it. Events up to a checkpoint can not be changed or
+// removed without the signing key.
+type AuditCheckpoint struct {
+	// ID is the position of the checkpoint.
+	ID int64 `json:"id" gorm:"primaryKey;autoIncrement"`
+	// EventID is the last event covered by the checkpoint.
+	EventID int64 `json:"event_id" gorm:"index;not null"`
+	// Hash is the hash of the event.
+	Hash string `json:"hash" gorm:"not null"`
+	// KeyID identifies the public key that verifies the signature.
+	KeyID string `json:"key_id" gorm:"not null"`
+	// Signature is the base64 encoded Ed25519 signature.
+	Signature string `json:"signature" gorm:"not null"`
+	// CreatedAt is the time of the checkpoint.
+	CreatedAt time.Time `json:"created_at"`
+}
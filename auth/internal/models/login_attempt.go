@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LoginAttempt counts the failed attempts of a subject, an IP or a subject from an IP.
+// Either Subject or IP is empty for the rows that count only one of them.
+type LoginAttempt struct {
+	// ID is the unique identifier of the row.
+	ID uuid.UUID `json:"id" gorm:"primaryKey;type:uuid;column:id;default:gen_random_uuid()"`
+	// Subject is the login name of the attempts, e.g. an email address or a user ID.
+	Subject string `json:"subject" gorm:"uniqueIndex:idx_login_attempts_subject_ip;not null;default:''"`
+	// IP is the client IP of the attempts.
+	IP string `json:"ip" gorm:"uniqueIndex:idx_login_attempts_subject_ip;index;not null;default:''"`
+	// Failures is the number of consecutive failed attempts.
+	Failures int `json:"failures"`
+	// LastFailureAt is the time of the last failed attempt.
+	LastFailureAt time.Time `json:"last_failure_at"`
+	// LockedUntil is the time until further attempts are rejected.
+	LockedUntil time.Time `json:"locked_until"`
+	// ExpiresAt is the time the row no longer affects attempts.
+	ExpiresAt time.Time `json:"expires_at" gorm:"index"`
+	// CreatedAt is the creation time of the row.
+	CreatedAt time.Time `json:"created_at"`
+	// UpdatedAt is the update time of the row.
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -2,6 +2,8 @@ package models
 
 import (
 	"time"
+
+	"github.com/google/uuid"
 )
 
 // MFAFactorStatus is an enum to represent the current state.
@@ -21,7 +23,9 @@ const (
 // Multi Factor
 type MFAFactor struct {
 	// Id.
-	Id string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Id string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	// User id.
+	UserID uuid.UUID `json:"user_id" gorm:"type:uuid;index"`
 	// Status.
 	Status MFAFactorStatus `protobuf:"varint,2,opt,name=status,proto3,enum=oci.cloud.glue.v1.auth.MFAFactorStatus" json:"status,omitempty"`
 	// Type.
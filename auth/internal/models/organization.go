@@ -0,0 +1,80 @@
+package models
+
+import (
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var slugFormat = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+// ValidSlug returns true if the slug can name an organization.
+func ValidSlug(slug string) bool {
+	return slugFormat.MatchString(slug)
+}
+
+// Organization is a tenant that users are members of. Its login policy
+// applies to the sessions that are active in the organization.
+type Organization struct {
+	// ID is the unique identifier of the organization.
+	ID uuid.UUID `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	// Slug is the unique name of the organization in URLs, e.g. acme.
+	Slug string `json:"slug" gorm:"uniqueIndex;not null"`
+	// Name is the display name of the organization.
+	Name string `json:"name"`
+	// AllowedProviders are the login methods of sessions in the organization,
+	// e.g. the key of a provider (see ProviderKey) or password. Empty allows
+	// all login methods.
+	AllowedProviders []string `json:"allowed_providers" gorm:"serializer:json;type:jsonb"`
+	// RequireMFA requires a verified second factor of sessions in the organization.
+	RequireMFA bool `json:"require_mfa"`
+	// Domains are the captured email domains. Users with a verified email
+	// address at a captured domain become members when they log in, and
+	// must log in according to the policy of the organization.
+	Domains []OrganizationDomain `json:"domains" gorm:"foreignKey:OrganizationID;constraint:OnDelete:CASCADE"`
+	// CreatedAt is the creation time of the organization.
+	CreatedAt time.Time `json:"created_at"`
+	// UpdatedAt is the time the organization was last changed.
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// DomainNames returns the captured email domains of the organization.
+func (o Organization) DomainNames() []string {
+	names := make([]string, 0, len(o.Domains))
+	for _, d := range o.Domains {
+		names = append(names, d.Domain)
+	}
+
+	return names
+}
+
+// OrganizationDomain is an email domain captured by an organization.
+// A domain is captured by at most one organization.
+type OrganizationDomain struct {
+	// Domain is the lowercase email domain, e.g. acme.com.
+	Domain string `json:"domain" gorm:"primaryKey"`
+	// OrganizationID is the organization that captures the domain.
+	OrganizationID uuid.UUID `json:"-" gorm:"type:uuid;index;not null"`
+}
+
+// Membership makes a user a member of an organization with a role. The
+// permissions of the role apply within the organization only.
+type Membership struct {
+	// OrganizationID is the organization of the membership.
+	OrganizationID uuid.UUID `json:"organization_id" gorm:"primaryKey;type:uuid"`
+	// Organization is the organization of the membership.
+	Organization Organization `json:"-" gorm:"foreignKey:OrganizationID;constraint:OnDelete:CASCADE"`
+	// UserID is the member.
+	UserID uuid.UUID `json:"user_id" gorm:"primaryKey;type:uuid;index"`
+	// User is the member.
+	User User `json:"-" gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+	// RoleID is the role of the member in the organization.
+	RoleID uuid.UUID `json:"role_id" gorm:"type:uuid;index;not null"`
+	// Role is the role of the member in the organization.
+	Role Role `json:"-" gorm:"foreignKey:RoleID;constraint:OnDelete:RESTRICT"`
+	// CreatedAt is the time the user joined the organization.
+	CreatedAt time.Time `json:"created_at"`
+	// UpdatedAt is the time the membership was last changed.
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,72 @@
+package models
+
+import "time"
+
+// WebhookStatus is the state of a webhook delivery.
+type WebhookStatus string
+
+const (
+	// WebhookPending is a delivery that is waiting for its next attempt.
+	WebhookPending WebhookStatus = "pending"
+	// WebhookDelivered is a delivery the endpoint accepted.
+	WebhookDelivered WebhookStatus = "delivered"
+	// WebhookDead is a delivery that failed too often and is no longer attempted.
+	WebhookDead WebhookStatus = "dead"
+)
+
+// OutboxData is the payload of an outbox event.
+type OutboxData struct {
+	// UserID is the user the event concerns.
+	UserID string `json:"user_id,omitempty"`
+	// TargetType is the kind of the changed object, e.g. user, account or session.
+	TargetType string `json:"target_type"`
+	// TargetID is the ID of the changed object.
+	TargetID string `json:"target_id"`
+	// Changes are the changed fields of the target.
+	Changes map[string]AuditChange `json:"changes,omitempty"`
+}
+
+// OutboxEvent is an event written in the transaction of the change it
+// describes, and delivered to webhooks after the transaction is committed.
+type OutboxEvent struct {
+	// ID is the position of the event in the outbox.
+	ID int64 `json:"id" gorm:"primaryKey;autoIncrement"`
+	// Type is the kind of the event, e.g. user.created.
+	Type string `json:"type" gorm:"index;not null"`
+	// Data is the payload of the event.
+	Data OutboxData `json:"data" gorm:"serializer:json;type:jsonb"`
+	// CreatedAt is the time of the event.
+	CreatedAt time.Time `json:"created_at"`
+	// DispatchedAt is the time deliveries were created for the event, it is nil until then.
+	DispatchedAt *time.Time `json:"dispatched_at,omitempty" gorm:"index"`
+	// ExpiresAt is the time after which the event can no longer be replayed.
+	ExpiresAt time.Time `json:"expires_at" gorm:"index"`
+}
+
+// WebhookDelivery is the delivery of an outbox event to a webhook endpoint.
+type WebhookDelivery struct {
+	// ID is the unique identifier of the delivery.
+	ID int64 `json:"id" gorm:"primaryKey;autoIncrement"`
+	// EventID is the delivered outbox event.
+	EventID int64 `json:"event_id" gorm:"uniqueIndex:idx_webhook_deliveries_event_endpoint;not null"`
+	// Endpoint is the ID of the endpoint in the configuration.
+	Endpoint string `json:"endpoint" gorm:"uniqueIndex:idx_webhook_deliveries_event_endpoint;index;not null"`
+	// Status is the state of the delivery.
+	Status WebhookStatus `json:"status" gorm:"index;not null"`
+	// Attempts is the number of attempts made.
+	Attempts int `json:"attempts"`
+	// NextAttemptAt is the time of the next attempt of a pending delivery.
+	NextAttemptAt time.Time `json:"next_attempt_at" gorm:"index"`
+	// LastStatusCode is the HTTP status of the last attempt, zero if there was no response.
+	LastStatusCode int `json:"last_status_code,omitempty"`
+	// LastError is the error of the last failed attempt.
+	LastError string `json:"last_error,omitempty"`
+	// DeliveredAt is the time the endpoint accepted the event.
+	DeliveredAt *time.Time `json:"delivered_at,omitempty"`
+	// CreatedAt is the creation time of the delivery.
+	CreatedAt time.Time `json:"created_at"`
+	// UpdatedAt is the time of the last attempt.
+	UpdatedAt time.Time `json:"updated_at"`
+	// ExpiresAt is the time the delivery is removed, together with its event.
+	ExpiresAt time.Time `json:"expires_at" gorm:"index"`
+}
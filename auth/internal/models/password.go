@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PasswordCredential is the password of a user. It is stored apart from
+// the user, so that the hash is never loaded or returned with the user.
+type PasswordCredential struct {
+	// UserID is the user the password belongs to.
+	UserID uuid.UUID `json:"-" gorm:"primaryKey;type:uuid"`
+	// User is the user the password belongs to.
+	User User `json:"-" gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+	// Hash is the Argon2id hash of the password in PHC string format.
+	Hash string `json:"-" gorm:"not null"`
+	// CreatedAt is the creation time of the password.
+	CreatedAt time.Time `json:"created_at"`
+	// UpdatedAt is the time the password or its hash was last changed.
+	UpdatedAt time.Time `json:"updated_at"`
+}
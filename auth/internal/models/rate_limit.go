@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// RateLimit is the state of a rate limit key shared by all replicas.
+// The meaning of the counts depends on the algorithm of the limiter.
+type RateLimit struct {
+	// Key is the rate limit key, e.g. the route group and the client IP.
+	Key string `json:"key" gorm:"primaryKey"`
+	// Start is the start of the current window, or the time the tokens were refilled.
+	Start time.Time `json:"start"`
+	// Current is the count of the current window, or the tokens left.
+	Current float64 `json:"current"`
+	// Previous is the count of the previous window.
+	Previous float64 `json:"previous"`
+	// ExpiresAt is the time the row no longer affects requests.
+	ExpiresAt time.Time `json:"expires_at" gorm:"index"`
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Permission is an action on a kind of resource, e.g. users:write.
+type Permission struct {
+	// Name is the resource and the action, separated by a colon.
+	Name string `json:"name" gorm:"primaryKey"`
+	// Description explains what the permission allows.
+	Description string `json:"description"`
+}
+
+// Role is a named set of permissions that is assigned to users.
+type Role struct {
+	// ID is the unique identifier of the role.
+	ID uuid.UUID `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	// Name is the unique name of the role, e.g. super-admin.
+	Name string `json:"name" gorm:"uniqueIndex;not null"`
+	// Description explains the purpose of the role.
+	Description string `json:"description"`
+	// BuiltIn roles are created at bootstrap and can not be changed or deleted.
+	BuiltIn bool `json:"built_in"`
+	// Permissions are the permissions granted by the role.
+	Permissions []RolePermission `json:"permissions" gorm:"foreignKey:RoleID;constraint:OnDelete:CASCADE"`
+	// CreatedAt is the creation time of the role.
+	CreatedAt time.Time `json:"created_at"`
+	// UpdatedAt is the time the role was last changed.
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// PermissionNames returns the names of the permissions of the role.
+func (r Role) PermissionNames() []string {
+	names := make([]string, 0, len(r.Permissions))
+	for _, p := range r.Permissions {
+		names = append(names, p.Permission)
+	}
+
+	return names
+}
+
+// RolePermission grants a permission to a role.
+type RolePermission struct {
+	// RoleID is the role the permission is granted to.
+	RoleID uuid.UUID `json:"-" gorm:"primaryKey;type:uuid"`
+	// Permission is the name of the permission.
+	Permission string `json:"permission" gorm:"primaryKey"`
+}
+
+// UserRole assigns a role to a user.
+type UserRole struct {
+	// UserID is the user the role is assigned to.
+	UserID uuid.UUID `json:"user_id" gorm:"primaryKey;type:uuid"`
+	// User is the user the role is assigned to.
+	User User `json:"-" gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+	// RoleID is the assigned role.
+	RoleID uuid.UUID `json:"role_id" gorm:"primaryKey;type:uuid;index"`
+	// Role is the assigned role.
+	Role Role `json:"-" gorm:"foreignKey:RoleID;constraint:OnDelete:CASCADE"`
+	// Source is the provider that manages the assignment with its role
+	// mappings, e.g. github. It is empty for roles assigned by an admin.
+	Source string `json:"source,omitempty"`
+	// CreatedAt is the time the role was assigned.
+	CreatedAt time.Time `json:"created_at"`
+}
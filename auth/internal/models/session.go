@@ -7,6 +7,16 @@ import (
 	"gorm.io/gorm"
 )
 
+// Login methods of sessions, besides the IDs of providers.
+const (
+	// LoginPassword logs in with email address and password.
+	LoginPassword = "password"
+	// LoginSMS logs in with a code sent by SMS.
+	LoginSMS = "sms"
+	// LoginAnonymous creates an anonymous user.
+	LoginAnonymous = "anonymous"
+)
+
 // VerificationToken is a verification token for a user.
 type VerificationToken struct {
 	// Token is the unique identifier of the token.
@@ -44,7 +54,7 @@ type Session struct {
 	// ID is the unique identifier of the session.
 	ID uuid.UUID `json:"id" gorm:"primaryKey;unique;type:uuid;column:id;default:gen_random_uuid()"`
 	// SessionToken is the token of the session.
-	SessionToken string `json:"session_token"`
+	SessionToken string `json:"session_token" gorm:"uniqueIndex"`
 	// CsrfToken is the CSRF token of the session.
 	CsrfToken CsrfToken `json:"csrf_token"`
 	// CsrfTokenID is the CSRF token ID of the session.
@@ -55,6 +65,17 @@ type Session struct {
 	User User `json:"user"`
 	// ExpiresAt is the expiry time of the session.
 	ExpiresAt time.Time `json:"expires_at"`
+	// MFARequired is true until the user completed a second factor.
+	MFARequired bool `json:"mfa_required"`
+	// MFAVerifiedAt is the time the session completed a second factor.
+	MFAVerifiedAt time.Time `json:"mfa_verified_at"`
+	// Provider is the login method of the session, e.g. the key of a provider
+	// (see ProviderKey) or password.
+	Provider string `json:"provider"`
+	// OrganizationID is the active organization of the session, if any.
+	OrganizationID *uuid.UUID `json:"organization_id,omitempty" gorm:"type:uuid;index"`
+	// Organization is the active organization of the session.
+	Organization *Organization `json:"organization,omitempty" gorm:"constraint:OnDelete:SET NULL"`
 	// CreatedAt is the creation time of the session.
 	CreatedAt time.Time `json:"created_at"`
 	// UpdatedAt is the update time of the session.
@@ -18,14 +18,17 @@ const (
 	MFAFactorType_FACTOR_TYPE_BIOMETRIC MFAFactorType = 2
 	// The factor is a hardware token.
 	MFAFactorType_FACTOR_TYPE_HARDWARE_TOKEN MFAFactorType = 3
+	// The factor is a phone number that receives codes by SMS.
+	MFAFactorType_FACTOR_TYPE_PHONE MFAFactorType = 4
 )
 
+// BannedForever is the BannedUntil of users that are banned indefinitely.
+var BannedForever = time.Date(9999, time.December, 31, 0, 0, 0, 0, time.UTC)
+
 // User represents a user in the system.
 type User struct {
 	// ID is the unique identifier of the user.
 	ID uuid.UUID `json:"id" gorm:"primaryKey;unique;type:uuid;column:id;default:gen_random_uuid()"`
-	// Role is the role of the user.
-	Role string `json:"role" gorm:"type:string"`
 	// Email is the email of the user.
 	Email string `json:"email" gorm:"type:string"`
 	// Name of the user.
@@ -47,11 +50,13 @@ type User struct {
 	// Last signed in at.
 	LastSignedInAt time.Time `protobuf:"bytes,10,opt,name=last_signed_in_at,json=lastSignedInAt,proto3" json:"last_signed_in_at,omitempty"`
 	// App metadata.
-	AppMetadata map[string]string `protobuf:"bytes,11,rep,name=app_metadata,json=appMetadata,proto3" json:"app_metadata,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	AppMetadata map[string]string `protobuf:"bytes,11,rep,name=app_metadata,json=appMetadata,proto3" json:"app_metadata,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3" gorm:"serializer:json"`
 	// User metadata.
-	UserMetadata map[string]string `protobuf:"bytes,12,rep,name=user_metadata,json=userMetadata,proto3" json:"user_metadata,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	UserMetadata map[string]string `protobuf:"bytes,12,rep,name=user_metadata,json=userMetadata,proto3" json:"user_metadata,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3" gorm:"serializer:json"`
 	// Banned until.
 	BannedUntil time.Time `protobuf:"bytes,13,opt,name=banned_until,json=bannedUntil,proto3" json:"banned_until,omitempty"`
+	// Ban reason.
+	BanReason string `json:"ban_reason,omitempty"`
 	// Created at.
 	CreatedAt time.Time `protobuf:"bytes,14,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
 	// Updated at.
@@ -61,9 +66,20 @@ type User struct {
 	// Is anonymous.
 	IsAnonymous bool `protobuf:"varint,17,opt,name=is_anonymous,json=isAnonymous,proto3" json:"is_anonymous,omitempty"`
 	// Identities.
-	Identities []*Identity `protobuf:"bytes,18,rep,name=identities,proto3" json:"identities,omitempty"`
+	Identities []*Identity `protobuf:"bytes,18,rep,name=identities,proto3" json:"identities,omitempty" gorm:"-"`
 	// MFA factors.
-	MfaFactors []*MFAFactor
+	MfaFactors []*MFAFactor `gorm:"-"`
+	// Roles are the names of the roles of the user. Providers that manage roles set it
+	// on the profile of a login, it is not loaded with the user.
+	Roles []string `json:"roles,omitempty" gorm:"-"`
+	// ManagedMetadata are the AppMetadata keys the provider of a login manages. Keys
+	// that are managed but not set on the profile are removed from the user.
+	ManagedMetadata []string `json:"-" gorm:"-"`
 	// Accounts associated with the user.
 	Accounts []Account `protobuf:"bytes,19,rep,name=accounts,proto3" json:"accounts,omitempty"`
 }
+
+// IsBanned returns true if the user is currently banned.
+func (u User) IsBanned() bool {
+	return time.Now().Before(u.BannedUntil)
+}
@@ -0,0 +1,125 @@
+package organization
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/open-cloud-initiative/glue/auth/internal/audit"
+	"github.com/open-cloud-initiative/glue/auth/internal/models"
+	"github.com/open-cloud-initiative/glue/auth/internal/ports"
+	"github.com/open-cloud-initiative/glue/auth/internal/verification"
+
+	"github.com/google/uuid"
+	"github.com/katallaxie/pkg/utilx"
+)
+
+// purposeInvite is the purpose of invitation tokens, the identifier of
+// a token is "<purpose>:<organization id>:<role>:<email>".
+const purposeInvite = "org-invite"
+
+// Invite sends an invitation to join the organization with the role to the
+// email address. A new invitation to the address replaces the previous one.
+// The caller must hold the permissions of the role.
+func (s *Service) Invite(ctx context.Context, caller uuid.UUID, slug, email, role string) error {
+	email = strings.TrimSpace(email)
+	org := models.Organization{Slug: slug}
+	var token string
+
+	err := s.store.ReadWriteTx(ctx, func(ctx context.Context, tx ports.WriteTx) error {
+		if err := tx.GetOrganizationBySlug(ctx, &org); err != nil {
+			return err
+		}
+
+		r, err := memberRole(ctx, tx, role)
+		if err != nil {
+			return err
+		}
+
+		if err := mayGrant(ctx, tx, caller, org, r); err != nil {
+			return err
+		}
+
+		token, err = verification.Issue(ctx, tx, inviteIdentifier(org.ID, role, email), s.inviteTTL)
+		if err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, tx, audit.ActionMemberInvited, audit.Organization(org),
+			map[string]models.AuditChange{"email": {To: email}, "role": {To: role}})
+	})
+	if err != nil {
+		return err
+	}
+
+	name := utilx.Or(org.Name, org.Slug)
+
+	return s.mailer.Send(ctx, ports.Mail{
+		To:      email,
+		Subject: fmt.Sprintf("You are invited to join %s", name),
+		Text: fmt.Sprintf("Hi,\n\nyou are invited to join %s. Sign in with this email address and open the link below to accept the invitation.\n\n%s\n\nThe link expires in %s.\n",
+			name, s.inviteURL+"?token="+url.QueryEscape(token), s.inviteTTL),
+	})
+}
+
+// Accept consumes an invitation sent by Invite and makes the user a member
+// of the organization. The user must have the invited email address.
+func (s *Service) Accept(ctx context.Context, userID uuid.UUID, token string) (models.Membership, error) {
+	membership := models.Membership{}
+
+	err := s.store.ReadWriteTx(ctx, func(ctx context.Context, tx ports.WriteTx) error {
+		vt, err := verification.Consume(ctx, tx, token)
+		if err != nil {
+			return err
+		}
+
+		orgID, role, email, ok := parseInviteIdentifier(vt.Identifier)
+		if !ok {
+			return verification.ErrInvalidToken
+		}
+
+		user := models.User{ID: userID}
+		if err := tx.GetUser(ctx, &user); err != nil {
+			return err
+		}
+
+		if !strings.EqualFold(user.Email, email) {
+			return ErrInvitationEmail
+		}
+
+		org := models.Organization{ID: orgID}
+		if err := tx.GetOrganization(ctx, &org); err != nil {
+			return err
+		}
+
+		r, err := memberRole(ctx, tx, role)
+		if err != nil {
+			return err
+		}
+
+		membership, err = join(ctx, tx, org, userID, r)
+
+		return err
+	})
+
+	return membership, err
+}
+
+func inviteIdentifier(orgID uuid.UUID, role, email string) string {
+	return strings.Join([]string{purposeInvite, orgID.String(), role, strings.ToLower(email)}, ":")
+}
+
+func parseInviteIdentifier(identifier string) (uuid.UUID, string, string, bool) {
+	parts := strings.SplitN(identifier, ":", 4)
+	if len(parts) != 4 || parts[0] != purposeInvite {
+		return uuid.Nil, "", "", false
+	}
+
+	orgID, err := uuid.Parse(parts[1])
+	if err != nil {
+		return uuid.Nil, "", "", false
+	}
+
+	return orgID, parts[2], parts[3], true
+}
@@ -0,0 +1,532 @@
+// Package organization manages organizations, their members and their
+// login policies.
+//
+// Members have a role within the organization, whose permissions apply to the
+// organization only. Each organization has a login policy: the login methods
+// it allows, whether a second factor is required, and the email domains it
+// captures. A session is active in at most one organization, which it enters
+// at login or by switching, if it satisfies the policy of the organization.
+package organization
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/open-cloud-initiative/glue/auth/internal/audit"
+	"github.com/open-cloud-initiative/glue/auth/internal/models"
+	"github.com/open-cloud-initiative/glue/auth/internal/ports"
+	"github.com/open-cloud-initiative/glue/auth/internal/rbac"
+
+	"github.com/google/uuid"
+	"github.com/katallaxie/pkg/dbx"
+	"gorm.io/gorm"
+)
+
+// DefaultInviteTTL is the default lifetime of an invitation.
+const DefaultInviteTTL = 7 * 24 * time.Hour
+
+var (
+	// ErrInvalidSlug is returned when the slug of an organization is invalid.
+	ErrInvalidSlug = errors.New("slugs must be lowercase letters, digits and '-'")
+	// ErrOrganizationExists is returned when an organization with the slug exists.
+	ErrOrganizationExists = errors.New("an organization with this slug exists")
+	// ErrInvalidDomain is returned when a captured domain is not a domain name.
+	ErrInvalidDomain = errors.New("invalid domain")
+	// ErrDomainCaptured is returned when a domain is captured by another organization.
+	ErrDomainCaptured = errors.New("domain is captured by another organization")
+	// ErrNotMember is returned when the user is not a member of the organization.
+	ErrNotMember = errors.New("user is not a member of the organization")
+	// ErrAlreadyMember is returned when the user is already a member of the organization.
+	ErrAlreadyMember = errors.New("user is already a member of the organization")
+	// ErrInvitationEmail is returned when an invitation was sent to another email address.
+	ErrInvitationEmail = errors.New("the invitation was sent to another email address")
+	// ErrMemberRole is returned when a role for members grants permissions outside of the organization.
+	ErrMemberRole = errors.New("the role of a member may only grant permissions within the organization")
+)
+
+var domainFormat = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]{2,63}$`)
+
+// Settings are the name and the login policy of an organization.
+type Settings struct {
+	// Name is the display name.
+	Name string
+	// AllowedProviders are the allowed login methods, empty allows all.
+	AllowedProviders []string
+	// RequireMFA requires a verified second factor.
+	RequireMFA bool
+	// Domains are the captured email domains, nil keeps the current domains.
+	Domains []string
+}
+
+// Service manages organizations and their members.
+type Service struct {
+	store     dbx.Database[ports.ReadTx, ports.WriteTx]
+	mailer    ports.Mailer
+	inviteURL string
+	inviteTTL time.Duration
+}
+
+// Opt is a function that configures the Service.
+type Opt func(*Service)
+
+// WithInviteURL sets the page invitation links point to. The page receives
+// the token as query parameter and posts it with the session of the invited user.
+func WithInviteURL(url string) Opt {
+	return func(s *Service) {
+		s.inviteURL = url
+	}
+}
+
+// WithInviteTTL sets the lifetime of invitations.
+func WithInviteTTL(ttl time.Duration) Opt {
+	return func(s *Service) {
+		s.inviteTTL = ttl
+	}
+}
+
+// New returns a new Service. Invitation links point to baseURL, unless
+// WithInviteURL is set.
+func New(store dbx.Database[ports.ReadTx, ports.WriteTx], mailer ports.Mailer, baseURL string, opts ...Opt) *Service {
+	s := &Service{
+		store:     store,
+		mailer:    mailer,
+		inviteTTL: DefaultInviteTTL,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if s.inviteURL == "" {
+		s.inviteURL = strings.TrimSuffix(baseURL, "/") + "/auth/invitations/accept"
+	}
+
+	return s
+}
+
+// List returns all organizations.
+func (s *Service) List(ctx context.Context) ([]models.Organization, error) {
+	orgs := []models.Organization{}
+
+	err := s.store.ReadTx(ctx, func(ctx context.Context, tx ports.ReadTx) error {
+		return tx.ListOrganizations(ctx, &orgs)
+	})
+
+	return orgs, err
+}
+
+// Discover returns the organization that captures the domain of the
+// email address, e.g. to find the providers of its tenant before the login.
+func (s *Service) Discover(ctx context.Context, email string) (models.Organization, error) {
+	org := models.Organization{}
+
+	_, d, ok := strings.Cut(strings.ToLower(strings.TrimSpace(email)), "@")
+	if !ok || !domainFormat.MatchString(d) {
+		return org, ErrInvalidDomain
+	}
+
+	err := s.store.ReadTx(ctx, func(ctx context.Context, tx ports.ReadTx) error {
+		return tx.GetOrganizationByDomain(ctx, d, &org)
+	})
+
+	return org, err
+}
+
+// Get returns the organization with the slug.
+func (s *Service) Get(ctx context.Context, slug string) (models.Organization, error) {
+	org := models.Organization{Slug: slug}
+
+	err := s.store.ReadTx(ctx, func(ctx context.Context, tx ports.ReadTx) error {
+		return tx.GetOrganizationBySlug(ctx, &org)
+	})
+
+	return org, err
+}
+
+// Create creates an organization.
+func (s *Service) Create(ctx context.Context, slug string, settings Settings) (models.Organization, error) {
+	if !models.ValidSlug(slug) {
+		return models.Organization{}, ErrInvalidSlug
+	}
+
+	org := models.Organization{Slug: slug}
+
+	err := s.store.ReadWriteTx(ctx, func(ctx context.Context, tx ports.WriteTx) error {
+		err := tx.GetOrganizationBySlug(ctx, &org)
+		if err == nil {
+			return ErrOrganizationExists
+		}
+
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		org = models.Organization{Slug: slug}
+		if err := apply(ctx, tx, &org, settings); err != nil {
+			return err
+		}
+
+		if err := tx.CreateOrganization(ctx, &org); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, tx, audit.ActionOrgCreated, audit.Organization(org), audit.Diff(models.Organization{}, org))
+	})
+
+	return org, err
+}
+
+// Update replaces the settings of an organization.
+func (s *Service) Update(ctx context.Context, slug string, settings Settings) (models.Organization, error) {
+	org := models.Organization{Slug: slug}
+
+	err := s.store.ReadWriteTx(ctx, func(ctx context.Context, tx ports.WriteTx) error {
+		if err := tx.GetOrganizationBySlug(ctx, &org); err != nil {
+			return err
+		}
+
+		before := org
+
+		if err := apply(ctx, tx, &org, settings); err != nil {
+			return err
+		}
+
+		if err := tx.UpdateOrganization(ctx, &org); err != nil {
+			return err
+		}
+
+		return audit.RecordChange(ctx, tx, audit.ActionOrgUpdated, audit.Organization(org), before, org)
+	})
+
+	return org, err
+}
+
+// Delete deletes an organization together with its memberships.
+func (s *Service) Delete(ctx context.Context, slug string) error {
+	return s.store.ReadWriteTx(ctx, func(ctx context.Context, tx ports.WriteTx) error {
+		org := models.Organization{Slug: slug}
+		if err := tx.GetOrganizationBySlug(ctx, &org); err != nil {
+			return err
+		}
+
+		if err := tx.DeleteOrganization(ctx, &org); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, tx, audit.ActionOrgDeleted, audit.Organization(org), audit.Diff(org, models.Organization{}))
+	})
+}
+
+// Members returns the memberships of an organization.
+func (s *Service) Members(ctx context.Context, slug string) ([]models.Membership, error) {
+	memberships := []models.Membership{}
+
+	err := s.store.ReadTx(ctx, func(ctx context.Context, tx ports.ReadTx) error {
+		org := models.Organization{Slug: slug}
+		if err := tx.GetOrganizationBySlug(ctx, &org); err != nil {
+			return err
+		}
+
+		return tx.ListMembers(ctx, org.ID, &memberships)
+	})
+
+	return memberships, err
+}
+
+// Memberships returns the memberships of a user.
+func (s *Service) Memberships(ctx context.Context, userID uuid.UUID) ([]models.Membership, error) {
+	memberships := []models.Membership{}
+
+	err := s.store.ReadTx(ctx, func(ctx context.Context, tx ports.ReadTx) error {
+		return tx.ListMemberships(ctx, userID, &memberships)
+	})
+
+	return memberships, err
+}
+
+// AddMember makes the user a member of the organization with the role.
+// The caller must hold the permissions of the role.
+func (s *Service) AddMember(ctx context.Context, caller uuid.UUID, slug string, userID uuid.UUID, role string) (models.Membership, error) {
+	membership := models.Membership{}
+
+	err := s.store.ReadWriteTx(ctx, func(ctx context.Context, tx ports.WriteTx) error {
+		org := models.Organization{Slug: slug}
+		if err := tx.GetOrganizationBySlug(ctx, &org); err != nil {
+			return err
+		}
+
+		if err := tx.GetUser(ctx, &models.User{ID: userID}); err != nil {
+			return err
+		}
+
+		r, err := memberRole(ctx, tx, role)
+		if err != nil {
+			return err
+		}
+
+		if err := mayGrant(ctx, tx, caller, org, r); err != nil {
+			return err
+		}
+
+		membership, err = join(ctx, tx, org, userID, r)
+
+		return err
+	})
+
+	return membership, err
+}
+
+// SetRole changes the role of a member of the organization. The caller must
+// hold the permissions of the former and the new role.
+func (s *Service) SetRole(ctx context.Context, caller uuid.UUID, slug string, userID uuid.UUID, role string) (models.Membership, error) {
+	membership := models.Membership{UserID: userID}
+
+	err := s.store.ReadWriteTx(ctx, func(ctx context.Context, tx ports.WriteTx) error {
+		if err := getMembership(ctx, tx, slug, &membership); err != nil {
+			return err
+		}
+
+		r, err := memberRole(ctx, tx, role)
+		if err != nil {
+			return err
+		}
+
+		if err := mayGrant(ctx, tx, caller, models.Organization{ID: membership.OrganizationID}, membership.Role, r); err != nil {
+			return err
+		}
+
+		before := membership.Role.Name
+		membership.RoleID = r.ID
+		membership.Role = r
+
+		if err := tx.UpdateMembership(ctx, &membership); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, tx, audit.ActionMemberUpdated, audit.Membership(membership),
+			map[string]models.AuditChange{"role": {From: before, To: r.Name}})
+	})
+
+	return membership, err
+}
+
+// RemoveMember removes the user from the organization. Sessions of
+// the user that are active in the organization leave it. The caller
+// must hold the permissions of the role of the member.
+func (s *Service) RemoveMember(ctx context.Context, caller uuid.UUID, slug string, userID uuid.UUID) error {
+	return s.store.ReadWriteTx(ctx, func(ctx context.Context, tx ports.WriteTx) error {
+		membership := models.Membership{UserID: userID}
+		if err := getMembership(ctx, tx, slug, &membership); err != nil {
+			return err
+		}
+
+		if err := mayGrant(ctx, tx, caller, models.Organization{ID: membership.OrganizationID}, membership.Role); err != nil {
+			return err
+		}
+
+		if err := tx.DeleteMembership(ctx, &membership); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, tx, audit.ActionMemberRemoved, audit.Membership(membership),
+			map[string]models.AuditChange{"role": {From: membership.Role.Name}})
+	})
+}
+
+// Check returns rbac.ErrPermissionDenied unless the user is a member of the
+// organization with a role that grants the permission.
+func (s *Service) Check(ctx context.Context, slug string, userID uuid.UUID, permission string) error {
+	membership := models.Membership{UserID: userID}
+
+	err := s.store.ReadTx(ctx, func(ctx context.Context, tx ports.ReadTx) error {
+		return getMembership(ctx, tx, slug, &membership)
+	})
+	if errors.Is(err, ErrNotMember) {
+		return fmt.Errorf("%w: %s", rbac.ErrPermissionDenied, permission)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if !rbac.Granted(membership.Role.PermissionNames(), permission) {
+		return fmt.Errorf("%w: %s", rbac.ErrPermissionDenied, permission)
+	}
+
+	return nil
+}
+
+// Switch makes the organization with the slug the active organization of
+// the session, or leaves the active organization if the slug is empty.
+// The user must be a member and the session must satisfy the login policy.
+// A session of a tenant provider can only switch to the organization of the tenant.
+func (s *Service) Switch(ctx context.Context, session models.Session, slug string) (models.Session, error) {
+	err := s.store.ReadWriteTx(ctx, func(ctx context.Context, tx ports.WriteTx) error {
+		if slug == "" {
+			session.OrganizationID = nil
+			session.Organization = nil
+
+			return tx.UpdateSession(ctx, &session)
+		}
+
+		if tenant := models.ProviderTenant(session.Provider); tenant != "" && tenant != slug {
+			return fmt.Errorf("%w: sessions of %s are limited to its tenant", ports.ErrLoginPolicy, session.Provider)
+		}
+
+		membership := models.Membership{UserID: session.UserID}
+		if err := getMembership(ctx, tx, slug, &membership); err != nil {
+			return err
+		}
+
+		org := models.Organization{ID: membership.OrganizationID}
+		if err := tx.GetOrganization(ctx, &org); err != nil {
+			return err
+		}
+
+		if err := allowsProvider(org, session.Provider); err != nil {
+			return err
+		}
+
+		if err := allowsMFA(org, session); err != nil {
+			return err
+		}
+
+		session.OrganizationID = &org.ID
+		session.Organization = &org
+
+		return tx.UpdateSession(ctx, &session)
+	})
+
+	return session, err
+}
+
+// getMembership retrieves the membership of the user in the organization with the slug.
+func getMembership(ctx context.Context, tx ports.ReadTx, slug string, membership *models.Membership) error {
+	org := models.Organization{Slug: slug}
+	if err := tx.GetOrganizationBySlug(ctx, &org); err != nil {
+		return err
+	}
+
+	membership.OrganizationID = org.ID
+
+	err := tx.GetMembership(ctx, membership)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotMember
+	}
+
+	return err
+}
+
+// memberRole retrieves the role with the name, which must only grant permissions within organizations.
+func memberRole(ctx context.Context, tx ports.ReadTx, name string) (models.Role, error) {
+	role := models.Role{Name: name}
+	if err := tx.GetRoleByName(ctx, &role); err != nil {
+		return role, err
+	}
+
+	if !rbac.OrgScoped(role) {
+		return role, fmt.Errorf("%w: %s", ErrMemberRole, name)
+	}
+
+	return role, nil
+}
+
+// mayGrant returns rbac.ErrPermissionDenied unless the caller manages all
+// organizations, or holds the permissions of the roles in the organization.
+func mayGrant(ctx context.Context, tx ports.ReadTx, caller uuid.UUID, org models.Organization, roles ...models.Role) error {
+	if caller == rbac.Internal {
+		return nil
+	}
+
+	global := []models.Role{}
+	if err := tx.ListUserRoles(ctx, caller, &global); err != nil {
+		return err
+	}
+
+	if rbac.Granted(rbac.Union(global), rbac.OrgsWrite) {
+		return nil
+	}
+
+	membership := models.Membership{OrganizationID: org.ID, UserID: caller}
+
+	err := tx.GetMembership(ctx, &membership)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("%w: %s", rbac.ErrPermissionDenied, rbac.MembersWrite)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return rbac.Holds(membership.Role.PermissionNames(), roles...)
+}
+
+// join makes the user a member of the organization with the role in the transaction.
+func join(ctx context.Context, tx ports.WriteTx, org models.Organization, userID uuid.UUID, role models.Role) (models.Membership, error) {
+	membership := models.Membership{OrganizationID: org.ID, UserID: userID}
+
+	err := tx.GetMembership(ctx, &membership)
+	if err == nil {
+		return models.Membership{}, ErrAlreadyMember
+	}
+
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.Membership{}, err
+	}
+
+	membership = models.Membership{OrganizationID: org.ID, UserID: userID, RoleID: role.ID}
+	if err := tx.CreateMembership(ctx, &membership); err != nil {
+		return models.Membership{}, err
+	}
+
+	membership.Role = role
+
+	if err := audit.Record(ctx, tx, audit.ActionMemberAdded, audit.Membership(membership),
+		map[string]models.AuditChange{"role": {To: role.Name}}); err != nil {
+		return models.Membership{}, err
+	}
+
+	return membership, nil
+}
+
+// apply validates the settings and applies them to the organization.
+func apply(ctx context.Context, tx ports.ReadTx, org *models.Organization, settings Settings) error {
+	org.Name = settings.Name
+	org.AllowedProviders = settings.AllowedProviders
+	org.RequireMFA = settings.RequireMFA
+
+	if settings.Domains == nil {
+		return nil
+	}
+
+	org.Domains = []models.OrganizationDomain{}
+
+	for _, d := range settings.Domains {
+		d = strings.ToLower(strings.TrimSpace(d))
+		if !domainFormat.MatchString(d) {
+			return fmt.Errorf("%w %q", ErrInvalidDomain, d)
+		}
+
+		other := models.Organization{}
+
+		err := tx.GetOrganizationByDomain(ctx, d, &other)
+		if err == nil && other.ID != org.ID {
+			return fmt.Errorf("%w: %s", ErrDomainCaptured, d)
+		}
+
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		if !slices.ContainsFunc(org.Domains, func(od models.OrganizationDomain) bool { return od.Domain == d }) {
+			org.Domains = append(org.Domains, models.OrganizationDomain{Domain: d})
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,192 @@
+package organization
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/open-cloud-initiative/glue/auth/internal/models"
+	"github.com/open-cloud-initiative/glue/auth/internal/ports"
+	"github.com/open-cloud-initiative/glue/auth/internal/rbac"
+
+	"github.com/katallaxie/pkg/utilx"
+	"gorm.io/gorm"
+)
+
+// Enter returns the organization a new session of the user is active in, or nil.
+//
+// Users are global, but the providers of a tenant only vouch for the users
+// of their tenant. A session of a tenant provider makes the user a member of
+// the organization of the tenant, and is active in no other organization.
+// Logins of a tenant without an organization are denied.
+//
+// A verified email address at a captured domain makes the user a member of
+// the capturing organization. The login must use a provider allowed by that
+// organization, and the session enters it if it completes a second factor
+// as required. Otherwise the session enters the first organization of the
+// user whose policy it satisfies.
+func Enter(ctx context.Context, tx ports.WriteTx, user models.User, session models.Session) (*models.Organization, error) {
+	if tenant := models.ProviderTenant(session.Provider); utilx.NotEmpty(tenant) {
+		return enterTenant(ctx, tx, user, session, tenant)
+	}
+
+	org, err := capture(ctx, tx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	if org != nil {
+		if err := allowsProvider(*org, session.Provider); err != nil {
+			return nil, err
+		}
+
+		if allowsMFA(*org, session) == nil {
+			return org, nil
+		}
+	}
+
+	memberships := []models.Membership{}
+	if err := tx.ListMemberships(ctx, user.ID, &memberships); err != nil {
+		return nil, err
+	}
+
+	for _, m := range memberships {
+		if allowsProvider(m.Organization, session.Provider) == nil && allowsMFA(m.Organization, session) == nil {
+			return &m.Organization, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// Vouches returns true if the tenant captures the domain of the email address.
+// The providers of a tenant are run by the customer, so the email addresses
+// they assert are only trusted at the domains the tenant captures. A domain
+// is captured by at most one organization.
+func Vouches(ctx context.Context, tx ports.ReadTx, tenant, email string) (bool, error) {
+	_, d, ok := strings.Cut(strings.ToLower(email), "@")
+	if utilx.Empty(tenant) || !ok {
+		return false, nil
+	}
+
+	org := models.Organization{}
+
+	err := tx.GetOrganizationByDomain(ctx, d, &org)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return org.Slug == tenant, nil
+}
+
+// capture returns the organization that captures the verified email domain
+// of the user, and makes the user a member of it, or nil.
+func capture(ctx context.Context, tx ports.WriteTx, user models.User) (*models.Organization, error) {
+	_, d, ok := strings.Cut(strings.ToLower(user.Email), "@")
+	if !ok || user.EmailVerifiedAt.IsZero() {
+		return nil, nil
+	}
+
+	org := models.Organization{}
+
+	err := tx.GetOrganizationByDomain(ctx, d, &org)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := admit(ctx, tx, org, user); err != nil {
+		return nil, fmt.Errorf("organization: %s captures %s: %w", org.Slug, d, err)
+	}
+
+	return &org, nil
+}
+
+// enterTenant returns the organization of the tenant a new session of a
+// provider of the tenant is active in, and makes the user a member of it.
+// The session is active in no organization if it has yet to complete a
+// second factor required by the organization.
+func enterTenant(ctx context.Context, tx ports.WriteTx, user models.User, session models.Session, tenant string) (*models.Organization, error) {
+	org := models.Organization{Slug: tenant}
+
+	err := tx.GetOrganizationBySlug(ctx, &org)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: tenant %s has no organization", ports.ErrLoginPolicy, tenant)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := allowsProvider(org, session.Provider); err != nil {
+		return nil, err
+	}
+
+	if err := admit(ctx, tx, org, user); err != nil {
+		return nil, fmt.Errorf("organization: tenant %s: %w", tenant, err)
+	}
+
+	if allowsMFA(org, session) != nil {
+		return nil, nil
+	}
+
+	return &org, nil
+}
+
+// admit makes the user a member of the organization with the org-member
+// role, unless the user is a member already.
+func admit(ctx context.Context, tx ports.WriteTx, org models.Organization, user models.User) error {
+	err := tx.GetMembership(ctx, &models.Membership{OrganizationID: org.ID, UserID: user.ID})
+	if err == nil {
+		return nil
+	}
+
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	role := models.Role{Name: rbac.OrgMember}
+
+	err = tx.GetRoleByName(ctx, &role)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("the %s role is missing", rbac.OrgMember)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	_, err = join(ctx, tx, org, user.ID, role)
+
+	return err
+}
+
+// allowsProvider returns an error wrapping ports.ErrLoginPolicy unless
+// the organization allows logins with the provider.
+func allowsProvider(org models.Organization, provider string) error {
+	if len(org.AllowedProviders) > 0 && !slices.Contains(org.AllowedProviders, provider) {
+		return fmt.Errorf("%w: %s does not allow logins with %s", ports.ErrLoginPolicy, org.Slug, provider)
+	}
+
+	return nil
+}
+
+// allowsMFA returns an error wrapping ports.ErrLoginPolicy if the organization
+// requires a second factor the session neither completed nor has to complete
+// before it can be used.
+func allowsMFA(org models.Organization, session models.Session) error {
+	if org.RequireMFA && !session.MFARequired && session.MFAVerifiedAt.IsZero() {
+		return fmt.Errorf("%w: %s requires a second factor", ports.ErrLoginPolicy, org.Slug)
+	}
+
+	return nil
+}
@@ -0,0 +1,328 @@
+package organization
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/open-cloud-initiative/glue/auth/internal/models"
+	"github.com/open-cloud-initiative/glue/auth/internal/ports"
+	"github.com/open-cloud-initiative/glue/auth/internal/rbac"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// writeTx keeps the organizations and memberships in memory.
+type writeTx struct {
+	ports.WriteTx
+	orgs        []models.Organization
+	memberships []models.Membership
+	// noRole removes the org-member role.
+	noRole bool
+}
+
+func (tx *writeTx) GetOrganizationBySlug(_ context.Context, org *models.Organization) error {
+	for _, o := range tx.orgs {
+		if o.Slug == org.Slug {
+			*org = o
+			return nil
+		}
+	}
+
+	return gorm.ErrRecordNotFound
+}
+
+func (tx *writeTx) GetOrganizationByDomain(_ context.Context, domain string, org *models.Organization) error {
+	for _, o := range tx.orgs {
+		if slices.Contains(o.DomainNames(), domain) {
+			*org = o
+			return nil
+		}
+	}
+
+	return gorm.ErrRecordNotFound
+}
+
+func (tx *writeTx) GetMembership(_ context.Context, membership *models.Membership) error {
+	for _, m := range tx.memberships {
+		if m.OrganizationID == membership.OrganizationID && m.UserID == membership.UserID {
+			*membership = m
+			return nil
+		}
+	}
+
+	return gorm.ErrRecordNotFound
+}
+
+func (tx *writeTx) ListMemberships(_ context.Context, userID uuid.UUID, memberships *[]models.Membership) error {
+	for _, m := range tx.memberships {
+		if m.UserID != userID {
+			continue
+		}
+
+		for _, o := range tx.orgs {
+			if o.ID == m.OrganizationID {
+				m.Organization = o
+			}
+		}
+
+		*memberships = append(*memberships, m)
+	}
+
+	return nil
+}
+
+func (tx *writeTx) GetRoleByName(_ context.Context, role *models.Role) error {
+	if tx.noRole || role.Name != rbac.OrgMember {
+		return gorm.ErrRecordNotFound
+	}
+
+	role.ID = uuid.New()
+
+	return nil
+}
+
+func (tx *writeTx) CreateMembership(_ context.Context, membership *models.Membership) error {
+	tx.memberships = append(tx.memberships, *membership)
+	return nil
+}
+
+func (tx *writeTx) CreateAuditEvent(context.Context, *models.AuditEvent) error {
+	return nil
+}
+
+// slugs returns the organizations the user is a member of.
+func (tx *writeTx) slugs(userID uuid.UUID) []string {
+	memberships := []models.Membership{}
+	_ = tx.ListMemberships(context.Background(), userID, &memberships)
+
+	slugs := []string{}
+	for _, m := range memberships {
+		slugs = append(slugs, m.Organization.Slug)
+	}
+
+	return slugs
+}
+
+func TestEnter(t *testing.T) {
+	acme := models.Organization{ID: uuid.New(), Slug: "acme", Domains: []models.OrganizationDomain{{Domain: "acme.com"}}}
+	strict := models.Organization{ID: uuid.New(), Slug: "strict", AllowedProviders: []string{"github"}, RequireMFA: true}
+	open := models.Organization{ID: uuid.New(), Slug: "open"}
+
+	user := models.User{ID: uuid.New(), Email: "ada@acme.com", EmailVerifiedAt: time.Now()}
+	unverified := models.User{ID: user.ID, Email: user.Email}
+	elsewhere := models.User{ID: user.ID, Email: "ada@example.com", EmailVerifiedAt: time.Now()}
+
+	mfa := models.Session{Provider: "github", MFAVerifiedAt: time.Now()}
+
+	with := func(org models.Organization, change func(*models.Organization)) models.Organization {
+		change(&org)
+		return org
+	}
+
+	tests := []struct {
+		name    string
+		orgs    []models.Organization
+		member  []models.Organization
+		noRole  bool
+		user    models.User
+		session models.Session
+		want    string
+		members []string
+		// fails expects an error, err the error it wraps.
+		fails bool
+		err   error
+	}{
+		{
+			name:    "no organization",
+			orgs:    []models.Organization{acme},
+			user:    elsewhere,
+			session: models.Session{Provider: "github"},
+			members: []string{},
+		},
+		{
+			name:    "captured domain",
+			orgs:    []models.Organization{acme},
+			user:    user,
+			session: models.Session{Provider: "github"},
+			want:    "acme",
+			members: []string{"acme"},
+		},
+		{
+			name:    "captured domain of a member",
+			orgs:    []models.Organization{acme, open},
+			member:  []models.Organization{open, acme},
+			user:    user,
+			session: models.Session{Provider: "github"},
+			want:    "acme",
+			members: []string{"open", "acme"},
+		},
+		{
+			name:    "unverified email is not captured",
+			orgs:    []models.Organization{acme},
+			user:    unverified,
+			session: models.Session{Provider: "github"},
+			members: []string{},
+		},
+		{
+			name:    "captured domain denies the provider",
+			orgs:    []models.Organization{with(acme, func(o *models.Organization) { o.AllowedProviders = []string{"acme/okta"} })},
+			user:    user,
+			session: models.Session{Provider: "github"},
+			fails:   true,
+			err:     ports.ErrLoginPolicy,
+		},
+		{
+			name:    "captured domain awaits the second factor",
+			orgs:    []models.Organization{with(acme, func(o *models.Organization) { o.RequireMFA = true })},
+			user:    user,
+			session: models.Session{Provider: "github", MFARequired: true},
+			want:    "acme",
+			members: []string{"acme"},
+		},
+		{
+			name:    "captured domain requires a second factor",
+			orgs:    []models.Organization{with(acme, func(o *models.Organization) { o.RequireMFA = true }), open},
+			member:  []models.Organization{open},
+			user:    user,
+			session: models.Session{Provider: "github"},
+			want:    "open",
+			members: []string{"open", "acme"},
+		},
+		{
+			name:    "first membership whose policy is satisfied",
+			orgs:    []models.Organization{strict, open},
+			member:  []models.Organization{strict, open},
+			user:    elsewhere,
+			session: models.Session{Provider: "gitlab"},
+			want:    "open",
+			members: []string{"strict", "open"},
+		},
+		{
+			name:    "first membership",
+			orgs:    []models.Organization{strict, open},
+			member:  []models.Organization{strict, open},
+			user:    elsewhere,
+			session: mfa,
+			want:    "strict",
+			members: []string{"strict", "open"},
+		},
+		{
+			name:    "missing role",
+			orgs:    []models.Organization{acme},
+			noRole:  true,
+			user:    user,
+			session: models.Session{Provider: "github"},
+			fails:   true,
+		},
+		{
+			name:    "tenant",
+			orgs:    []models.Organization{acme, open},
+			member:  []models.Organization{open},
+			user:    elsewhere,
+			session: models.Session{Provider: "acme/okta"},
+			want:    "acme",
+			members: []string{"open", "acme"},
+		},
+		{
+			name:    "tenant is not captured elsewhere",
+			orgs:    []models.Organization{acme, with(open, func(o *models.Organization) { o.Domains = []models.OrganizationDomain{{Domain: "example.com"}} })},
+			user:    elsewhere,
+			session: models.Session{Provider: "acme/okta"},
+			want:    "acme",
+			members: []string{"acme"},
+		},
+		{
+			name:    "tenant without an organization",
+			orgs:    []models.Organization{open},
+			user:    user,
+			session: models.Session{Provider: "globex/okta"},
+			fails:   true,
+			err:     ports.ErrLoginPolicy,
+		},
+		{
+			name:    "tenant denies the provider",
+			orgs:    []models.Organization{with(acme, func(o *models.Organization) { o.AllowedProviders = []string{"acme/azure"} })},
+			user:    user,
+			session: models.Session{Provider: "acme/okta"},
+			fails:   true,
+			err:     ports.ErrLoginPolicy,
+		},
+		{
+			name:    "tenant requires a second factor",
+			orgs:    []models.Organization{with(acme, func(o *models.Organization) { o.RequireMFA = true }), open},
+			member:  []models.Organization{open},
+			user:    user,
+			session: models.Session{Provider: "acme/okta"},
+			members: []string{"open", "acme"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := &writeTx{orgs: tt.orgs, noRole: tt.noRole}
+			for _, o := range tt.member {
+				tx.memberships = append(tx.memberships, models.Membership{OrganizationID: o.ID, UserID: tt.user.ID})
+			}
+
+			org, err := Enter(context.Background(), tx, tt.user, tt.session)
+
+			if tt.fails {
+				if err == nil || (tt.err != nil && !errors.Is(err, tt.err)) {
+					t.Fatalf("Enter() = %v, want %v", err, tt.err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Enter() = %v", err)
+			}
+
+			got := ""
+			if org != nil {
+				got = org.Slug
+			}
+
+			if got != tt.want {
+				t.Errorf("Enter() = %q, want %q", got, tt.want)
+			}
+
+			if members := tx.slugs(tt.user.ID); !slices.Equal(members, tt.members) {
+				t.Errorf("member of %v, want %v", members, tt.members)
+			}
+		})
+	}
+}
+
+func TestVouches(t *testing.T) {
+	tx := &writeTx{orgs: []models.Organization{
+		{ID: uuid.New(), Slug: "acme", Domains: []models.OrganizationDomain{{Domain: "acme.com"}}},
+	}}
+
+	tests := []struct {
+		name   string
+		tenant string
+		email  string
+		want   bool
+	}{
+		{name: "captured domain", tenant: "acme", email: "ada@acme.com", want: true},
+		{name: "case insensitive", tenant: "acme", email: "Ada@ACME.com", want: true},
+		{name: "domain of another tenant", tenant: "globex", email: "ada@acme.com", want: false},
+		{name: "uncaptured domain", tenant: "acme", email: "ada@example.com", want: false},
+		{name: "global provider", tenant: "", email: "ada@acme.com", want: false},
+		{name: "no domain", tenant: "acme", email: "ada", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Vouches(context.Background(), tx, tt.tenant, tt.email)
+			if err != nil || got != tt.want {
+				t.Errorf("Vouches(%q, %q) = %v, %v, want %v", tt.tenant, tt.email, got, err, tt.want)
+			}
+		})
+	}
+}
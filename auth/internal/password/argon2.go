@@ -0,0 +1,109 @@
+// Package password hashes passwords with Argon2id, checks them against
+// a policy and logs in users with their email address and password.
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// ErrInvalidHash is returned when a hash is not an Argon2id PHC string.
+var ErrInvalidHash = errors.New("password: invalid hash")
+
+// Params are the parameters of Argon2id.
+type Params struct {
+	// Memory is the memory used in KiB.
+	Memory uint32
+	// Iterations is the number of passes over the memory.
+	Iterations uint32
+	// Parallelism is the number of threads.
+	Parallelism uint8
+	// SaltLength is the length of the random salt in bytes.
+	SaltLength uint32
+	// KeyLength is the length of the derived key in bytes.
+	KeyLength uint32
+}
+
+// DefaultParams are the parameters of new hashes, following RFC 9106.
+var DefaultParams = Params{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 2,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// Hash hashes the password with a random salt and returns it as PHC string,
+// e.g. $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>.
+func Hash(password string, p Params) (string, error) {
+	salt := make([]byte, p.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, p.Memory, p.Iterations, p.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// Verify returns true if the password matches the hash.
+func Verify(password, hash string) (bool, error) {
+	p, salt, key, err := decode(hash)
+	if err != nil {
+		return false, err
+	}
+
+	other := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+
+	return subtle.ConstantTimeCompare(key, other) == 1, nil
+}
+
+// NeedsRehash returns true if the hash was not created with the parameters.
+func NeedsRehash(hash string, p Params) bool {
+	q, salt, key, err := decode(hash)
+	if err != nil {
+		return true
+	}
+
+	return q.Memory != p.Memory || q.Iterations != p.Iterations || q.Parallelism != p.Parallelism ||
+		uint32(len(salt)) != p.SaltLength || uint32(len(key)) != p.KeyLength
+}
+
+func decode(hash string) (Params, []byte, []byte, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return Params{}, nil, nil, ErrInvalidHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return Params{}, nil, nil, ErrInvalidHash
+	}
+
+	p := Params{}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism); err != nil {
+		return Params{}, nil, nil, ErrInvalidHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return Params{}, nil, nil, ErrInvalidHash
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return Params{}, nil, nil, ErrInvalidHash
+	}
+
+	p.SaltLength = uint32(len(salt))
+	p.KeyLength = uint32(len(key))
+
+	return p, salt, key, nil
+}
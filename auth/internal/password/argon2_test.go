@@ -0,0 +1,108 @@
+package password
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// params are cheap parameters, so that the tests run fast.
+var params = Params{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+// with returns the cheap parameters changed by fn.
+func with(fn func(p *Params)) Params {
+	p := params
+	fn(&p)
+
+	return p
+}
+
+func TestHash(t *testing.T) {
+	a, err := Hash("correct horse", params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(a, "$argon2id$v=19$m=64,t=1,p=1$") {
+		t.Errorf("Hash() = %q, want a PHC string with the parameters", a)
+	}
+
+	b, err := Hash("correct horse", params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if a == b {
+		t.Error("Hash() returned the same hash twice, want a random salt")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	hash, err := Hash("correct horse", params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		password string
+		hash     string
+		want     bool
+		err      error
+	}{
+		{name: "match", password: "correct horse", hash: hash, want: true},
+		{name: "mismatch", password: "battery staple", hash: hash},
+		{name: "empty password", password: "", hash: hash},
+		{name: "prefix of the password", password: "correct", hash: hash},
+		{name: "other algorithm", password: "correct horse", hash: strings.Replace(hash, "argon2id", "argon2i", 1), err: ErrInvalidHash},
+		{name: "other version", password: "correct horse", hash: strings.Replace(hash, "v=19", "v=16", 1), err: ErrInvalidHash},
+		{name: "missing parameters", password: "correct horse", hash: strings.Replace(hash, "m=64,t=1,p=1", "m=64", 1), err: ErrInvalidHash},
+		{name: "missing key", password: "correct horse", hash: hash[:strings.LastIndex(hash, "$")+1], err: ErrInvalidHash},
+		{name: "invalid salt", password: "correct horse", hash: strings.Replace(hash, "p=1$", "p=1$!", 1), err: ErrInvalidHash},
+		{name: "bcrypt", password: "correct horse", hash: "$2a$10$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy", err: ErrInvalidHash},
+		{name: "empty", password: "correct horse", hash: "", err: ErrInvalidHash},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Verify(tt.password, tt.hash)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Verify() error = %v, want %v", err, tt.err)
+			}
+
+			if got != tt.want {
+				t.Errorf("Verify() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	hash, err := Hash("correct horse", params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		hash   string
+		params Params
+		want   bool
+	}{
+		{name: "same parameters", hash: hash, params: params, want: false},
+		{name: "more memory", hash: hash, params: with(func(p *Params) { p.Memory = 128 }), want: true},
+		{name: "more iterations", hash: hash, params: with(func(p *Params) { p.Iterations = 2 }), want: true},
+		{name: "more threads", hash: hash, params: with(func(p *Params) { p.Parallelism = 2 }), want: true},
+		{name: "longer salt", hash: hash, params: with(func(p *Params) { p.SaltLength = 32 }), want: true},
+		{name: "longer key", hash: hash, params: with(func(p *Params) { p.KeyLength = 64 }), want: true},
+		{name: "invalid hash", hash: "$2a$10$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy", params: params, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NeedsRehash(tt.hash, tt.params); got != tt.want {
+				t.Errorf("NeedsRehash() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
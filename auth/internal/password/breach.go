@@ -0,0 +1,193 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha1" //nolint:gosec // Have I Been Pwned identifies passwords by SHA-1.
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+const (
+	// prefixLength is the length of the hash prefix that names a range file.
+	prefixLength = 5
+	// hashLength is the length of a hex encoded SHA-1 hash.
+	hashLength = 40
+)
+
+// ErrInvalidCorpus is returned when a corpus file is not in range format.
+var ErrInvalidCorpus = errors.New("password: invalid breach corpus")
+
+// BreachChecker reports if a password appeared in a data breach.
+type BreachChecker interface {
+	// Breached returns true if the password appeared in a data breach.
+	Breached(password string) bool
+}
+
+var _ BreachChecker = (*Corpus)(nil)
+
+// Corpus is a set of breached passwords read from disk in the range format
+// of Have I Been Pwned. Only the first 64 bits of each SHA-1 hash are kept
+// in a sorted slice, so that a lookup is a binary search over 8 bytes per
+// hash and needs no network.
+type Corpus struct {
+	path     string
+	minCount int
+	hashes   atomic.Pointer[[]uint64]
+}
+
+// CorpusOpt is a function that configures the Corpus.
+type CorpusOpt func(*Corpus)
+
+// WithMinCount skips hashes seen less than n times, which keeps the corpus small.
+func WithMinCount(n int) CorpusOpt {
+	return func(c *Corpus) {
+		c.minCount = n
+	}
+}
+
+// NewCorpus returns an empty corpus that is loaded from path. The path is
+// either a directory of range files named by the hash prefix, e.g. 21BD1.txt
+// with lines of SUFFIX:COUNT, or a single file with lines of HASH:COUNT.
+func NewCorpus(path string, opts ...CorpusOpt) *Corpus {
+	c := &Corpus{path: path}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// Path returns the path the corpus is loaded from.
+func (c *Corpus) Path() string {
+	return c.path
+}
+
+// Len returns the number of hashes in the corpus.
+func (c *Corpus) Len() int {
+	hashes := c.hashes.Load()
+	if hashes == nil {
+		return 0
+	}
+
+	return len(*hashes)
+}
+
+// Breached returns true if the SHA-1 hash of the password is in the corpus.
+func (c *Corpus) Breached(password string) bool {
+	hashes := c.hashes.Load()
+	if hashes == nil {
+		return false
+	}
+
+	sum := sha1.Sum([]byte(password)) //nolint:gosec
+	_, found := slices.BinarySearch(*hashes, binary.BigEndian.Uint64(sum[:8]))
+
+	return found
+}
+
+// Load reads the corpus from disk and replaces the hashes in use once it is
+// read completely. It returns the number of hashes.
+func (c *Corpus) Load() (int, error) {
+	info, err := os.Stat(c.path)
+	if err != nil {
+		return 0, err
+	}
+
+	hashes := []uint64{}
+
+	if !info.IsDir() {
+		if hashes, err = c.read(c.path, "", hashes); err != nil {
+			return 0, err
+		}
+	} else {
+		entries, err := os.ReadDir(c.path)
+		if err != nil {
+			return 0, err
+		}
+
+		for _, e := range entries {
+			prefix := strings.TrimSuffix(e.Name(), filepath.Ext(e.Name()))
+			if e.IsDir() || len(prefix) != prefixLength || !isHex(prefix) {
+				continue
+			}
+
+			if hashes, err = c.read(filepath.Join(c.path, e.Name()), strings.ToUpper(prefix), hashes); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	slices.Sort(hashes)
+	hashes = slices.Clip(slices.Compact(hashes))
+
+	c.hashes.Store(&hashes)
+
+	return len(hashes), nil
+}
+
+// read appends the hashes of a file to hashes. Lines are prefixed with
+// prefix, which is empty for files of full hashes.
+func (c *Corpus) read(path, prefix string, hashes []uint64) ([]uint64, error) {
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" {
+			continue
+		}
+
+		hash, count, _ := strings.Cut(line, ":")
+		hash = prefix + hash
+
+		if len(hash) != hashLength || !isHex(hash) {
+			return nil, fmt.Errorf("%w: %s:%d", ErrInvalidCorpus, path, n)
+		}
+
+		if c.minCount > 0 {
+			seen, err := strconv.Atoi(strings.TrimSpace(count))
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s:%d", ErrInvalidCorpus, path, n)
+			}
+
+			if seen < c.minCount {
+				continue
+			}
+		}
+
+		v, err := strconv.ParseUint(hash[:16], 16, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s:%d", ErrInvalidCorpus, path, n)
+		}
+
+		hashes = append(hashes, v)
+	}
+
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return hashes, nil
+}
+
+func isHex(s string) bool {
+	for _, r := range s {
+		if (r < '0' || r > '9') && (r < 'a' || r > 'f') && (r < 'A' || r > 'F') {
+			return false
+		}
+	}
+
+	return true
+}
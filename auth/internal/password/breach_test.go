@@ -0,0 +1,138 @@
+package password
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCorpusLoad(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		opts     []CorpusOpt
+		want     int
+		breached []string
+		safe     []string
+	}{
+		{
+			name:     "range files",
+			path:     "testdata/range",
+			want:     5,
+			breached: []string{"password", "123456", "qwerty"},
+			safe:     []string{"letmein", "correct horse battery staple", ""},
+		},
+		{
+			name:     "range files with a minimum count",
+			path:     "testdata/range",
+			opts:     []CorpusOpt{WithMinCount(10)},
+			want:     2,
+			breached: []string{"password", "123456"},
+			safe:     []string{"qwerty", "letmein"},
+		},
+		{
+			name:     "file of full hashes",
+			path:     "testdata/hashes.txt",
+			want:     2,
+			breached: []string{"password", "letmein"},
+			safe:     []string{"123456", "qwerty"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCorpus(tt.path, tt.opts...)
+
+			n, err := c.Load()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if n != tt.want || c.Len() != tt.want {
+				t.Errorf("Load() = %d, Len() = %d, want %d", n, c.Len(), tt.want)
+			}
+
+			for _, p := range tt.breached {
+				if !c.Breached(p) {
+					t.Errorf("Breached(%q) = false, want true", p)
+				}
+			}
+
+			for _, p := range tt.safe {
+				if c.Breached(p) {
+					t.Errorf("Breached(%q) = true, want false", p)
+				}
+			}
+		})
+	}
+}
+
+func TestCorpusLoadInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		file  string
+		lines string
+		opts  []CorpusOpt
+		err   error
+	}{
+		{name: "suffix too short", file: "5BAA6.txt", lines: "1E4C9B93F3F0682250B6CF8331B7EE68FD:3\n"},
+		{name: "suffix not hex", file: "5BAA6.txt", lines: "1E4C9B93F3F0682250B6CF8331B7EE68FDX:3\n"},
+		{name: "full hash in a range file", file: "5BAA6.txt", lines: "5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8:3\n"},
+		{name: "count not a number", file: "5BAA6.txt", lines: "1E4C9B93F3F0682250B6CF8331B7EE68FD8:many\n", opts: []CorpusOpt{WithMinCount(2)}},
+		{name: "missing count", file: "5BAA6.txt", lines: "1E4C9B93F3F0682250B6CF8331B7EE68FD8\n", opts: []CorpusOpt{WithMinCount(2)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, tt.file), []byte(tt.lines), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			c := NewCorpus(dir, tt.opts...)
+			if _, err := c.Load(); !errors.Is(err, ErrInvalidCorpus) {
+				t.Errorf("Load() error = %v, want %v", err, ErrInvalidCorpus)
+			}
+		})
+	}
+}
+
+func TestCorpusReload(t *testing.T) {
+	c := NewCorpus("testdata/range")
+	if _, err := c.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A failed load keeps the hashes in use.
+	c.path = "testdata/missing"
+	if _, err := c.Load(); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Load() error = %v, want %v", err, os.ErrNotExist)
+	}
+
+	if !c.Breached("password") {
+		t.Error("Breached() = false after a failed load, want the previous hashes")
+	}
+
+	if NewCorpus("testdata/range").Breached("password") {
+		t.Error("Breached() = true before the corpus is loaded, want false")
+	}
+}
+
+func TestPolicyBreached(t *testing.T) {
+	c := NewCorpus("testdata/range")
+	if _, err := c.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	p := Policy{Breached: c}
+
+	var perr *PolicyError
+	if err := p.Check("password"); !errors.As(err, &perr) {
+		t.Errorf("Check() of a breached password error = %v, want a PolicyError", err)
+	}
+
+	if err := p.Check("letmein"); err != nil {
+		t.Errorf("Check() of a safe password error = %v, want nil", err)
+	}
+}
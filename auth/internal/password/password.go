@@ -0,0 +1,293 @@
+package password
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/open-cloud-initiative/glue/auth/internal/audit"
+	"github.com/open-cloud-initiative/glue/auth/internal/models"
+	"github.com/open-cloud-initiative/glue/auth/internal/ports"
+
+	"github.com/google/uuid"
+	"github.com/katallaxie/pkg/dbx"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInvalidCredentials is returned when the email address or the password is wrong.
+	ErrInvalidCredentials = errors.New("invalid email address or password")
+	// ErrEmailInUse is returned when a user with the email address exists.
+	ErrEmailInUse = errors.New("email address is used by another user")
+)
+
+// Service logs in users with their email address and password and manages passwords.
+type Service struct {
+	store  dbx.Database[ports.ReadTx, ports.WriteTx]
+	params Params
+	policy Policy
+	hooks  ports.Hooks
+	dummy  func() (string, error)
+}
+
+// Opt is a function that configures the Service.
+type Opt func(*Service)
+
+// WithParams sets the Argon2id parameters of new hashes. Existing
+// hashes are upgraded to the parameters at the next login.
+func WithParams(params Params) Opt {
+	return func(s *Service) {
+		s.params = params
+	}
+}
+
+// WithPolicy sets the policy of new passwords.
+func WithPolicy(policy Policy) Opt {
+	return func(s *Service) {
+		s.policy = policy
+	}
+}
+
+// WithHooks runs the hooks before users sign up.
+func WithHooks(hooks ports.Hooks) Opt {
+	return func(s *Service) {
+		s.hooks = hooks
+	}
+}
+
+// New returns a new Service.
+func New(store dbx.Database[ports.ReadTx, ports.WriteTx], opts ...Opt) *Service {
+	s := &Service{
+		store:  store,
+		params: DefaultParams,
+		policy: DefaultPolicy,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	// Logins of unknown users verify against a dummy hash, so that they take as long as other logins.
+	s.dummy = sync.OnceValues(func() (string, error) {
+		return Hash("", s.params)
+	})
+
+	return s
+}
+
+// Login returns the user with the email address and password.
+// Hashes created with other parameters are upgraded.
+func (s *Service) Login(ctx context.Context, email, password string) (models.User, error) {
+	user := models.User{Email: email}
+	credential := models.PasswordCredential{}
+
+	err := s.store.ReadTx(ctx, func(ctx context.Context, tx ports.ReadTx) error {
+		if err := tx.GetUserByEmail(ctx, &user); err != nil {
+			return err
+		}
+
+		credential.UserID = user.ID
+
+		return tx.GetPasswordCredential(ctx, &credential)
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.User{}, s.unknown()
+	}
+
+	if err != nil {
+		return models.User{}, err
+	}
+
+	// Hashing takes long, so it runs outside of transactions.
+	ok, err := Verify(password, credential.Hash)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	if !ok {
+		return models.User{}, ErrInvalidCredentials
+	}
+
+	if user.IsBanned() {
+		return models.User{}, ports.ErrUserBanned
+	}
+
+	rehash := ""
+	if NeedsRehash(credential.Hash, s.params) {
+		if rehash, err = Hash(password, s.params); err != nil {
+			return models.User{}, err
+		}
+	}
+
+	err = s.store.ReadWriteTx(ctx, func(ctx context.Context, tx ports.WriteTx) error {
+		if rehash != "" {
+			if err := s.replace(ctx, tx, credential, rehash); err != nil {
+				return err
+			}
+		}
+
+		user.LastSignedInAt = time.Now()
+		user.ReauthenticatedAt = user.LastSignedInAt
+
+		return tx.UpdateUser(ctx, &user)
+	})
+	if err != nil {
+		return models.User{}, err
+	}
+
+	return user, nil
+}
+
+// unknown returns ErrInvalidCredentials for a user or password that does
+// not exist, after the time a password check takes.
+func (s *Service) unknown() error {
+	dummy, err := s.dummy()
+	if err != nil {
+		return err
+	}
+
+	_, _ = Verify("", dummy)
+
+	return ErrInvalidCredentials
+}
+
+// replace replaces the hash of the credential, unless the password
+// was changed since the credential was read.
+func (s *Service) replace(ctx context.Context, tx ports.WriteTx, credential models.PasswordCredential, hash string) error {
+	current := models.PasswordCredential{UserID: credential.UserID}
+	if err := tx.GetPasswordCredential(ctx, &current); err != nil {
+		return err
+	}
+
+	if current.Hash != credential.Hash {
+		return nil
+	}
+
+	current.Hash = hash
+
+	return tx.SavePasswordCredential(ctx, &current)
+}
+
+// Signup creates a user with the email address, password and user metadata.
+func (s *Service) Signup(ctx context.Context, email, name, password string, metadata map[string]string) (models.User, error) {
+	hash, err := s.HashPassword(password)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	user := models.User{Email: email, Name: name, UserMetadata: metadata}
+
+	// The hooks run before the lookup, so that a denial does not reveal whether the address is taken.
+	if s.hooks != nil {
+		patch, err := s.hooks.Run(ctx, ports.HookBeforeUserCreated, user)
+		if err != nil {
+			return models.User{}, err
+		}
+
+		patch.Apply(&user)
+	}
+
+	err = s.store.ReadWriteTx(ctx, func(ctx context.Context, tx ports.WriteTx) error {
+		err := tx.GetUserByEmail(ctx, &models.User{Email: email})
+		if err == nil {
+			return ErrEmailInUse
+		}
+
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		user.LastSignedInAt = time.Now()
+		user.ReauthenticatedAt = user.LastSignedInAt
+
+		if err := tx.CreateUser(ctx, &user); err != nil {
+			return err
+		}
+
+		if err := s.SavePassword(ctx, tx, user.ID, hash); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, tx, audit.ActionUserCreated, audit.User(user.ID), audit.Diff(models.User{}, user))
+	})
+	if err != nil {
+		return models.User{}, err
+	}
+
+	return user, nil
+}
+
+// Change replaces the password of the user and revokes the other sessions
+// of the user than the session with the token keep. If the user has a
+// password, the current password has to match. Otherwise the password is added.
+func (s *Service) Change(ctx context.Context, userID uuid.UUID, keep, current, password string) error {
+	hash, err := s.HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	credential := models.PasswordCredential{UserID: userID}
+
+	err = s.store.ReadTx(ctx, func(ctx context.Context, tx ports.ReadTx) error {
+		return tx.GetPasswordCredential(ctx, &credential)
+	})
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	if err == nil {
+		ok, err := Verify(current, credential.Hash)
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			return ErrInvalidCredentials
+		}
+	}
+
+	return s.store.ReadWriteTx(ctx, func(ctx context.Context, tx ports.WriteTx) error {
+		// The password must not have changed since it was checked.
+		latest := models.PasswordCredential{UserID: userID}
+
+		err := tx.GetPasswordCredential(ctx, &latest)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		if latest.Hash != credential.Hash {
+			return ErrInvalidCredentials
+		}
+
+		if err := s.SavePassword(ctx, tx, userID, hash); err != nil {
+			return err
+		}
+
+		var revoked []models.Session
+		if err := tx.DeleteOtherSessions(ctx, &models.Session{UserID: userID, SessionToken: keep}, &revoked); err != nil {
+			return err
+		}
+
+		if err := audit.RecordRevoked(ctx, tx, revoked); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, tx, audit.ActionPasswordChanged, audit.User(userID), nil)
+	})
+}
+
+// HashPassword checks the password against the policy and hashes it.
+// Hashing takes long, so it should not run within a transaction.
+func (s *Service) HashPassword(password string) (string, error) {
+	if err := s.policy.Check(password); err != nil {
+		return "", err
+	}
+
+	return Hash(password, s.params)
+}
+
+// SavePassword sets the hash of HashPassword as the password of the user within the transaction.
+func (s *Service) SavePassword(ctx context.Context, tx ports.WriteTx, userID uuid.UUID, hash string) error {
+	return tx.SavePasswordCredential(ctx, &models.PasswordCredential{UserID: userID, Hash: hash})
+}
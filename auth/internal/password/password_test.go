@@ -0,0 +1,176 @@
+package password
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/open-cloud-initiative/glue/auth/internal/models"
+	"github.com/open-cloud-initiative/glue/auth/internal/ports"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// store keeps users and their passwords in memory.
+type store struct {
+	users       map[string]models.User
+	credentials map[uuid.UUID]models.PasswordCredential
+}
+
+func (s *store) ReadTx(ctx context.Context, fn func(context.Context, ports.ReadTx) error) error {
+	return fn(ctx, &writeTx{store: s})
+}
+
+func (s *store) ReadWriteTx(ctx context.Context, fn func(context.Context, ports.WriteTx) error) error {
+	return fn(ctx, &writeTx{store: s})
+}
+
+func (s *store) Migrate(context.Context, ...any) error {
+	return nil
+}
+
+func (s *store) Close() error {
+	return nil
+}
+
+type writeTx struct {
+	ports.WriteTx
+	store *store
+}
+
+func (t *writeTx) GetUserByEmail(_ context.Context, user *models.User) error {
+	u, ok := t.store.users[user.Email]
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+
+	*user = u
+
+	return nil
+}
+
+func (t *writeTx) UpdateUser(_ context.Context, user *models.User) error {
+	t.store.users[user.Email] = *user
+	return nil
+}
+
+func (t *writeTx) GetPasswordCredential(_ context.Context, credential *models.PasswordCredential) error {
+	c, ok := t.store.credentials[credential.UserID]
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+
+	*credential = c
+
+	return nil
+}
+
+func (t *writeTx) SavePasswordCredential(_ context.Context, credential *models.PasswordCredential) error {
+	t.store.credentials[credential.UserID] = *credential
+	return nil
+}
+
+func newStore(t *testing.T, p Params, user models.User, password string) *store {
+	t.Helper()
+
+	hash, err := Hash(password, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &store{
+		users:       map[string]models.User{user.Email: user},
+		credentials: map[uuid.UUID]models.PasswordCredential{user.ID: {UserID: user.ID, Hash: hash}},
+	}
+}
+
+func TestLogin(t *testing.T) {
+	ada := models.User{ID: uuid.New(), Email: "ada@example.com"}
+	banned := models.User{ID: uuid.New(), Email: "ada@example.com", BannedUntil: time.Now().Add(time.Hour)}
+	upgraded := with(func(p *Params) { p.Iterations = 2 })
+
+	tests := []struct {
+		name     string
+		user     models.User
+		stored   Params
+		params   Params
+		email    string
+		password string
+		err      error
+		rehashed bool
+	}{
+		{name: "valid", user: ada, stored: params, params: params, email: ada.Email, password: "correct horse"},
+		{name: "rehash with new parameters", user: ada, stored: params, params: upgraded, email: ada.Email, password: "correct horse", rehashed: true},
+		{name: "wrong password", user: ada, stored: params, params: upgraded, email: ada.Email, password: "battery staple", err: ErrInvalidCredentials},
+		{name: "unknown user", user: ada, stored: params, params: params, email: "bob@example.com", password: "correct horse", err: ErrInvalidCredentials},
+		{name: "banned user", user: banned, stored: params, params: params, email: ada.Email, password: "correct horse", err: ports.ErrUserBanned},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newStore(t, tt.stored, tt.user, "correct horse")
+			before := s.credentials[tt.user.ID].Hash
+
+			user, err := New(s, WithParams(tt.params)).Login(t.Context(), tt.email, tt.password)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Login() error = %v, want %v", err, tt.err)
+			}
+
+			after := s.credentials[tt.user.ID].Hash
+			if (after != before) != tt.rehashed {
+				t.Errorf("Login() rehashed = %v, want %v", after != before, tt.rehashed)
+			}
+
+			if tt.rehashed && NeedsRehash(after, tt.params) {
+				t.Error("Login() stored a hash with the previous parameters")
+			}
+
+			if tt.err != nil {
+				return
+			}
+
+			if ok, err := Verify(tt.password, after); err != nil || !ok {
+				t.Errorf("Verify() of the stored hash = %v, %v, want true", ok, err)
+			}
+
+			if user.ID != tt.user.ID || user.LastSignedInAt.IsZero() {
+				t.Errorf("Login() = %+v, want the signed in user", user)
+			}
+		})
+	}
+}
+
+func TestHashPassword(t *testing.T) {
+	s := New(&store{}, WithParams(params), WithPolicy(Policy{MinLength: 12, RequireDigit: true}))
+
+	tests := []struct {
+		name     string
+		password string
+		fails    bool
+	}{
+		{name: "valid", password: "correct horse 1"},
+		{name: "too short", password: "horse 1", fails: true},
+		{name: "without digit", password: "correct horse battery", fails: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hash, err := s.HashPassword(tt.password)
+
+			var perr *PolicyError
+			if tt.fails != errors.As(err, &perr) {
+				t.Fatalf("HashPassword() error = %v, want a PolicyError %v", err, tt.fails)
+			}
+
+			if tt.fails {
+				return
+			}
+
+			if ok, err := Verify(tt.password, hash); err != nil || !ok {
+				t.Errorf("Verify() = %v, %v, want true", ok, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package password
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Policy are the rules a new password has to follow.
+type Policy struct {
+	// MinLength is the minimum number of characters.
+	MinLength int
+	// MaxLength is the maximum number of characters, which bounds the cost of hashing.
+	MaxLength int
+	// RequireUpper requires an upper case letter.
+	RequireUpper bool
+	// RequireLower requires a lower case letter.
+	RequireLower bool
+	// RequireDigit requires a digit.
+	RequireDigit bool
+	// RequireSymbol requires a character that is neither a letter nor a digit.
+	RequireSymbol bool
+	// Breached rejects passwords that appeared in a data breach, if it is set.
+	Breached BreachChecker
+}
+
+// DefaultPolicy is the policy used if none is configured.
+var DefaultPolicy = Policy{MinLength: 12, MaxLength: 128}
+
+// PolicyError is returned when a password violates the policy.
+type PolicyError struct {
+	// Violations describe the rules that are violated.
+	Violations []string
+}
+
+// Error implements the error interface.
+func (e *PolicyError) Error() string {
+	return "password " + strings.Join(e.Violations, ", ")
+}
+
+// Check returns a PolicyError if the password violates the policy.
+func (p Policy) Check(password string) error {
+	violations := []string{}
+	n := utf8.RuneCountInString(password)
+
+	if n < p.MinLength {
+		violations = append(violations, "must have at least "+strconv.Itoa(p.MinLength)+" characters")
+	}
+
+	if p.MaxLength > 0 && n > p.MaxLength {
+		violations = append(violations, "must have at most "+strconv.Itoa(p.MaxLength)+" characters")
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case !unicode.IsLetter(r):
+			symbol = true
+		}
+	}
+
+	for _, rule := range []struct {
+		required, ok bool
+		violation    string
+	}{
+		{p.RequireUpper, upper, "must contain an upper case letter"},
+		{p.RequireLower, lower, "must contain a lower case letter"},
+		{p.RequireDigit, digit, "must contain a digit"},
+		{p.RequireSymbol, symbol, "must contain a symbol"},
+	} {
+		if rule.required && !rule.ok {
+			violations = append(violations, rule.violation)
+		}
+	}
+
+	if p.Breached != nil && p.Breached.Breached(password) {
+		violations = append(violations, "appeared in a data breach and must not be used")
+	}
+
+	if len(violations) > 0 {
+		return &PolicyError{Violations: violations}
+	}
+
+	return nil
+}
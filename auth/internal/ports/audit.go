@@ -0,0 +1,25 @@
+package ports
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AuditFilter selects audit events. Zero values match all events.
+type AuditFilter struct {
+	// Action matches events of the action.
+	Action string
+	// ActorID matches events made by the user.
+	ActorID uuid.UUID
+	// UserID matches events that concern the user.
+	UserID uuid.UUID
+	// Since matches events written at or after the time.
+	Since time.Time
+	// Until matches events written before the time.
+	Until time.Time
+	// After matches events after the event with the ID, to page through the log.
+	After int64
+	// Limit is the maximum number of events.
+	Limit int
+}
@@ -2,6 +2,7 @@ package ports
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/open-cloud-initiative/glue/auth/internal/models"
@@ -9,10 +10,38 @@ import (
 	"github.com/google/uuid"
 )
 
+var (
+	// ErrSessionExpired is returned when a session has expired.
+	ErrSessionExpired = errors.New("session expired")
+	// ErrAccountNotLinked is returned when a user with the same email exists,
+	// but the link policy does not allow to link the new account.
+	ErrAccountNotLinked = errors.New("a user with this email exists, sign in and link the account instead")
+	// ErrNoAccount is returned when a profile has no provider account.
+	ErrNoAccount = errors.New("profile has no provider account")
+	// ErrAccountInUse is returned when a provider account is linked to another user.
+	ErrAccountInUse = errors.New("account is linked to another user")
+	// ErrLastLoginMethod is returned when the last login method of a user would be removed.
+	ErrLastLoginMethod = errors.New("the last login method can not be removed")
+	// ErrUserBanned is returned when a banned user authenticates.
+	ErrUserBanned = errors.New("user is banned")
+	// ErrMFARequired is returned when a session requires a second factor.
+	ErrMFARequired = errors.New("mfa required")
+	// ErrReauthenticationRequired is returned when an operation requires a recent login.
+	ErrReauthenticationRequired = errors.New("reauthentication required")
+	// ErrLoginPolicy is returned when a login violates the policy of an organization.
+	ErrLoginPolicy = errors.New("login is not allowed by the policy of the organization")
+)
+
 // Auth defines the authentication port interface.
 type Auth interface {
 	// CreateUser creates a new user.
 	CreateUser(ctx context.Context, user models.User) (models.User, error)
+	// CreateAnonymousUser creates an anonymous user. It becomes a permanent
+	// user once an account, email address or phone number is linked.
+	CreateAnonymousUser(ctx context.Context) (models.User, error)
+	// UpsertUser finds the user by the provider account of the profile and
+	// updates it, or links or creates the user according to the link policy.
+	UpsertUser(ctx context.Context, profile models.User) (models.User, error)
 	// GetUser retrieves a user by ID.
 	GetUser(ctx context.Context, id uuid.UUID) (models.User, error)
 	// GetUserByEmail retrieves a user by email.
@@ -21,12 +50,25 @@ type Auth interface {
 	UpdateUser(ctx context.Context, user models.User) (models.User, error)
 	// DeleteUser deletes a user by ID.
 	DeleteUser(ctx context.Context, id uuid.UUID) error
+	// BanUser bans a user until the given time and revokes all sessions and
+	// provider tokens of the user. Use models.BannedForever to ban indefinitely.
+	BanUser(ctx context.Context, userID uuid.UUID, until time.Time, reason string) (models.User, error)
+	// UnbanUser lifts the ban of a user.
+	UnbanUser(ctx context.Context, userID uuid.UUID) (models.User, error)
+	// LinkUser links the provider account of the profile to an existing user.
+	LinkUser(ctx context.Context, userID uuid.UUID, profile models.User) (models.User, error)
+	// ListAccounts lists the accounts linked to a user.
+	ListAccounts(ctx context.Context, userID uuid.UUID) ([]models.Account, error)
 	// LinkAccount links an account to a user.
 	LinkAccount(ctx context.Context, accountID, userID uuid.UUID) error
-	// UnlinkAccount unlinks an account from a user.
+	// UnlinkAccount unlinks and removes an account from a user.
+	// The last login method of a user can not be unlinked.
 	UnlinkAccount(ctx context.Context, accountID, userID uuid.UUID) error
-	// CreateSession creates a new session.
-	CreateSession(ctx context.Context, userID uuid.UUID, expires time.Time) (models.Session, error)
+	// CreateSession creates a new session of the user logged in with the provider,
+	// e.g. a models.ProviderKey or models.LoginPassword. The session is active in the
+	// organization of the user that captures its email domain, or else in the
+	// first organization whose login policy the session satisfies.
+	CreateSession(ctx context.Context, userID uuid.UUID, provider string, expires time.Time) (models.Session, error)
 	// GetSession retrieves a session by session token.
 	GetSession(ctx context.Context, sessionToken string) (models.Session, error)
 	// UpdateSession updates a session.
@@ -0,0 +1,86 @@
+package ports
+
+import (
+	"context"
+	"errors"
+
+	"github.com/open-cloud-initiative/glue/auth/internal/models"
+)
+
+// HookEvent is the point of the login flow a hook runs at.
+type HookEvent string
+
+const (
+	// HookBeforeUserCreated runs before a user is created, e.g. by a signup or the first login with a provider.
+	// It runs as well before an anonymous user becomes permanent and before the email address of a user changes.
+	HookBeforeUserCreated HookEvent = "before_user_created"
+	// HookBeforeSessionCreated runs before a session is created by a login.
+	HookBeforeSessionCreated HookEvent = "before_session_created"
+)
+
+// ErrHookDenied is returned when a hook denies a signup or login.
+var ErrHookDenied = errors.New("denied by hook")
+
+// HookDeniedError is returned when a hook denies a signup or login.
+type HookDeniedError struct {
+	// Hook is the ID of the hook.
+	Hook string
+	// Reason is shown to the user.
+	Reason string
+}
+
+// Error implements the error interface.
+func (e *HookDeniedError) Error() string {
+	if e.Reason == "" {
+		return ErrHookDenied.Error()
+	}
+
+	return e.Reason
+}
+
+// Is makes errors.Is(err, ErrHookDenied) match.
+func (e *HookDeniedError) Is(target error) bool {
+	return target == ErrHookDenied
+}
+
+// HookPatch are the changes of hooks to the metadata of a user. A nil value removes the key.
+type HookPatch struct {
+	AppMetadata  map[string]*string
+	UserMetadata map[string]*string
+}
+
+// Apply changes the metadata of the user.
+func (p HookPatch) Apply(user *models.User) {
+	user.AppMetadata = patch(user.AppMetadata, p.AppMetadata)
+	user.UserMetadata = patch(user.UserMetadata, p.UserMetadata)
+}
+
+func patch(m map[string]string, changes map[string]*string) map[string]string {
+	if len(changes) == 0 {
+		return m
+	}
+
+	if m == nil {
+		m = map[string]string{}
+	}
+
+	for k, v := range changes {
+		if v == nil {
+			delete(m, k)
+			continue
+		}
+
+		m[k] = *v
+	}
+
+	return m
+}
+
+// Hooks runs custom logic at points of the login flow.
+type Hooks interface {
+	// Run evaluates the hooks of the event for the user and returns their
+	// changes to the metadata of the user. It returns a *HookDeniedError if
+	// a hook denies the event. Hooks may call HTTP endpoints, so Run should
+	// not be called within a transaction.
+	Run(ctx context.Context, event HookEvent, user models.User) (HookPatch, error)
+}
@@ -0,0 +1,21 @@
+package ports
+
+import (
+	"context"
+)
+
+// Mail is an email sent to a user.
+type Mail struct {
+	// To is the address of the recipient.
+	To string
+	// Subject is the subject of the email.
+	Subject string
+	// Text is the plain text body of the email.
+	Text string
+}
+
+// Mailer sends emails.
+type Mailer interface {
+	// Send sends an email.
+	Send(ctx context.Context, mail Mail) error
+}
@@ -2,20 +2,85 @@ package ports
 
 import (
 	"context"
+	"time"
 
 	"github.com/open-cloud-initiative/glue/auth/internal/models"
+
+	"github.com/google/uuid"
 )
 
 // ReadTx is the interface for read-only transactions.
 type ReadTx interface {
 	// GetUser retrieves a user by ID.
 	GetUser(ctx context.Context, user *models.User) error
+	// GetUserByEmail retrieves a user by email.
+	GetUserByEmail(ctx context.Context, user *models.User) error
+	// GetUserByPhone retrieves a user by verified phone number.
+	GetUserByPhone(ctx context.Context, user *models.User) error
+	// ListMFAFactors retrieves the MFA factors of a user into user.MfaFactors.
+	ListMFAFactors(ctx context.Context, user *models.User) error
+	// GetPasswordCredential retrieves the password of a user by user ID.
+	GetPasswordCredential(ctx context.Context, credential *models.PasswordCredential) error
 	// GetAccount retrieves an external account by ID.
 	GetAccount(ctx context.Context, account *models.Account) error
+	// ListAccounts retrieves the accounts of a user into user.Accounts.
+	ListAccounts(ctx context.Context, user *models.User) error
+	// ListAccountsAfter retrieves up to limit accounts, including deleted ones, ordered by ID after the given ID.
+	ListAccountsAfter(ctx context.Context, after uuid.UUID, limit int, accounts *[]models.Account) error
+	// GetAccountByProvider retrieves an external account by tenant, provider and provider account ID.
+	GetAccountByProvider(ctx context.Context, account *models.Account) error
+	// GetUserAccount retrieves the most recently used account of a user with a provider of a tenant.
+	GetUserAccount(ctx context.Context, account *models.Account) error
+	// GetSession retrieves a session by session token.
+	GetSession(ctx context.Context, session *models.Session) error
+	// ListAuditEvents retrieves the audit events matching the filter in the order they were written.
+	ListAuditEvents(ctx context.Context, filter AuditFilter, events *[]models.AuditEvent) error
+	// GetAuditEvent retrieves an audit event by ID.
+	GetAuditEvent(ctx context.Context, event *models.AuditEvent) error
+	// GetLastAuditEvent retrieves the last chained audit event.
+	GetLastAuditEvent(ctx context.Context, event *models.AuditEvent) error
+	// ListChainedAuditEvents retrieves up to limit chained audit events after the position in the chain, in chain order.
+	ListChainedAuditEvents(ctx context.Context, after int64, limit int, events *[]models.AuditEvent) error
+	// ListUnchainedAuditEvents retrieves up to limit audit events that are not chained yet, in the order they were written.
+	ListUnchainedAuditEvents(ctx context.Context, limit int, events *[]models.AuditEvent) error
+	// ListAuditCheckpoints retrieves the checkpoints after the checkpoint with the ID in the order they were written.
+	ListAuditCheckpoints(ctx context.Context, after int64, limit int, checkpoints *[]models.AuditCheckpoint) error
+	// GetLastAuditCheckpoint retrieves the last audit checkpoint.
+	GetLastAuditCheckpoint(ctx context.Context, checkpoint *models.AuditCheckpoint) error
+	// GetOutboxEvent retrieves an outbox event by ID.
+	GetOutboxEvent(ctx context.Context, event *models.OutboxEvent) error
+	// ListWebhookDeliveries retrieves the webhook deliveries matching the filter ordered by ID.
+	ListWebhookDeliveries(ctx context.Context, filter WebhookFilter, deliveries *[]models.WebhookDelivery) error
+	// ListPermissions retrieves all permissions ordered by name.
+	ListPermissions(ctx context.Context, permissions *[]models.Permission) error
+	// GetRoleByName retrieves a role with its permissions by name.
+	GetRoleByName(ctx context.Context, role *models.Role) error
+	// ListRoles retrieves all roles with their permissions ordered by name.
+	ListRoles(ctx context.Context, roles *[]models.Role) error
+	// ListUserRoles retrieves the roles of a user with their permissions ordered by name.
+	ListUserRoles(ctx context.Context, userID uuid.UUID, roles *[]models.Role) error
+	// ListUserRoleAssignments retrieves the role assignments of a user.
+	ListUserRoleAssignments(ctx context.Context, userID uuid.UUID, assignments *[]models.UserRole) error
+	// GetOrganization retrieves an organization with its domains by ID.
+	GetOrganization(ctx context.Context, org *models.Organization) error
+	// GetOrganizationBySlug retrieves an organization with its domains by slug.
+	GetOrganizationBySlug(ctx context.Context, org *models.Organization) error
+	// GetOrganizationByDomain retrieves the organization with its domains that captures the email domain.
+	GetOrganizationByDomain(ctx context.Context, domain string, org *models.Organization) error
+	// ListOrganizations retrieves all organizations with their domains ordered by slug.
+	ListOrganizations(ctx context.Context, orgs *[]models.Organization) error
+	// GetMembership retrieves the membership of a user in an organization with its role.
+	GetMembership(ctx context.Context, membership *models.Membership) error
+	// ListMemberships retrieves the memberships of a user with their organizations and roles in the order they were created.
+	ListMemberships(ctx context.Context, userID uuid.UUID, memberships *[]models.Membership) error
+	// ListMembers retrieves the memberships of an organization with their users and roles in the order they were created.
+	ListMembers(ctx context.Context, orgID uuid.UUID, memberships *[]models.Membership) error
 }
 
 // WriteTx is the interface for read-write transactions.
 type WriteTx interface {
+	ReadTx
+
 	// CreateUser creates a new user.
 	CreateUser(ctx context.Context, user *models.User) error
 	// UpdateUser updates an existing user.
@@ -30,6 +95,103 @@ type WriteTx interface {
 	CreateAccount(ctx context.Context, account *models.Account) error
 	// UpdateAccount updates an existing external account.
 	UpdateAccount(ctx context.Context, account *models.Account) error
+	// LockAccount retrieves an external account by ID and locks it until the transaction ends.
+	LockAccount(ctx context.Context, account *models.Account) error
+	// UpdateAccountTokens rewrites the tokens of an external account.
+	UpdateAccountTokens(ctx context.Context, account *models.Account) error
 	// DeleteAccount deletes an external account by ID.
 	DeleteAccount(ctx context.Context, account *models.Account) error
+	// RevokeAccountTokens removes the provider tokens of all accounts of a user.
+	RevokeAccountTokens(ctx context.Context, user *models.User) error
+	// CreateMFAFactor creates a new MFA factor.
+	CreateMFAFactor(ctx context.Context, factor *models.MFAFactor) error
+	// UpdateMFAFactor updates an existing MFA factor.
+	UpdateMFAFactor(ctx context.Context, factor *models.MFAFactor) error
+	// DeleteMFAFactor deletes an MFA factor of a user by ID.
+	DeleteMFAFactor(ctx context.Context, factor *models.MFAFactor) error
+	// SavePasswordCredential creates or replaces the password of a user.
+	SavePasswordCredential(ctx context.Context, credential *models.PasswordCredential) error
+	// CreateSession creates a new session.
+	CreateSession(ctx context.Context, session *models.Session) error
+	// UpdateSession updates an existing session.
+	UpdateSession(ctx context.Context, session *models.Session) error
+	// DeleteSession deletes a session by session token.
+	DeleteSession(ctx context.Context, session *models.Session) error
+	// DeleteUserSessions deletes all sessions of a user and returns them in sessions.
+	DeleteUserSessions(ctx context.Context, user *models.User, sessions *[]models.Session) error
+	// DeleteOtherSessions deletes all sessions of the user of the session but the session.
+	DeleteOtherSessions(ctx context.Context, session *models.Session, sessions *[]models.Session) error
+	// CreateVerificationToken creates a new verification token.
+	CreateVerificationToken(ctx context.Context, token *models.VerificationToken) error
+	// UseVerificationToken retrieves and deletes a verification token by identifier and token.
+	UseVerificationToken(ctx context.Context, token *models.VerificationToken) error
+	// ConsumeVerificationToken retrieves and deletes an unexpired verification token by token.
+	ConsumeVerificationToken(ctx context.Context, token *models.VerificationToken) error
+	// DeleteVerificationTokens deletes all verification tokens of an identifier.
+	DeleteVerificationTokens(ctx context.Context, identifier string) error
+	// RecordLoginFailure counts a failed attempt of the counter with the subject and IP of attempt
+	// and retrieves the counter. Counters with no failure since the given time start over.
+	RecordLoginFailure(ctx context.Context, attempt *models.LoginAttempt, since time.Time) error
+	// RefundLoginFailure uncounts a failed attempt of the counter with the subject and IP of attempt
+	// and retrieves the counter into attempt. The attempt is left empty if the counter does not exist.
+	RefundLoginFailure(ctx context.Context, attempt *models.LoginAttempt) error
+	// UpdateLoginAttempt updates an existing attempt counter.
+	UpdateLoginAttempt(ctx context.Context, attempt *models.LoginAttempt) error
+	// DeleteSubjectLoginAttempts deletes the attempt counters of the subjects.
+	DeleteSubjectLoginAttempts(ctx context.Context, subjects ...string) error
+	// DeleteIPLoginAttempts deletes the attempt counters of an IP.
+	DeleteIPLoginAttempts(ctx context.Context, ip string) error
+	// LockRateLimit retrieves the state of a rate limit key and locks it until the
+	// transaction ends. A missing key is created with an empty state.
+	LockRateLimit(ctx context.Context, limit *models.RateLimit) error
+	// UpdateRateLimit updates the state of a rate limit key.
+	UpdateRateLimit(ctx context.Context, limit *models.RateLimit) error
+	// LockAuditLog locks the audit log until the transaction ends, so that
+	// events are chained one at a time.
+	LockAuditLog(ctx context.Context) error
+	// ChainAuditEvent sets the position in the chain, the previous hash and the hash of an audit event.
+	ChainAuditEvent(ctx context.Context, event *models.AuditEvent) error
+	// CreateAuditEvent appends an audit event.
+	CreateAuditEvent(ctx context.Context, event *models.AuditEvent) error
+	// CreateAuditCheckpoint appends an audit checkpoint.
+	CreateAuditCheckpoint(ctx context.Context, checkpoint *models.AuditCheckpoint) error
+	// CreateOutboxEvent appends an outbox event.
+	CreateOutboxEvent(ctx context.Context, event *models.OutboxEvent) error
+	// LockUndispatchedOutboxEvents retrieves up to limit outbox events without deliveries
+	// ordered by ID, and locks them until the transaction ends. Events locked by
+	// other transactions are skipped.
+	LockUndispatchedOutboxEvents(ctx context.Context, limit int, events *[]models.OutboxEvent) error
+	// UpdateOutboxEvent updates an existing outbox event.
+	UpdateOutboxEvent(ctx context.Context, event *models.OutboxEvent) error
+	// CreateWebhookDelivery creates a webhook delivery, unless the event already has a delivery to the endpoint.
+	CreateWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) error
+	// LockDueWebhookDeliveries retrieves up to limit pending deliveries due at now and
+	// locks them until the transaction ends. Deliveries locked by other transactions are skipped.
+	LockDueWebhookDeliveries(ctx context.Context, now time.Time, limit int, deliveries *[]models.WebhookDelivery) error
+	// UpdateWebhookDelivery updates an existing webhook delivery.
+	UpdateWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) error
+	// SavePermission creates a permission or updates its description.
+	SavePermission(ctx context.Context, permission *models.Permission) error
+	// CreateRole creates a role with its permissions.
+	CreateRole(ctx context.Context, role *models.Role) error
+	// UpdateRole updates a role and replaces its permissions.
+	UpdateRole(ctx context.Context, role *models.Role) error
+	// DeleteRole deletes a role by ID together with its assignments.
+	DeleteRole(ctx context.Context, role *models.Role) error
+	// AssignRole assigns a role to a user, unless the user already has the role.
+	AssignRole(ctx context.Context, assignment *models.UserRole) error
+	// UnassignRole removes a role from a user.
+	UnassignRole(ctx context.Context, assignment *models.UserRole) error
+	// CreateOrganization creates an organization with its domains.
+	CreateOrganization(ctx context.Context, org *models.Organization) error
+	// UpdateOrganization updates an organization and replaces its domains.
+	UpdateOrganization(ctx context.Context, org *models.Organization) error
+	// DeleteOrganization deletes an organization by ID together with its domains and memberships.
+	DeleteOrganization(ctx context.Context, org *models.Organization) error
+	// CreateMembership creates a membership.
+	CreateMembership(ctx context.Context, membership *models.Membership) error
+	// UpdateMembership updates the role of a membership.
+	UpdateMembership(ctx context.Context, membership *models.Membership) error
+	// DeleteMembership deletes a membership and leaves the organization in the sessions of the user.
+	DeleteMembership(ctx context.Context, membership *models.Membership) error
 }
@@ -0,0 +1,11 @@
+package ports
+
+import (
+	"context"
+)
+
+// SMSSender sends text messages.
+type SMSSender interface {
+	// SendSMS sends a text message to a phone number in E.164 format.
+	SendSMS(ctx context.Context, to, body string) error
+}
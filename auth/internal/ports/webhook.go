@@ -0,0 +1,17 @@
+package ports
+
+import "github.com/open-cloud-initiative/glue/auth/internal/models"
+
+// WebhookFilter selects webhook deliveries. Zero values match all deliveries.
+type WebhookFilter struct {
+	// EventID matches deliveries of the outbox event.
+	EventID int64
+	// Endpoint matches deliveries to the endpoint.
+	Endpoint string
+	// Status matches deliveries in the state.
+	Status models.WebhookStatus
+	// After matches deliveries after the delivery with the ID, to page through them.
+	After int64
+	// Limit is the maximum number of deliveries.
+	Limit int
+}
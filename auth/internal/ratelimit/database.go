@@ -0,0 +1,63 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/open-cloud-initiative/glue/auth/internal/models"
+	"github.com/open-cloud-initiative/glue/auth/internal/ports"
+
+	"github.com/katallaxie/pkg/dbx"
+)
+
+var _ Limiter = (*Database)(nil)
+
+// Database is a limiter that keeps its state in the database, so that all
+// replicas of a cluster share the counts. Each action runs a transaction that
+// upserts and locks the row of its key, so actions of the same key serialize:
+// a hot key, e.g. the IP of a busy NAT or proxy, allows about one action per
+// transaction round trip, while distinct keys do not contend. Limits with hot
+// keys and a high rate are better kept in memory per replica.
+type Database struct {
+	store     dbx.Database[ports.ReadTx, ports.WriteTx]
+	name      string
+	algorithm Algorithm
+	limit     int
+	window    time.Duration
+}
+
+// NewDatabase returns a new database limiter that allows limit actions per key and window.
+// The name separates the keys of limiters that share the database.
+func NewDatabase(store dbx.Database[ports.ReadTx, ports.WriteTx], name string, algorithm Algorithm, limit int, window time.Duration) *Database {
+	return &Database{
+		store:     store,
+		name:      name,
+		algorithm: algorithm,
+		limit:     limit,
+		window:    window,
+	}
+}
+
+// Allow records an action for the key and returns if it is allowed.
+// Denied actions are not counted.
+func (l *Database) Allow(ctx context.Context, key string) (Result, error) {
+	var res Result
+
+	err := l.store.ReadWriteTx(ctx, func(ctx context.Context, tx ports.WriteTx) error {
+		row := models.RateLimit{Key: l.name + ":" + key}
+		if err := tx.LockRateLimit(ctx, &row); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		s := state{Start: row.Start, Current: row.Current, Previous: row.Previous}
+		res = l.algorithm.take(&s, now, l.limit, l.window)
+
+		row.Start, row.Current, row.Previous = s.Start, s.Current, s.Previous
+		row.ExpiresAt = s.Start.Add(2 * l.window)
+
+		return tx.UpdateRateLimit(ctx, &row)
+	})
+
+	return res, err
+}
@@ -0,0 +1,76 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+var _ Limiter = (*Memory)(nil)
+
+// Memory is a limiter that keeps its state in memory. It fits a single
+// instance, replicas of a cluster count separately.
+type Memory struct {
+	algorithm Algorithm
+	limit     int
+	window    time.Duration
+	now       func() time.Time
+
+	mu     sync.Mutex
+	states map[string]*state
+	swept  time.Time
+}
+
+// NewMemory returns a new in-memory limiter that allows limit actions per key and window.
+func NewMemory(algorithm Algorithm, limit int, window time.Duration) *Memory {
+	return &Memory{
+		algorithm: algorithm,
+		limit:     limit,
+		window:    window,
+		now:       time.Now,
+		states:    map[string]*state{},
+	}
+}
+
+// NewSlidingWindow returns a new in-memory sliding window limiter.
+func NewSlidingWindow(limit int, window time.Duration) *Memory {
+	return NewMemory(AlgorithmSlidingWindow, limit, window)
+}
+
+// NewTokenBucket returns a new in-memory token bucket limiter.
+func NewTokenBucket(limit int, window time.Duration) *Memory {
+	return NewMemory(AlgorithmTokenBucket, limit, window)
+}
+
+// Allow records an action for the key and returns if it is allowed.
+// Denied actions are not counted.
+func (l *Memory) Allow(_ context.Context, key string) (Result, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	s, ok := l.states[key]
+	if !ok {
+		s = &state{}
+		l.states[key] = s
+	}
+
+	return l.algorithm.take(s, now, l.limit, l.window), nil
+}
+
+// sweep removes the states of idle keys, at most once per window.
+func (l *Memory) sweep(now time.Time) {
+	if now.Sub(l.swept) < l.window {
+		return
+	}
+
+	l.swept = now
+
+	for key, s := range l.states {
+		if idle(s, now, l.window) {
+			delete(l.states, key)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+// Package ratelimit limits how often an action is performed per key,
+// e.g. per phone number or per client IP.
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// Result is the outcome of a rate limit check.
+type Result struct {
+	// Allowed is true if the action is allowed.
+	Allowed bool
+	// Limit is the number of actions allowed per window.
+	Limit int
+	// Remaining is the number of actions left in the current window.
+	Remaining int
+	// Reset is the time until the limit is fully restored.
+	Reset time.Duration
+	// RetryAfter is the time until the next action is allowed, if it is denied.
+	RetryAfter time.Duration
+}
+
+// Limiter limits how often an action is performed per key.
+type Limiter interface {
+	// Allow records an action for the key and returns if it is allowed.
+	Allow(ctx context.Context, key string) (Result, error)
+}
+
+// Algorithm is the algorithm a limiter counts actions with.
+type Algorithm string
+
+const (
+	// AlgorithmSlidingWindow allows limit actions in any window. It approximates
+	// the sliding window with the counts of the current and the previous fixed window.
+	AlgorithmSlidingWindow Algorithm = "sliding-window"
+	// AlgorithmTokenBucket allows bursts of limit actions and refills limit tokens per window.
+	AlgorithmTokenBucket Algorithm = "token-bucket"
+)
+
+// state is the state of a key. Its fields depend on the algorithm.
+type state struct {
+	// Start is the start of the current window, or the time the tokens were refilled.
+	Start time.Time
+	// Current is the count of the current window, or the tokens left.
+	Current float64
+	// Previous is the count of the previous window.
+	Previous float64
+}
+
+// take records an action in the state and returns if it is allowed.
+// Denied actions are not counted.
+func (a Algorithm) take(s *state, now time.Time, limit int, window time.Duration) Result {
+	if a == AlgorithmTokenBucket {
+		return refill(s, now, limit, window)
+	}
+
+	return slide(s, now, limit, window)
+}
+
+// idle returns true if the state of a key no longer affects actions.
+func idle(s *state, now time.Time, window time.Duration) bool {
+	return now.Sub(s.Start) >= 2*window
+}
+
+func slide(s *state, now time.Time, limit int, window time.Duration) Result {
+	start := now.Truncate(window)
+
+	switch {
+	case start.Equal(s.Start):
+	case start.Sub(s.Start) == window:
+		s.Previous, s.Current = s.Current, 0
+		s.Start = start
+	default:
+		s.Previous, s.Current = 0, 0
+		s.Start = start
+	}
+
+	elapsed := now.Sub(s.Start)
+	weight := float64(window-elapsed) / float64(window)
+	count := int(s.Previous*weight) + int(s.Current)
+
+	res := Result{Limit: limit, Reset: window - elapsed}
+
+	if count >= limit {
+		res.RetryAfter = slideRetryAfter(s, elapsed, limit, window)
+		return res
+	}
+
+	s.Current++
+
+	res.Allowed = true
+	res.Remaining = limit - count - 1
+
+	if s.Previous > 0 {
+		res.Reset += window
+	}
+
+	return res
+}
+
+// slideRetryAfter estimates when the weighted count of the previous
+// window has decayed enough to allow another action.
+func slideRetryAfter(s *state, elapsed time.Duration, limit int, window time.Duration) time.Duration {
+	if int(s.Current) >= limit || s.Previous == 0 {
+		return window - elapsed
+	}
+
+	// The weighted previous count must drop to limit - current - 1.
+	allowed := float64(limit) - s.Current - 1
+	at := time.Duration((1 - allowed/s.Previous) * float64(window))
+
+	return max(at-elapsed, time.Second)
+}
+
+func refill(s *state, now time.Time, limit int, window time.Duration) Result {
+	rate := float64(limit) / float64(window)
+
+	if s.Start.IsZero() {
+		s.Current = float64(limit)
+	} else {
+		s.Current = min(float64(limit), s.Current+float64(now.Sub(s.Start))*rate)
+	}
+
+	s.Start = now
+
+	res := Result{Limit: limit}
+
+	if s.Current < 1 {
+		res.RetryAfter = time.Duration((1 - s.Current) / rate)
+	} else {
+		s.Current--
+		res.Allowed = true
+		res.Remaining = int(s.Current)
+	}
+
+	res.Reset = time.Duration((float64(limit) - s.Current) / rate)
+
+	return res
+}
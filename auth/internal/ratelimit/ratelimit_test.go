@@ -0,0 +1,177 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type step struct {
+	at         time.Duration
+	allowed    bool
+	remaining  int
+	retryAfter time.Duration
+}
+
+var epoch = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// near compares durations computed with floats.
+func near(a, b time.Duration) bool {
+	return (a - b).Abs() < time.Millisecond
+}
+
+func run(t *testing.T, algorithm Algorithm, limit int, window time.Duration, steps []step) {
+	t.Helper()
+
+	s := &state{}
+
+	for i, st := range steps {
+		res := algorithm.take(s, epoch.Add(st.at), limit, window)
+
+		if res.Allowed != st.allowed {
+			t.Fatalf("step %d at %s: allowed = %v, want %v", i, st.at, res.Allowed, st.allowed)
+		}
+
+		if res.Limit != limit {
+			t.Errorf("step %d at %s: limit = %d, want %d", i, st.at, res.Limit, limit)
+		}
+
+		if st.allowed && res.Remaining != st.remaining {
+			t.Errorf("step %d at %s: remaining = %d, want %d", i, st.at, res.Remaining, st.remaining)
+		}
+
+		if !st.allowed && !near(res.RetryAfter, st.retryAfter) {
+			t.Errorf("step %d at %s: retry after = %s, want %s", i, st.at, res.RetryAfter, st.retryAfter)
+		}
+	}
+}
+
+func TestSlide(t *testing.T) {
+	tests := []struct {
+		name  string
+		limit int
+		steps []step
+	}{
+		{
+			name:  "within a window",
+			limit: 4,
+			steps: []step{
+				{at: 0, allowed: true, remaining: 3},
+				{at: 10 * time.Second, allowed: true, remaining: 2},
+				{at: 20 * time.Second, allowed: true, remaining: 1},
+				{at: 30 * time.Second, allowed: true, remaining: 0},
+				{at: 40 * time.Second, allowed: false, retryAfter: 20 * time.Second},
+			},
+		},
+		{
+			name:  "weighs the previous window",
+			limit: 4,
+			steps: []step{
+				{at: 0, allowed: true, remaining: 3},
+				{at: 0, allowed: true, remaining: 2},
+				{at: 0, allowed: true, remaining: 1},
+				{at: 0, allowed: true, remaining: 0},
+				// 3 of the previous 4 count with 45s of the window left.
+				{at: 75 * time.Second, allowed: true, remaining: 0},
+				{at: 75 * time.Second, allowed: false, retryAfter: 15 * time.Second},
+				{at: 90 * time.Second, allowed: true, remaining: 0},
+				// The 2 of the previous window count half.
+				{at: 150 * time.Second, allowed: true, remaining: 2},
+			},
+		},
+		{
+			name:  "starts over after an idle window",
+			limit: 2,
+			steps: []step{
+				{at: 0, allowed: true, remaining: 1},
+				{at: 0, allowed: true, remaining: 0},
+				{at: 0, allowed: false, retryAfter: time.Minute},
+				{at: 150 * time.Second, allowed: true, remaining: 1},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			run(t, AlgorithmSlidingWindow, tt.limit, time.Minute, tt.steps)
+		})
+	}
+}
+
+func TestRefill(t *testing.T) {
+	tests := []struct {
+		name  string
+		limit int
+		steps []step
+	}{
+		{
+			name:  "allows a burst",
+			limit: 3,
+			steps: []step{
+				{at: 0, allowed: true, remaining: 2},
+				{at: 0, allowed: true, remaining: 1},
+				{at: 0, allowed: true, remaining: 0},
+				{at: 0, allowed: false, retryAfter: 20 * time.Second},
+			},
+		},
+		{
+			name:  "refills over the window",
+			limit: 2,
+			steps: []step{
+				{at: 0, allowed: true, remaining: 1},
+				{at: 0, allowed: true, remaining: 0},
+				{at: 15 * time.Second, allowed: false, retryAfter: 15 * time.Second},
+				{at: 31 * time.Second, allowed: true, remaining: 0},
+			},
+		},
+		{
+			name:  "refills up to the limit",
+			limit: 2,
+			steps: []step{
+				{at: 0, allowed: true, remaining: 1},
+				{at: time.Hour, allowed: true, remaining: 1},
+				{at: time.Hour, allowed: true, remaining: 0},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			run(t, AlgorithmTokenBucket, tt.limit, time.Minute, tt.steps)
+		})
+	}
+}
+
+func TestMemory(t *testing.T) {
+	now := epoch
+
+	l := NewSlidingWindow(1, time.Minute)
+	l.now = func() time.Time { return now }
+
+	allow := func(key string, want bool) {
+		t.Helper()
+
+		res, err := l.Allow(context.Background(), key)
+		if err != nil {
+			t.Fatalf("Allow(%q) = %v", key, err)
+		}
+
+		if res.Allowed != want {
+			t.Fatalf("Allow(%q) at %s: allowed = %v, want %v", key, now.Sub(epoch), res.Allowed, want)
+		}
+	}
+
+	allow("a", true)
+	allow("a", false)
+	allow("b", true)
+
+	now = now.Add(2 * time.Minute)
+	allow("c", true)
+
+	if len(l.states) != 1 {
+		t.Errorf("states = %d after the idle keys are swept, want 1", len(l.states))
+	}
+
+	allow("a", true)
+	allow("c", false)
+}
@@ -0,0 +1,521 @@
+// Package rbac authorizes users with roles and permissions.
+//
+// A permission is an action on a kind of resource, e.g. users:write. Roles
+// are named sets of permissions that are assigned to users, by an admin or by
+// the role mappings of a provider. The built-in super-admin role grants all
+// permissions, it is created at bootstrap.
+//
+// Members of an organization have a role within the organization, whose
+// permissions apply to the organization only. The built-in org-admin and
+// org-member roles are created at bootstrap as well.
+package rbac
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"time"
+
+	"github.com/open-cloud-initiative/glue/auth/internal/audit"
+	"github.com/open-cloud-initiative/glue/auth/internal/models"
+	"github.com/open-cloud-initiative/glue/auth/internal/ports"
+
+	"github.com/google/uuid"
+	"github.com/katallaxie/pkg/dbx"
+	"gorm.io/gorm"
+)
+
+// Built-in roles.
+const (
+	// SuperAdmin grants all permissions.
+	SuperAdmin = "super-admin"
+	// OrgAdmin manages an organization and its members.
+	OrgAdmin = "org-admin"
+	// OrgMember reads the members of an organization.
+	OrgMember = "org-member"
+)
+
+// Permissions.
+const (
+	// All grants all permissions.
+	All           = "*"
+	UsersRead     = "users:read"
+	UsersWrite    = "users:write"
+	LockoutsWrite = "lockouts:write"
+	BreachWrite   = "breach:write"
+	AuditRead     = "audit:read"
+	WebhooksRead  = "webhooks:read"
+	WebhooksWrite = "webhooks:write"
+	RolesRead     = "roles:read"
+	RolesWrite    = "roles:write"
+	OrgsRead      = "orgs:read"
+	OrgsWrite     = "orgs:write"
+	// Permissions within an organization.
+	OrgWrite     = "org:write"
+	MembersRead  = "members:read"
+	MembersWrite = "members:write"
+)
+
+// OrgPermissions are the permissions that apply within an organization.
+var OrgPermissions = []string{OrgWrite, MembersRead, MembersWrite}
+
+// Permissions are all permissions, they are saved at bootstrap.
+var Permissions = []models.Permission{
+	{Name: All, Description: "All permissions"},
+	{Name: UsersRead, Description: "Read users"},
+	{Name: UsersWrite, Description: "Ban and unban users"},
+	{Name: LockoutsWrite, Description: "Unlock users and IPs locked out after failed logins"},
+	{Name: BreachWrite, Description: "Reload the breached password corpus"},
+	{Name: AuditRead, Description: "Read and export the audit log"},
+	{Name: WebhooksRead, Description: "Read webhook deliveries"},
+	{Name: WebhooksWrite, Description: "Replay and retry webhook deliveries"},
+	{Name: RolesRead, Description: "Read roles and the roles of users"},
+	{Name: RolesWrite, Description: "Change roles and assign them to users"},
+	{Name: OrgsRead, Description: "Read all organizations and their members"},
+	{Name: OrgsWrite, Description: "Change all organizations, their domains and members"},
+	{Name: OrgWrite, Description: "Change the name and login policy of the organization"},
+	{Name: MembersRead, Description: "Read the members of the organization"},
+	{Name: MembersWrite, Description: "Invite, change and remove members of the organization"},
+}
+
+// builtIn are the roles created at bootstrap.
+var builtIn = []models.Role{
+	{
+		Name:        SuperAdmin,
+		Description: "Grants all permissions",
+		Permissions: []models.RolePermission{{Permission: All}},
+	},
+	{
+		Name:        OrgAdmin,
+		Description: "Manages an organization and its members",
+		Permissions: []models.RolePermission{{Permission: OrgWrite}, {Permission: MembersRead}, {Permission: MembersWrite}},
+	},
+	{
+		Name:        OrgMember,
+		Description: "Reads the members of an organization",
+		Permissions: []models.RolePermission{{Permission: MembersRead}},
+	},
+}
+
+var (
+	// ErrPermissionDenied is returned when a user lacks a permission.
+	ErrPermissionDenied = errors.New("permission denied")
+	// ErrBuiltInRole is returned when a built-in role would be changed or deleted.
+	ErrBuiltInRole = errors.New("built-in roles can not be changed")
+	// ErrRoleExists is returned when a role with the name exists.
+	ErrRoleExists = errors.New("a role with this name exists")
+	// ErrInvalidRoleName is returned when the name of a role is invalid.
+	ErrInvalidRoleName = errors.New("role names must be lowercase letters, digits, '-', '_' and '.'")
+	// ErrUnknownPermission is returned when a role would grant an unknown permission.
+	ErrUnknownPermission = errors.New("unknown permission")
+	// ErrAllPermissions is returned when a role other than super-admin would grant all permissions.
+	ErrAllPermissions = errors.New("only the built-in super-admin role grants all permissions")
+)
+
+var roleName = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{0,62}$`)
+
+// Granted returns true if the granted permissions include the permission.
+func Granted(granted []string, permission string) bool {
+	return slices.Contains(granted, All) || slices.Contains(granted, permission)
+}
+
+// Holds returns ErrPermissionDenied unless the granted permissions include all permissions of the roles.
+func Holds(granted []string, roles ...models.Role) error {
+	for _, r := range roles {
+		for _, p := range r.PermissionNames() {
+			if !Granted(granted, p) {
+				return fmt.Errorf("%w: %s", ErrPermissionDenied, p)
+			}
+		}
+	}
+
+	return nil
+}
+
+// OrgScoped returns true if the role only grants permissions within an organization.
+func OrgScoped(role models.Role) bool {
+	return !slices.ContainsFunc(role.PermissionNames(), func(p string) bool { return !slices.Contains(OrgPermissions, p) })
+}
+
+// Union returns the sorted permissions granted by the roles.
+func Union(roles []models.Role) []string {
+	permissions := []string{}
+
+	for _, r := range roles {
+		for _, p := range r.PermissionNames() {
+			if !slices.Contains(permissions, p) {
+				permissions = append(permissions, p)
+			}
+		}
+	}
+
+	slices.Sort(permissions)
+
+	return permissions
+}
+
+// Internal is the caller of calls with the internal token, which holds all permissions.
+var Internal = uuid.Nil
+
+// Service manages roles and checks the permissions of users.
+type Service struct {
+	store dbx.Database[ports.ReadTx, ports.WriteTx]
+}
+
+// New returns a new Service.
+func New(store dbx.Database[ports.ReadTx, ports.WriteTx]) *Service {
+	return &Service{store: store}
+}
+
+// Bootstrap saves the permissions and the built-in roles.
+func (s *Service) Bootstrap(ctx context.Context) error {
+	return s.store.ReadWriteTx(ctx, func(ctx context.Context, tx ports.WriteTx) error {
+		for _, p := range Permissions {
+			if err := tx.SavePermission(ctx, &p); err != nil {
+				return err
+			}
+		}
+
+		for _, r := range builtIn {
+			role := models.Role{Name: r.Name}
+
+			err := tx.GetRoleByName(ctx, &role)
+			if err == nil {
+				continue
+			}
+
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+
+			role = models.Role{
+				Name:        r.Name,
+				Description: r.Description,
+				BuiltIn:     true,
+				Permissions: slices.Clone(r.Permissions),
+			}
+
+			if err := tx.CreateRole(ctx, &role); err != nil {
+				return err
+			}
+
+			if err := audit.Record(ctx, tx, audit.ActionRoleCreated, audit.Role(role), audit.Diff(models.Role{}, role)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Permissions returns the permissions of the user, granted by all of its roles.
+func (s *Service) Permissions(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	roles, err := s.UserRoles(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return Union(roles), nil
+}
+
+// Check returns ErrPermissionDenied unless a role of the user grants the permission.
+func (s *Service) Check(ctx context.Context, userID uuid.UUID, permission string) error {
+	permissions, err := s.Permissions(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if !Granted(permissions, permission) {
+		return fmt.Errorf("%w: %s", ErrPermissionDenied, permission)
+	}
+
+	return nil
+}
+
+// Catalog returns all permissions.
+func (s *Service) Catalog(ctx context.Context) ([]models.Permission, error) {
+	permissions := []models.Permission{}
+
+	err := s.store.ReadTx(ctx, func(ctx context.Context, tx ports.ReadTx) error {
+		return tx.ListPermissions(ctx, &permissions)
+	})
+
+	return permissions, err
+}
+
+// Roles returns all roles.
+func (s *Service) Roles(ctx context.Context) ([]models.Role, error) {
+	roles := []models.Role{}
+
+	err := s.store.ReadTx(ctx, func(ctx context.Context, tx ports.ReadTx) error {
+		return tx.ListRoles(ctx, &roles)
+	})
+
+	return roles, err
+}
+
+// UserRoles returns the roles of the user.
+func (s *Service) UserRoles(ctx context.Context, userID uuid.UUID) ([]models.Role, error) {
+	roles := []models.Role{}
+
+	err := s.store.ReadTx(ctx, func(ctx context.Context, tx ports.ReadTx) error {
+		return tx.ListUserRoles(ctx, userID, &roles)
+	})
+
+	return roles, err
+}
+
+// CreateRole creates a role that grants the permissions. The caller must hold the permissions.
+func (s *Service) CreateRole(ctx context.Context, caller uuid.UUID, name, description string, permissions []string) (models.Role, error) {
+	if !roleName.MatchString(name) {
+		return models.Role{}, ErrInvalidRoleName
+	}
+
+	role := models.Role{Name: name}
+
+	err := s.store.ReadWriteTx(ctx, func(ctx context.Context, tx ports.WriteTx) error {
+		err := tx.GetRoleByName(ctx, &role)
+		if err == nil {
+			return ErrRoleExists
+		}
+
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		role = models.Role{Name: name, Description: description}
+		if role.Permissions, err = grants(ctx, tx, permissions); err != nil {
+			return err
+		}
+
+		if err := holds(ctx, tx, caller, role); err != nil {
+			return err
+		}
+
+		if err := tx.CreateRole(ctx, &role); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, tx, audit.ActionRoleCreated, audit.Role(role), audit.Diff(models.Role{}, role))
+	})
+
+	return role, err
+}
+
+// UpdateRole replaces the description and permissions of a role. The caller
+// must hold the permissions the role grants before and after the change.
+func (s *Service) UpdateRole(ctx context.Context, caller uuid.UUID, name, description string, permissions []string) (models.Role, error) {
+	role := models.Role{Name: name}
+
+	err := s.store.ReadWriteTx(ctx, func(ctx context.Context, tx ports.WriteTx) error {
+		if err := tx.GetRoleByName(ctx, &role); err != nil {
+			return err
+		}
+
+		if role.BuiltIn {
+			return ErrBuiltInRole
+		}
+
+		before := role
+
+		var err error
+		if role.Permissions, err = grants(ctx, tx, permissions); err != nil {
+			return err
+		}
+
+		if err := holds(ctx, tx, caller, before, role); err != nil {
+			return err
+		}
+
+		role.Description = description
+
+		if err := tx.UpdateRole(ctx, &role); err != nil {
+			return err
+		}
+
+		return audit.RecordChange(ctx, tx, audit.ActionRoleUpdated, audit.Role(role), before, role)
+	})
+
+	return role, err
+}
+
+// DeleteRole deletes a role and removes it from all users. The caller must
+// hold the permissions of the role.
+func (s *Service) DeleteRole(ctx context.Context, caller uuid.UUID, name string) error {
+	return s.store.ReadWriteTx(ctx, func(ctx context.Context, tx ports.WriteTx) error {
+		role := models.Role{Name: name}
+		if err := tx.GetRoleByName(ctx, &role); err != nil {
+			return err
+		}
+
+		if role.BuiltIn {
+			return ErrBuiltInRole
+		}
+
+		if err := holds(ctx, tx, caller, role); err != nil {
+			return err
+		}
+
+		if err := tx.DeleteRole(ctx, &role); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, tx, audit.ActionRoleDeleted, audit.Role(role), audit.Diff(role, models.Role{}))
+	})
+}
+
+// Assign assigns the role to the user. The caller must hold the permissions
+// of the role, so only super-admins assign super-admin.
+func (s *Service) Assign(ctx context.Context, caller, userID uuid.UUID, name string) error {
+	return s.store.ReadWriteTx(ctx, func(ctx context.Context, tx ports.WriteTx) error {
+		if err := tx.GetUser(ctx, &models.User{ID: userID}); err != nil {
+			return err
+		}
+
+		role := models.Role{Name: name}
+		if err := tx.GetRoleByName(ctx, &role); err != nil {
+			return err
+		}
+
+		if err := holds(ctx, tx, caller, role); err != nil {
+			return err
+		}
+
+		return Assign(ctx, tx, userID, role, "")
+	})
+}
+
+// Unassign removes the role from the user. The caller must hold the permissions of the role.
+func (s *Service) Unassign(ctx context.Context, caller, userID uuid.UUID, name string) error {
+	return s.store.ReadWriteTx(ctx, func(ctx context.Context, tx ports.WriteTx) error {
+		role := models.Role{Name: name}
+		if err := tx.GetRoleByName(ctx, &role); err != nil {
+			return err
+		}
+
+		if err := holds(ctx, tx, caller, role); err != nil {
+			return err
+		}
+
+		return Unassign(ctx, tx, userID, role)
+	})
+}
+
+// Assign assigns the role to the user in the transaction, unless the user
+// has the role. The source is the provider that manages the assignment.
+func Assign(ctx context.Context, tx ports.WriteTx, userID uuid.UUID, role models.Role, source string) error {
+	assignments := []models.UserRole{}
+	if err := tx.ListUserRoleAssignments(ctx, userID, &assignments); err != nil {
+		return err
+	}
+
+	if slices.ContainsFunc(assignments, func(a models.UserRole) bool { return a.RoleID == role.ID }) {
+		return nil
+	}
+
+	assignment := models.UserRole{UserID: userID, RoleID: role.ID, Source: source, CreatedAt: time.Now()}
+	if err := tx.AssignRole(ctx, &assignment); err != nil {
+		return err
+	}
+
+	return audit.Record(ctx, tx, audit.ActionRoleAssigned, audit.Assignment(assignment),
+		map[string]models.AuditChange{"role": {To: role.Name}})
+}
+
+// Unassign removes the role from the user in the transaction.
+func Unassign(ctx context.Context, tx ports.WriteTx, userID uuid.UUID, role models.Role) error {
+	assignment := models.UserRole{UserID: userID, RoleID: role.ID}
+	if err := tx.UnassignRole(ctx, &assignment); err != nil {
+		return err
+	}
+
+	return audit.Record(ctx, tx, audit.ActionRoleUnassigned, audit.Assignment(assignment),
+		map[string]models.AuditChange{"role": {From: role.Name}})
+}
+
+// Sync makes the roles with the names the roles of the user managed by
+// the source. Roles assigned by an admin are kept, unknown roles are skipped.
+func Sync(ctx context.Context, tx ports.WriteTx, userID uuid.UUID, source string, names []string) error {
+	assignments := []models.UserRole{}
+	if err := tx.ListUserRoleAssignments(ctx, userID, &assignments); err != nil {
+		return err
+	}
+
+	roles := []models.Role{}
+	if err := tx.ListUserRoles(ctx, userID, &roles); err != nil {
+		return err
+	}
+
+	for _, role := range roles {
+		i := slices.IndexFunc(assignments, func(a models.UserRole) bool { return a.RoleID == role.ID })
+		if i < 0 || assignments[i].Source != source || slices.Contains(names, role.Name) {
+			continue
+		}
+
+		if err := Unassign(ctx, tx, userID, role); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range names {
+		role := models.Role{Name: name}
+
+		err := tx.GetRoleByName(ctx, &role)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if err := Assign(ctx, tx, userID, role, source); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// holds returns ErrPermissionDenied unless the caller holds all permissions of the roles.
+func holds(ctx context.Context, tx ports.ReadTx, caller uuid.UUID, roles ...models.Role) error {
+	if caller == Internal {
+		return nil
+	}
+
+	held := []models.Role{}
+	if err := tx.ListUserRoles(ctx, caller, &held); err != nil {
+		return err
+	}
+
+	return Holds(Union(held), roles...)
+}
+
+// grants returns the role permissions of the names, which must be known
+// permissions other than All, which only the built-in super-admin grants.
+func grants(ctx context.Context, tx ports.ReadTx, names []string) ([]models.RolePermission, error) {
+	known := []models.Permission{}
+	if err := tx.ListPermissions(ctx, &known); err != nil {
+		return nil, err
+	}
+
+	grants := []models.RolePermission{}
+
+	for _, name := range names {
+		if name == All {
+			return nil, ErrAllPermissions
+		}
+
+		if !slices.ContainsFunc(known, func(p models.Permission) bool { return p.Name == name }) {
+			return nil, fmt.Errorf("%w %q", ErrUnknownPermission, name)
+		}
+
+		if !slices.ContainsFunc(grants, func(g models.RolePermission) bool { return g.Permission == name }) {
+			grants = append(grants, models.RolePermission{Permission: name})
+		}
+	}
+
+	return grants, nil
+}
@@ -0,0 +1,185 @@
+package rbac
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/open-cloud-initiative/glue/auth/internal/models"
+	"github.com/open-cloud-initiative/glue/auth/internal/ports"
+
+	"github.com/google/uuid"
+)
+
+func role(name string, permissions ...string) models.Role {
+	r := models.Role{Name: name}
+	for _, p := range permissions {
+		r.Permissions = append(r.Permissions, models.RolePermission{Permission: p})
+	}
+
+	return r
+}
+
+// readTx serves the permissions and the roles of users from memory.
+type readTx struct {
+	ports.ReadTx
+	roles map[uuid.UUID][]models.Role
+}
+
+func (tx *readTx) ListPermissions(_ context.Context, permissions *[]models.Permission) error {
+	*permissions = append(*permissions, Permissions...)
+	return nil
+}
+
+func (tx *readTx) ListUserRoles(_ context.Context, userID uuid.UUID, roles *[]models.Role) error {
+	*roles = append(*roles, tx.roles[userID]...)
+	return nil
+}
+
+func TestGranted(t *testing.T) {
+	tests := []struct {
+		name       string
+		granted    []string
+		permission string
+		want       bool
+	}{
+		{name: "none", granted: nil, permission: UsersRead, want: false},
+		{name: "granted", granted: []string{UsersRead, UsersWrite}, permission: UsersWrite, want: true},
+		{name: "other", granted: []string{UsersRead}, permission: UsersWrite, want: false},
+		{name: "all", granted: []string{All}, permission: RolesWrite, want: true},
+		{name: "prefix is not a wildcard", granted: []string{"users"}, permission: UsersRead, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Granted(tt.granted, tt.permission); got != tt.want {
+				t.Errorf("Granted(%v, %q) = %v, want %v", tt.granted, tt.permission, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHolds(t *testing.T) {
+	tests := []struct {
+		name    string
+		granted []string
+		roles   []models.Role
+		denied  bool
+	}{
+		{name: "no roles", granted: nil, roles: nil},
+		{name: "empty role", granted: nil, roles: []models.Role{role("empty")}},
+		{name: "subset", granted: []string{UsersRead, UsersWrite}, roles: []models.Role{role("support", UsersRead)}},
+		{name: "all roles", granted: []string{UsersRead, AuditRead}, roles: []models.Role{role("a", UsersRead), role("b", AuditRead)}},
+		{name: "missing permission", granted: []string{UsersRead}, roles: []models.Role{role("support", UsersRead, UsersWrite)}, denied: true},
+		{name: "missing in second role", granted: []string{UsersRead}, roles: []models.Role{role("a", UsersRead), role("b", AuditRead)}, denied: true},
+		{name: "all permissions", granted: []string{All}, roles: []models.Role{role(SuperAdmin, All)}},
+		{name: "super-admin needs all", granted: []string{UsersRead, UsersWrite}, roles: []models.Role{role(SuperAdmin, All)}, denied: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Holds(tt.granted, tt.roles...)
+			if errors.Is(err, ErrPermissionDenied) != tt.denied {
+				t.Errorf("Holds() = %v, want denied %v", err, tt.denied)
+			}
+		})
+	}
+}
+
+func TestOrgScoped(t *testing.T) {
+	tests := []struct {
+		name string
+		role models.Role
+		want bool
+	}{
+		{name: "org-admin", role: builtIn[1], want: true},
+		{name: "org-member", role: builtIn[2], want: true},
+		{name: "no permissions", role: role("empty"), want: true},
+		{name: "super-admin", role: builtIn[0], want: false},
+		{name: "global permission", role: role("support", MembersRead, UsersRead), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := OrgScoped(tt.role); got != tt.want {
+				t.Errorf("OrgScoped(%s) = %v, want %v", tt.role.Name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnion(t *testing.T) {
+	got := Union([]models.Role{role("a", UsersWrite, UsersRead), role("b", UsersRead, AuditRead)})
+	want := []string{AuditRead, UsersRead, UsersWrite}
+
+	if !slices.Equal(got, want) {
+		t.Errorf("Union() = %v, want %v", got, want)
+	}
+}
+
+func TestGrants(t *testing.T) {
+	tests := []struct {
+		name  string
+		names []string
+		want  []string
+		err   error
+	}{
+		{name: "none", names: nil, want: nil},
+		{name: "known", names: []string{UsersRead, AuditRead}, want: []string{UsersRead, AuditRead}},
+		{name: "duplicates", names: []string{UsersRead, UsersRead}, want: []string{UsersRead}},
+		{name: "unknown", names: []string{UsersRead, "users:delete"}, err: ErrUnknownPermission},
+		{name: "all", names: []string{All}, err: ErrAllPermissions},
+		{name: "all among others", names: []string{UsersRead, All}, err: ErrAllPermissions},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := grants(context.Background(), &readTx{}, tt.names)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("grants(%v) = %v, want %v", tt.names, err, tt.err)
+			}
+
+			if tt.err != nil {
+				return
+			}
+
+			names := models.Role{Permissions: got}.PermissionNames()
+			if !slices.Equal(names, tt.want) && len(names)+len(tt.want) > 0 {
+				t.Errorf("grants(%v) = %v, want %v", tt.names, names, tt.want)
+			}
+		})
+	}
+}
+
+func TestCallerHolds(t *testing.T) {
+	admin, support, nobody := uuid.New(), uuid.New(), uuid.New()
+
+	tx := &readTx{roles: map[uuid.UUID][]models.Role{
+		admin:   {builtIn[0]},
+		support: {role("support", UsersRead), role("auditor", AuditRead)},
+	}}
+
+	tests := []struct {
+		name   string
+		caller uuid.UUID
+		roles  []models.Role
+		denied bool
+	}{
+		{name: "internal", caller: Internal, roles: []models.Role{builtIn[0]}},
+		{name: "super-admin", caller: admin, roles: []models.Role{builtIn[0]}},
+		{name: "union of the roles", caller: support, roles: []models.Role{role("both", UsersRead, AuditRead)}},
+		{name: "escalation", caller: support, roles: []models.Role{role("more", UsersRead, UsersWrite)}, denied: true},
+		{name: "super-admin by a non super-admin", caller: support, roles: []models.Role{builtIn[0]}, denied: true},
+		{name: "no roles", caller: nobody, roles: []models.Role{builtIn[2]}, denied: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := holds(context.Background(), tx, tt.caller, tt.roles...)
+			if errors.Is(err, ErrPermissionDenied) != tt.denied || (!tt.denied && err != nil) {
+				t.Errorf("holds() = %v, want denied %v", err, tt.denied)
+			}
+		})
+	}
+}
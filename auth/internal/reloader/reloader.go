@@ -0,0 +1,246 @@
+package reloader
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/open-cloud-initiative/glue/auth/internal/adapters/auth"
+	"github.com/open-cloud-initiative/glue/auth/internal/config"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// DefaultDebounce is the default time to wait for further file changes before reloading.
+const DefaultDebounce = 500 * time.Millisecond
+
+var (
+	reloadsTotal       = expvar.NewInt("config_reloads_total")
+	reloadFailures     = expvar.NewInt("config_reload_failures_total")
+	lastReloadSuccess  = expvar.NewInt("config_last_reload_success_timestamp_seconds")
+	lastReloadFailed   = expvar.NewInt("config_last_reload_failed")
+	lastReloadErrorMsg = expvar.NewString("config_last_reload_error")
+	restartRequired    = expvar.NewInt("config_restart_required")
+)
+
+// BuildFunc builds the providers of a configuration file.
+type BuildFunc func(ctx context.Context, f *config.File) ([]auth.Provider, error)
+
+// Reloader reloads the configuration file and atomically swaps
+// the providers of the registry. Only the providers are reloadable, the
+// other settings are read at startup. Changes to them are reported by Pending
+// and the config_restart_required metric, and take effect after a restart.
+//
+// Login flows keep their state in cookies and look up the provider
+// by ID on callback, so flows started before a reload complete
+// against the new provider with the same ID.
+type Reloader struct {
+	path     string
+	registry *auth.Registry
+	build    BuildFunc
+	debounce time.Duration
+	initial  *config.File
+	current  atomic.Pointer[config.File]
+
+	mu        sync.Mutex
+	listeners []func(*config.File)
+	failures  []func(error)
+}
+
+// Opt is a function that configures the Reloader.
+type Opt func(*Reloader)
+
+// WithDebounce sets the time to wait for further file changes before reloading.
+func WithDebounce(d time.Duration) Opt {
+	return func(r *Reloader) {
+		r.debounce = d
+	}
+}
+
+// New returns a new Reloader for the configuration file at path.
+// The initial configuration is the already loaded file f.
+func New(path string, f *config.File, registry *auth.Registry, build BuildFunc, opts ...Opt) *Reloader {
+	r := &Reloader{
+		path:     path,
+		registry: registry,
+		build:    build,
+		debounce: DefaultDebounce,
+		initial:  f,
+	}
+	r.current.Store(f)
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+// Current returns the currently active configuration.
+func (r *Reloader) Current() *config.File {
+	return r.current.Load()
+}
+
+// OnReload registers a function that is called with the new configuration
+// after a successful reload.
+func (r *Reloader) OnReload(fn func(*config.File)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.listeners = append(r.listeners, fn)
+}
+
+// OnError registers a function that is called with the error of a failed
+// reload or of watching the file.
+func (r *Reloader) OnError(fn func(error)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.failures = append(r.failures, fn)
+}
+
+func (r *Reloader) fail(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, fn := range r.failures {
+		fn(err)
+	}
+}
+
+// Reload loads, validates and builds the configuration file.
+// On failure the active configuration is left in place.
+func (r *Reloader) Reload(ctx context.Context) error {
+	reloadsTotal.Add(1)
+
+	err := r.reload(ctx)
+	if err != nil {
+		reloadFailures.Add(1)
+		lastReloadFailed.Set(1)
+		lastReloadErrorMsg.Set(err.Error())
+
+		r.fail(fmt.Errorf("reload of %s failed, keeping previous configuration: %w", r.path, err))
+
+		return err
+	}
+
+	lastReloadFailed.Set(0)
+	lastReloadErrorMsg.Set("")
+	lastReloadSuccess.Set(time.Now().Unix())
+
+	return nil
+}
+
+func (r *Reloader) reload(ctx context.Context) error {
+	f, err := config.LoadFile(r.path)
+	if err != nil {
+		return err
+	}
+
+	providers, err := r.build(ctx, f)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.registry.Replace(providers...)
+	r.current.Store(f)
+
+	if len(Pending(r.initial, f)) > 0 {
+		restartRequired.Set(1)
+	} else {
+		restartRequired.Set(0)
+	}
+
+	for _, fn := range r.listeners {
+		fn(f)
+	}
+
+	return nil
+}
+
+// Pending returns the JSON names of the settings other than the providers
+// that differ between the configuration read at startup and the file f.
+func Pending(initial, f *config.File) []string {
+	pending := []string{}
+
+	a, b := reflect.ValueOf(*initial), reflect.ValueOf(*f)
+	for i := range a.NumField() {
+		field := a.Type().Field(i)
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "providers" {
+			continue
+		}
+
+		if !reflect.DeepEqual(a.Field(i).Interface(), b.Field(i).Interface()) {
+			pending = append(pending, name)
+		}
+	}
+
+	return pending
+}
+
+// Watch reloads the configuration on SIGHUP and when the file changes,
+// until the context is canceled.
+func (r *Reloader) Watch(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	// Watch the directory, as editors and orchestrators replace files
+	// instead of writing to them.
+	err = watcher.Add(filepath.Dir(r.path))
+	if err != nil {
+		return err
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	timer := time.NewTimer(r.debounce)
+	timer.Stop()
+
+	name := filepath.Clean(r.path)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-hup:
+			_ = r.Reload(ctx)
+		case ev, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+
+			if filepath.Clean(ev.Name) != name && !ev.Has(fsnotify.Create) {
+				continue
+			}
+
+			timer.Reset(r.debounce)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+
+			r.fail(fmt.Errorf("watching %s: %w", r.path, err))
+		case <-timer.C:
+			_ = r.Reload(ctx)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package reloader
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/open-cloud-initiative/glue/auth/internal/adapters/auth"
+	"github.com/open-cloud-initiative/glue/auth/internal/config"
+	"github.com/open-cloud-initiative/glue/auth/internal/models"
+)
+
+func TestPending(t *testing.T) {
+	initial := &config.File{BaseURL: "https://login.example.com", Providers: []config.Provider{{ID: "github"}}}
+
+	tests := []struct {
+		name string
+		f    *config.File
+		want []string
+	}{
+		{
+			name: "unchanged",
+			f:    &config.File{BaseURL: "https://login.example.com", Providers: []config.Provider{{ID: "github"}}},
+			want: []string{},
+		},
+		{
+			name: "providers are reloaded",
+			f:    &config.File{BaseURL: "https://login.example.com", Providers: []config.Provider{{ID: "gitlab"}}},
+			want: []string{},
+		},
+		{
+			name: "settings need a restart",
+			f: &config.File{
+				BaseURL:        "https://auth.example.com",
+				AccountLinking: models.LinkPolicyAlways,
+				Providers:      []config.Provider{{ID: "github"}},
+			},
+			want: []string{"accountLinking", "baseUrl"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Pending(initial, tt.f); !slices.Equal(got, tt.want) {
+				t.Errorf("Pending() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(data string) {
+		t.Helper()
+
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	errBuild := errors.New("build failed")
+	fail := false
+
+	build := func(context.Context, *config.File) ([]auth.Provider, error) {
+		if fail {
+			return nil, errBuild
+		}
+
+		return nil, nil
+	}
+
+	initial := &config.File{BaseURL: "https://login.example.com"}
+	r := New(path, initial, auth.NewRegistry(), build)
+
+	reloaded := []*config.File{}
+	r.OnReload(func(f *config.File) { reloaded = append(reloaded, f) })
+
+	failed := []error{}
+	r.OnError(func(err error) { failed = append(failed, err) })
+
+	write("baseUrl: https://auth.example.com\n")
+
+	if err := r.Reload(context.Background()); err != nil {
+		t.Fatalf("Reload() = %v", err)
+	}
+
+	if r.Current().BaseURL != "https://auth.example.com" || len(reloaded) != 1 || len(failed) != 0 {
+		t.Fatalf("Current() = %q after %d reloads and %d failures", r.Current().BaseURL, len(reloaded), len(failed))
+	}
+
+	tests := []struct {
+		name string
+		data string
+		fail bool
+		err  error
+	}{
+		{name: "invalid file", data: "baseUrl: [\n"},
+		{name: "invalid configuration", data: "accountLinking: sometimes\n"},
+		{name: "failed build", data: "baseUrl: https://other.example.com\n", fail: true, err: errBuild},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			write(tt.data)
+			fail = tt.fail
+			n := len(failed)
+
+			err := r.Reload(context.Background())
+			if err == nil || (tt.err != nil && !errors.Is(err, tt.err)) {
+				t.Fatalf("Reload() = %v, want %v", err, tt.err)
+			}
+
+			if r.Current().BaseURL != "https://auth.example.com" {
+				t.Errorf("Current() = %q, want the previous configuration", r.Current().BaseURL)
+			}
+
+			if len(reloaded) != 1 || len(failed) != n+1 {
+				t.Errorf("%d reloads and %d failures, want 1 reload and %d failures", len(reloaded), len(failed), n+1)
+			}
+		})
+	}
+}
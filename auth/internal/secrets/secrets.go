@@ -0,0 +1,213 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Redacted is printed instead of the value of a secret.
+const Redacted = "[REDACTED]"
+
+var (
+	// ErrNotFound is returned when a referenced secret does not exist.
+	ErrNotFound = errors.New("secret not found")
+	// ErrInvalidRef is returned when a secret reference can not be parsed.
+	ErrInvalidRef = errors.New("invalid secret reference")
+	// ErrUnknownScheme is returned when a secret reference has a scheme without a resolver.
+	ErrUnknownScheme = errors.New("unknown secret reference scheme")
+)
+
+// Secret is a resolved secret. It never prints or marshals its value,
+// use Value to access it.
+type Secret struct {
+	value string
+}
+
+// New returns a new secret with the value.
+func New(value string) Secret {
+	return Secret{value: value}
+}
+
+// Value returns the plaintext value of the secret.
+func (s Secret) Value() string {
+	return s.value
+}
+
+// Empty returns true if the secret has no value.
+func (s Secret) Empty() bool {
+	return s.value == ""
+}
+
+// String implements the fmt.Stringer interface.
+func (s Secret) String() string {
+	return Redacted
+}
+
+// GoString implements the fmt.GoStringer interface.
+func (s Secret) GoString() string {
+	return Redacted
+}
+
+// Format implements the fmt.Formatter interface.
+func (s Secret) Format(f fmt.State, _ rune) {
+	_, _ = f.Write([]byte(Redacted))
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (s Secret) MarshalText() ([]byte, error) {
+	return []byte(Redacted), nil
+}
+
+// LogValue implements the slog.LogValuer interface.
+func (s Secret) LogValue() slog.Value {
+	return slog.StringValue(Redacted)
+}
+
+// Ref is a reference to a secret, e.g. file:///run/secrets/x or env://NAME.
+// A value without a scheme is used literally. As a reference may
+// be the secret itself, it never prints or marshals its value.
+type Ref string
+
+// IsZero returns true if the reference is empty.
+func (r Ref) IsZero() bool {
+	return r == ""
+}
+
+// String implements the fmt.Stringer interface.
+func (r Ref) String() string {
+	return Redacted
+}
+
+// GoString implements the fmt.GoStringer interface.
+func (r Ref) GoString() string {
+	return Redacted
+}
+
+// Format implements the fmt.Formatter interface.
+func (r Ref) Format(f fmt.State, _ rune) {
+	_, _ = f.Write([]byte(Redacted))
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (r Ref) MarshalText() ([]byte, error) {
+	return []byte(Redacted), nil
+}
+
+// LogValue implements the slog.LogValuer interface.
+func (r Ref) LogValue() slog.Value {
+	return slog.StringValue(Redacted)
+}
+
+// Resolver resolves the secret references of a scheme.
+type Resolver interface {
+	// Resolve returns the secret the reference points to.
+	Resolve(ctx context.Context, ref *url.URL) (Secret, error)
+}
+
+// ResolverFunc is a function that implements the Resolver interface.
+type ResolverFunc func(ctx context.Context, ref *url.URL) (Secret, error)
+
+// Resolve implements the Resolver interface.
+func (fn ResolverFunc) Resolve(ctx context.Context, ref *url.URL) (Secret, error) {
+	return fn(ctx, ref)
+}
+
+// Resolvers resolves secret references by their scheme.
+type Resolvers struct {
+	mu        sync.RWMutex
+	resolvers map[string]Resolver
+}
+
+// NewResolvers returns new resolvers with the file and env schemes registered.
+func NewResolvers() *Resolvers {
+	r := &Resolvers{resolvers: map[string]Resolver{}}
+	r.Register("file", ResolverFunc(resolveFile))
+	r.Register("env", ResolverFunc(resolveEnv))
+
+	return r
+}
+
+// Register registers a resolver for a scheme, e.g. "vault".
+func (r *Resolvers) Register(scheme string, resolver Resolver) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.resolvers[strings.ToLower(scheme)] = resolver
+}
+
+// Resolve resolves the secret reference. References without a scheme are
+// returned as literal secrets, references with an unregistered scheme fail
+// with ErrUnknownScheme, so that a mistyped scheme is not used as the secret.
+func (r *Resolvers) Resolve(ctx context.Context, ref Ref) (Secret, error) {
+	scheme, _, ok := strings.Cut(string(ref), "://")
+	if !ok {
+		return New(string(ref)), nil
+	}
+
+	r.mu.RLock()
+	resolver, ok := r.resolvers[strings.ToLower(scheme)]
+	r.mu.RUnlock()
+
+	if !ok {
+		return Secret{}, fmt.Errorf("%w %q", ErrUnknownScheme, scheme)
+	}
+
+	u, err := url.Parse(string(ref))
+	if err != nil {
+		// The parse error contains the reference, which may contain the secret.
+		return Secret{}, fmt.Errorf("%w with scheme %q", ErrInvalidRef, scheme)
+	}
+
+	s, err := resolver.Resolve(ctx, u)
+	if err != nil {
+		return Secret{}, fmt.Errorf("resolving %s secret: %w", scheme, err)
+	}
+
+	return s, nil
+}
+
+// Default are the resolvers used by the package-level helpers.
+var Default = NewResolvers()
+
+// Register registers a resolver for a scheme with the default resolvers.
+func Register(scheme string, resolver Resolver) {
+	Default.Register(scheme, resolver)
+}
+
+// Resolve resolves the secret reference with the default resolvers.
+func Resolve(ctx context.Context, ref Ref) (Secret, error) {
+	return Default.Resolve(ctx, ref)
+}
+
+func resolveFile(_ context.Context, ref *url.URL) (Secret, error) {
+	path := filepath.Clean(ref.Host + ref.Path)
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return Secret{}, fmt.Errorf("%w: file %s", ErrNotFound, path)
+	}
+
+	if err != nil {
+		return Secret{}, err
+	}
+
+	return New(strings.TrimRight(string(b), "\r\n")), nil
+}
+
+func resolveEnv(_ context.Context, ref *url.URL) (Secret, error) {
+	name := ref.Host + ref.Path
+
+	v, ok := os.LookupEnv(name)
+	if !ok {
+		return Secret{}, fmt.Errorf("%w: environment variable %s", ErrNotFound, name)
+	}
+
+	return New(v), nil
+}
@@ -0,0 +1,102 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "secret")
+
+	if err := os.WriteFile(path, []byte("s3cr3t\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("SECRETS_TEST_TOKEN", "t0k3n")
+
+	r := NewResolvers()
+	r.Register("Vault", ResolverFunc(func(_ context.Context, ref *url.URL) (Secret, error) {
+		return New("vault:" + ref.Host + ref.Path), nil
+	}))
+
+	tests := []struct {
+		name string
+		ref  Ref
+		want string
+		err  error
+	}{
+		{name: "literal", ref: "plain-secret", want: "plain-secret"},
+		{name: "empty", ref: "", want: ""},
+		{name: "file", ref: Ref("file://" + path), want: "s3cr3t"},
+		{name: "missing file", ref: Ref("file://" + filepath.Join(dir, "missing")), err: ErrNotFound},
+		{name: "env", ref: "env://SECRETS_TEST_TOKEN", want: "t0k3n"},
+		{name: "scheme in upper case", ref: "ENV://SECRETS_TEST_TOKEN", want: "t0k3n"},
+		{name: "missing env", ref: "env://SECRETS_TEST_MISSING", err: ErrNotFound},
+		{name: "registered scheme", ref: "vault://kv/app", want: "vault:kv/app"},
+		{name: "unknown scheme", ref: "fiel:///run/secrets/x", err: ErrUnknownScheme},
+		{name: "invalid reference", ref: "env://%zz", err: ErrInvalidRef},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := r.Resolve(t.Context(), tt.ref)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Resolve() error = %v, want %v", err, tt.err)
+			}
+
+			if s.Value() != tt.want {
+				t.Errorf("Resolve() = %q, want %q", s.Value(), tt.want)
+			}
+		})
+	}
+}
+
+func TestRedaction(t *testing.T) {
+	const value = "s3cr3t"
+
+	tests := []struct {
+		name string
+		v    any
+	}{
+		{name: "secret", v: New(value)},
+		{name: "reference", v: Ref(value)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := json.Marshal(map[string]any{"v": tt.v})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var buf bytes.Buffer
+			slog.New(slog.NewTextHandler(&buf, nil)).Info("msg", "v", tt.v)
+
+			outputs := map[string]string{
+				"String":      tt.v.(fmt.Stringer).String(),
+				"Sprintf %v":  fmt.Sprintf("%v", tt.v),
+				"Sprintf %s":  fmt.Sprintf("%s", tt.v),
+				"Sprintf %#v": fmt.Sprintf("%#v", tt.v),
+				"Sprintf %+v": fmt.Sprintf("%+v", struct{ V any }{tt.v}),
+				"MarshalJSON": string(b),
+				"slog":        buf.String(),
+			}
+
+			for name, out := range outputs {
+				if strings.Contains(out, value) || !strings.Contains(out, Redacted) {
+					t.Errorf("%s = %q, want %s", name, out, Redacted)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+// Package tenancy resolves the tenant of a request from its host, either
+// a custom host of the tenant or a subdomain of a shared parent domain.
+package tenancy
+
+import (
+	"net"
+	"strings"
+)
+
+// Resolver resolves the tenant of a host. It is safe for concurrent use.
+type Resolver struct {
+	domain string
+	hosts  map[string]string
+}
+
+// Opt is a function that configures the Resolver.
+type Opt func(*Resolver)
+
+// WithDomain resolves the subdomains of the parent domain to tenants,
+// e.g. login.example.com resolves acme.login.example.com to acme.
+func WithDomain(domain string) Opt {
+	return func(r *Resolver) {
+		r.domain = normalize(domain)
+	}
+}
+
+// WithHosts resolves custom hosts to tenants, e.g. login.acme.com to acme.
+func WithHosts(hosts map[string]string) Opt {
+	return func(r *Resolver) {
+		for host, tenant := range hosts {
+			r.hosts[normalize(host)] = tenant
+		}
+	}
+}
+
+// New creates a new Resolver.
+func New(opts ...Opt) *Resolver {
+	r := &Resolver{hosts: map[string]string{}}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+// Resolve returns the tenant of the host, or an empty string if the
+// host belongs to no tenant. Custom hosts take precedence over subdomains.
+func (r *Resolver) Resolve(host string) string {
+	host = normalize(host)
+
+	if tenant, ok := r.hosts[host]; ok {
+		return tenant
+	}
+
+	if r.domain == "" {
+		return ""
+	}
+
+	sub, ok := strings.CutSuffix(host, "."+r.domain)
+	if !ok || strings.Contains(sub, ".") {
+		return ""
+	}
+
+	return sub
+}
+
+func normalize(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+}
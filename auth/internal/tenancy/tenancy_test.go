@@ -0,0 +1,49 @@
+package tenancy
+
+import "testing"
+
+func TestResolve(t *testing.T) {
+	r := New(
+		WithDomain("Login.Example.com."),
+		WithHosts(map[string]string{"login.acme.com": "acme", "globex.login.example.com": "initech"}),
+	)
+
+	tests := []struct {
+		name string
+		host string
+		want string
+	}{
+		{name: "subdomain", host: "acme.login.example.com", want: "acme"},
+		{name: "subdomain with port", host: "acme.login.example.com:8443", want: "acme"},
+		{name: "subdomain in upper case", host: "ACME.login.example.com", want: "acme"},
+		{name: "fully qualified subdomain", host: "acme.login.example.com.", want: "acme"},
+		{name: "custom host", host: "login.acme.com", want: "acme"},
+		{name: "custom host with port", host: "Login.Acme.com:443", want: "acme"},
+		{name: "custom host before subdomain", host: "globex.login.example.com", want: "initech"},
+		{name: "parent domain", host: "login.example.com", want: ""},
+		{name: "nested subdomain", host: "a.acme.login.example.com", want: ""},
+		{name: "suffix without a dot", host: "acmelogin.example.com", want: ""},
+		{name: "unknown host", host: "example.org", want: ""},
+		{name: "empty", host: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := r.Resolve(tt.host); got != tt.want {
+				t.Errorf("Resolve(%q) = %q, want %q", tt.host, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveWithoutDomain(t *testing.T) {
+	r := New(WithHosts(map[string]string{"login.acme.com": "acme"}))
+
+	if got := r.Resolve("login.acme.com"); got != "acme" {
+		t.Errorf("Resolve() = %q, want acme", got)
+	}
+
+	if got := r.Resolve("acme.login.example.com"); got != "" {
+		t.Errorf("Resolve() = %q, want no tenant", got)
+	}
+}
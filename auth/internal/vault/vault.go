@@ -0,0 +1,187 @@
+// Package vault hands out valid provider access tokens of users to
+// downstream apps, refreshing and persisting them when they expire.
+package vault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/open-cloud-initiative/glue/auth/internal/adapters/auth"
+	"github.com/open-cloud-initiative/glue/auth/internal/models"
+	"github.com/open-cloud-initiative/glue/auth/internal/ports"
+
+	"github.com/google/uuid"
+	"github.com/katallaxie/pkg/cast"
+	"github.com/katallaxie/pkg/dbx"
+	"github.com/katallaxie/pkg/utilx"
+	"golang.org/x/oauth2"
+	"golang.org/x/sync/singleflight"
+)
+
+// DefaultLeeway is the default time before the expiry at which a token is refreshed.
+const DefaultLeeway = time.Minute
+
+var (
+	// ErrNoToken is returned when the account of a user has no access token.
+	ErrNoToken = errors.New("account has no access token")
+	// ErrReauthorizationRequired is returned when a token expired and can not
+	// be refreshed, the user has to log in with the provider again.
+	ErrReauthorizationRequired = errors.New("token expired, the user has to log in with the provider again")
+)
+
+// Vault returns valid access tokens for the provider accounts of users.
+// Concurrent refreshes of the same account are serialized, within the
+// process by a single flight and across processes by a row lock.
+type Vault struct {
+	store    dbx.Database[ports.ReadTx, ports.WriteTx]
+	registry *auth.Registry
+	leeway   time.Duration
+	group    singleflight.Group
+}
+
+// Opt is a function that configures the Vault.
+type Opt func(*Vault)
+
+// WithLeeway sets the time before the expiry at which a token is refreshed.
+func WithLeeway(leeway time.Duration) Opt {
+	return func(v *Vault) {
+		v.leeway = leeway
+	}
+}
+
+// New creates a new Vault.
+func New(store dbx.Database[ports.ReadTx, ports.WriteTx], registry *auth.Registry, opts ...Opt) *Vault {
+	v := &Vault{
+		store:    store,
+		registry: registry,
+		leeway:   DefaultLeeway,
+	}
+
+	for _, opt := range opts {
+		opt(v)
+	}
+
+	return v
+}
+
+// Token returns a valid access token of the user for the provider of the tenant.
+// The empty tenant selects a global provider. It refreshes the token if it expires within the leeway.
+func (v *Vault) Token(ctx context.Context, userID uuid.UUID, tenant, provider string) (*oauth2.Token, error) {
+	account := models.Account{UserID: &userID, Tenant: tenant, Provider: provider}
+
+	err := v.store.ReadTx(ctx, func(ctx context.Context, tx ports.ReadTx) error {
+		user := models.User{ID: userID}
+		if err := tx.GetUser(ctx, &user); err != nil {
+			return err
+		}
+
+		if user.IsBanned() {
+			return ports.ErrUserBanned
+		}
+
+		return tx.GetUserAccount(ctx, &account)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if utilx.Empty(cast.Value(account.AccessToken)) {
+		return nil, ErrNoToken
+	}
+
+	if v.valid(account) {
+		return token(account), nil
+	}
+
+	// The refresh is shared by all callers, it must not be canceled by the first one.
+	res, err, _ := v.group.Do(account.ID.String(), func() (any, error) {
+		return v.refresh(context.WithoutCancel(ctx), account.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res.(*oauth2.Token), nil
+}
+
+func (v *Vault) refresh(ctx context.Context, id uuid.UUID) (*oauth2.Token, error) {
+	var t *oauth2.Token
+
+	err := v.store.ReadWriteTx(ctx, func(ctx context.Context, tx ports.WriteTx) error {
+		account := models.Account{ID: id}
+
+		if err := tx.LockAccount(ctx, &account); err != nil {
+			return err
+		}
+
+		// Another process may have refreshed the token while we waited for the lock.
+		if v.valid(account) {
+			t = token(account)
+			return nil
+		}
+
+		p, err := v.registry.Get(account.Tenant, account.Provider)
+		if err != nil {
+			return err
+		}
+
+		refresher, ok := p.(auth.TokenRefresher)
+		if !ok || utilx.Empty(cast.Value(account.RefreshToken)) {
+			return ErrReauthorizationRequired
+		}
+
+		t, err = refresher.RefreshToken(ctx, token(account))
+		if rejected(err) {
+			return fmt.Errorf("%w: %w", ErrReauthorizationRequired, err)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		account.AccessToken = cast.Ptr(t.AccessToken)
+		account.RefreshToken = cast.Ptr(utilx.Or(t.RefreshToken, cast.Value(account.RefreshToken)))
+		account.ExpiresAt = utilx.IfElse(t.Expiry.IsZero(), nil, cast.Ptr(t.Expiry))
+		account.TokenType = cast.Ptr(t.Type())
+
+		return tx.UpdateAccountTokens(ctx, &account)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// valid returns true if the token of the account does not expire within the leeway.
+// Tokens without an expiry never expire.
+func (v *Vault) valid(account models.Account) bool {
+	if account.ExpiresAt == nil || account.ExpiresAt.IsZero() {
+		return true
+	}
+
+	return time.Until(*account.ExpiresAt) > v.leeway
+}
+
+// rejected returns true if the provider rejected the refresh token,
+// as opposed to a transient failure that may be retried.
+func rejected(err error) bool {
+	var re *oauth2.RetrieveError
+	if errors.As(err, &re) {
+		return re.Response == nil || re.Response.StatusCode < http.StatusInternalServerError
+	}
+
+	return errors.Is(err, auth.ErrNoRefreshToken)
+}
+
+func token(account models.Account) *oauth2.Token {
+	return &oauth2.Token{
+		AccessToken:  cast.Value(account.AccessToken),
+		TokenType:    cast.Value(account.TokenType),
+		RefreshToken: cast.Value(account.RefreshToken),
+		Expiry:       cast.Value(account.ExpiresAt),
+	}
+}
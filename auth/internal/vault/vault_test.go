@@ -0,0 +1,274 @@
+package vault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/open-cloud-initiative/glue/auth/internal/adapters/auth"
+	"github.com/open-cloud-initiative/glue/auth/internal/models"
+	"github.com/open-cloud-initiative/glue/auth/internal/ports"
+
+	"github.com/google/uuid"
+	"github.com/katallaxie/pkg/cast"
+	"golang.org/x/oauth2"
+)
+
+// store keeps a user and one account in memory. Write transactions are
+// serialized, like the row lock of LockAccount.
+type store struct {
+	mu      sync.Mutex
+	tx      sync.Mutex
+	user    models.User
+	account models.Account
+	reads   atomic.Int32
+	locks   atomic.Int32
+}
+
+func (s *store) ReadTx(ctx context.Context, fn func(context.Context, ports.ReadTx) error) error {
+	return fn(ctx, &writeTx{store: s})
+}
+
+func (s *store) ReadWriteTx(ctx context.Context, fn func(context.Context, ports.WriteTx) error) error {
+	s.tx.Lock()
+	defer s.tx.Unlock()
+
+	return fn(ctx, &writeTx{store: s})
+}
+
+func (s *store) Migrate(context.Context, ...any) error {
+	return nil
+}
+
+func (s *store) Close() error {
+	return nil
+}
+
+// get returns the stored account.
+func (s *store) get() models.Account {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.account
+}
+
+type writeTx struct {
+	ports.WriteTx
+	store *store
+}
+
+func (t *writeTx) GetUser(_ context.Context, user *models.User) error {
+	*user = t.store.user
+	return nil
+}
+
+func (t *writeTx) GetUserAccount(_ context.Context, account *models.Account) error {
+	t.store.reads.Add(1)
+	*account = t.store.get()
+
+	return nil
+}
+
+func (t *writeTx) LockAccount(_ context.Context, account *models.Account) error {
+	t.store.locks.Add(1)
+	*account = t.store.get()
+
+	return nil
+}
+
+func (t *writeTx) UpdateAccountTokens(_ context.Context, account *models.Account) error {
+	t.store.mu.Lock()
+	defer t.store.mu.Unlock()
+
+	t.store.account = *account
+
+	return nil
+}
+
+// provider refreshes tokens, after release is closed if it is set.
+type provider struct {
+	auth.Provider
+	release chan struct{}
+	err     error
+	calls   atomic.Int32
+}
+
+func (p *provider) ID() string     { return "github" }
+func (p *provider) Tenant() string { return "" }
+
+func (p *provider) RefreshToken(_ context.Context, t *oauth2.Token) (*oauth2.Token, error) {
+	n := p.calls.Add(1)
+
+	if p.release != nil {
+		<-p.release
+	}
+
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	return &oauth2.Token{AccessToken: "refreshed", TokenType: "bearer", RefreshToken: fmt.Sprintf("%s%d", t.RefreshToken, n), Expiry: time.Now().Add(time.Hour)}, nil
+}
+
+// plain is a provider that can not refresh tokens.
+type plain struct {
+	auth.Provider
+}
+
+func (p *plain) ID() string     { return "github" }
+func (p *plain) Tenant() string { return "" }
+
+func account(expiresIn time.Duration, refreshToken string) models.Account {
+	a := models.Account{ID: uuid.New(), Provider: "github", AccessToken: cast.Ptr("current"), TokenType: cast.Ptr("bearer")}
+
+	if expiresIn != 0 {
+		a.ExpiresAt = cast.Ptr(time.Now().Add(expiresIn))
+	}
+
+	if refreshToken != "" {
+		a.RefreshToken = cast.Ptr(refreshToken)
+	}
+
+	return a
+}
+
+func TestToken(t *testing.T) {
+	tests := []struct {
+		name     string
+		user     models.User
+		account  models.Account
+		provider auth.Provider
+		want     string
+		err      error
+		fails    bool
+		calls    int32
+	}{
+		{name: "valid", account: account(time.Hour, "r"), provider: &provider{}, want: "current"},
+		{name: "without expiry", account: account(0, "r"), provider: &provider{}, want: "current"},
+		{name: "expires within the leeway", account: account(30*time.Second, "r"), provider: &provider{}, want: "refreshed", calls: 1},
+		{name: "expired", account: account(-time.Hour, "r"), provider: &provider{}, want: "refreshed", calls: 1},
+		{name: "no access token", account: models.Account{ID: uuid.New(), Provider: "github"}, provider: &provider{}, err: ErrNoToken, fails: true},
+		{name: "no refresh token", account: account(-time.Hour, ""), provider: &provider{}, err: ErrReauthorizationRequired, fails: true},
+		{name: "provider can not refresh", account: account(-time.Hour, "r"), provider: &plain{}, err: ErrReauthorizationRequired, fails: true},
+		{
+			name:     "refresh token rejected",
+			account:  account(-time.Hour, "r"),
+			provider: &provider{err: &oauth2.RetrieveError{Response: &http.Response{StatusCode: http.StatusBadRequest}}},
+			err:      ErrReauthorizationRequired,
+			fails:    true,
+			calls:    1,
+		},
+		{
+			name:     "provider unavailable",
+			account:  account(-time.Hour, "r"),
+			provider: &provider{err: &oauth2.RetrieveError{Response: &http.Response{StatusCode: http.StatusBadGateway}}},
+			fails:    true,
+			calls:    1,
+		},
+		{name: "banned user", user: models.User{BannedUntil: time.Now().Add(time.Hour)}, account: account(time.Hour, "r"), provider: &provider{}, err: ports.ErrUserBanned, fails: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &store{user: tt.user, account: tt.account}
+			v := New(s, auth.NewRegistry(tt.provider))
+
+			got, err := v.Token(t.Context(), uuid.New(), "", "github")
+			if (err != nil) != tt.fails || (tt.err != nil && !errors.Is(err, tt.err)) {
+				t.Fatalf("Token() error = %v, want %v", err, tt.err)
+			}
+
+			if tt.fails && errors.Is(err, ErrReauthorizationRequired) != errors.Is(tt.err, ErrReauthorizationRequired) {
+				t.Errorf("Token() error = %v, want a transient error", err)
+			}
+
+			if p, ok := tt.provider.(*provider); ok && p.calls.Load() != tt.calls {
+				t.Errorf("RefreshToken() called %d times, want %d", p.calls.Load(), tt.calls)
+			}
+
+			if tt.fails {
+				return
+			}
+
+			if got.AccessToken != tt.want {
+				t.Errorf("Token() = %q, want %q", got.AccessToken, tt.want)
+			}
+
+			if stored := cast.Value(s.get().AccessToken); stored != tt.want {
+				t.Errorf("stored access token = %q, want %q", stored, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenConcurrent(t *testing.T) {
+	const callers = 8
+
+	s := &store{account: account(-time.Hour, "r")}
+	p := &provider{release: make(chan struct{})}
+	v := New(s, auth.NewRegistry(p))
+
+	var wg sync.WaitGroup
+	tokens := make([]*oauth2.Token, callers)
+	errs := make([]error, callers)
+
+	for i := range callers {
+		wg.Go(func() {
+			tokens[i], errs[i] = v.Token(t.Context(), uuid.New(), "", "github")
+		})
+	}
+
+	// Hold the refresh until all callers read the expired token and joined the flight.
+	for s.reads.Load() < callers {
+		time.Sleep(time.Millisecond)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	close(p.release)
+	wg.Wait()
+
+	for i := range callers {
+		if errs[i] != nil {
+			t.Fatalf("Token() error = %v", errs[i])
+		}
+
+		if tokens[i].AccessToken != "refreshed" || tokens[i].RefreshToken != "r1" {
+			t.Errorf("Token() = %+v, want the token of the first refresh", tokens[i])
+		}
+	}
+
+	if n := p.calls.Load(); n != 1 {
+		t.Errorf("RefreshToken() called %d times, want 1", n)
+	}
+
+	if n := s.locks.Load(); n != 1 {
+		t.Errorf("LockAccount() called %d times, want 1 shared refresh", n)
+	}
+}
+
+func TestTokenRefreshedByAnotherProcess(t *testing.T) {
+	s := &store{account: account(-time.Hour, "r")}
+	p := &provider{}
+
+	// Both vaults read the expired token, the first one refreshes it.
+	first := New(s, auth.NewRegistry(p))
+	second := New(s, auth.NewRegistry(p))
+
+	if _, err := first.Token(t.Context(), uuid.New(), "", "github"); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := second.refresh(t.Context(), s.get().ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.AccessToken != "refreshed" || p.calls.Load() != 1 {
+		t.Errorf("refresh() = %q after %d refreshes, want the stored token without a refresh", got.AccessToken, p.calls.Load())
+	}
+}
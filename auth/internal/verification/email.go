@@ -0,0 +1,297 @@
+package verification
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/open-cloud-initiative/glue/auth/internal/audit"
+	"github.com/open-cloud-initiative/glue/auth/internal/hooks"
+	"github.com/open-cloud-initiative/glue/auth/internal/models"
+	"github.com/open-cloud-initiative/glue/auth/internal/ports"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Purposes of email tokens, the identifier of a token is "<purpose>:<user id>:<email>".
+const (
+	purposeVerifyEmail = "email-verify"
+	purposeChangeEmail = "email-change"
+)
+
+// SendEmailVerification sends a link that verifies the email address of the user.
+func (s *Service) SendEmailVerification(ctx context.Context, userID uuid.UUID) error {
+	user := models.User{ID: userID}
+	var token string
+
+	err := s.store.ReadWriteTx(ctx, func(ctx context.Context, tx ports.WriteTx) error {
+		if err := tx.GetUser(ctx, &user); err != nil {
+			return err
+		}
+
+		if user.Email == "" {
+			return ErrNoEmail
+		}
+
+		if !user.EmailVerifiedAt.IsZero() {
+			return ErrAlreadyVerified
+		}
+
+		if err := s.throttle(&user); err != nil {
+			return err
+		}
+
+		var err error
+		token, err = Issue(ctx, tx, identifier(purposeVerifyEmail, user.ID, user.Email), s.emailTTL)
+		if err != nil {
+			return err
+		}
+
+		return tx.UpdateUser(ctx, &user)
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(ctx, ports.Mail{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Text: fmt.Sprintf("Hi %s,\n\nplease verify your email address by opening the link below.\n\n%s\n\nThe link expires in %s.\n",
+			user.Name, s.confirmURL(token), s.emailTTL),
+	})
+}
+
+// SendSignupNotice tells the owner of a registered email address that someone
+// tried to sign up with it, as a signup does not reveal that the address is taken.
+// It succeeds if no user has the address or a message was sent recently.
+func (s *Service) SendSignupNotice(ctx context.Context, email string) error {
+	user := models.User{Email: email}
+	sent := false
+
+	err := s.store.ReadWriteTx(ctx, func(ctx context.Context, tx ports.WriteTx) error {
+		err := tx.GetUserByEmail(ctx, &user)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if user.IsBanned() || s.throttle(&user) != nil {
+			return nil
+		}
+
+		sent = true
+
+		return tx.UpdateUser(ctx, &user)
+	})
+	if err != nil || !sent {
+		return err
+	}
+
+	return s.mailer.Send(ctx, ports.Mail{
+		To:      user.Email,
+		Subject: "You already have an account",
+		Text: fmt.Sprintf("Hi %s,\n\nsomeone tried to sign up with your email address, which already has an account. "+
+			"If this was you, log in instead or reset your password. Otherwise you can ignore this email.\n", user.Name),
+	})
+}
+
+// RequestEmailChange sends a link to the new address that changes the email
+// address of the user once it is opened. The current address is notified.
+func (s *Service) RequestEmailChange(ctx context.Context, userID uuid.UUID, email string) error {
+	user := models.User{ID: userID}
+	var token string
+
+	err := s.store.ReadWriteTx(ctx, func(ctx context.Context, tx ports.WriteTx) error {
+		if err := tx.GetUser(ctx, &user); err != nil {
+			return err
+		}
+
+		if strings.EqualFold(user.Email, email) {
+			return ErrAlreadyVerified
+		}
+
+		if err := emailAvailable(ctx, tx, user.ID, email); err != nil {
+			return err
+		}
+
+		if err := s.throttle(&user); err != nil {
+			return err
+		}
+
+		var err error
+		token, err = Issue(ctx, tx, identifier(purposeChangeEmail, user.ID, email), s.emailTTL)
+		if err != nil {
+			return err
+		}
+
+		return tx.UpdateUser(ctx, &user)
+	})
+	if err != nil {
+		return err
+	}
+
+	err = s.mailer.Send(ctx, ports.Mail{
+		To:      email,
+		Subject: "Confirm your new email address",
+		Text: fmt.Sprintf("Hi %s,\n\nplease confirm your new email address by opening the link below.\n\n%s\n\nThe link expires in %s.\n",
+			user.Name, s.confirmURL(token), s.emailTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	s.notify(ctx, user.Email, "Your email address is about to change",
+		fmt.Sprintf("Hi %s,\n\na change of the email address of your account to %s was requested. "+
+			"If this was not you, secure your account and do not share the link sent to the new address.\n", user.Name, email))
+
+	return nil
+}
+
+// ConfirmEmail consumes a token sent by SendEmailVerification or
+// RequestEmailChange and verifies or changes the email address.
+func (s *Service) ConfirmEmail(ctx context.Context, token string) (models.User, error) {
+	user := models.User{}
+	previous := ""
+
+	err := hooks.Defer(ctx, s.hooks, func(run hooks.RunFunc) error {
+		return s.store.ReadWriteTx(ctx, func(ctx context.Context, tx ports.WriteTx) error {
+			var err error
+			user, previous, err = s.confirmEmail(ctx, tx, run, token)
+
+			return err
+		})
+	})
+	if err != nil {
+		return models.User{}, err
+	}
+
+	if previous != "" {
+		s.notify(ctx, previous, "Your email address was changed",
+			fmt.Sprintf("Hi %s,\n\nthe email address of your account was changed to %s. "+
+				"If this was not you, contact support immediately.\n", user.Name, user.Email))
+	}
+
+	return user, nil
+}
+
+// confirmEmail consumes the token and verifies or changes the email address
+// of the user. It returns the user and the former address of a change.
+func (s *Service) confirmEmail(ctx context.Context, tx ports.WriteTx, run hooks.RunFunc, token string) (models.User, string, error) {
+	vt, err := Consume(ctx, tx, token)
+	if err != nil {
+		return models.User{}, "", err
+	}
+
+	purpose, id, email, ok := parseIdentifier(vt.Identifier)
+	if !ok {
+		return models.User{}, "", ErrInvalidToken
+	}
+
+	user := models.User{ID: id}
+	if err := tx.GetUser(ctx, &user); err != nil {
+		return models.User{}, "", err
+	}
+
+	before := snapshot(user)
+	action := audit.ActionEmailVerified
+	previous := ""
+
+	switch purpose {
+	case purposeVerifyEmail:
+		// The address changed since the link was sent.
+		if !strings.EqualFold(user.Email, email) {
+			return models.User{}, "", ErrInvalidToken
+		}
+	case purposeChangeEmail:
+		if err := emailAvailable(ctx, tx, user.ID, email); err != nil {
+			return models.User{}, "", err
+		}
+
+		previous = user.Email
+		user.Email = email
+		user.IsAnonymous = false
+		action = audit.ActionEmailChanged
+
+		if err := run(ports.HookBeforeUserCreated, &user); err != nil {
+			return models.User{}, "", err
+		}
+	default:
+		return models.User{}, "", ErrInvalidToken
+	}
+
+	user.EmailVerifiedAt = time.Now()
+	if user.ConfirmedAt.IsZero() {
+		user.ConfirmedAt = user.EmailVerifiedAt
+	}
+
+	if err := tx.UpdateUser(ctx, &user); err != nil {
+		return models.User{}, "", err
+	}
+
+	return user, previous, audit.Record(ctx, tx, action, audit.User(user.ID), audit.Diff(before, user))
+}
+
+// snapshot copies the user before a change, hooks update the metadata in place.
+func snapshot(user models.User) models.User {
+	user.AppMetadata = maps.Clone(user.AppMetadata)
+	user.UserMetadata = maps.Clone(user.UserMetadata)
+	return user
+}
+
+// notify sends an informational email. Failures are reported, as the
+// operation that triggered the notification already succeeded.
+func (s *Service) notify(ctx context.Context, to, subject, text string) {
+	if to == "" {
+		return
+	}
+
+	if err := s.mailer.Send(ctx, ports.Mail{To: to, Subject: subject, Text: text}); err != nil {
+		s.onError(fmt.Errorf("notify: %w", err))
+	}
+}
+
+func (s *Service) confirmURL(token string) string {
+	return s.baseURL + "/auth/email/confirm?token=" + url.QueryEscape(token)
+}
+
+func emailAvailable(ctx context.Context, tx ports.ReadTx, userID uuid.UUID, email string) error {
+	other := models.User{Email: email}
+
+	err := tx.GetUserByEmail(ctx, &other)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return nil
+	case err != nil:
+		return err
+	case other.ID != userID:
+		return ErrEmailInUse
+	default:
+		return nil
+	}
+}
+
+func identifier(purpose string, userID uuid.UUID, value string) string {
+	return purpose + ":" + userID.String() + ":" + value
+}
+
+func parseIdentifier(identifier string) (string, uuid.UUID, string, bool) {
+	parts := strings.SplitN(identifier, ":", 3)
+	if len(parts) != 3 {
+		return "", uuid.Nil, "", false
+	}
+
+	id, err := uuid.Parse(parts[1])
+	if err != nil {
+		return "", uuid.Nil, "", false
+	}
+
+	return parts[0], id, parts[2], true
+}
@@ -0,0 +1,178 @@
+package verification
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/open-cloud-initiative/glue/auth/internal/audit"
+	"github.com/open-cloud-initiative/glue/auth/internal/models"
+	"github.com/open-cloud-initiative/glue/auth/internal/ports"
+
+	"gorm.io/gorm"
+)
+
+// purposeResetPassword is the purpose of password reset tokens.
+const purposeResetPassword = "password-reset"
+
+// SendPasswordReset sends a link that resets the password to the email address.
+// To not reveal which addresses are registered, it succeeds if no user has the
+// address or a link was sent recently.
+func (s *Service) SendPasswordReset(ctx context.Context, email string) error {
+	if s.passwords == nil {
+		return ErrPasswordsDisabled
+	}
+
+	user := models.User{Email: email}
+	var token string
+
+	err := s.store.ReadWriteTx(ctx, func(ctx context.Context, tx ports.WriteTx) error {
+		err := tx.GetUserByEmail(ctx, &user)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if user.IsBanned() || s.throttle(&user) != nil {
+			return nil
+		}
+
+		token, err = Issue(ctx, tx, identifier(purposeResetPassword, user.ID, user.Email), s.resetTTL)
+		if err != nil {
+			return err
+		}
+
+		return tx.UpdateUser(ctx, &user)
+	})
+	if err != nil || token == "" {
+		return err
+	}
+
+	return s.mailer.Send(ctx, ports.Mail{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Text: fmt.Sprintf("Hi %s,\n\nopen the link below to choose a new password.\n\n%s\n\n"+
+			"The link expires in %s. If you did not request it, you can ignore this email.\n",
+			user.Name, s.resetURL+"?token="+url.QueryEscape(token), s.resetTTL),
+	})
+}
+
+// ResetPassword sets the password of the user with a token sent by
+// SendPasswordReset. All sessions of the user are revoked. If the email
+// address was not verified, whoever signed up with it may not own it, so the
+// linked accounts, the phone number and the second factors are removed as well.
+func (s *Service) ResetPassword(ctx context.Context, token, password string) (models.User, error) {
+	if s.passwords == nil {
+		return models.User{}, ErrPasswordsDisabled
+	}
+
+	hash, err := s.passwords.HashPassword(password)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	user := models.User{}
+
+	err = s.store.ReadWriteTx(ctx, func(ctx context.Context, tx ports.WriteTx) error {
+		vt, err := Consume(ctx, tx, token)
+		if err != nil {
+			return err
+		}
+
+		purpose, id, email, ok := parseIdentifier(vt.Identifier)
+		if !ok || purpose != purposeResetPassword {
+			return ErrInvalidToken
+		}
+
+		user.ID = id
+		if err := tx.GetUser(ctx, &user); err != nil {
+			return err
+		}
+
+		// The address changed since the link was sent.
+		if !strings.EqualFold(user.Email, email) {
+			return ErrInvalidToken
+		}
+
+		if user.IsBanned() {
+			return ports.ErrUserBanned
+		}
+
+		if err := s.passwords.SavePassword(ctx, tx, user.ID, hash); err != nil {
+			return err
+		}
+
+		var revoked []models.Session
+		if err := tx.DeleteUserSessions(ctx, &user, &revoked); err != nil {
+			return err
+		}
+
+		if err := audit.RecordRevoked(ctx, tx, revoked); err != nil {
+			return err
+		}
+
+		// The link proves that the user receives emails to the address.
+		if user.EmailVerifiedAt.IsZero() {
+			if err := reclaim(ctx, tx, &user); err != nil {
+				return err
+			}
+
+			user.EmailVerifiedAt = time.Now()
+			if err := tx.UpdateUser(ctx, &user); err != nil {
+				return err
+			}
+		}
+
+		return audit.Record(ctx, tx, audit.ActionPasswordReset, audit.User(user.ID), nil)
+	})
+	if err != nil {
+		return models.User{}, err
+	}
+
+	return user, nil
+}
+
+// reclaim removes the linked accounts, the phone number and the second
+// factors of a user whose email address was not verified.
+func reclaim(ctx context.Context, tx ports.WriteTx, user *models.User) error {
+	if err := tx.ListAccounts(ctx, user); err != nil {
+		return err
+	}
+
+	for _, account := range user.Accounts {
+		if err := tx.DeleteAccount(ctx, &account); err != nil {
+			return err
+		}
+
+		if err := audit.Record(ctx, tx, audit.ActionAccountUnlinked, audit.Account(account), nil); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.ListMFAFactors(ctx, user); err != nil {
+		return err
+	}
+
+	for _, factor := range user.MfaFactors {
+		if err := tx.DeleteMFAFactor(ctx, factor); err != nil {
+			return err
+		}
+
+		if err := audit.Record(ctx, tx, audit.ActionMFARemoved, audit.Factor(*factor), nil); err != nil {
+			return err
+		}
+	}
+
+	user.Accounts = nil
+	user.MfaFactors = nil
+	user.PhoneNumber = ""
+	user.PhoneNumberVerifiedAt = time.Time{}
+
+	return nil
+}
@@ -0,0 +1,315 @@
+package verification
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"regexp"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/open-cloud-initiative/glue/auth/internal/audit"
+	"github.com/open-cloud-initiative/glue/auth/internal/models"
+	"github.com/open-cloud-initiative/glue/auth/internal/password"
+	"github.com/open-cloud-initiative/glue/auth/internal/ports"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var resetLink = regexp.MustCompile(`\?token=(\S+)`)
+
+// store keeps one user with its tokens, password, sessions and accounts in memory.
+type store struct {
+	user     models.User
+	tokens   map[string]models.VerificationToken
+	hash     string
+	sessions []models.Session
+	events   []string
+}
+
+func (s *store) ReadTx(ctx context.Context, fn func(context.Context, ports.ReadTx) error) error {
+	return fn(ctx, &writeTx{store: s})
+}
+
+func (s *store) ReadWriteTx(ctx context.Context, fn func(context.Context, ports.WriteTx) error) error {
+	return fn(ctx, &writeTx{store: s})
+}
+
+func (s *store) Migrate(context.Context, ...any) error {
+	return nil
+}
+
+func (s *store) Close() error {
+	return nil
+}
+
+type writeTx struct {
+	ports.WriteTx
+	store *store
+}
+
+func (t *writeTx) GetUserByEmail(_ context.Context, user *models.User) error {
+	if user.Email != t.store.user.Email {
+		return gorm.ErrRecordNotFound
+	}
+
+	*user = t.store.user
+
+	return nil
+}
+
+func (t *writeTx) GetUser(_ context.Context, user *models.User) error {
+	if user.ID != t.store.user.ID {
+		return gorm.ErrRecordNotFound
+	}
+
+	*user = t.store.user
+
+	return nil
+}
+
+func (t *writeTx) UpdateUser(_ context.Context, user *models.User) error {
+	t.store.user = *user
+	return nil
+}
+
+func (t *writeTx) DeleteVerificationTokens(_ context.Context, identifier string) error {
+	for k, vt := range t.store.tokens {
+		if vt.Identifier == identifier {
+			delete(t.store.tokens, k)
+		}
+	}
+
+	return nil
+}
+
+func (t *writeTx) CreateVerificationToken(_ context.Context, token *models.VerificationToken) error {
+	t.store.tokens[token.Token] = *token
+	return nil
+}
+
+func (t *writeTx) ConsumeVerificationToken(_ context.Context, token *models.VerificationToken) error {
+	vt, ok := t.store.tokens[token.Token]
+	if !ok || time.Now().After(vt.ExpiresAt) {
+		return gorm.ErrRecordNotFound
+	}
+
+	delete(t.store.tokens, token.Token)
+	*token = vt
+
+	return nil
+}
+
+func (t *writeTx) SavePasswordCredential(_ context.Context, credential *models.PasswordCredential) error {
+	t.store.hash = credential.Hash
+	return nil
+}
+
+func (t *writeTx) DeleteUserSessions(_ context.Context, _ *models.User, sessions *[]models.Session) error {
+	*sessions, t.store.sessions = t.store.sessions, nil
+	return nil
+}
+
+func (t *writeTx) ListAccounts(_ context.Context, user *models.User) error {
+	user.Accounts = t.store.user.Accounts
+	return nil
+}
+
+func (t *writeTx) DeleteAccount(context.Context, *models.Account) error {
+	return nil
+}
+
+func (t *writeTx) ListMFAFactors(_ context.Context, user *models.User) error {
+	user.MfaFactors = nil
+	return nil
+}
+
+func (t *writeTx) CreateAuditEvent(_ context.Context, event *models.AuditEvent) error {
+	t.store.events = append(t.store.events, event.Action)
+	return nil
+}
+
+func (t *writeTx) CreateOutboxEvent(context.Context, *models.OutboxEvent) error {
+	return nil
+}
+
+// mailer keeps the sent mails.
+type mailer struct {
+	mails []ports.Mail
+}
+
+func (m *mailer) Send(_ context.Context, mail ports.Mail) error {
+	m.mails = append(m.mails, mail)
+	return nil
+}
+
+// token returns the token of the last reset link that was sent.
+func (m *mailer) token(t *testing.T) string {
+	t.Helper()
+
+	if len(m.mails) == 0 {
+		t.Fatal("no reset link was sent")
+	}
+
+	match := resetLink.FindStringSubmatch(m.mails[len(m.mails)-1].Text)
+	if match == nil {
+		t.Fatalf("mail %q has no reset link", m.mails[len(m.mails)-1].Text)
+	}
+
+	token, err := url.QueryUnescape(match[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return token
+}
+
+func newService(s *store, m *mailer, opts ...Opt) *Service {
+	passwords := password.New(s,
+		password.WithParams(password.Params{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}),
+		password.WithPolicy(password.Policy{MinLength: 12}),
+	)
+
+	return New(s, m, "https://auth.example.com", append([]Opt{WithPasswords(passwords)}, opts...)...)
+}
+
+func TestResetPassword(t *testing.T) {
+	const newPassword = "correct horse battery"
+
+	tests := []struct {
+		name     string
+		verified bool
+		opts     []Opt
+		change   func(s *store)
+		token    func(token string) string
+		password string
+		err      error
+		fails    bool
+		accounts int
+	}{
+		{name: "unverified address", password: newPassword},
+		{name: "verified address", verified: true, password: newPassword, accounts: 1},
+		{name: "unknown token", token: func(string) string { return "unknown" }, password: newPassword, err: ErrInvalidToken, fails: true},
+		{name: "expired token", opts: []Opt{WithResetTTL(-time.Minute)}, password: newPassword, err: ErrInvalidToken, fails: true},
+		{name: "address changed", change: func(s *store) { s.user.Email = "eve@example.com" }, password: newPassword, err: ErrInvalidToken, fails: true},
+		{name: "banned user", change: func(s *store) { s.user.BannedUntil = time.Now().Add(time.Hour) }, password: newPassword, err: ports.ErrUserBanned, fails: true},
+		{name: "password violates the policy", password: "short", fails: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := models.User{ID: uuid.New(), Email: "ada@example.com", Accounts: []models.Account{{ID: uuid.New(), Provider: "github"}}}
+			if tt.verified {
+				user.EmailVerifiedAt = time.Now()
+			}
+
+			s := &store{user: user, tokens: map[string]models.VerificationToken{}, sessions: []models.Session{{ID: uuid.New(), UserID: user.ID}}}
+			m := &mailer{}
+			svc := newService(s, m, tt.opts...)
+
+			if err := svc.SendPasswordReset(t.Context(), user.Email); err != nil {
+				t.Fatal(err)
+			}
+
+			token := m.token(t)
+			if tt.token != nil {
+				token = tt.token(token)
+			}
+
+			if tt.change != nil {
+				tt.change(s)
+			}
+
+			got, err := svc.ResetPassword(t.Context(), token, tt.password)
+			if (err != nil) != tt.fails || (tt.err != nil && !errors.Is(err, tt.err)) {
+				t.Fatalf("ResetPassword() error = %v, want %v", err, tt.err)
+			}
+
+			if tt.fails {
+				if s.hash != "" || len(s.sessions) != 1 {
+					t.Error("ResetPassword() changed the password or revoked sessions of a failed reset")
+				}
+
+				return
+			}
+
+			if ok, err := password.Verify(tt.password, s.hash); err != nil || !ok {
+				t.Errorf("Verify() of the new password = %v, %v, want true", ok, err)
+			}
+
+			if len(s.sessions) != 0 {
+				t.Errorf("ResetPassword() kept %d sessions, want all revoked", len(s.sessions))
+			}
+
+			if got.EmailVerifiedAt.IsZero() || len(got.Accounts) != tt.accounts {
+				t.Errorf("ResetPassword() = %d accounts, verified at %v, want %d accounts and a verified address", len(got.Accounts), got.EmailVerifiedAt, tt.accounts)
+			}
+
+			if !slices.Contains(s.events, audit.ActionPasswordReset) {
+				t.Errorf("ResetPassword() recorded %v, want %s", s.events, audit.ActionPasswordReset)
+			}
+
+			if _, err := svc.ResetPassword(t.Context(), token, tt.password); !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("ResetPassword() with a used token error = %v, want %v", err, ErrInvalidToken)
+			}
+		})
+	}
+}
+
+func TestResetPasswordAfterPolicyViolation(t *testing.T) {
+	user := models.User{ID: uuid.New(), Email: "ada@example.com"}
+	s := &store{user: user, tokens: map[string]models.VerificationToken{}}
+	m := &mailer{}
+	svc := newService(s, m)
+
+	if err := svc.SendPasswordReset(t.Context(), user.Email); err != nil {
+		t.Fatal(err)
+	}
+
+	token := m.token(t)
+
+	var perr *password.PolicyError
+	if _, err := svc.ResetPassword(t.Context(), token, "short"); !errors.As(err, &perr) {
+		t.Fatalf("ResetPassword() error = %v, want a PolicyError", err)
+	}
+
+	if _, err := svc.ResetPassword(t.Context(), token, "correct horse battery"); err != nil {
+		t.Errorf("ResetPassword() error = %v, want the token to be kept after a policy violation", err)
+	}
+}
+
+func TestSendPasswordReset(t *testing.T) {
+	tests := []struct {
+		name  string
+		email string
+		user  models.User
+		mails int
+	}{
+		{name: "registered address", email: "ada@example.com", user: models.User{ID: uuid.New(), Email: "ada@example.com"}, mails: 1},
+		{name: "unknown address", email: "bob@example.com", user: models.User{ID: uuid.New(), Email: "ada@example.com"}},
+		{name: "banned user", email: "ada@example.com", user: models.User{ID: uuid.New(), Email: "ada@example.com", BannedUntil: time.Now().Add(time.Hour)}},
+		{name: "sent recently", email: "ada@example.com", user: models.User{ID: uuid.New(), Email: "ada@example.com", ConfirmationSentAt: time.Now()}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &store{user: tt.user, tokens: map[string]models.VerificationToken{}}
+			m := &mailer{}
+
+			if err := newService(s, m).SendPasswordReset(t.Context(), tt.email); err != nil {
+				t.Fatalf("SendPasswordReset() error = %v", err)
+			}
+
+			if len(m.mails) != tt.mails || len(s.tokens) != tt.mails {
+				t.Errorf("SendPasswordReset() sent %d mails with %d tokens, want %d", len(m.mails), len(s.tokens), tt.mails)
+			}
+		})
+	}
+
+	svc := New(&store{}, &mailer{}, "https://auth.example.com")
+	if err := svc.SendPasswordReset(t.Context(), "ada@example.com"); !errors.Is(err, ErrPasswordsDisabled) {
+		t.Errorf("SendPasswordReset() error = %v, want %v", err, ErrPasswordsDisabled)
+	}
+}
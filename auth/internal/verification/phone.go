@@ -0,0 +1,422 @@
+package verification
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"time"
+
+	"github.com/open-cloud-initiative/glue/auth/internal/audit"
+	"github.com/open-cloud-initiative/glue/auth/internal/models"
+	"github.com/open-cloud-initiative/glue/auth/internal/ports"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Purposes of SMS codes.
+const (
+	purposeVerifyPhone = "phone-verify"
+	purposeLogin       = "sms-login"
+	purposeMFA         = "sms-mfa"
+
+	codeDigits = 6
+)
+
+var e164 = regexp.MustCompile(`^\+[1-9][0-9]{6,14}$`)
+
+// ValidPhone returns true if the phone number is in E.164 format.
+func ValidPhone(phone string) bool {
+	return e164.MatchString(phone)
+}
+
+// SendPhoneVerification sends a code that verifies the phone number for the user.
+func (s *Service) SendPhoneVerification(ctx context.Context, userID uuid.UUID, phone string) error {
+	if err := s.checkNumber(ctx, phone); err != nil {
+		return err
+	}
+
+	var code string
+
+	err := s.store.ReadWriteTx(ctx, func(ctx context.Context, tx ports.WriteTx) error {
+		user := models.User{ID: userID}
+		if err := tx.GetUser(ctx, &user); err != nil {
+			return err
+		}
+
+		if user.PhoneNumber == phone && !user.PhoneNumberVerifiedAt.IsZero() {
+			return ErrAlreadyVerified
+		}
+
+		if err := phoneAvailable(ctx, tx, userID, phone); err != nil {
+			return err
+		}
+
+		var err error
+		code, err = issueCode(ctx, tx, identifier(purposeVerifyPhone, userID, phone), s.codeTTL)
+
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.sms.SendSMS(ctx, phone, fmt.Sprintf("Your verification code is %s. It expires in %s.", code, s.codeTTL))
+}
+
+// ConfirmPhone verifies the phone number of the user with a code sent by SendPhoneVerification.
+// If it replaces a verified number, the SMS factor moves to the new number and
+// the previous number and the email address are notified.
+func (s *Service) ConfirmPhone(ctx context.Context, userID uuid.UUID, phone, code string) (models.User, error) {
+	id := identifier(purposeVerifyPhone, userID, phone)
+	user := models.User{ID: userID}
+	previous := ""
+
+	if err := s.attempt(ctx, id); err != nil {
+		return models.User{}, err
+	}
+
+	err := s.store.ReadWriteTx(ctx, func(ctx context.Context, tx ports.WriteTx) error {
+		if err := useCode(ctx, tx, id, code); err != nil {
+			return err
+		}
+
+		if err := tx.GetUser(ctx, &user); err != nil {
+			return err
+		}
+
+		if err := phoneAvailable(ctx, tx, userID, phone); err != nil {
+			return err
+		}
+
+		if !user.PhoneNumberVerifiedAt.IsZero() && user.PhoneNumber != phone {
+			previous = user.PhoneNumber
+		}
+
+		before := user
+		user.PhoneNumber = phone
+		user.PhoneNumberVerifiedAt = time.Now()
+		user.IsAnonymous = false
+
+		if err := tx.UpdateUser(ctx, &user); err != nil {
+			return err
+		}
+
+		if err := tx.ListMFAFactors(ctx, &user); err != nil {
+			return err
+		}
+
+		// The factor must not keep sending codes to a number the user gave up.
+		if f := smsFactor(user); f != nil && f.PhoneNumber != phone {
+			f.PhoneNumber = phone
+			if err := tx.UpdateMFAFactor(ctx, f); err != nil {
+				return err
+			}
+		}
+
+		return audit.Record(ctx, tx, audit.ActionPhoneVerified, audit.User(userID), audit.Diff(before, user))
+	})
+	if err != nil {
+		return models.User{}, err
+	}
+
+	if previous != "" {
+		if err := s.sms.SendSMS(ctx, previous, "The phone number of your account was changed. "+
+			"If this was not you, contact support immediately."); err != nil {
+			s.onError(fmt.Errorf("notify: %w", err))
+		}
+
+		s.notify(ctx, user.Email, "Your phone number was changed",
+			fmt.Sprintf("Hi %s,\n\nthe phone number of your account was changed to %s. "+
+				"If this was not you, contact support immediately.\n", user.Name, phone))
+	}
+
+	return user, nil
+}
+
+// SendLoginCode sends a login code to a verified phone number. To not reveal
+// which numbers are registered, it succeeds if no user has the number.
+func (s *Service) SendLoginCode(ctx context.Context, phone string) error {
+	if err := s.checkNumber(ctx, phone); err != nil {
+		return err
+	}
+
+	var code string
+
+	err := s.store.ReadWriteTx(ctx, func(ctx context.Context, tx ports.WriteTx) error {
+		user := models.User{PhoneNumber: phone}
+
+		err := tx.GetUserByPhone(ctx, &user)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if user.IsBanned() {
+			return nil
+		}
+
+		code, err = issueCode(ctx, tx, purposeLogin+":"+phone, s.codeTTL)
+
+		return err
+	})
+	if err != nil || code == "" {
+		return err
+	}
+
+	return s.sms.SendSMS(ctx, phone, fmt.Sprintf("Your login code is %s. It expires in %s.", code, s.codeTTL))
+}
+
+// VerifyLoginCode logs in the user with the phone number with a code sent by SendLoginCode.
+func (s *Service) VerifyLoginCode(ctx context.Context, phone, code string) (models.User, error) {
+	id := purposeLogin + ":" + phone
+	user := models.User{PhoneNumber: phone}
+
+	if err := s.attempt(ctx, id); err != nil {
+		return models.User{}, err
+	}
+
+	err := s.store.ReadWriteTx(ctx, func(ctx context.Context, tx ports.WriteTx) error {
+		if err := useCode(ctx, tx, id, code); err != nil {
+			return err
+		}
+
+		if err := tx.GetUserByPhone(ctx, &user); err != nil {
+			return err
+		}
+
+		if user.IsBanned() {
+			return ports.ErrUserBanned
+		}
+
+		user.LastSignedInAt = time.Now()
+		user.ReauthenticatedAt = user.LastSignedInAt
+
+		return tx.UpdateUser(ctx, &user)
+	})
+	if err != nil {
+		return models.User{}, err
+	}
+
+	return user, nil
+}
+
+// EnrollSMSFactor adds the verified phone number of the user as SMS factor.
+// New sessions of the user require a code sent to the number.
+func (s *Service) EnrollSMSFactor(ctx context.Context, userID uuid.UUID) (models.MFAFactor, error) {
+	factor := models.MFAFactor{}
+
+	err := s.store.ReadWriteTx(ctx, func(ctx context.Context, tx ports.WriteTx) error {
+		user := models.User{ID: userID}
+		if err := tx.GetUser(ctx, &user); err != nil {
+			return err
+		}
+
+		if user.PhoneNumberVerifiedAt.IsZero() {
+			return ErrPhoneNotVerified
+		}
+
+		if err := tx.ListMFAFactors(ctx, &user); err != nil {
+			return err
+		}
+
+		if f := smsFactor(user); f != nil && f.PhoneNumber == user.PhoneNumber {
+			factor = *f
+			return nil
+		}
+
+		factor = models.MFAFactor{
+			UserID:       userID,
+			Status:       models.MFAFactorStatus_MFA_FACTOR_STATUS_VERIFIED,
+			Type:         models.MFAFactorType_FACTOR_TYPE_PHONE,
+			FriendlyName: "SMS",
+			PhoneNumber:  user.PhoneNumber,
+		}
+
+		if err := tx.CreateMFAFactor(ctx, &factor); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, tx, audit.ActionMFAEnrolled, audit.Factor(factor), nil)
+	})
+
+	return factor, err
+}
+
+// RemoveMFAFactor removes an MFA factor of the user.
+func (s *Service) RemoveMFAFactor(ctx context.Context, userID uuid.UUID, id string) error {
+	return s.store.ReadWriteTx(ctx, func(ctx context.Context, tx ports.WriteTx) error {
+		factor := models.MFAFactor{Id: id, UserID: userID}
+		if err := tx.DeleteMFAFactor(ctx, &factor); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, tx, audit.ActionMFARemoved, audit.Factor(factor), nil)
+	})
+}
+
+// SendMFACode sends a code to the SMS factor of the user.
+func (s *Service) SendMFACode(ctx context.Context, userID uuid.UUID) error {
+	if s.sms == nil {
+		return ErrSMSDisabled
+	}
+
+	var (
+		code  string
+		phone string
+	)
+
+	err := s.store.ReadWriteTx(ctx, func(ctx context.Context, tx ports.WriteTx) error {
+		user := models.User{ID: userID}
+		if err := tx.ListMFAFactors(ctx, &user); err != nil {
+			return err
+		}
+
+		factor := smsFactor(user)
+		if factor == nil {
+			return ErrNoFactor
+		}
+
+		phone = factor.PhoneNumber
+		if err := s.limitNumber(ctx, phone); err != nil {
+			return err
+		}
+
+		factor.LastChallengedAt = time.Now()
+		if err := tx.UpdateMFAFactor(ctx, factor); err != nil {
+			return err
+		}
+
+		var err error
+		code, err = issueCode(ctx, tx, purposeMFA+":"+userID.String(), s.codeTTL)
+
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.sms.SendSMS(ctx, phone, fmt.Sprintf("Your security code is %s. It expires in %s.", code, s.codeTTL))
+}
+
+// VerifyMFACode verifies a code sent by SendMFACode.
+func (s *Service) VerifyMFACode(ctx context.Context, userID uuid.UUID, code string) error {
+	id := purposeMFA + ":" + userID.String()
+
+	if err := s.attempt(ctx, id); err != nil {
+		return err
+	}
+
+	return s.store.ReadWriteTx(ctx, func(ctx context.Context, tx ports.WriteTx) error {
+		return useCode(ctx, tx, id, code)
+	})
+}
+
+// checkNumber checks that SMS is enabled, that the number is valid
+// and that the number did not receive too many messages.
+func (s *Service) checkNumber(ctx context.Context, phone string) error {
+	if s.sms == nil {
+		return ErrSMSDisabled
+	}
+
+	if !ValidPhone(phone) {
+		return ErrInvalidPhone
+	}
+
+	return s.limitNumber(ctx, phone)
+}
+
+func (s *Service) limitNumber(ctx context.Context, phone string) error {
+	res, err := s.numberLimit.Allow(ctx, "number:"+phone)
+	if err != nil {
+		return err
+	}
+
+	if !res.Allowed {
+		return ErrThrottled
+	}
+
+	return nil
+}
+
+// attempt counts an attempt to enter the code of the identifier,
+// so that codes can not be guessed.
+func (s *Service) attempt(ctx context.Context, identifier string) error {
+	res, err := s.attemptLimit.Allow(ctx, "attempt:"+identifier)
+	if err != nil {
+		return err
+	}
+
+	if !res.Allowed {
+		return ErrThrottled
+	}
+
+	return nil
+}
+
+func phoneAvailable(ctx context.Context, tx ports.ReadTx, userID uuid.UUID, phone string) error {
+	other := models.User{PhoneNumber: phone}
+
+	err := tx.GetUserByPhone(ctx, &other)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return nil
+	case err != nil:
+		return err
+	case other.ID != userID:
+		return ErrPhoneInUse
+	default:
+		return nil
+	}
+}
+
+func smsFactor(user models.User) *models.MFAFactor {
+	for _, f := range user.MfaFactors {
+		if f.Type == models.MFAFactorType_FACTOR_TYPE_PHONE && f.Status == models.MFAFactorStatus_MFA_FACTOR_STATUS_VERIFIED {
+			return f
+		}
+	}
+
+	return nil
+}
+
+// issueCode replaces the outstanding codes of the identifier with a new code.
+// Codes are short, so the hash includes the identifier to keep tokens unique.
+func issueCode(ctx context.Context, tx ports.WriteTx, identifier string, ttl time.Duration) (string, error) {
+	if err := tx.DeleteVerificationTokens(ctx, identifier); err != nil {
+		return "", err
+	}
+
+	n, err := rand.Int(rand.Reader, big.NewInt(1_000_000))
+	if err != nil {
+		return "", err
+	}
+
+	code := fmt.Sprintf("%0*d", codeDigits, n)
+
+	err = tx.CreateVerificationToken(ctx, &models.VerificationToken{
+		Token:      hash(identifier + ":" + code),
+		Identifier: identifier,
+		ExpiresAt:  time.Now().Add(ttl),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return code, nil
+}
+
+func useCode(ctx context.Context, tx ports.WriteTx, identifier, code string) error {
+	err := tx.UseVerificationToken(ctx, &models.VerificationToken{Identifier: identifier, Token: hash(identifier + ":" + code)})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrInvalidToken
+	}
+
+	return err
+}
@@ -0,0 +1,253 @@
+// Package verification confirms the contact addresses of users
+// with one-time tokens that are sent to them.
+package verification
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/open-cloud-initiative/glue/auth/internal/models"
+	"github.com/open-cloud-initiative/glue/auth/internal/ports"
+	"github.com/open-cloud-initiative/glue/auth/internal/ratelimit"
+
+	"github.com/google/uuid"
+	"github.com/katallaxie/pkg/dbx"
+	"gorm.io/gorm"
+)
+
+const (
+	// DefaultResendInterval is the default time a user has to wait before another message is sent.
+	DefaultResendInterval = time.Minute
+	// DefaultEmailTTL is the default lifetime of an email verification link.
+	DefaultEmailTTL = 24 * time.Hour
+	// DefaultCodeTTL is the default lifetime of a code sent by SMS.
+	DefaultCodeTTL = 10 * time.Minute
+	// DefaultNumberLimit is the default number of messages sent to a phone number per hour.
+	DefaultNumberLimit = 5
+	// DefaultAttemptLimit is the default number of attempts to enter a code.
+	DefaultAttemptLimit = 5
+	// DefaultResetTTL is the default lifetime of a password reset link.
+	DefaultResetTTL = time.Hour
+
+	tokenLength = 32
+)
+
+var (
+	// ErrThrottled is returned when a message was sent too recently.
+	ErrThrottled = errors.New("a message was sent recently, try again later")
+	// ErrAlreadyVerified is returned when the address is already verified.
+	ErrAlreadyVerified = errors.New("address is already verified")
+	// ErrNoEmail is returned when the user has no email address.
+	ErrNoEmail = errors.New("user has no email address")
+	// ErrEmailInUse is returned when the email address belongs to another user.
+	ErrEmailInUse = errors.New("email address is used by another user")
+	// ErrInvalidToken is returned when a token is unknown, expired or used.
+	ErrInvalidToken = errors.New("invalid or expired token")
+	// ErrInvalidPhone is returned when a phone number is not in E.164 format.
+	ErrInvalidPhone = errors.New("phone number must be in E.164 format, e.g. +4915112345678")
+	// ErrPhoneInUse is returned when the phone number is verified by another user.
+	ErrPhoneInUse = errors.New("phone number is used by another user")
+	// ErrPhoneNotVerified is returned when the user has no verified phone number.
+	ErrPhoneNotVerified = errors.New("user has no verified phone number")
+	// ErrNoFactor is returned when the user has no SMS factor.
+	ErrNoFactor = errors.New("user has no sms factor")
+	// ErrSMSDisabled is returned when no SMS sender is configured.
+	ErrSMSDisabled = errors.New("sms is not enabled")
+	// ErrPasswordsDisabled is returned when passwords are not enabled.
+	ErrPasswordsDisabled = errors.New("passwords are not enabled")
+)
+
+// PasswordSetter sets the password of a user.
+type PasswordSetter interface {
+	// HashPassword checks the password against the policy and hashes it.
+	HashPassword(password string) (string, error)
+	// SavePassword sets the hash as the password of the user within the transaction.
+	SavePassword(ctx context.Context, tx ports.WriteTx, userID uuid.UUID, hash string) error
+}
+
+// Service sends verification messages and confirms them.
+type Service struct {
+	store          dbx.Database[ports.ReadTx, ports.WriteTx]
+	mailer         ports.Mailer
+	sms            ports.SMSSender
+	baseURL        string
+	resendInterval time.Duration
+	emailTTL       time.Duration
+	codeTTL        time.Duration
+	numberLimit    ratelimit.Limiter
+	attemptLimit   ratelimit.Limiter
+	passwords      PasswordSetter
+	resetURL       string
+	resetTTL       time.Duration
+	hooks          ports.Hooks
+	onError        func(error)
+}
+
+// Opt is a function that configures the Service.
+type Opt func(*Service)
+
+// WithResendInterval sets the time a user has to wait before another message is sent.
+func WithResendInterval(interval time.Duration) Opt {
+	return func(s *Service) {
+		s.resendInterval = interval
+	}
+}
+
+// WithEmailTTL sets the lifetime of email verification links.
+func WithEmailTTL(ttl time.Duration) Opt {
+	return func(s *Service) {
+		s.emailTTL = ttl
+	}
+}
+
+// WithSMS sends codes to phone numbers with the sender.
+func WithSMS(sender ports.SMSSender) Opt {
+	return func(s *Service) {
+		s.sms = sender
+	}
+}
+
+// WithCodeTTL sets the lifetime of codes sent by SMS.
+func WithCodeTTL(ttl time.Duration) Opt {
+	return func(s *Service) {
+		s.codeTTL = ttl
+	}
+}
+
+// WithNumberLimit sets the limiter of the messages sent to a phone number.
+func WithNumberLimit(limiter ratelimit.Limiter) Opt {
+	return func(s *Service) {
+		s.numberLimit = limiter
+	}
+}
+
+// WithAttemptLimit sets the limiter of the attempts to enter a code.
+func WithAttemptLimit(limiter ratelimit.Limiter) Opt {
+	return func(s *Service) {
+		s.attemptLimit = limiter
+	}
+}
+
+// WithPasswords resets passwords with the setter.
+func WithPasswords(setter PasswordSetter) Opt {
+	return func(s *Service) {
+		s.passwords = setter
+	}
+}
+
+// WithResetURL sets the page password reset links point to. The page
+// receives the token as query parameter and posts it with the new password.
+func WithResetURL(url string) Opt {
+	return func(s *Service) {
+		s.resetURL = url
+	}
+}
+
+// WithResetTTL sets the lifetime of password reset links.
+func WithResetTTL(ttl time.Duration) Opt {
+	return func(s *Service) {
+		s.resetTTL = ttl
+	}
+}
+
+// WithHooks runs the hooks before the email address of a user changes.
+func WithHooks(hooks ports.Hooks) Opt {
+	return func(s *Service) {
+		s.hooks = hooks
+	}
+}
+
+// WithErrorHandler sets the function failed notifications are passed to.
+func WithErrorHandler(fn func(error)) Opt {
+	return func(s *Service) {
+		s.onError = fn
+	}
+}
+
+// New returns a new Service. Links in emails point to baseURL.
+func New(store dbx.Database[ports.ReadTx, ports.WriteTx], mailer ports.Mailer, baseURL string, opts ...Opt) *Service {
+	s := &Service{
+		store:          store,
+		mailer:         mailer,
+		baseURL:        strings.TrimSuffix(baseURL, "/"),
+		resendInterval: DefaultResendInterval,
+		emailTTL:       DefaultEmailTTL,
+		codeTTL:        DefaultCodeTTL,
+		numberLimit:    ratelimit.NewSlidingWindow(DefaultNumberLimit, time.Hour),
+		attemptLimit:   ratelimit.NewSlidingWindow(DefaultAttemptLimit, DefaultCodeTTL),
+		resetTTL:       DefaultResetTTL,
+		onError:        func(error) {},
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if s.resetURL == "" {
+		s.resetURL = s.baseURL + "/auth/password/reset"
+	}
+
+	return s
+}
+
+// throttle records that a message is sent to the user, or returns
+// ErrThrottled if the last message was sent within the resend interval.
+func (s *Service) throttle(user *models.User) error {
+	if time.Since(user.ConfirmationSentAt) < s.resendInterval {
+		return ErrThrottled
+	}
+
+	user.ConfirmationSentAt = time.Now()
+
+	return nil
+}
+
+// Issue replaces the outstanding tokens of the identifier with a new token
+// and returns the new token. Only the hash of the token is stored.
+func Issue(ctx context.Context, tx ports.WriteTx, identifier string, ttl time.Duration) (string, error) {
+	if err := tx.DeleteVerificationTokens(ctx, identifier); err != nil {
+		return "", err
+	}
+
+	b := make([]byte, tokenLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	err := tx.CreateVerificationToken(ctx, &models.VerificationToken{
+		Token:      hash(token),
+		Identifier: identifier,
+		ExpiresAt:  time.Now().Add(ttl),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// Consume deletes the unexpired token and returns it with its identifier,
+// or ErrInvalidToken if the token is unknown, expired or used.
+func Consume(ctx context.Context, tx ports.WriteTx, token string) (models.VerificationToken, error) {
+	vt := models.VerificationToken{Token: hash(token)}
+
+	err := tx.ConsumeVerificationToken(ctx, &vt)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.VerificationToken{}, ErrInvalidToken
+	}
+
+	return vt, err
+}
+
+func hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
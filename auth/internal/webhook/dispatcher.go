@@ -0,0 +1,517 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/open-cloud-initiative/glue/auth/internal/models"
+	"github.com/open-cloud-initiative/glue/auth/internal/ports"
+
+	"github.com/katallaxie/pkg/dbx"
+)
+
+const (
+	// DefaultInterval is the default time between two polls of the outbox.
+	DefaultInterval = 5 * time.Second
+	// DefaultTimeout is the default timeout of an attempt.
+	DefaultTimeout = 10 * time.Second
+	// DefaultMaxAttempts is the default number of attempts before a delivery is dead.
+	DefaultMaxAttempts = 10
+	// DefaultBackoff is the default delay after the first failed attempt, it doubles with each further failure.
+	DefaultBackoff = 30 * time.Second
+	// DefaultMaxBackoff is the default longest delay between two attempts.
+	DefaultMaxBackoff = 6 * time.Hour
+	// DefaultBatchSize is the default number of events and deliveries handled per poll.
+	DefaultBatchSize = 100
+
+	// maxErrorLength is the maximum length of the error kept of a failed attempt.
+	maxErrorLength = 512
+)
+
+// ErrConcurrentReplay is returned when a delivery of a replayed event was created meanwhile.
+var ErrConcurrentReplay = errors.New("webhook: the event was dispatched concurrently, replay it again")
+
+var deliveriesTotal = expvar.NewMap("webhook_deliveries_total")
+
+// Dispatcher delivers the events of the outbox. Any number of replicas can
+// run a Dispatcher, each event and delivery is claimed by one of them.
+type Dispatcher struct {
+	store       dbx.Database[ports.ReadTx, ports.WriteTx]
+	endpoints   []Endpoint
+	client      *http.Client
+	interval    time.Duration
+	timeout     time.Duration
+	maxAttempts int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+	batchSize   int
+	onError     func(error)
+}
+
+// Opt is a function that configures the Dispatcher.
+type Opt func(*Dispatcher)
+
+// WithClient sets the HTTP client deliveries are sent with.
+func WithClient(client *http.Client) Opt {
+	return func(d *Dispatcher) {
+		d.client = client
+	}
+}
+
+// WithInterval sets the time between two polls of the outbox.
+func WithInterval(interval time.Duration) Opt {
+	return func(d *Dispatcher) {
+		d.interval = interval
+	}
+}
+
+// WithTimeout sets the timeout of an attempt.
+func WithTimeout(timeout time.Duration) Opt {
+	return func(d *Dispatcher) {
+		d.timeout = timeout
+	}
+}
+
+// WithMaxAttempts sets the number of attempts before a delivery is dead.
+func WithMaxAttempts(attempts int) Opt {
+	return func(d *Dispatcher) {
+		d.maxAttempts = attempts
+	}
+}
+
+// WithBackoff sets the delay after the first failed attempt and the longest delay.
+func WithBackoff(backoff, maxBackoff time.Duration) Opt {
+	return func(d *Dispatcher) {
+		d.backoff = backoff
+		d.maxBackoff = maxBackoff
+	}
+}
+
+// WithBatchSize sets the number of events and deliveries handled per poll.
+func WithBatchSize(size int) Opt {
+	return func(d *Dispatcher) {
+		d.batchSize = size
+	}
+}
+
+// WithErrorHandler sets the function the failed runs of Start are passed to.
+func WithErrorHandler(fn func(error)) Opt {
+	return func(d *Dispatcher) {
+		d.onError = fn
+	}
+}
+
+// NewDispatcher returns a new Dispatcher. Publish writes events to the outbox
+// from then on, for the endpoints subscribed to them.
+func NewDispatcher(store dbx.Database[ports.ReadTx, ports.WriteTx], endpoints []Endpoint, opts ...Opt) *Dispatcher {
+	d := &Dispatcher{
+		store:       store,
+		endpoints:   endpoints,
+		client:      http.DefaultClient,
+		interval:    DefaultInterval,
+		timeout:     DefaultTimeout,
+		maxAttempts: DefaultMaxAttempts,
+		backoff:     DefaultBackoff,
+		maxBackoff:  DefaultMaxBackoff,
+		batchSize:   DefaultBatchSize,
+		onError:     func(error) {},
+	}
+
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	subscribed.Store(&d.endpoints)
+
+	return d
+}
+
+// Start polls the outbox every interval until the context is canceled.
+// A full batch is followed by the next batch without waiting.
+func (d *Dispatcher) Start(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		n, err := d.Run(ctx)
+		if err != nil && ctx.Err() == nil {
+			d.onError(err)
+		}
+
+		if err == nil && n >= d.batchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Run creates the deliveries of new events and makes one attempt of each
+// due delivery, one batch each. It returns the number of attempts made.
+func (d *Dispatcher) Run(ctx context.Context) (int, error) {
+	if err := d.dispatch(ctx); err != nil {
+		return 0, fmt.Errorf("dispatch: %w", err)
+	}
+
+	claimed, events, err := d.claim(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("claim: %w", err)
+	}
+
+	wg := sync.WaitGroup{}
+	for i := range claimed {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			d.attempt(ctx, &claimed[i], events[claimed[i].EventID])
+		}()
+	}
+	wg.Wait()
+
+	// Record the outcome even if the run was canceled meanwhile.
+	err = d.store.ReadWriteTx(context.WithoutCancel(ctx), func(ctx context.Context, tx ports.WriteTx) error {
+		for i := range claimed {
+			if err := tx.UpdateWebhookDelivery(ctx, &claimed[i]); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	return len(claimed), err
+}
+
+// dispatch creates a delivery of each new event for every endpoint subscribed to it.
+func (d *Dispatcher) dispatch(ctx context.Context) error {
+	return d.store.ReadWriteTx(ctx, func(ctx context.Context, tx ports.WriteTx) error {
+		events := []models.OutboxEvent{}
+		if err := tx.LockUndispatchedOutboxEvents(ctx, d.batchSize, &events); err != nil {
+			return err
+		}
+
+		now := time.Now()
+
+		for i := range events {
+			for _, e := range d.endpoints {
+				if !e.Subscribed(events[i].Type) {
+					continue
+				}
+
+				if err := tx.CreateWebhookDelivery(ctx, newDelivery(events[i], e.ID, now)); err != nil {
+					return err
+				}
+			}
+
+			events[i].DispatchedAt = &now
+			if err := tx.UpdateOutboxEvent(ctx, &events[i]); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// claim retrieves the due deliveries and their events, and moves their next
+// attempt past the timeout, so that no other replica attempts them meanwhile.
+func (d *Dispatcher) claim(ctx context.Context) ([]models.WebhookDelivery, map[int64]models.OutboxEvent, error) {
+	claimed := []models.WebhookDelivery{}
+	events := map[int64]models.OutboxEvent{}
+
+	err := d.store.ReadWriteTx(ctx, func(ctx context.Context, tx ports.WriteTx) error {
+		now := time.Now()
+
+		if err := tx.LockDueWebhookDeliveries(ctx, now, d.batchSize, &claimed); err != nil {
+			return err
+		}
+
+		for i := range claimed {
+			if _, ok := events[claimed[i].EventID]; !ok {
+				event := models.OutboxEvent{ID: claimed[i].EventID}
+				if err := tx.GetOutboxEvent(ctx, &event); err != nil {
+					return err
+				}
+
+				events[event.ID] = event
+			}
+
+			claimed[i].NextAttemptAt = now.Add(2 * d.timeout)
+			if err := tx.UpdateWebhookDelivery(ctx, &claimed[i]); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	return claimed, events, err
+}
+
+// attempt sends the event and records the outcome in the delivery.
+func (d *Dispatcher) attempt(ctx context.Context, delivery *models.WebhookDelivery, event models.OutboxEvent) {
+	delivery.Attempts++
+	delivery.LastStatusCode = 0
+	delivery.LastError = ""
+
+	var err error
+
+	endpoint, ok := d.endpoint(delivery.Endpoint)
+	if ok {
+		delivery.LastStatusCode, err = d.send(ctx, endpoint, event)
+	} else {
+		err = ErrUnknownEndpoint
+	}
+
+	now := time.Now()
+
+	switch {
+	case err == nil:
+		delivery.Status = models.WebhookDelivered
+		delivery.DeliveredAt = &now
+	case !ok || delivery.Attempts >= d.maxAttempts:
+		delivery.Status = models.WebhookDead
+	default:
+		delivery.NextAttemptAt = now.Add(d.delay(delivery.Attempts))
+	}
+
+	if err != nil {
+		delivery.LastError = truncate(err.Error(), maxErrorLength)
+		deliveriesTotal.Add("failed", 1)
+	}
+
+	if delivery.Status != models.WebhookPending {
+		deliveriesTotal.Add(string(delivery.Status), 1)
+	}
+}
+
+// send posts the signed event to the endpoint. Only 2xx responses are successful.
+func (d *Dispatcher) send(ctx context.Context, endpoint Endpoint, event models.OutboxEvent) (int, error) {
+	body, err := json.Marshal(Payload{ID: event.ID, Type: event.Type, CreatedAt: event.CreatedAt, Data: event.Data})
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, d.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	id := strconv.FormatInt(event.ID, 10)
+	now := time.Now()
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderID, id)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(HeaderSignature, Sign(endpoint.Secret, id, now, body))
+
+	res, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	// Drain a little of the body, so that the connection can be reused.
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 4096))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("unexpected status %s", res.Status)
+	}
+
+	return res.StatusCode, nil
+}
+
+// delay returns the backoff after the attempt, with jitter so that the
+// retries of many deliveries to a recovering endpoint are spread out.
+func (d *Dispatcher) delay(attempts int) time.Duration {
+	delay := d.backoff
+	for i := 1; i < attempts && delay < d.maxBackoff; i++ {
+		delay *= 2
+	}
+
+	delay = min(delay, d.maxBackoff)
+
+	return delay/2 + rand.N(delay/2+1)
+}
+
+func (d *Dispatcher) endpoint(id string) (Endpoint, bool) {
+	for _, e := range d.endpoints {
+		if e.ID == id {
+			return e, true
+		}
+	}
+
+	return Endpoint{}, false
+}
+
+// Deliveries returns a page of deliveries matching the filter and the After
+// of the next page, which is zero on the last page.
+func (d *Dispatcher) Deliveries(ctx context.Context, filter ports.WebhookFilter) ([]models.WebhookDelivery, int64, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultBatchSize
+	}
+
+	deliveries := []models.WebhookDelivery{}
+
+	err := d.store.ReadTx(ctx, func(ctx context.Context, tx ports.ReadTx) error {
+		return tx.ListWebhookDeliveries(ctx, filter, &deliveries)
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if len(deliveries) < filter.Limit {
+		return deliveries, 0, nil
+	}
+
+	return deliveries, deliveries[len(deliveries)-1].ID, nil
+}
+
+// Replay delivers an event again, to the endpoint or to all endpoints subscribed
+// to it if the endpoint is empty. Deliveries of the event are reset to pending,
+// missing ones are created. It returns the deliveries that were queued.
+func (d *Dispatcher) Replay(ctx context.Context, eventID int64, endpoint string) ([]models.WebhookDelivery, error) {
+	if endpoint != "" {
+		if _, ok := d.endpoint(endpoint); !ok {
+			return nil, ErrUnknownEndpoint
+		}
+	}
+
+	queued := []models.WebhookDelivery{}
+
+	err := d.store.ReadWriteTx(ctx, func(ctx context.Context, tx ports.WriteTx) error {
+		event := models.OutboxEvent{ID: eventID}
+		if err := tx.GetOutboxEvent(ctx, &event); err != nil {
+			return err
+		}
+
+		existing := []models.WebhookDelivery{}
+		if err := tx.ListWebhookDeliveries(ctx, ports.WebhookFilter{EventID: eventID, Endpoint: endpoint}, &existing); err != nil {
+			return err
+		}
+
+		now := time.Now()
+
+		for _, e := range d.endpoints {
+			if (endpoint != "" && e.ID != endpoint) || (endpoint == "" && !e.Subscribed(event.Type)) {
+				continue
+			}
+
+			delivery := newDelivery(event, e.ID, now)
+			for _, x := range existing {
+				if x.Endpoint == e.ID {
+					delivery = &x
+					requeue(delivery, now)
+				}
+			}
+
+			if err := save(ctx, tx, delivery); err != nil {
+				return err
+			}
+
+			queued = append(queued, *delivery)
+		}
+
+		return nil
+	})
+
+	return queued, err
+}
+
+// RetryDead resets the dead deliveries to the endpoint, or to all endpoints
+// if it is empty, to pending. It returns the number of deliveries queued.
+func (d *Dispatcher) RetryDead(ctx context.Context, endpoint string) (int, error) {
+	filter := ports.WebhookFilter{Endpoint: endpoint, Status: models.WebhookDead, Limit: d.batchSize}
+	total := 0
+
+	for {
+		dead := []models.WebhookDelivery{}
+
+		err := d.store.ReadWriteTx(ctx, func(ctx context.Context, tx ports.WriteTx) error {
+			if err := tx.ListWebhookDeliveries(ctx, filter, &dead); err != nil {
+				return err
+			}
+
+			now := time.Now()
+
+			for i := range dead {
+				requeue(&dead[i], now)
+				if err := tx.UpdateWebhookDelivery(ctx, &dead[i]); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+		if err != nil {
+			return total, err
+		}
+
+		total += len(dead)
+
+		if len(dead) < filter.Limit {
+			return total, nil
+		}
+
+		filter.After = dead[len(dead)-1].ID
+	}
+}
+
+func save(ctx context.Context, tx ports.WriteTx, delivery *models.WebhookDelivery) error {
+	if delivery.ID != 0 {
+		return tx.UpdateWebhookDelivery(ctx, delivery)
+	}
+
+	if err := tx.CreateWebhookDelivery(ctx, delivery); err != nil {
+		return err
+	}
+
+	// The delivery is not created if the dispatcher created it meanwhile.
+	if delivery.ID == 0 {
+		return ErrConcurrentReplay
+	}
+
+	return nil
+}
+
+func newDelivery(event models.OutboxEvent, endpoint string, now time.Time) *models.WebhookDelivery {
+	return &models.WebhookDelivery{
+		EventID:       event.ID,
+		Endpoint:      endpoint,
+		Status:        models.WebhookPending,
+		NextAttemptAt: now,
+		ExpiresAt:     event.ExpiresAt,
+	}
+}
+
+func requeue(delivery *models.WebhookDelivery, now time.Time) {
+	delivery.Status = models.WebhookPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = now
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+
+	return s[:n]
+}
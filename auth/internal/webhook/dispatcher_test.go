@@ -0,0 +1,243 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/open-cloud-initiative/glue/auth/internal/models"
+)
+
+// receiver is an endpoint that answers with the statuses in turn, and the
+// last one once they are used up.
+type receiver struct {
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	body, _ := io.ReadAll(req.Body)
+	r.requests = append(r.requests, req)
+	r.bodies = append(r.bodies, body)
+
+	status := r.statuses[min(len(r.requests), len(r.statuses))-1]
+	w.WriteHeader(status)
+}
+
+func newServer(t *testing.T, statuses ...int) (*httptest.Server, *receiver) {
+	t.Helper()
+
+	r := &receiver{statuses: statuses}
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+
+	return srv, r
+}
+
+func TestRunSignsDeliveries(t *testing.T) {
+	srv, r := newServer(t, http.StatusNoContent)
+	secret := []byte("secret")
+
+	s := &store{}
+	d := NewDispatcher(s, []Endpoint{{ID: "crm", URL: srv.URL, Secret: secret}})
+	publish(t, s, "user.created")
+
+	if n, err := d.Run(t.Context()); err != nil || n != 1 {
+		t.Fatalf("Run() = %d, %v, want 1 attempt", n, err)
+	}
+
+	if len(r.requests) != 1 {
+		t.Fatalf("endpoint received %d requests, want 1", len(r.requests))
+	}
+
+	req, body := r.requests[0], r.bodies[0]
+	id := req.Header.Get(HeaderID)
+
+	ts, err := strconv.ParseInt(req.Header.Get(HeaderTimestamp), 10, 64)
+	if err != nil {
+		t.Fatalf("%s = %q, want a unix timestamp", HeaderTimestamp, req.Header.Get(HeaderTimestamp))
+	}
+
+	if want := Sign(secret, id, time.Unix(ts, 0), body); req.Header.Get(HeaderSignature) != want {
+		t.Errorf("%s = %q, want %q", HeaderSignature, req.Header.Get(HeaderSignature), want)
+	}
+
+	if other := Sign([]byte("other"), id, time.Unix(ts, 0), body); req.Header.Get(HeaderSignature) == other {
+		t.Error("signature verifies with another secret")
+	}
+
+	var p Payload
+	if err := json.Unmarshal(body, &p); err != nil {
+		t.Fatal(err)
+	}
+
+	if strconv.FormatInt(p.ID, 10) != id || p.Type != "user.created" || p.Data.UserID != "ada" {
+		t.Errorf("payload = %+v with %s %s, want the event", p, HeaderID, id)
+	}
+
+	if got := s.deliveries[0]; got.Status != models.WebhookDelivered || got.DeliveredAt == nil || got.LastStatusCode != http.StatusNoContent {
+		t.Errorf("delivery = %+v, want delivered", got)
+	}
+}
+
+func TestRunRetries(t *testing.T) {
+	const maxAttempts = 3
+
+	tests := []struct {
+		name     string
+		statuses []int
+		status   models.WebhookStatus
+		attempts int
+		code     int
+	}{
+		{name: "delivered at once", statuses: []int{http.StatusOK}, status: models.WebhookDelivered, attempts: 1, code: http.StatusOK},
+		{name: "delivered after failures", statuses: []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusAccepted}, status: models.WebhookDelivered, attempts: 3, code: http.StatusAccepted},
+		{name: "dead after the last attempt", statuses: []int{http.StatusInternalServerError}, status: models.WebhookDead, attempts: maxAttempts, code: http.StatusInternalServerError},
+		{name: "redirects are failures", statuses: []int{http.StatusNotModified}, status: models.WebhookDead, attempts: maxAttempts, code: http.StatusNotModified},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, r := newServer(t, tt.statuses...)
+
+			s := &store{}
+			d := NewDispatcher(s, []Endpoint{{ID: "crm", URL: srv.URL}}, WithMaxAttempts(maxAttempts), WithBackoff(time.Minute, 90*time.Second))
+			publish(t, s, "user.created")
+
+			// Each run attempts the due delivery once, a pending one is due again after its backoff.
+			for attempt := 1; attempt <= maxAttempts+1; attempt++ {
+				before := time.Now()
+
+				if _, err := d.Run(t.Context()); err != nil {
+					t.Fatal(err)
+				}
+
+				got := s.deliveries[0]
+				if got.Status != models.WebhookPending {
+					break
+				}
+
+				// The backoff doubles up to the maximum, with a jitter of up to half of it.
+				backoff := min(time.Minute<<(attempt-1), 90*time.Second)
+				if wait := got.NextAttemptAt.Sub(before); wait < backoff/2 || wait > backoff+time.Second {
+					t.Errorf("attempt %d: next attempt in %v, want between %v and %v", attempt, wait, backoff/2, backoff)
+				}
+
+				if got.LastError == "" {
+					t.Errorf("attempt %d: no error recorded", attempt)
+				}
+
+				s.due()
+			}
+
+			got := s.deliveries[0]
+			if got.Status != tt.status || got.Attempts != tt.attempts || got.LastStatusCode != tt.code {
+				t.Errorf("delivery = %s after %d attempts with %d, want %s after %d with %d", got.Status, got.Attempts, got.LastStatusCode, tt.status, tt.attempts, tt.code)
+			}
+
+			if len(r.requests) != tt.attempts {
+				t.Errorf("endpoint received %d requests, want %d", len(r.requests), tt.attempts)
+			}
+
+			if tt.status == models.WebhookDead && got.LastError == "" {
+				t.Error("dead delivery has no error")
+			}
+		})
+	}
+}
+
+func TestRunSkipsUnsubscribedEndpoints(t *testing.T) {
+	srv, r := newServer(t, http.StatusOK)
+
+	s := &store{}
+	d := NewDispatcher(s, []Endpoint{
+		{ID: "crm", URL: srv.URL, Events: []string{"user.created"}},
+		{ID: "siem", URL: srv.URL, Events: []string{"session.revoked"}},
+		{ID: "archive", URL: srv.URL},
+	})
+
+	publish(t, s, "user.created")
+
+	if _, err := d.Run(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	endpoints := []string{}
+	for _, x := range s.deliveries {
+		endpoints = append(endpoints, x.Endpoint)
+	}
+
+	if len(endpoints) != 2 || endpoints[0] != "crm" || endpoints[1] != "archive" {
+		t.Errorf("deliveries to %v, want crm and archive", endpoints)
+	}
+
+	if len(r.requests) != 2 {
+		t.Errorf("endpoints received %d requests, want 2", len(r.requests))
+	}
+
+	if s.events[0].DispatchedAt == nil {
+		t.Error("event is not dispatched")
+	}
+
+	// A dispatched event is not delivered again.
+	if n, err := d.Run(t.Context()); err != nil || n != 0 || len(s.deliveries) != 2 {
+		t.Errorf("Run() = %d, %v with %d deliveries, want no further attempts", n, err, len(s.deliveries))
+	}
+}
+
+func TestRunUnknownEndpoint(t *testing.T) {
+	s := &store{}
+	d := NewDispatcher(s, []Endpoint{{ID: "crm", URL: "http://127.0.0.1:0"}})
+	publish(t, s, "user.created")
+
+	if err := d.dispatch(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The endpoint was removed from the configuration since.
+	d.endpoints = nil
+
+	if _, err := d.Run(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := s.deliveries[0]; got.Status != models.WebhookDead || got.Attempts != 1 {
+		t.Errorf("delivery = %s after %d attempts, want dead after 1", got.Status, got.Attempts)
+	}
+}
+
+func TestDelay(t *testing.T) {
+	d := NewDispatcher(&store{}, nil, WithBackoff(time.Second, 10*time.Second))
+
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{attempts: 1, want: time.Second},
+		{attempts: 2, want: 2 * time.Second},
+		{attempts: 3, want: 4 * time.Second},
+		{attempts: 4, want: 8 * time.Second},
+		{attempts: 5, want: 10 * time.Second},
+		{attempts: 50, want: 10 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.attempts), func(t *testing.T) {
+			for range 100 {
+				if got := d.delay(tt.attempts); got < tt.want/2 || got > tt.want {
+					t.Fatalf("delay(%d) = %v, want between %v and %v", tt.attempts, got, tt.want/2, tt.want)
+				}
+			}
+		})
+	}
+}
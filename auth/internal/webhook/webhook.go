@@ -0,0 +1,97 @@
+// Package webhook delivers the events of the outbox to webhook endpoints.
+//
+// Events are written to the outbox with the WriteTx of the change they
+// describe, so that an event is published if and only if its change is
+// committed. The Dispatcher creates a delivery of each event for every
+// endpoint subscribed to it, and sends them signed as Standard Webhooks
+// (https://www.standardwebhooks.com), retrying failures with backoff until a
+// delivery is dead.
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"slices"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/open-cloud-initiative/glue/auth/internal/models"
+	"github.com/open-cloud-initiative/glue/auth/internal/ports"
+)
+
+// Retention is the time events and their deliveries are kept, and so can be replayed.
+const Retention = 7 * 24 * time.Hour
+
+// Headers of a delivery.
+const (
+	HeaderID        = "webhook-id"
+	HeaderTimestamp = "webhook-timestamp"
+	HeaderSignature = "webhook-signature"
+)
+
+// ErrUnknownEndpoint is returned when an endpoint is not configured.
+var ErrUnknownEndpoint = errors.New("webhook: unknown endpoint")
+
+// Endpoint is a receiver of events.
+type Endpoint struct {
+	// ID identifies the endpoint in deliveries.
+	ID string
+	// URL is where events are posted to.
+	URL string
+	// Secret is the key of the HMAC signature of deliveries.
+	Secret []byte
+	// Events are the event types sent to the endpoint, all if empty.
+	Events []string
+}
+
+// Subscribed returns true if events of the type are sent to the endpoint.
+func (e Endpoint) Subscribed(eventType string) bool {
+	return len(e.Events) == 0 || slices.Contains(e.Events, eventType)
+}
+
+// Payload is the body of a delivery.
+type Payload struct {
+	// ID is the ID of the outbox event, which is the same for all attempts.
+	ID int64 `json:"id"`
+	// Type is the kind of the event, e.g. user.created.
+	Type string `json:"type"`
+	// CreatedAt is the time of the event.
+	CreatedAt time.Time `json:"created_at"`
+	// Data is the payload of the event.
+	Data models.OutboxData `json:"data"`
+}
+
+// subscribed are the endpoints of the Dispatcher, nil if webhooks are disabled.
+var subscribed atomic.Pointer[[]Endpoint]
+
+// Publish appends an event to the outbox. It is delivered once the transaction
+// is committed. Events no endpoint is subscribed to are not written.
+func Publish(ctx context.Context, tx ports.WriteTx, eventType string, data models.OutboxData) error {
+	endpoints := subscribed.Load()
+	if endpoints == nil || !slices.ContainsFunc(*endpoints, func(e Endpoint) bool { return e.Subscribed(eventType) }) {
+		return nil
+	}
+
+	now := time.Now()
+
+	return tx.CreateOutboxEvent(ctx, &models.OutboxEvent{
+		Type:      eventType,
+		Data:      data,
+		CreatedAt: now,
+		ExpiresAt: now.Add(Retention),
+	})
+}
+
+// Sign returns the signature of a delivery: the base64 encoded HMAC-SHA256 of
+// "<id>.<timestamp>.<body>" with the secret, prefixed with the version v1.
+func Sign(secret []byte, id string, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(id + "." + strconv.FormatInt(timestamp.Unix(), 10) + "."))
+	mac.Write(body)
+
+	return "v1," + base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
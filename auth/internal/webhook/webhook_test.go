@@ -0,0 +1,182 @@
+package webhook
+
+import (
+	"context"
+	"encoding/base64"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/open-cloud-initiative/glue/auth/internal/models"
+	"github.com/open-cloud-initiative/glue/auth/internal/ports"
+
+	"gorm.io/gorm"
+)
+
+// store keeps the outbox and the deliveries in memory.
+type store struct {
+	mu         sync.Mutex
+	events     []models.OutboxEvent
+	deliveries []models.WebhookDelivery
+}
+
+func (s *store) ReadTx(ctx context.Context, fn func(context.Context, ports.ReadTx) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return fn(ctx, &writeTx{store: s})
+}
+
+func (s *store) ReadWriteTx(ctx context.Context, fn func(context.Context, ports.WriteTx) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return fn(ctx, &writeTx{store: s})
+}
+
+func (s *store) Migrate(context.Context, ...any) error {
+	return nil
+}
+
+func (s *store) Close() error {
+	return nil
+}
+
+// due makes the pending deliveries due, as if their backoff passed.
+func (s *store) due() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.deliveries {
+		s.deliveries[i].NextAttemptAt = time.Now().Add(-time.Second)
+	}
+}
+
+type writeTx struct {
+	ports.WriteTx
+	store *store
+}
+
+func (t *writeTx) CreateOutboxEvent(_ context.Context, event *models.OutboxEvent) error {
+	event.ID = int64(len(t.store.events) + 1)
+	t.store.events = append(t.store.events, *event)
+
+	return nil
+}
+
+func (t *writeTx) GetOutboxEvent(_ context.Context, event *models.OutboxEvent) error {
+	i := slices.IndexFunc(t.store.events, func(e models.OutboxEvent) bool { return e.ID == event.ID })
+	if i < 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	*event = t.store.events[i]
+
+	return nil
+}
+
+func (t *writeTx) LockUndispatchedOutboxEvents(_ context.Context, limit int, events *[]models.OutboxEvent) error {
+	for _, e := range t.store.events {
+		if e.DispatchedAt == nil && len(*events) < limit {
+			*events = append(*events, e)
+		}
+	}
+
+	return nil
+}
+
+func (t *writeTx) UpdateOutboxEvent(_ context.Context, event *models.OutboxEvent) error {
+	t.store.events[event.ID-1] = *event
+	return nil
+}
+
+func (t *writeTx) CreateWebhookDelivery(_ context.Context, delivery *models.WebhookDelivery) error {
+	if slices.ContainsFunc(t.store.deliveries, func(d models.WebhookDelivery) bool {
+		return d.EventID == delivery.EventID && d.Endpoint == delivery.Endpoint
+	}) {
+		return nil
+	}
+
+	delivery.ID = int64(len(t.store.deliveries) + 1)
+	t.store.deliveries = append(t.store.deliveries, *delivery)
+
+	return nil
+}
+
+func (t *writeTx) LockDueWebhookDeliveries(_ context.Context, now time.Time, limit int, deliveries *[]models.WebhookDelivery) error {
+	for _, d := range t.store.deliveries {
+		if d.Status == models.WebhookPending && !d.NextAttemptAt.After(now) && len(*deliveries) < limit {
+			*deliveries = append(*deliveries, d)
+		}
+	}
+
+	return nil
+}
+
+func (t *writeTx) UpdateWebhookDelivery(_ context.Context, delivery *models.WebhookDelivery) error {
+	t.store.deliveries[delivery.ID-1] = *delivery
+	return nil
+}
+
+// publish writes an event of the type to the outbox.
+func publish(t *testing.T, s *store, eventType string) {
+	t.Helper()
+
+	err := s.ReadWriteTx(t.Context(), func(ctx context.Context, tx ports.WriteTx) error {
+		return Publish(ctx, tx, eventType, models.OutboxData{UserID: "ada", TargetType: "user", TargetID: "ada"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSign(t *testing.T) {
+	// The example of the Standard Webhooks specification.
+	secret, err := base64.StdEncoding.DecodeString("MfKQ9r8GKYqrTwjUPD8ILPZIo2LaLaSw")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := Sign(secret, "msg_p5jXN8AQM9LWM0D4loKWxJek", time.Unix(1614265330, 0), []byte(`{"test": 2432232314}`))
+	if want := "v1,g0hM9SsE+OTPJTGt/tmIKtSyZlE3uFJELVlNIOLJ1OE="; got != want {
+		t.Errorf("Sign() = %q, want %q", got, want)
+	}
+}
+
+func TestSubscribed(t *testing.T) {
+	tests := []struct {
+		name   string
+		events []string
+		event  string
+		want   bool
+	}{
+		{name: "all events", event: "user.created", want: true},
+		{name: "subscribed event", events: []string{"user.created", "user.deleted"}, event: "user.deleted", want: true},
+		{name: "other event", events: []string{"user.created"}, event: "user.deleted", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (Endpoint{Events: tt.events}).Subscribed(tt.event); got != tt.want {
+				t.Errorf("Subscribed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPublish(t *testing.T) {
+	s := &store{}
+	NewDispatcher(s, []Endpoint{{ID: "crm", Events: []string{"user.created"}}})
+
+	publish(t, s, "user.created")
+	publish(t, s, "session.revoked")
+
+	if len(s.events) != 1 || s.events[0].Type != "user.created" {
+		t.Errorf("Publish() wrote %+v, want only the subscribed event", s.events)
+	}
+
+	if s.events[0].ExpiresAt.Sub(s.events[0].CreatedAt) != Retention {
+		t.Errorf("Publish() expires at %v, want after %v", s.events[0].ExpiresAt, Retention)
+	}
+}